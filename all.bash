@@ -158,6 +158,8 @@ main() {
       standard_linters
       runcmd go mod tidy
       runcmd go test ./...
+      # The queue runs fetches and their timers on separate goroutines.
+      runcmd go test -race ./internal/queue
       # To test internal/secrets, set GO_DISCOVERY_SECRETS_BUCKET and GO_DISCOVERY_KMS_KEY_NAME
       # to appropriate test values.
       runcmd go test ./internal/secrets
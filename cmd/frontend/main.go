@@ -15,9 +15,11 @@ import (
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"cloud.google.com/go/profiler"
+	"cloud.google.com/go/storage"
 	"contrib.go.opencensus.io/integrations/ocsql"
 	"github.com/go-redis/redis/v7"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blobstore"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/dcensus"
@@ -35,6 +37,8 @@ import (
 
 var (
 	queueName      = config.GetEnv("GO_DISCOVERY_FRONTEND_TASK_QUEUE", "")
+	queueFull      = config.GetEnv("GO_DISCOVERY_FRONTEND_TASK_QUEUE_FULL_NAME", "")
+	verifyQueue    = config.GetEnv("GO_DISCOVERY_VERIFY_TASK_QUEUE", "") == "true"
 	staticPath     = flag.String("static", "content/static", "path to folder containing static files served")
 	thirdPartyPath = flag.String("third_party", "third_party", "path to folder containing third-party libraries")
 	devMode        = flag.Bool("dev", false, "enable developer mode (reload templates on each page load, serve non-minified JS/CSS, etc.)")
@@ -81,6 +85,13 @@ func main() {
 		}
 		db := postgres.New(ddb)
 		defer db.Close()
+		if cfg.SourceFilesBucket != "" {
+			client, err := storage.NewClient(ctx)
+			if err != nil {
+				log.Fatal(ctx, err)
+			}
+			db.UseBlobStore(blobstore.NewGCS(client, cfg.SourceFilesBucket))
+		}
 		ds = db
 		exp = db
 		sourceClient := source.NewClient(config.SourceTimeout)
@@ -171,7 +182,7 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 			}
 		}
 		return queue.NewInMemory(ctx, proxyClient, sourceClient, db, 10,
-			frontend.FetchAndUpdateState, experiment.NewSet(set))
+			frontend.FetchAndUpdateState, experiment.NewSet(set), nil)
 	}
 	client, err := cloudtasks.NewClient(ctx)
 	if err != nil {
@@ -180,7 +191,13 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 	if queueName == "" {
 		log.Fatalf(ctx, "queueName cannot be empty")
 	}
-	return queue.NewGCP(cfg, client, queueName)
+	q := queue.NewGCP(cfg, client, queueName, &queue.GCPOptions{QueueName: queueFull})
+	if verifyQueue {
+		if err := q.VerifyQueue(ctx); err != nil {
+			log.Fatal(ctx, err)
+		}
+	}
+	return q
 }
 
 // openDB opens a connection to a database with the given driver, using connection info from
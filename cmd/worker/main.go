@@ -19,7 +19,9 @@ import (
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"cloud.google.com/go/errorreporting"
 	"cloud.google.com/go/profiler"
+	"cloud.google.com/go/storage"
 	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/blobstore"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/dcensus"
@@ -38,10 +40,16 @@ import (
 )
 
 var (
-	timeout    = config.GetEnv("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", "10")
-	queueName  = config.GetEnv("GO_DISCOVERY_WORKER_TASK_QUEUE", "")
-	workers    = flag.Int("workers", 10, "number of concurrent requests to the fetch service, when running locally")
-	staticPath = flag.String("static", "content/static", "path to folder containing static files served")
+	timeout     = config.GetEnv("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", "10")
+	queueName   = config.GetEnv("GO_DISCOVERY_WORKER_TASK_QUEUE", "")
+	queueFull   = config.GetEnv("GO_DISCOVERY_WORKER_TASK_QUEUE_FULL_NAME", "")
+	verifyQueue = config.GetEnv("GO_DISCOVERY_VERIFY_TASK_QUEUE", "") == "true"
+	maxFetches  = config.GetEnv("GO_DISCOVERY_WORKER_MAX_FETCHES", "")
+	workers     = flag.Int("workers", 10, "number of concurrent requests to the fetch service, when running locally")
+	maxWorkers  = flag.Int("max_workers", 0, "if positive, adjust the number of concurrent requests between 1 and max_workers based on fetch errors, when running locally")
+	softTimeout = flag.Duration("soft_timeout", 0, "if positive, log fetches that run longer than this, when running locally")
+	fallback    = flag.Bool("fallback_queue", false, "when running locally, prefer the Cloud Tasks queue named by GO_DISCOVERY_WORKER_TASK_QUEUE and fetch in-process if it is unavailable")
+	staticPath  = flag.String("static", "content/static", "path to folder containing static files served")
 )
 
 func main() {
@@ -74,6 +82,13 @@ func main() {
 	}
 	db := postgres.New(ddb)
 	defer db.Close()
+	if cfg.SourceFilesBucket != "" {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		db.UseBlobStore(blobstore.NewGCS(client, cfg.SourceFilesBucket))
+	}
 
 	populateExcluded(ctx, db)
 
@@ -90,6 +105,11 @@ func main() {
 	reportingClient := reportingClient(ctx, cfg)
 	redisHAClient := getHARedis(ctx, cfg)
 	redisCacheClient := getCacheRedis(ctx, cfg)
+	handlerTimeout, err := strconv.Atoi(timeout)
+	if err != nil {
+		log.Fatalf(ctx, "strconv.Atoi(%q): %v", timeout, err)
+	}
+	fetchSemaphore := newFetchSemaphore(ctx, redisHAClient, time.Duration(handlerTimeout)*time.Minute)
 	server, err := worker.NewServer(cfg, worker.ServerConfig{
 		DB:                   db,
 		IndexClient:          indexClient,
@@ -101,6 +121,7 @@ func main() {
 		ReportingClient:      reportingClient,
 		TaskIDChangeInterval: config.TaskIDChangeIntervalWorker,
 		StaticPath:           *staticPath,
+		FetchSemaphore:       fetchSemaphore,
 	})
 	if err != nil {
 		log.Fatal(ctx, err)
@@ -122,10 +143,6 @@ func main() {
 		go http.ListenAndServe(cfg.DebugAddr("localhost:8001"), dcensusServer)
 	}
 
-	handlerTimeout, err := strconv.Atoi(timeout)
-	if err != nil {
-		log.Fatalf(ctx, "strconv.Atoi(%q): %v", timeout, err)
-	}
 	requestLogger := logger(ctx, cfg)
 
 	experimenter, err := middleware.NewExperimenter(ctx, 1*time.Minute, db, requestLogger)
@@ -156,8 +173,27 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 				set[e.Name] = true
 			}
 		}
-		return queue.NewInMemory(ctx, proxyClient, sourceClient, db, *workers,
-			worker.FetchAndUpdateState, experiment.NewSet(set))
+		opts := &queue.InMemoryOptions{SoftTimeout: *softTimeout}
+		if *maxWorkers > 0 {
+			opts.Adaptive = &queue.AdaptiveOptions{MinWorkers: 1, MaxWorkers: *maxWorkers}
+		}
+		mem := queue.NewInMemory(ctx, proxyClient, sourceClient, db, *workers,
+			worker.FetchAndUpdateState, experiment.NewSet(set), opts)
+		if !*fallback {
+			return mem
+		}
+		if queueName == "" {
+			log.Fatal(ctx, "-fallback_queue: must set GO_DISCOVERY_WORKER_TASK_QUEUE env var")
+		}
+		// Prefer the Cloud Tasks queue, but process fetches in-process if it
+		// cannot be reached.
+		client, err := cloudtasks.NewClient(ctx)
+		if err != nil {
+			log.Errorf(ctx, "cloudtasks.NewClient: %v; using the in-memory queue", err)
+			return mem
+		}
+		gcp := queue.NewGCP(cfg, client, queueName, &queue.GCPOptions{QueueName: queueFull})
+		return queue.NewFallbackQueue(gcp, mem)
 	}
 	if queueName == "" {
 		log.Fatal(ctx, "missing queue: must set GO_DISCOVERY_WORKER_TASK_QUEUE env var")
@@ -166,7 +202,13 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
-	return queue.NewGCP(cfg, client, queueName)
+	q := queue.NewGCP(cfg, client, queueName, &queue.GCPOptions{QueueName: queueFull})
+	if verifyQueue {
+		if err := q.VerifyQueue(ctx); err != nil {
+			log.Fatal(ctx, err)
+		}
+	}
+	return q
 }
 
 func getHARedis(ctx context.Context, cfg *config.Config) *redis.Client {
@@ -196,6 +238,24 @@ func getRedis(ctx context.Context, host, port string, writeTimeout, readTimeout
 	})
 }
 
+// newFetchSemaphore returns a semaphore that limits the number of fetches in
+// flight across all worker instances to maxFetches, or nil if maxFetches is
+// not set. A slot is held for at most twice the handler timeout, after which
+// it is assumed that its holder has crashed.
+func newFetchSemaphore(ctx context.Context, client *redis.Client, handlerTimeout time.Duration) *queue.RedisSemaphore {
+	if maxFetches == "" {
+		return nil
+	}
+	limit, err := strconv.Atoi(maxFetches)
+	if err != nil || limit <= 0 {
+		log.Fatalf(ctx, "invalid GO_DISCOVERY_WORKER_MAX_FETCHES %q: want a positive integer", maxFetches)
+	}
+	if client == nil {
+		log.Fatal(ctx, "GO_DISCOVERY_WORKER_MAX_FETCHES requires the HA Redis instance to be configured")
+	}
+	return queue.NewRedisSemaphore(client, "worker-fetches", limit, 2*handlerTimeout)
+}
+
 func reportingClient(ctx context.Context, cfg *config.Config) *errorreporting.Client {
 	if !cfg.OnAppEngine() {
 		return nil
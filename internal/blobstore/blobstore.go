@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package blobstore provides stores for large blobs of module version data,
+// such as file contents, that are kept outside the database.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A BlobStore stores blobs of data belonging to module versions. A blob is
+// identified by the module path and version it belongs to and by a name,
+// which is unique within the module version, such as the path of a file
+// relative to the module root.
+type BlobStore interface {
+	// Put stores data as the named blob of the module version, replacing
+	// any blob already stored under that name.
+	Put(ctx context.Context, modulePath, version, name string, data []byte) error
+	// Get returns the named blob of the module version. If there is no such
+	// blob, an error wrapping derrors.NotFound is returned.
+	Get(ctx context.Context, modulePath, version, name string) ([]byte, error)
+}
+
+// objectName returns the name under which the named blob of modulePath at
+// version is stored. Module paths cannot contain "@", so names of different
+// module versions cannot collide.
+func objectName(modulePath, version, name string) string {
+	return modulePath + "@" + version + "/" + name
+}
+
+// InMemory is a BlobStore that keeps blobs in memory. It is meant for tests
+// and for local development.
+type InMemory struct {
+	mu    sync.Mutex
+	blobs map[string][]byte // by objectName
+}
+
+// NewInMemory returns an empty InMemory BlobStore.
+func NewInMemory() *InMemory {
+	return &InMemory{blobs: map[string][]byte{}}
+}
+
+// Put stores a copy of data as the named blob of the module version.
+func (s *InMemory) Put(ctx context.Context, modulePath, version, name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[objectName(modulePath, version, name)] = append([]byte{}, data...)
+	return nil
+}
+
+// Get returns a copy of the named blob of the module version.
+func (s *InMemory) Get(ctx context.Context, modulePath, version, name string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "InMemory.Get(%q, %q, %q)", modulePath, version, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.blobs[objectName(modulePath, version, name)]
+	if !ok {
+		return nil, fmt.Errorf("blob: %w", derrors.NotFound)
+	}
+	return append([]byte{}, data...), nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestInMemory(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemory()
+
+	data := []byte("package p\n")
+	if err := s.Put(ctx, "example.com/m", "v1.0.0", "p/p.go", data); err != nil {
+		t.Fatal(err)
+	}
+	// The store keeps a copy of data.
+	data[0] = 'X'
+
+	got, err := s.Get(ctx, "example.com/m", "v1.0.0", "p/p.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package p\n"; string(got) != want {
+		t.Errorf("Get = %q, want %q", got, want)
+	}
+
+	for _, test := range []struct {
+		modulePath, version, name string
+	}{
+		{"example.com/m", "v1.1.0", "p/p.go"},
+		{"example.com/m/p", "v1.0.0", "p.go"},
+		{"example.com/m", "v1.0.0", "q.go"},
+	} {
+		if _, err := s.Get(ctx, test.modulePath, test.version, test.name); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("Get(%q, %q, %q): got error %v, want %v", test.modulePath, test.version, test.name, err, derrors.NotFound)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GCS is a BlobStore backed by a Google Cloud Storage bucket. The named blob
+// of a module version is stored in the object MODULE_PATH@VERSION/NAME.
+type GCS struct {
+	bucket *storage.BucketHandle
+	name   string
+}
+
+// NewGCS returns a GCS BlobStore that stores blobs in the bucket with the
+// given name, using client. The bucket must already exist.
+func NewGCS(client *storage.Client, bucket string) *GCS {
+	return &GCS{bucket: client.Bucket(bucket), name: bucket}
+}
+
+// Put writes data to the object for the named blob of the module version.
+func (s *GCS) Put(ctx context.Context, modulePath, version, name string, data []byte) (err error) {
+	defer derrors.Wrap(&err, "GCS.Put(%q, %q, %q)", modulePath, version, name)
+	w := s.bucket.Object(objectName(modulePath, version, name)).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	// The object is only created when the writer is closed.
+	return w.Close()
+}
+
+// Get reads the object for the named blob of the module version.
+func (s *GCS) Get(ctx context.Context, modulePath, version, name string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "GCS.Get(%q, %q, %q)", modulePath, version, name)
+	r, err := s.bucket.Object(objectName(modulePath, version, name)).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, fmt.Errorf("object in bucket %q: %w", s.name, derrors.NotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blobstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/pkgsite/internal/derrors"
+	"google.golang.org/api/option"
+)
+
+// fakeGCS serves the parts of the Cloud Storage API used by GCS: multipart
+// uploads, and object downloads.
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte // by "BUCKET/OBJECT"
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const uploadPrefix = "/upload/storage/v1/b/"
+	switch {
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, uploadPrefix):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, uploadPrefix), "/o")
+		name, data, err := readMultipartUpload(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.objects[bucket+"/"+name] = data
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"bucket": bucket, "name": name, "size": strconv.Itoa(len(data))})
+	case r.Method == "GET":
+		f.mu.Lock()
+		data, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/")]
+		f.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+// readMultipartUpload returns the object name from the metadata part of the
+// multipart upload in r, and the contents from its media part.
+func readMultipartUpload(r *http.Request) (name string, data []byte, err error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", nil, err
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		return "", nil, err
+	}
+	var meta struct{ Name string }
+	if err := json.NewDecoder(part).Decode(&meta); err != nil {
+		return "", nil, err
+	}
+	part, err = mr.NextPart()
+	if err != nil {
+		return "", nil, err
+	}
+	data, err = ioutil.ReadAll(part)
+	if err != nil {
+		return "", nil, err
+	}
+	return meta.Name, data, nil
+}
+
+func TestGCS(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeGCS{objects: map[string][]byte{}}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	client, err := storage.NewClient(ctx,
+		option.WithEndpoint(server.URL+"/storage/v1/"),
+		option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewGCS(client, "bucket")
+
+	const data = "package p\n"
+	if err := s.Put(ctx, "example.com/m", "v1.0.0", "p/p.go", []byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["bucket/example.com/m@v1.0.0/p/p.go"]; !ok {
+		t.Errorf("object not stored under its name; have %v", fake.objects)
+	}
+	got, err := s.Get(ctx, "example.com/m", "v1.0.0", "p/p.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+	if _, err := s.Get(ctx, "example.com/m", "v1.1.0", "p/p.go"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("Get of missing blob: got error %v, want %v", err, derrors.NotFound)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+// A BuildContext is a GOOS/GOARCH pair used to load a package and render its
+// documentation.
+type BuildContext struct {
+	GOOS, GOARCH string
+}
+
+// String returns the build context in the form "GOOS/GOARCH".
+func (b BuildContext) String() string {
+	return b.GOOS + "/" + b.GOARCH
+}
+
+// BuildContexts are the build contexts that documentation is generated for,
+// in the order in which they are tried when a package is fetched. A package
+// is documented under the first of them for which it contains any files.
+var BuildContexts = []BuildContext{
+	{"linux", "amd64"},
+	{"windows", "amd64"},
+	{"darwin", "amd64"},
+	{"js", "wasm"},
+	{"linux", "js"},
+}
@@ -106,6 +106,10 @@ type Config struct {
 	// UseProfiler specifies whether to enable Stackdriver Profiler.
 	UseProfiler bool
 
+	// SourceFilesBucket, if non-empty, is the Cloud Storage bucket that
+	// stores the source files of packages, instead of the database.
+	SourceFilesBucket string
+
 	Quota QuotaSettings
 }
 
@@ -306,6 +310,7 @@ func load(ctx context.Context) (_ *Config, err error) {
 		AcceptedURLs: parseCommaList(GetEnv("GO_DISCOVERY_ACCEPTED_LIST", "")),
 	}
 	cfg.UseProfiler = os.Getenv("GO_DISCOVERY_USE_PROFILER") == "TRUE"
+	cfg.SourceFilesBucket = os.Getenv("GO_DISCOVERY_SOURCE_FILES_BUCKET")
 
 	// If GO_DISCOVERY_CONFIG_OVERRIDE is set, it should point to a file
 	// in overrideBucket which provides overrides for selected configuration.
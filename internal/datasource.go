@@ -6,6 +6,8 @@ package internal
 
 import (
 	"context"
+	"html/template"
+	"time"
 
 	"golang.org/x/pkgsite/internal/licenses"
 )
@@ -15,17 +17,192 @@ type DataSource interface {
 	// See the internal/postgres package for further documentation of these
 	// methods, particularly as they pertain to the main postgres implementation.
 
+	// ClassifyPath reports whether path is the root of the module, a package,
+	// or a directory without a package in the module version specified by
+	// modulePath and version, or PathKindNotFound if it is none of those. As
+	// with GetPathInfo, modulePath may be UnknownModulePath and version may be
+	// LatestVersion.
+	ClassifyPath(ctx context.Context, path, modulePath, version string) (PathKind, error)
+	// GetAllDocs returns the documentation of every package in the module
+	// version specified by modulePath and version, keyed by package path. A
+	// package without documentation maps to nil. The whole result is held in
+	// memory, so it should not be used for large modules on a hot path.
+	GetAllDocs(ctx context.Context, modulePath, version string) (map[string]*Documentation, error)
+	// GetCommitSHA returns the full hash of the commit that the module version
+	// specified by modulePath and version was built from, as reported by the
+	// module proxy when the module was fetched. It returns an error wrapping
+	// derrors.NotFound if the hash is not known.
+	GetCommitSHA(ctx context.Context, modulePath, version string) (string, error)
+	// GetContentHash returns a hash of the data displayed for the module
+	// version specified by modulePath and version. It changes when
+	// reprocessing the module version changes that data.
+	GetContentHash(ctx context.Context, modulePath, version string) (string, error)
+	// GetDependencyDepth returns the depth of the transitive requirement
+	// graph of the module version specified by modulePath and version, as
+	// computed by DependencyDepth from the requirements known to the
+	// DataSource. It is at most MaxDependencyDepth.
+	GetDependencyDepth(ctx context.Context, modulePath, version string) (int, error)
 	// GetDirectoryNew returns information about a directory, which may also be a module and/or package.
-	// The module and version must both be known.
-	GetDirectoryNew(ctx context.Context, dirPath, modulePath, version string) (_ *VersionedDirectory, err error)
+	// The module and version must both be known. Vendored directories are
+	// not found unless opts.IncludeVendored is set. Links to standard library
+	// packages in its documentation point to the Go release chosen by
+	// opts.GoVersion.
+	GetDirectoryNew(ctx context.Context, dirPath, modulePath, version string, opts PathOptions) (_ *VersionedDirectory, err error)
+	// GetDocBuildInfo returns how the documentation of the package specified
+	// by pkgPath, modulePath and version was generated. If that was not
+	// recorded, an error wrapping derrors.NotFound is returned.
+	GetDocBuildInfo(ctx context.Context, pkgPath, modulePath, version string) (*DocBuildInfo, error)
+	// GetDocDiffAcrossPlatforms returns, for each build context for which
+	// the package specified by pkgPath, modulePath and version is documented,
+	// the exported symbols that are documented only for that build context,
+	// as computed by PlatformOnlySymbols. The keys have the form
+	// "GOOS/GOARCH".
+	GetDocDiffAcrossPlatforms(ctx context.Context, pkgPath, modulePath, version string) (map[string][]string, error)
+	// GetDocGenGoVersion returns the version of the Go toolchain, as
+	// reported by runtime.Version, that generated the documentation of the
+	// module version specified by modulePath and version. If the module
+	// version does not exist, or the version was not recorded, an error
+	// wrapping derrors.NotFound is returned.
+	GetDocGenGoVersion(ctx context.Context, modulePath, version string) (string, error)
+	// GetDocOutline returns the outline of the documentation of the package
+	// specified by pkgPath, modulePath and version, as built by BuildOutline
+	// from its exported symbols.
+	GetDocOutline(ctx context.Context, pkgPath, modulePath, version string) (*Outline, error)
+	// GetDocSection returns the documentation of the package specified by
+	// pkgPath, modulePath and version with only the named section of its
+	// HTML, as extracted by DocSection, so that large documentation can be
+	// loaded a section at a time. The Doc and Symbols fields are not set. If
+	// section is not one of DocSections, an error wrapping
+	// derrors.InvalidArgument is returned.
+	GetDocSection(ctx context.Context, pkgPath, modulePath, version, section string) (*Documentation, error)
+	// GetDocSizes returns the size in bytes of the documentation HTML of
+	// each package in the module version specified by modulePath and
+	// version, keyed by package path.
+	GetDocSizes(ctx context.Context, modulePath, version string) (map[string]int, error)
+	// GetDocumentationWithLinks returns the documentation HTML of the
+	// package specified by pkgPath, modulePath and version, with references
+	// to the exported symbols of the packages it imports turned into links,
+	// as described by LinkDocReferences.
+	GetDocumentationWithLinks(ctx context.Context, pkgPath, modulePath, version string) (template.HTML, error)
+	// GetDocText returns the plain text of the documentation of the package
+	// specified by pkgPath, modulePath and version: its package doc comment
+	// followed by the doc comments of its symbols. See DocText.
+	GetDocText(ctx context.Context, pkgPath, modulePath, version string) (string, error)
+	// GetFailedVersions returns the versions of the module with path
+	// modulePath whose most recent fetch failed, with the status and time of
+	// that fetch, highest version first. Versions marked for reprocessing
+	// after a successful fetch are not failures.
+	GetFailedVersions(ctx context.Context, modulePath string) ([]FailedVersion, error)
+	// GetFetchTime returns the time at which the module version specified by
+	// modulePath and version was last successfully fetched.
+	GetFetchTime(ctx context.Context, modulePath, version string) (time.Time, error)
+	// GetFileStats returns statistics about the files in the module version
+	// specified by modulePath and version.
+	GetFileStats(ctx context.Context, modulePath, version string) (*FileStats, error)
+	// GetGoDirective returns the version in the go directive of the go.mod
+	// file of the module version specified by modulePath and version, such
+	// as "1.14". If the module version has no go.mod file, or its go.mod file
+	// has no go directive, the empty string is returned.
+	GetGoDirective(ctx context.Context, modulePath, version string) (string, error)
+	// GetImportablePackages returns the packages in the module version
+	// specified by modulePath and version that can be imported by other
+	// modules, as reported by IsImportable. Unlike GetPackagesInModule, it
+	// omits commands and packages with an "internal" or "testdata" path
+	// element.
+	GetImportablePackages(ctx context.Context, modulePath, version string) ([]*LegacyPackage, error)
+	// GetImportPathMismatches returns the packages in the module version
+	// specified by modulePath and version whose import comment names a path
+	// other than their own, sorted by package path.
+	GetImportPathMismatches(ctx context.Context, modulePath, version string) ([]*ImportPathMismatch, error)
 	// GetImports returns a slice of import paths imported by the package
 	// specified by path and version.
 	GetImports(ctx context.Context, pkgPath, modulePath, version string) ([]string, error)
+	// GetImportsGrouped returns the imports of the package specified by path
+	// and version, split into standard library imports and all other imports.
+	GetImportsGrouped(ctx context.Context, pkgPath, modulePath, version string) (std, external []string, err error)
+	// GetIndexStats returns counts of the modules, module versions and
+	// packages in the data source. They may be slightly out of date.
+	GetIndexStats(ctx context.Context) (*IndexStats, error)
+	// GetInternalImports returns the imports of the package specified by
+	// pkgPath, modulePath and version that are within modulePath: those whose
+	// path is modulePath or begins with modulePath followed by a slash. The
+	// result is sorted, and is empty rather than nil if there are none.
+	GetInternalImports(ctx context.Context, pkgPath, modulePath, version string) ([]string, error)
+	// GetLatestVersions returns a map from each of modulePaths to its latest
+	// version, chosen as for GetModuleInfo with LatestVersion. Modules with
+	// no known versions are absent from the map.
+	GetLatestVersions(ctx context.Context, modulePaths []string) (map[string]string, error)
+	// GetLicenseBadge returns a summary of the top-level licenses of the
+	// module version specified by modulePath and version, as built by
+	// NewLicenseBadge, for embedding in a README badge.
+	GetLicenseBadge(ctx context.Context, modulePath, version string) (*Badge, error)
+	// GetLicenseFiles returns every license file in the module version
+	// specified by modulePath and version, including those in
+	// subdirectories, with their file paths and full contents.
+	GetLicenseFiles(ctx context.Context, modulePath, version string) ([]*licenses.License, error)
+	// GetModFileDiff returns the difference between the require directives
+	// of the go.mod files of versions fromVersion and toVersion of the
+	// module with path modulePath. If either version does not exist or has
+	// no go.mod file, an error wrapping derrors.NotFound is returned.
+	GetModFileDiff(ctx context.Context, modulePath, fromVersion, toVersion string) (*ModDiff, error)
+	// GetModuleByCommit returns the LegacyModuleInfo for the pseudo-version
+	// of the module with path modulePath that was made from the commit whose
+	// hash, or a prefix of it, is commitHash. If more than one pseudo-version
+	// matches, the highest is returned.
+	GetModuleByCommit(ctx context.Context, modulePath, commitHash string) (*LegacyModuleInfo, error)
+	// GetModuleDocCoverage returns how many of the packages in the module
+	// version specified by modulePath and version are documented, as
+	// described by ModuleDocCoverage.
+	GetModuleDocCoverage(ctx context.Context, modulePath, version string) (*ModuleDocCoverage, error)
 	// GetModuleInfo returns the LegacyModuleInfo corresponding to modulePath and
 	// version.
 	GetModuleInfo(ctx context.Context, modulePath, version string) (*LegacyModuleInfo, error)
+	// GetModuleKeywords returns keywords describing the module version
+	// specified by modulePath and version, derived from its packages by
+	// ModuleKeywords. A module without keywords yields an empty slice.
+	GetModuleKeywords(ctx context.Context, modulePath, version string) ([]string, error)
+	// GetModuleReadmeForPackage returns the README at the root of the module
+	// version that contains the package pkgPath, specified by modulePath and
+	// version. If modulePath is UnknownModulePath, the longest module path
+	// that contains a package at pkgPath in version is used. If the package
+	// does not exist, or the module has no README, an error wrapping
+	// derrors.NotFound is returned.
+	GetModuleReadmeForPackage(ctx context.Context, pkgPath, modulePath, version string) (*Readme, error)
+	// GetModulesByOwner returns the latest version of each module whose
+	// source repository is hosted at host and belongs to owner, the first
+	// element of the repository path, as "someorg" in
+	// github.com/someorg/repo. Host and owner are matched without regard to
+	// case. Modules are ranked by popularity, the most popular first. At most
+	// limit modules are returned, after skipping the first offset. A
+	// negative limit or offset is an InvalidArgument error.
+	GetModulesByOwner(ctx context.Context, host, owner string, limit, offset int) ([]*ModuleInfo, error)
+	// GetPackagesByPrefix returns the latest version of each package whose
+	// import path is prefix or is under prefix, across all modules, in
+	// order of package path. At most limit packages are returned, after
+	// skipping the first offset. A negative limit or offset is an
+	// InvalidArgument error.
+	GetPackagesByPrefix(ctx context.Context, prefix string, limit, offset int) ([]*SearchResult, error)
+	// GetParsedModFile returns the go.mod file of the module version
+	// specified by modulePath and version, as parsed by ParseModFile. If the
+	// module version has no go.mod file, an error wrapping derrors.NotFound
+	// is returned.
+	GetParsedModFile(ctx context.Context, modulePath, version string) (*ModFile, error)
 	// GetPathInfo returns information about a path.
 	GetPathInfo(ctx context.Context, path, inModulePath, inVersion string) (outModulePath, outVersion string, isPackage bool, err error)
+	// GetPlaygroundExamples returns the examples in the documentation of the
+	// package specified by pkgPath, modulePath and version that can be run on
+	// the Go playground, each as a complete main program that imports the
+	// package, sorted by name.
+	GetPlaygroundExamples(ctx context.Context, pkgPath, modulePath, version string) ([]*PlaygroundExample, error)
+	// GetPrimaryPackage returns the package of the module version specified
+	// by modulePath and version that a page for the module should show by
+	// default: the package whose path is modulePath if there is one, and
+	// otherwise the package with the shortest path.
+	GetPrimaryPackage(ctx context.Context, modulePath, version string) (*LegacyPackage, error)
+	// GetProcessingInfo returns when the module version specified by
+	// modulePath and version was last successfully fetched, and by which
+	// version of the fetcher.
+	GetProcessingInfo(ctx context.Context, modulePath, version string) (*ProcessingInfo, error)
 	// GetPseudoVersionsForModule returns LegacyModuleInfo for all known
 	// pseudo-versions for the module corresponding to modulePath.
 	GetPseudoVersionsForModule(ctx context.Context, modulePath string) ([]*LegacyModuleInfo, error)
@@ -33,12 +210,96 @@ type DataSource interface {
 	// pseudo-versions for any module containing a package with the given import
 	// path.
 	GetPseudoVersionsForPackageSeries(ctx context.Context, pkgPath string) ([]*LegacyModuleInfo, error)
+	// GetReadmeHash returns a hash of the contents of the README of the
+	// module version specified by modulePath and version, as computed by
+	// Readme.Hash, for comparing READMEs across versions. If the module
+	// version has no README, an error wrapping derrors.NotFound is returned.
+	GetReadmeHash(ctx context.Context, modulePath, version string) (string, error)
+	// GetRecentlyUpdatedPackages returns at most limit packages whose latest
+	// version was stored after since, most recently stored first, with ties
+	// broken by package path. A negative limit is an InvalidArgument error.
+	GetRecentlyUpdatedPackages(ctx context.Context, since time.Time, limit int) ([]*SearchResult, error)
+	// GetReplaceDirectives returns the replace directives of the go.mod file
+	// of the module version specified by modulePath and version, in file
+	// order. If the module version has no go.mod file, an error wrapping
+	// derrors.NotFound is returned.
+	GetReplaceDirectives(ctx context.Context, modulePath, version string) ([]Replace, error)
+	// GetRequirements returns the modules required by the go.mod file of the
+	// module version specified by modulePath and version.
+	GetRequirements(ctx context.Context, modulePath, version string) ([]*Requirement, error)
+	// GetSiblingPackages returns the packages of the module version specified
+	// by modulePath and version, other than pkgPath, whose immediate parent
+	// directory is the same as that of pkgPath, sorted by path.
+	GetSiblingPackages(ctx context.Context, pkgPath, modulePath, version string) ([]*LegacyPackage, error)
+	// GetSitemapEntries returns at most limit of the package and module
+	// paths that the DataSource holds, in order of path, starting at offset.
+	// Each path appears once. limit must be positive and at most
+	// MaxSitemapEntries.
+	GetSitemapEntries(ctx context.Context, limit, offset int) ([]SitemapEntry, error)
+	// GetSourceFile returns the contents of the .go file named fileName in
+	// the directory of the package specified by pkgPath, modulePath and
+	// version.
+	GetSourceFile(ctx context.Context, pkgPath, modulePath, version, fileName string) ([]byte, error)
+	// GetStabilitySignal returns a heuristic signal of how stable the
+	// exported API of the package with path pkgPath has been over its most
+	// recent tagged versions, as described by Stability.
+	GetStabilitySignal(ctx context.Context, pkgPath string) (*Stability, error)
+	// GetSymbolCounts returns the number of exported symbols of each kind in
+	// the package specified by pkgPath, modulePath and version.
+	GetSymbolCounts(ctx context.Context, pkgPath, modulePath, version string) (map[SymbolKind]int, error)
+	// GetSymbolUsages returns at most limit of the packages that refer to
+	// the exported symbol of the package with path pkgPath, with the number
+	// of references in each, most references first. The references are
+	// found syntactically in the latest version of each module, so the
+	// result is best-effort: references through dot imports, embedded
+	// fields or method values, for example, are not counted. A negative limit
+	// is an InvalidArgument error.
+	GetSymbolUsages(ctx context.Context, pkgPath, symbol string, limit int) ([]*SymbolUsage, error)
+	// GetSymbolPresence reports which of the named symbols are exported by
+	// each version of the package with path pkgPath. The result maps each
+	// version to a map from each name in symbols to whether it is present.
+	// Only the MaxSymbolPresenceVersions highest versions are included.
+	GetSymbolPresence(ctx context.Context, pkgPath string, symbols []string) (map[string]map[string]bool, error)
+	// GetSymbols returns all exported symbols in the package specified by
+	// pkgPath, modulePath and version, sorted by name.
+	GetSymbols(ctx context.Context, pkgPath, modulePath, version string) ([]*Symbol, error)
 	// GetTaggedVersionsForModule returns LegacyModuleInfo for all known tagged
 	// versions for the module corresponding to modulePath.
 	GetTaggedVersionsForModule(ctx context.Context, modulePath string) ([]*LegacyModuleInfo, error)
 	// GetTaggedVersionsForModule returns LegacyModuleInfo for all known tagged
 	// versions for any module containing a package with the given import path.
 	GetTaggedVersionsForPackageSeries(ctx context.Context, pkgPath string) ([]*LegacyModuleInfo, error)
+	// GetUndetectedLicenseFiles returns the license files, such as LICENSE
+	// or COPYING, in the module version specified by modulePath and version
+	// whose contents could not be classified as any license type, in order
+	// of file path. It returns an empty slice if every license file was
+	// classified.
+	GetUndetectedLicenseFiles(ctx context.Context, modulePath, version string) ([]LicenseFile, error)
+	// GetVersionsMatching returns LegacyModuleInfo for the known versions of
+	// the module with path modulePath that satisfy constraint, such as
+	// ">=v1.2.0 <v2.0.0", sorted in descending semver order. See
+	// version.ParseConstraint for the syntax of constraint. The error wraps
+	// derrors.InvalidArgument if constraint is invalid.
+	GetVersionsMatching(ctx context.Context, modulePath, constraint string) ([]*LegacyModuleInfo, error)
+	// RecentlyFetched returns the outcomes of the at most limit most recent
+	// fetches, most recent first, including unsuccessful ones. Only the most
+	// recent fetch of each module version is reported. A negative limit is an
+	// InvalidArgument error.
+	RecentlyFetched(ctx context.Context, limit int) ([]*FetchEvent, error)
+	// ResolveBranch returns the version that the branch named branch of the
+	// module with path modulePath resolved to when it was last fetched, as in
+	// "go get example.com/foo@master". It is usually a pseudo-version. If the
+	// branch has not been fetched, or its version is not known, an error
+	// wrapping derrors.NotFound is returned; if branch cannot name a branch, as
+	// reported by version.IsBranch, the error wraps derrors.InvalidArgument.
+	ResolveBranch(ctx context.Context, modulePath, branch string) (string, error)
+	// SuggestSimilarPaths returns at most limit known paths that are similar
+	// to path, closest first, for suggesting alternatives when path is not
+	// found. See SimilarPaths for what counts as similar.
+	SuggestSimilarPaths(ctx context.Context, path string, limit int) ([]string, error)
+	// SupportedBuildContexts returns the build contexts that documentation is
+	// generated for, in the order in which they are tried.
+	SupportedBuildContexts(ctx context.Context) ([]BuildContext, error)
 
 	// TODO(b/155474770): Deprecate these methods.
 	//
@@ -58,6 +319,7 @@ type DataSource interface {
 	// module version specified by modulePath and version.
 	GetPackageLicenses(ctx context.Context, pkgPath, modulePath, version string) ([]*licenses.License, error)
 	// GetPackagesInModule returns LegacyPackages contained in the module version
-	// specified by modulePath and version.
-	GetPackagesInModule(ctx context.Context, modulePath, version string) ([]*LegacyPackage, error)
+	// specified by modulePath and version. Vendored packages are omitted
+	// unless opts.IncludeVendored is set.
+	GetPackagesInModule(ctx context.Context, modulePath, version string, opts PathOptions) ([]*LegacyPackage, error)
 }
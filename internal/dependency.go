@@ -0,0 +1,52 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "context"
+
+// MaxDependencyDepth is the largest depth reported by DependencyDepth. A walk
+// of the requirement graph stops after this many levels, so that a single
+// call makes a bounded number of queries however deep the graph is.
+const MaxDependencyDepth = 25
+
+// DependencyDepth returns the depth of the requirement graph of the module
+// version specified by modulePath and version, whose go.mod file requires
+// reqs: the largest number of requirement edges on the shortest path from the
+// module version to any module version it transitively requires. A module
+// version without requirements has depth 0; one whose requirements have no
+// requirements of their own has depth 1. The result is at most
+// MaxDependencyDepth.
+//
+// The graph is walked breadth-first, one level per call to requirementsOf,
+// which must return the requirements of all the given module versions.
+// Module versions about which requirementsOf knows nothing have no
+// requirements. Each module version is visited at most once, so cycles are
+// harmless.
+func DependencyDepth(ctx context.Context, modulePath, version string, reqs []*Requirement,
+	requirementsOf func(context.Context, []*Requirement) ([]*Requirement, error)) (int, error) {
+	type key struct{ path, version string }
+	visited := map[key]bool{{modulePath, version}: true}
+	unvisited := func(reqs []*Requirement) []*Requirement {
+		var level []*Requirement
+		for _, r := range reqs {
+			k := key{r.ModulePath, r.Version}
+			if !visited[k] {
+				visited[k] = true
+				level = append(level, r)
+			}
+		}
+		return level
+	}
+
+	depth := 0
+	for level := unvisited(reqs); len(level) > 0 && depth < MaxDependencyDepth; depth++ {
+		next, err := requirementsOf(ctx, level)
+		if err != nil {
+			return 0, err
+		}
+		level = unvisited(next)
+	}
+	return depth, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestDependencyDepth(t *testing.T) {
+	ctx := context.Background()
+	req := func(path string) *Requirement { return &Requirement{ModulePath: path, Version: "v1.0.0"} }
+	for _, test := range []struct {
+		name  string
+		graph map[string][]string // module path to required module paths, all at v1.0.0
+		want  int
+	}{
+		{"no requirements", map[string][]string{}, 0},
+		{"unknown requirements", map[string][]string{"root": {"a", "b"}}, 1},
+		{"chain", map[string][]string{"root": {"a"}, "a": {"b"}, "b": {"c"}}, 3},
+		{"shortest path counts", map[string][]string{"root": {"a", "c"}, "a": {"b"}, "b": {"c"}}, 2},
+		{"cycle", map[string][]string{"root": {"a"}, "a": {"b"}, "b": {"root", "a"}}, 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var reqs []*Requirement
+			for _, p := range test.graph["root"] {
+				reqs = append(reqs, req(p))
+			}
+			requirementsOf := func(_ context.Context, mvs []*Requirement) ([]*Requirement, error) {
+				var next []*Requirement
+				for _, mv := range mvs {
+					for _, p := range test.graph[mv.ModulePath] {
+						next = append(next, req(p))
+					}
+				}
+				return next, nil
+			}
+			got, err := DependencyDepth(ctx, "root", "v1.0.0", reqs, requirementsOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+
+	t.Run("capped", func(t *testing.T) {
+		// Each module requires a new one, without end.
+		calls := 0
+		requirementsOf := func(_ context.Context, mvs []*Requirement) ([]*Requirement, error) {
+			calls++
+			return []*Requirement{req(fmt.Sprintf("m%d", calls))}, nil
+		}
+		got, err := DependencyDepth(ctx, "root", "v1.0.0", []*Requirement{req("m0")}, requirementsOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != MaxDependencyDepth || calls != MaxDependencyDepth {
+			t.Errorf("got depth %d after %d calls, want %d for both", got, calls, MaxDependencyDepth)
+		}
+	})
+}
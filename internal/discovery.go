@@ -5,7 +5,10 @@
 package internal
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"path"
+	"strings"
 	"time"
 
 	"golang.org/x/mod/module"
@@ -46,6 +49,10 @@ type LegacyModuleInfo struct {
 	ModuleInfo
 	LegacyReadmeFilePath string
 	LegacyReadmeContents string
+	// IsPrerelease reports whether Version has a semver pre-release suffix.
+	// Pseudo-versions are pre-releases. It is only set by the methods that
+	// list versions.
+	IsPrerelease bool
 }
 
 // VersionMap holds metadata associated with module queries for a version.
@@ -58,6 +65,29 @@ type VersionMap struct {
 	Error            string
 }
 
+// IndexStats holds counts over everything a DataSource holds.
+type IndexStats struct {
+	// TotalModules is the number of distinct module paths.
+	TotalModules int
+	// TotalModuleVersions is the number of module versions.
+	TotalModuleVersions int
+	// TotalPackages is the number of distinct package paths, over all
+	// versions.
+	TotalPackages int
+}
+
+// MaxSitemapEntries is the largest number of entries that
+// DataSource.GetSitemapEntries returns in one call. It is the most that a
+// single sitemap file may list.
+const MaxSitemapEntries = 50000
+
+// A SitemapEntry is a package or module path to list in a sitemap.
+type SitemapEntry struct {
+	Path string
+	// LastModified is when the highest version of Path was fetched.
+	LastModified time.Time
+}
+
 // SeriesPath returns the series path for the module.
 //
 // A series is a group of modules that share the same base path and are assumed
@@ -99,10 +129,68 @@ type Module struct {
 	// that may be contained in nested subdirectories.
 	Licenses    []*licenses.License
 	Directories []*DirectoryNew
+	// Requirements holds the modules required by the go.mod file of this
+	// module version.
+	Requirements []*Requirement
+	// GoMod holds the contents of the go.mod file of this module version. It
+	// is nil if the module zip has no go.mod file.
+	GoMod []byte
+	// ContentHash is a hash of the data displayed for this module version,
+	// including its rendered documentation. It changes when reprocessing the
+	// module changes that data, so it can be used to validate caches.
+	ContentHash string
+	// FileStats holds statistics about the files in the module zip. It is
+	// nil if they were not computed.
+	FileStats *FileStats
 
 	LegacyPackages []*LegacyPackage
 }
 
+// FileStats holds statistics about the files in a module version.
+type FileStats struct {
+	// NumFiles is the number of files in the module zip.
+	NumFiles int
+	// NumNonGoFiles is the number of those files whose names do not end in
+	// ".go".
+	NumNonGoFiles int
+	// GoLines is the number of lines in .go files other than tests.
+	GoLines int
+	// TestLines is the number of lines in _test.go files.
+	TestLines int
+}
+
+// ProcessingInfo describes when and by what version of the fetcher a module
+// version was processed.
+type ProcessingInfo struct {
+	// FetchedAt is when the module version was last successfully fetched
+	// and stored.
+	FetchedAt time.Time
+	// FetcherVersion is the app version of the worker that last processed
+	// the module version (see ModuleVersionState.AppVersion). It is empty
+	// if unknown.
+	FetcherVersion string
+}
+
+// ModuleDocCoverage describes how many of the packages of a module version are
+// documented. A package is documented if it has a package doc comment, and
+// so a non-empty synopsis.
+type ModuleDocCoverage struct {
+	// Documented is the number of packages with a package doc comment.
+	Documented int
+	// Undocumented is the number of packages without one.
+	Undocumented int
+}
+
+// Requirement is a module requirement declared by a require directive in a
+// go.mod file.
+type Requirement struct {
+	ModulePath string
+	Version    string
+	// Indirect reports whether the requirement is marked with an
+	// "// indirect" comment.
+	Indirect bool
+}
+
 // VersionedDirectory is a DirectoryNew along with its corresponding module
 // information.
 type VersionedDirectory struct {
@@ -120,8 +208,37 @@ type DirectoryNew struct {
 	Licenses          []*licenses.Metadata // metadata of applicable licenses
 	Readme            *Readme
 	Package           *PackageNew
+
+	// Subdirectories lists the directories below this one in the same
+	// module version, sorted by path. It is populated only by
+	// GetDirectoryNew, and only when PathOptions.IncludeSubdirectories is set.
+	Subdirectories []*Subdirectory
+}
+
+// A Subdirectory is an entry in a listing of the directories below another.
+type Subdirectory struct {
+	Path      string
+	HasReadme bool
 }
 
+// PathKind is what a path is in a module version, as reported by
+// DataSource.ClassifyPath.
+type PathKind string
+
+const (
+	// PathKindModuleRoot is the path of the module itself, whether or not
+	// it contains a package.
+	PathKindModuleRoot PathKind = "ModuleRoot"
+	// PathKindPackage is a path below the module root that contains a
+	// package.
+	PathKindPackage PathKind = "Package"
+	// PathKindDirectory is a path below the module root that contains no
+	// package, only other directories.
+	PathKindDirectory PathKind = "Directory"
+	// PathKindNotFound is a path that is not in the module version.
+	PathKindNotFound PathKind = "NotFound"
+)
+
 // PackageNew is a group of one or more Go source files with the same package
 // header. A PackageNew is part of a directory.
 // It will replace LegacyPackage once everything has been migrated.
@@ -130,6 +247,8 @@ type PackageNew struct {
 	Path          string
 	Documentation *Documentation
 	Imports       []string
+	SourceFiles   map[string][]byte // see LegacyPackage.SourceFiles
+	ImportComment string            // see LegacyPackage.ImportComment
 }
 
 // Documentation is the rendered documentation for a given package
@@ -141,6 +260,13 @@ type Documentation struct {
 	GOARCH   string
 	Synopsis string
 	HTML     string
+	// Doc is the text of the package doc comment.
+	Doc string
+	// Symbols are the exported identifiers in the documentation.
+	Symbols []*Symbol
+	// PlaygroundExamples are the examples in the documentation that can be
+	// run as programs.
+	PlaygroundExamples []*PlaygroundExample
 }
 
 // Readme is a README at a given directory.
@@ -149,6 +275,12 @@ type Readme struct {
 	Contents string
 }
 
+// Hash returns a hex-encoded SHA-256 hash of the contents of r. READMEs with
+// the same contents have the same hash, even if their file paths differ.
+func (r *Readme) Hash() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(r.Contents)))
+}
+
 // IndexVersion holds the version information returned by the module index.
 type IndexVersion struct {
 	Path      string
@@ -156,6 +288,31 @@ type IndexVersion struct {
 	Timestamp time.Time
 }
 
+// A FetchEvent is the outcome of the most recent fetch of a module version.
+type FetchEvent struct {
+	ModulePath string
+	Version    string
+	// Status is the HTTP status code of the fetch, as in
+	// ModuleVersionState.Status.
+	Status int
+	// Error is the error message of an unsuccessful fetch.
+	Error string
+	// FetchedAt is when the fetch completed.
+	FetchedAt time.Time
+}
+
+// A FailedVersion is a version of a module whose most recent fetch failed.
+type FailedVersion struct {
+	Version string
+	// Status is the HTTP status code of the fetch, as in
+	// ModuleVersionState.Status.
+	Status int
+	// Error is the error message of the fetch.
+	Error string
+	// FetchedAt is when the fetch completed.
+	FetchedAt time.Time
+}
+
 // ModuleVersionState holds a worker module version state.
 type ModuleVersionState struct {
 	ModulePath string
@@ -210,6 +367,20 @@ type PackageVersionState struct {
 	Error       string
 }
 
+// PackageMeta holds summary information about the latest version of a
+// package, for use in lists of packages.
+type PackageMeta struct {
+	Path       string
+	ModulePath string
+	Version    string
+	Name       string
+	Synopsis   string
+	Licenses   []string // license types
+
+	// NumImportedBy is the number of packages that import Path.
+	NumImportedBy uint64
+}
+
 // SearchResult represents a single search result from SearchDocuments.
 type SearchResult struct {
 	Name        string
@@ -293,6 +464,135 @@ type LegacyPackage struct {
 	// V1Path is the package path of a package with major version 1 in a given
 	// series.
 	V1Path string
+
+	// Doc is the text of the package doc comment.
+	Doc string
+
+	// Symbols are the exported identifiers in the package documentation.
+	Symbols []*Symbol
+
+	// SourceFiles maps the name of each .go file in the package directory,
+	// including test files and files excluded by build constraints, to its
+	// contents.
+	SourceFiles map[string][]byte
+
+	// ImportComment is the path in the import comment of the package
+	// clause, as in
+	//   package foo // import "example.com/foo"
+	// or the empty string if there is none.
+	ImportComment string
+
+	// DocBuildInfo describes how DocumentationHTML was generated. It is nil
+	// if that is unknown.
+	DocBuildInfo *DocBuildInfo
+
+	// SymbolReferences are the references in the package to the exported
+	// symbols of the packages it imports, sorted by import path and symbol.
+	// They are found without type checking, so they are best-effort.
+	SymbolReferences []*SymbolReference
+
+	// PlaygroundExamples are the examples in the package documentation that
+	// can be run as programs, sorted by name.
+	PlaygroundExamples []*PlaygroundExample
+}
+
+// A PlaygroundExample is a documentation example as a complete program that
+// can be run on the Go playground.
+type PlaygroundExample struct {
+	// Name is the name of the example function without its "Example"
+	// prefix: the exemplified identifier, such as "Foo" or "Foo_Bar" for a
+	// method, followed by an underscore and the example's suffix if it has
+	// one. It is empty or just the suffix for a package example.
+	Name string
+	// Code is the source of a main package that runs the example, importing
+	// the documented package and whatever else the example uses.
+	Code string
+}
+
+// A SymbolReference counts the references in a package to an exported symbol
+// of a package it imports.
+type SymbolReference struct {
+	ImportPath string
+	Symbol     string
+	Count      int
+}
+
+// A SymbolUsage is a package that references an exported symbol of another
+// package, as reported by GetSymbolUsages.
+type SymbolUsage struct {
+	PackagePath string
+	ModulePath  string
+	// Count is the number of references to the symbol in the package.
+	Count int
+}
+
+// DocBuildInfo describes how the documentation of a package was generated.
+type DocBuildInfo struct {
+	// GoVersion is the version of the Go toolchain that the fetcher was
+	// built with, as reported by runtime.Version, such as "go1.14.4".
+	GoVersion string
+	// RendererVersion is the dochtml.RendererVersion of the fetcher.
+	RendererVersion string
+}
+
+// An ImportPathMismatch is a package whose import comment names a path other
+// than the one it is served from.
+type ImportPathMismatch struct {
+	// PackagePath is the path of the package in its module.
+	PackagePath string
+	// ImportComment is the path named by its import comment.
+	ImportComment string
+}
+
+// IsImportable reports whether a package with the given import path and name
+// can be imported by packages in other modules. It cannot if it is a command
+// (its name is "main"), or if any element of its path is "internal" or
+// "testdata". Packages under an internal directory can only be imported from
+// within the tree rooted at its parent, and the go command ignores testdata
+// directories.
+func IsImportable(pkgPath, name string) bool {
+	if name == "main" {
+		return false
+	}
+	for _, elem := range strings.Split(pkgPath, "/") {
+		if elem == "internal" || elem == "testdata" {
+			return false
+		}
+	}
+	return true
+}
+
+// IsVendored reports whether path is inside a vendor directory: whether it
+// has a "vendor" element followed by at least one other element.
+//
+// The logic for what is considered a vendor directory is documented at
+// https://golang.org/cmd/go/#hdr-Vendor_Directories.
+func IsVendored(path string) bool {
+	return strings.HasPrefix(path, "vendor/") ||
+		strings.Contains(path, "/vendor/")
+}
+
+// PathOptions control which paths are returned by the DataSource methods
+// that accept them.
+type PathOptions struct {
+	// IncludeVendored includes paths for which IsVendored is true. They are
+	// omitted by default, because vendored copies of other modules' packages
+	// clutter listings of a module's own packages.
+	IncludeVendored bool
+
+	// IncludeSubdirectories makes GetDirectoryNew list the directories below
+	// the one it returns, along with whether each has a README, so that a
+	// listing of them doesn't need a query per directory. Vendored
+	// subdirectories are listed only if IncludeVendored is also set.
+	IncludeSubdirectories bool
+
+	// GoVersion is the Go version, such as "1.13", whose standard library
+	// the links to standard library packages in the returned documentation
+	// point to. It is resolved by stdlib.LinkTagForGoVersion against the
+	// versions of the standard library that are known, so a version newer
+	// than all of them points to the latest. If empty, the links point to
+	// the latest Go release.
+	GoVersion string
 }
 
 // LegacyVersionedPackage is a LegacyPackage along with its corresponding module
@@ -37,6 +37,26 @@ func TestSeriesPathForModule(t *testing.T) {
 	}
 }
 
+func TestIsImportable(t *testing.T) {
+	for _, test := range []struct {
+		path, name string
+		want       bool
+	}{
+		{"example.com/m", "m", true},
+		{"example.com/m/internalize", "internalize", true},
+		{"example.com/m/cmd/tool", "main", false},
+		{"example.com/m/internal", "internal", false},
+		{"example.com/m/internal/x", "x", false},
+		{"example.com/internal/m", "m", false},
+		{"example.com/m/testdata/x", "x", false},
+		{"internal/bytealg", "bytealg", false},
+	} {
+		if got := IsImportable(test.path, test.name); got != test.want {
+			t.Errorf("IsImportable(%q, %q) = %t, want %t", test.path, test.name, got, test.want)
+		}
+	}
+}
+
 func TestV1Path(t *testing.T) {
 	for _, test := range []struct {
 		modulePath, suffix string
@@ -53,3 +73,20 @@ func TestV1Path(t *testing.T) {
 		}
 	}
 }
+
+func TestIsVendored(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"example.com/m", false},
+		{"example.com/m/vendor", false},
+		{"example.com/m/vendors/p", false},
+		{"example.com/m/vendor/example.com/dep", true},
+		{"vendor/golang.org/x/net/http2", true},
+	} {
+		if got := IsVendored(test.path); got != test.want {
+			t.Errorf("IsVendored(%q) = %t, want %t", test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+// A LinkTarget is a package that documentation can refer to, as
+// name.Symbol, in text that LinkDocReferences turns into links.
+type LinkTarget struct {
+	// Path is the import path of the package.
+	Path string
+	// Name is the package name, by which documentation refers to it.
+	Name string
+	// Symbols is the set of exported symbols of the package, named as in
+	// Symbol.Name.
+	Symbols map[string]bool
+}
+
+var (
+	// htmlTagRegexp matches an HTML start or end tag.
+	htmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+	// docReferenceRegexp matches a reference to an exported symbol of another
+	// package, such as io.Reader or bytes.Buffer.Len.
+	docReferenceRegexp = regexp.MustCompile(`\b([a-z][a-z0-9_]*)\.([A-Z][A-Za-z0-9_]*)(\.[A-Z][A-Za-z0-9_]*)?\b`)
+	// pkgLinkRegexp matches the href attribute of a link to a package, with
+	// groups for the package path, any version, and any fragment.
+	pkgLinkRegexp = regexp.MustCompile(`href="/pkg/([^"#@]+)(@[^"#]*)?(#[^"]*)?"`)
+)
+
+// LinkDocReferences returns docHTML, the documentation HTML of a package,
+// with the references to symbols of targets in its text replaced by links of
+// the form
+//   <a href="/pkg/[path]#[symbol]">[name].[symbol]</a>
+// which is the form used by the dochtml package for the links it generates.
+//
+// A reference has the form name.Symbol or name.Type.Method, where name is
+// the Name of one of targets. If only name.Type exists, the link covers
+// name.Type and the method is left as text. References to symbols that do
+// not exist, and references by a name that more than one target has, are
+// left as text, as is everything inside HTML tags and existing links.
+func LinkDocReferences(docHTML string, targets []*LinkTarget) template.HTML {
+	byName := map[string]*LinkTarget{}
+	conflicts := map[string]bool{}
+	for _, t := range targets {
+		if _, ok := byName[t.Name]; ok {
+			conflicts[t.Name] = true
+		}
+		byName[t.Name] = t
+	}
+	for name := range conflicts {
+		delete(byName, name)
+	}
+
+	link := func(ref string) string {
+		m := docReferenceRegexp.FindStringSubmatch(ref)
+		t := byName[m[1]]
+		if t == nil {
+			return ref
+		}
+		sym, rest := m[2], m[3]
+		if rest != "" && t.Symbols[sym+rest] {
+			sym, rest = sym+rest, ""
+		}
+		if !t.Symbols[sym] {
+			return ref
+		}
+		return fmt.Sprintf(`<a href="/pkg/%s#%s">%s.%s</a>%s`, template.HTMLEscapeString(t.Path), sym, t.Name, sym, rest)
+	}
+
+	var b strings.Builder
+	inLink := false
+	text := func(s string) {
+		if inLink {
+			b.WriteString(s)
+			return
+		}
+		b.WriteString(docReferenceRegexp.ReplaceAllStringFunc(s, link))
+	}
+	last := 0
+	for _, loc := range htmlTagRegexp.FindAllStringIndex(docHTML, -1) {
+		text(docHTML[last:loc[0]])
+		tag := docHTML[loc[0]:loc[1]]
+		switch {
+		case strings.HasPrefix(tag, "<a ") || tag == "<a>":
+			inLink = true
+		case tag == "</a>":
+			inLink = false
+		}
+		b.WriteString(tag)
+		last = loc[1]
+	}
+	text(docHTML[last:])
+	return template.HTML(b.String())
+}
+
+// LinkStdlibAtTag returns docHTML, the documentation HTML of a package, with
+// its links to standard library packages changed to point to the Go release
+// with tag, such as "go1.13", replacing any version they had. If tag is
+// empty, the links are left unversioned, so that they point to the latest Go
+// release.
+func LinkStdlibAtTag(docHTML, tag string) string {
+	return pkgLinkRegexp.ReplaceAllStringFunc(docHTML, func(href string) string {
+		m := pkgLinkRegexp.FindStringSubmatch(href)
+		path, fragment := m[1], m[3]
+		if !stdlib.Contains(path) {
+			return href
+		}
+		if tag != "" {
+			path += "@" + tag
+		}
+		return fmt.Sprintf(`href="/pkg/%s%s"`, path, fragment)
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestLinkDocReferences(t *testing.T) {
+	targets := []*LinkTarget{
+		{Path: "io", Name: "io", Symbols: map[string]bool{"Reader": true, "Reader.Read": true}},
+		{Path: "bytes", Name: "bytes", Symbols: map[string]bool{"Buffer": true}},
+		{Path: "a.com/dup", Name: "dup", Symbols: map[string]bool{"X": true}},
+		{Path: "b.com/dup", Name: "dup", Symbols: map[string]bool{"X": true}},
+	}
+	for _, test := range []struct {
+		name, in string
+		want     template.HTML
+	}{
+		{
+			"known symbol",
+			`<p>Wraps an io.Reader.</p>`,
+			`<p>Wraps an <a href="/pkg/io#Reader">io.Reader</a>.</p>`,
+		},
+		{
+			"method",
+			`<p>See io.Reader.Read.</p>`,
+			`<p>See <a href="/pkg/io#Reader.Read">io.Reader.Read</a>.</p>`,
+		},
+		{
+			"unknown method",
+			`<p>See bytes.Buffer.Len.</p>`,
+			`<p>See <a href="/pkg/bytes#Buffer">bytes.Buffer</a>.Len.</p>`,
+		},
+		{
+			"unknown symbol",
+			`<p>Not io.Writer or fmt.Println.</p>`,
+			`<p>Not io.Writer or fmt.Println.</p>`,
+		},
+		{
+			"ambiguous package name",
+			`<p>dup.X</p>`,
+			`<p>dup.X</p>`,
+		},
+		{
+			"existing link and tag",
+			`<a href="/pkg/io#Reader">io.Reader</a> <span title="io.Reader">x</span>`,
+			`<a href="/pkg/io#Reader">io.Reader</a> <span title="io.Reader">x</span>`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := LinkDocReferences(test.in, targets); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLinkStdlibAtTag(t *testing.T) {
+	const docHTML = `<a href="/pkg/io#Reader">io.Reader</a> <a href="/pkg/net/http@go1.12">http</a> ` +
+		`<a href="/pkg/example.com/p#T">p.T</a> <a href="#F">F</a>`
+	for _, test := range []struct {
+		tag, want string
+	}{
+		{
+			"go1.13",
+			`<a href="/pkg/io@go1.13#Reader">io.Reader</a> <a href="/pkg/net/http@go1.13">http</a> ` +
+				`<a href="/pkg/example.com/p#T">p.T</a> <a href="#F">F</a>`,
+		},
+		{
+			"",
+			`<a href="/pkg/io#Reader">io.Reader</a> <a href="/pkg/net/http">http</a> ` +
+				`<a href="/pkg/example.com/p#T">p.T</a> <a href="#F">F</a>`,
+		},
+	} {
+		if got := LinkStdlibAtTag(docHTML, test.tag); got != test.want {
+			t.Errorf("LinkStdlibAtTag(%q):\ngot  %s\nwant %s", test.tag, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "strings"
+
+// DocSections are the names of the sections of package documentation HTML
+// that can be loaded separately with DataSource.GetDocSection, in the order
+// they appear.
+var DocSections = []string{"overview", "constants", "variables", "functions", "types"}
+
+// DocSectionEnd is the closing tag of every section of documentation HTML.
+// Sections are not nested.
+const DocSectionEnd = "</section>"
+
+// DocSectionStart returns the opening tag of the named section in package
+// documentation HTML rendered by the dochtml package. It reports false if
+// section is not one of DocSections.
+func DocSectionStart(section string) (string, bool) {
+	for _, s := range DocSections {
+		if s == section {
+			return `<section class="Documentation-` + section + `">`, true
+		}
+	}
+	return "", false
+}
+
+// DocSection returns the named section of documentation HTML, from its
+// opening tag to its closing one, or the empty string if the documentation
+// has no such section, as when a package declares no constants. It reports
+// false if section is not one of DocSections.
+func DocSection(html, section string) (string, bool) {
+	start, ok := DocSectionStart(section)
+	if !ok {
+		return "", false
+	}
+	i := strings.Index(html, start)
+	if i < 0 {
+		return "", true
+	}
+	html = html[i:]
+	j := strings.Index(html, DocSectionEnd)
+	if j < 0 {
+		return html, true
+	}
+	return html[:j+len(DocSectionEnd)], true
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "testing"
+
+func TestDocSection(t *testing.T) {
+	const html = `<div>` +
+		`<section class="Documentation-overview"><p>Package p.</p></section>` +
+		`<section class="Documentation-index"><ul></ul></section>` +
+		`<section class="Documentation-functions"><h3 id="F">func F</h3></section>` +
+		`</div>`
+	for _, test := range []struct {
+		section string
+		want    string
+		wantOK  bool
+	}{
+		{"overview", `<section class="Documentation-overview"><p>Package p.</p></section>`, true},
+		{"functions", `<section class="Documentation-functions"><h3 id="F">func F</h3></section>`, true},
+		{"constants", "", true},
+		{"index", "", false},
+	} {
+		got, ok := DocSection(html, test.section)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("DocSection(%q) = %q, %t; want %q, %t", test.section, got, ok, test.want, test.wantOK)
+		}
+	}
+}
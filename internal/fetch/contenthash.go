@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+)
+
+// contentHash returns a hash of the data derived from a module version that
+// is used to display it, including its rendered documentation. It changes
+// whenever reprocessing the module changes what pkgsite would display for it.
+func contentHash(m *internal.Module) string {
+	h := sha256.New()
+	write := func(s string) {
+		// Prefix each value with its length, so that the boundaries between
+		// values are unambiguous.
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	write(m.ModulePath)
+	write(m.Version)
+	write(m.CommitTime.UTC().Format(time.RFC3339Nano))
+	write(fmt.Sprint(m.IsRedistributable, m.HasGoMod))
+	write(m.LegacyReadmeFilePath)
+	write(m.LegacyReadmeContents)
+
+	lics := append(m.Licenses[:0:0], m.Licenses...)
+	sort.Slice(lics, func(i, j int) bool { return lics[i].FilePath < lics[j].FilePath })
+	for _, l := range lics {
+		write(l.FilePath)
+		write(strings.Join(l.Types, ","))
+		write(string(l.Contents))
+	}
+
+	pkgs := append(m.LegacyPackages[:0:0], m.LegacyPackages...)
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path })
+	for _, p := range pkgs {
+		write(p.Path)
+		write(p.Name)
+		write(p.Synopsis)
+		write(p.GOOS + "/" + p.GOARCH)
+		write(fmt.Sprint(p.IsRedistributable))
+		write(p.DocumentationHTML)
+		write(strings.Join(p.Imports, ","))
+		for _, l := range p.Licenses {
+			write(l.FilePath)
+		}
+	}
+
+	dirs := append(m.Directories[:0:0], m.Directories...)
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path < dirs[j].Path })
+	for _, d := range dirs {
+		if d.Readme != nil {
+			write(d.Path)
+			write(d.Readme.Filepath)
+			write(d.Readme.Contents)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
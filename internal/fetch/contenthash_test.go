@@ -0,0 +1,51 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+)
+
+func TestContentHash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	fetchHash := func(version, src string) string {
+		t.Helper()
+		proxyClient, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+			ModulePath: "example.com/hash",
+			Version:    version,
+			Files: map[string]string{
+				"go.mod":  "module example.com/hash",
+				"hash.go": src,
+			},
+		}})
+		defer teardownProxy()
+		res := FetchModule(ctx, "example.com/hash", version, proxyClient, source.NewClient(sourceTimeout))
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if res.Module.ContentHash == "" {
+			t.Fatal("empty content hash")
+		}
+		return res.Module.ContentHash
+	}
+
+	const src = "// Package hash is a package.\npackage hash\n\n// F is a function.\nfunc F() {}\n"
+	h1 := fetchHash("v1.0.0", src)
+	if h2 := fetchHash("v1.0.0", src); h2 != h1 {
+		t.Errorf("fetching the same content twice gave hashes %s and %s, want the same", h1, h2)
+	}
+	if h := fetchHash("v1.0.0", src+"\n// G is another function.\nfunc G() {}\n"); h == h1 {
+		t.Error("changing the documentation did not change the hash")
+	}
+	if h := fetchHash("v1.0.1", src); h == h1 {
+		t.Error("changing the version did not change the hash")
+	}
+}
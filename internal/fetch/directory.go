@@ -58,14 +58,20 @@ func moduleDirectories(modulePath string,
 		}
 		if pkg, ok := pkgLookup[dirPath]; ok {
 			dir.Package = &internal.PackageNew{
-				Path:    pkg.Path,
-				Name:    pkg.Name,
-				Imports: pkg.Imports,
+				Path:          pkg.Path,
+				Name:          pkg.Name,
+				Imports:       pkg.Imports,
+				SourceFiles:   pkg.SourceFiles,
+				ImportComment: pkg.ImportComment,
 				Documentation: &internal.Documentation{
 					GOOS:     pkg.GOOS,
 					GOARCH:   pkg.GOARCH,
 					Synopsis: pkg.Synopsis,
 					HTML:     pkg.DocumentationHTML,
+					Doc:      pkg.Doc,
+					Symbols:  pkg.Symbols,
+
+					PlaygroundExamples: pkg.PlaygroundExamples,
 				},
 			}
 		}
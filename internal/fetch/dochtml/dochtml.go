@@ -33,6 +33,12 @@ var (
 	ErrTooLarge = errors.New("rendered documentation HTML size exceeded the specified limit")
 )
 
+// RendererVersion identifies the HTML that Render produces. It should be
+// changed whenever a change to Render or its templates changes the HTML for
+// existing packages, so that documentation rendered before the change can be
+// told apart.
+const RendererVersion = "1"
+
 // RenderOptions are options for Render.
 type RenderOptions struct {
 	SourceLinkFunc func(ast.Node) string
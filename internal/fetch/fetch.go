@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"regexp"
 	"runtime"
 	"runtime/debug"
 	"sort"
@@ -82,6 +83,7 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 
 	var (
 		commitTime time.Time
+		commitHash string
 		zipReader  *zip.Reader
 		err        error
 	)
@@ -100,6 +102,7 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 		}
 		fr.ResolvedVersion = info.Version
 		commitTime = info.Time
+		commitHash = originCommitHash(info)
 
 		goModBytes, err := proxyClient.GetMod(ctx, modulePath, fr.ResolvedVersion)
 		if err != nil {
@@ -135,6 +138,9 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 		fr.Error = err
 		return fr
 	}
+	if commitHash != "" {
+		mod.SourceInfo = mod.SourceInfo.WithCommitHash(commitHash)
+	}
 	fr.Module = mod
 	fr.PackageVersionStates = pvs
 	if modulePath == stdlib.ModulePath {
@@ -148,6 +154,22 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 	return fr
 }
 
+// originCommitHash returns the full commit hash in the origin of info, or the
+// empty string if the proxy did not report one or it does not match the commit
+// named by a pseudo-version.
+func originCommitHash(info *proxy.VersionInfo) string {
+	if info.Origin == nil || !fullCommitHashRE.MatchString(info.Origin.Hash) {
+		return ""
+	}
+	if rev := version.PseudoRevision(info.Version); rev != "" && !strings.HasPrefix(info.Origin.Hash, rev) {
+		return ""
+	}
+	return info.Origin.Hash
+}
+
+// fullCommitHashRE matches a full git commit hash, in SHA-1 or SHA-256.
+var fullCommitHashRE = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
 // processZipFile extracts information from the module version zip.
 func processZipFile(ctx context.Context, modulePath string, versionType version.Type, resolvedVersion string, commitTime time.Time, zipReader *zip.Reader, sourceClient *source.Client) (_ *internal.Module, _ []*internal.PackageVersionState, err error) {
 	defer derrors.Wrap(&err, "processZipFile(%q, %q)", modulePath, resolvedVersion)
@@ -168,6 +190,10 @@ func processZipFile(ctx context.Context, modulePath string, versionType version.
 	}
 	d := licenses.NewDetector(modulePath, resolvedVersion, zipReader, logf)
 	allLicenses := d.AllLicenses()
+	goModFile, goMod, err := parseGoModFile(modulePath, resolvedVersion, zipReader)
+	if err != nil {
+		log.Infof(ctx, "error parsing go.mod file: %v", err)
+	}
 	packages, packageVersionStates, err := extractPackagesFromZip(ctx, modulePath, resolvedVersion, zipReader, d, sourceInfo)
 	if errors.Is(err, errModuleContainsNoPackages) || errors.Is(err, errMalformedZip) {
 		return nil, nil, fmt.Errorf("%v: %w", err.Error(), derrors.BadModule)
@@ -176,6 +202,10 @@ func processZipFile(ctx context.Context, modulePath string, versionType version.
 		return nil, nil, fmt.Errorf("extractPackagesFromZip(%q, %q, zipReader, %v): %v", modulePath, resolvedVersion, allLicenses, err)
 	}
 	hasGoMod := zipContainsFilename(zipReader, path.Join(moduleVersionDir(modulePath, resolvedVersion), "go.mod"))
+	stats, err := fileStats(zipReader)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var readmeFilePath, readmeContents string
 	for _, r := range readmes {
@@ -186,7 +216,7 @@ func processZipFile(ctx context.Context, modulePath string, versionType version.
 		readmeContents = r.Contents
 		break
 	}
-	return &internal.Module{
+	m := &internal.Module{
 		LegacyModuleInfo: internal.LegacyModuleInfo{
 			ModuleInfo: internal.ModuleInfo{
 				ModulePath:        modulePath,
@@ -203,7 +233,63 @@ func processZipFile(ctx context.Context, modulePath string, versionType version.
 		LegacyPackages: packages,
 		Licenses:       allLicenses,
 		Directories:    moduleDirectories(modulePath, packages, readmes, d),
-	}, packageVersionStates, nil
+		Requirements:   goModRequirements(goModFile),
+		GoMod:          goMod,
+		FileStats:      stats,
+	}
+	m.ContentHash = contentHash(m)
+	return m, packageVersionStates, nil
+}
+
+// parseGoModFile parses the go.mod file in the module zip r, and returns it
+// along with its contents. It returns nils if the module has no go.mod file.
+// The contents are returned even if parsing fails.
+func parseGoModFile(modulePath, resolvedVersion string, r *zip.Reader) (_ *modfile.File, _ []byte, err error) {
+	defer derrors.Wrap(&err, "parseGoModFile(%q, %q)", modulePath, resolvedVersion)
+	if modulePath == stdlib.ModulePath {
+		return nil, nil, nil
+	}
+	name := path.Join(moduleVersionDir(modulePath, resolvedVersion), "go.mod")
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		if f.UncompressedSize64 > MaxFileSize {
+			return nil, nil, fmt.Errorf("file size %d exceeds max limit %d", f.UncompressedSize64, MaxFileSize)
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		mf, err := modfile.ParseLax(name, b, nil)
+		return mf, b, err
+	}
+	return nil, nil, nil
+}
+
+// goModRequirements returns the requirements declared in mf, which may be
+// nil. If a module is required more than once, only the last requirement is
+// kept.
+func goModRequirements(mf *modfile.File) []*internal.Requirement {
+	if mf == nil {
+		return nil
+	}
+	var reqs []*internal.Requirement
+	index := map[string]int{}
+	for _, r := range mf.Require {
+		req := &internal.Requirement{
+			ModulePath: r.Mod.Path,
+			Version:    r.Mod.Version,
+			Indirect:   r.Indirect,
+		}
+		if i, ok := index[req.ModulePath]; ok {
+			reqs[i] = req
+			continue
+		}
+		index[req.ModulePath] = len(reqs)
+		reqs = append(reqs, req)
+	}
+	return reqs
 }
 
 // moduleVersionDir formats the content subdirectory for the given
@@ -256,7 +342,7 @@ func isReadme(file string) bool {
 // that they contained .go files but couldn't be processed due to current
 // limitations of this site. The limitations are:
 // * a maximum file size (MaxFileSize)
-// * the particular set of build contexts we consider (goEnvs)
+// * the particular set of build contexts we consider (internal.BuildContexts)
 // * whether the import path is valid.
 func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion string, r *zip.Reader, d *licenses.Detector, sourceInfo *source.Info) (_ []*internal.LegacyPackage, _ []*internal.PackageVersionState, err error) {
 	ctx, span := trace.StartSpan(ctx, "fetch.extractPackagesFromZip")
@@ -323,7 +409,7 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 			continue
 		}
 		importPath := path.Join(modulePath, innerPath)
-		if ignoredByGoTool(importPath) || isVendored(importPath) {
+		if ignoredByGoTool(importPath) || internal.IsVendored(importPath) {
 			// File is in a directory we're not looking to process at this time, so skip it.
 			continue
 		}
@@ -405,6 +491,10 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 			}
 			pkgPath = path.Join(modulePath, innerPath)
 		} else {
+			pkg.SourceFiles, err = readSourceFiles(goFiles)
+			if err != nil {
+				return nil, nil, err
+			}
 			if d != nil { //  should only be nil for tests
 				isRedist, lics := d.PackageInfo(innerPath)
 				pkg.IsRedistributable = isRedist
@@ -451,16 +541,6 @@ func ignoredByGoTool(importPath string) bool {
 	return false
 }
 
-// isVendored reports whether the given import path corresponds
-// to a Go package that is inside a vendor directory.
-//
-// The logic for what is considered a vendor directory is documented at
-// https://golang.org/cmd/go/#hdr-Vendor_Directories.
-func isVendored(importPath string) bool {
-	return strings.HasPrefix(importPath, "vendor/") ||
-		strings.Contains(importPath, "/vendor/")
-}
-
 // zipContainsFilename reports whether there is a file with the given name in the zip.
 func zipContainsFilename(r *zip.Reader, name string) bool {
 	for _, f := range r.File {
@@ -482,15 +562,6 @@ type BadPackageError struct {
 
 func (bpe *BadPackageError) Error() string { return bpe.Err.Error() }
 
-// Go environments used to construct build contexts in loadPackage.
-var goEnvs = []struct{ GOOS, GOARCH string }{
-	{"linux", "amd64"},
-	{"windows", "amd64"},
-	{"darwin", "amd64"},
-	{"js", "wasm"},
-	{"linux", "js"},
-}
-
 // loadPackage loads a Go package by calling loadPackageWithBuildContext, trying
 // several build contexts in turn. The first build context in the list to produce
 // a non-empty package is used. If none of them result in a package, then
@@ -501,7 +572,7 @@ var goEnvs = []struct{ GOOS, GOARCH string }{
 func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath, modulePath string, sourceInfo *source.Info) (*internal.LegacyPackage, error) {
 	ctx, span := trace.StartSpan(ctx, "fetch.loadPackage")
 	defer span.End()
-	for _, env := range goEnvs {
+	for _, env := range internal.BuildContexts {
 		pkg, err := loadPackageWithBuildContext(ctx, env.GOOS, env.GOARCH, zipGoFiles, innerPath, modulePath, sourceInfo)
 		if err != nil && !errors.Is(err, dochtml.ErrTooLarge) {
 			return nil, err
@@ -590,6 +661,11 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 		noTypeAssociation = true
 	}
 
+	// Read the import comment and symbol references before computing the
+	// documentation, which modifies the files.
+	importComment := packageImportComment(fset, goFiles)
+	symbolRefs := packageSymbolReferences(goFiles)
+
 	// Compute package documentation.
 	importPath := path.Join(modulePath, innerPath)
 	var m doc.Mode
@@ -654,6 +730,10 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 	playURLFunc := func(ex *doc.Example) string {
 		return playURLs[ex]
 	}
+	playExamples, err := playgroundExamples(fset, d)
+	if err != nil {
+		return nil, err
+	}
 
 	docHTML, err := dochtml.Render(fset, d, dochtml.RenderOptions{
 		SourceLinkFunc: sourceLinkFunc,
@@ -671,14 +751,23 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 		importPath = innerPath
 	}
 	return &internal.LegacyPackage{
-		Path:              importPath,
-		Name:              packageName,
-		Synopsis:          doc.Synopsis(d.Doc),
-		V1Path:            v1path,
-		Imports:           d.Imports,
-		DocumentationHTML: docHTML,
-		GOOS:              goos,
-		GOARCH:            goarch,
+		Path:               importPath,
+		Name:               packageName,
+		Synopsis:           doc.Synopsis(d.Doc),
+		V1Path:             v1path,
+		Imports:            d.Imports,
+		DocumentationHTML:  docHTML,
+		GOOS:               goos,
+		GOARCH:             goarch,
+		Doc:                d.Doc,
+		Symbols:            packageSymbols(fset, d),
+		ImportComment:      importComment,
+		SymbolReferences:   symbolRefs,
+		PlaygroundExamples: playExamples,
+		DocBuildInfo: &internal.DocBuildInfo{
+			GoVersion:       runtime.Version(),
+			RendererVersion: dochtml.RendererVersion,
+		},
 	}, err
 }
 
@@ -735,6 +824,20 @@ func matchingFiles(goos, goarch string, zipGoFiles []*zip.File) (files map[strin
 	return files, nil
 }
 
+// readSourceFiles returns a map from the base name of each file in
+// zipGoFiles to its contents.
+func readSourceFiles(zipGoFiles []*zip.File) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(zipGoFiles))
+	for _, f := range zipGoFiles {
+		b, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Base(f.Name)] = b
+	}
+	return files, nil
+}
+
 // readZipFile decompresses zip file f and returns its uncompressed contents.
 // The caller can check f.UncompressedSize64 before calling readZipFile to
 // get the expected uncompressed size of f.
@@ -791,6 +894,35 @@ func fetchPlayURL(ex *doc.Example, post func(url, contentType string, body io.Re
 	return fmt.Sprintf("https://play.golang.org/p/%s", p), nil
 }
 
+// playgroundExamples returns the examples in the documentation d, whose files
+// were parsed with fset, that can be run as programs, sorted by name.
+func playgroundExamples(fset *token.FileSet, d *doc.Package) ([]*internal.PlaygroundExample, error) {
+	var (
+		exs      []*internal.PlaygroundExample
+		firstErr error
+	)
+	dochtml.WalkExamples(d, func(_ string, ex *doc.Example) {
+		if ex.Play == nil {
+			return
+		}
+		// Unlike fetchPlayURL, format the program with the FileSet its
+		// comments were parsed with, so they stay where they belong.
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, ex.Play); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("formatting example %q: %v", ex.Name, err)
+			}
+			return
+		}
+		exs = append(exs, &internal.PlaygroundExample{Name: ex.Name, Code: buf.String()})
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Slice(exs, func(i, j int) bool { return exs[i].Name < exs[j].Name })
+	return exs, nil
+}
+
 func allocMeg() int {
 	var ms runtime.MemStats
 	runtime.ReadMemStats(&ms)
@@ -65,6 +65,7 @@ func TestFetchModule(t *testing.T) {
 		{name: "wasm", mod: moduleWasm},
 		{name: "no go.mod file", mod: moduleOnePackage},
 		{name: "has go.mod", mod: moduleMultiPackage},
+		{name: "go.mod with requirements", mod: moduleRequirements},
 		{name: "module with bad packages", mod: moduleBadPackages},
 		{name: "module with build constraints", mod: moduleBuildConstraints},
 		{name: "module with packages with bad import paths", mod: moduleBadImportPath},
@@ -99,8 +100,10 @@ func TestFetchModule(t *testing.T) {
 			sortFetchResult(fr)
 			sortFetchResult(got)
 			opts := []cmp.Option{
-				cmpopts.IgnoreFields(internal.LegacyPackage{}, "DocumentationHTML"),
-				cmpopts.IgnoreFields(internal.Documentation{}, "HTML"),
+				cmpopts.IgnoreFields(internal.Module{}, "ContentHash", "FileStats", "GoMod"),
+				cmpopts.IgnoreFields(internal.LegacyPackage{}, "DocumentationHTML", "Doc", "Symbols", "SourceFiles", "SymbolReferences", "PlaygroundExamples"),
+				cmpopts.IgnoreFields(internal.PackageNew{}, "SourceFiles"),
+				cmpopts.IgnoreFields(internal.Documentation{}, "HTML", "Doc", "Symbols", "PlaygroundExamples"),
 				cmpopts.IgnoreFields(internal.PackageVersionState{}, "Error"),
 				cmp.AllowUnexported(source.Info{}),
 				cmpopts.EquateEmpty(),
@@ -113,6 +116,46 @@ func TestFetchModule(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchModule_SourceFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	files := map[string]string{
+		"go.mod":       "module example.com/src",
+		"LICENSE":      testhelper.MITLicense,
+		"src.go":       "package src\n\nconst A = 1\n",
+		"src_test.go":  "package src\n",
+		"src_linux.go": "// +build linux\n\npackage src\n",
+		"README.md":    "readme",
+		"sub/sub.go":   "package sub\n",
+	}
+	proxyClient, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "example.com/src",
+		Version:    "v1.0.0",
+		Files:      files,
+	}})
+	defer teardownProxy()
+	got := FetchModule(ctx, "example.com/src", "v1.0.0", proxyClient, source.NewClient(sourceTimeout))
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	want := map[string]map[string][]byte{
+		"example.com/src": {
+			"src.go":       []byte(files["src.go"]),
+			"src_test.go":  []byte(files["src_test.go"]),
+			"src_linux.go": []byte(files["src_linux.go"]),
+		},
+		"example.com/src/sub": {"sub.go": []byte(files["sub/sub.go"])},
+	}
+	gotFiles := map[string]map[string][]byte{}
+	for _, p := range got.Module.LegacyPackages {
+		gotFiles[p.Path] = p.SourceFiles
+	}
+	if diff := cmp.Diff(want, gotFiles); diff != "" {
+		t.Errorf("SourceFiles mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestFetchModule_Errors(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -436,3 +479,97 @@ package p
 		}
 	}
 }
+
+func TestPlaygroundExamples(t *testing.T) {
+	fset := token.NewFileSet()
+	files := []*ast.File{
+		mustParse(fset, "p.go", `
+// Package p is a package.
+package p
+
+func F() string { return "f" }
+`),
+		mustParse(fset, "p_test.go", `
+package p_test
+
+import (
+	"fmt"
+
+	"example.com/p"
+)
+
+func ExampleF() {
+	// Print the result of F.
+	fmt.Println(p.F())
+	// Output: f
+}
+
+func Example() {
+	fmt.Println("no output")
+}
+`),
+	}
+	d, err := doc.NewFromFiles(fset, files, "example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := playgroundExamples(fset, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.PlaygroundExample{
+		{
+			Name: "",
+			Code: `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	fmt.Println("no output")
+}
+`,
+		},
+		{
+			Name: "F",
+			Code: `package main
+
+import (
+	"example.com/p"
+	"fmt"
+)
+
+func main() {
+	// Print the result of F.
+	fmt.Println(p.F())
+}
+`,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("playgroundExamples mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestOriginCommitHash(t *testing.T) {
+	const hash = "abcdef1234567890abcdef1234567890abcdef12"
+	for _, test := range []struct {
+		name string
+		info *proxy.VersionInfo
+		want string
+	}{
+		{"no origin", &proxy.VersionInfo{Version: "v1.0.0"}, ""},
+		{"tag", &proxy.VersionInfo{Version: "v1.0.0", Origin: &proxy.Origin{Hash: hash}}, hash},
+		{"pseudo", &proxy.VersionInfo{Version: "v0.0.0-20200101000000-abcdef123456", Origin: &proxy.Origin{Hash: hash}}, hash},
+		{"pseudo mismatch", &proxy.VersionInfo{Version: "v0.0.0-20200101000000-123456abcdef", Origin: &proxy.Origin{Hash: hash}}, ""},
+		{"short hash", &proxy.VersionInfo{Version: "v1.0.0", Origin: &proxy.Origin{Hash: "abcdef123456"}}, ""},
+		{"not hex", &proxy.VersionInfo{Version: "v1.0.0", Origin: &proxy.Origin{Hash: "ABCDEF1234567890ABCDEF1234567890ABCDEF12"}}, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := originCommitHash(test.info); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -644,6 +644,61 @@ var moduleWasm = &testModule{
 	},
 }
 
+var moduleRequirements = &testModule{
+	mod: &proxy.TestModule{
+		ModulePath: "github.com/my/module",
+		Files: map[string]string{
+			"go.mod": `
+			module github.com/my/module
+
+			go 1.14
+
+			require (
+				github.com/a/b v1.2.3
+				golang.org/x/c v0.1.0 // indirect
+			)
+			require example.com/d/v2 v2.0.0-20200101000000-abcdef123456
+			`,
+			"LICENSE": testhelper.BSD0License,
+			"foo/foo.go": `
+			// Package foo
+			package foo`,
+		},
+	},
+	fr: &FetchResult{
+		Module: &internal.Module{
+			LegacyModuleInfo: internal.LegacyModuleInfo{
+				ModuleInfo: internal.ModuleInfo{
+					ModulePath: "github.com/my/module",
+					HasGoMod:   true,
+					SourceInfo: source.NewGitHubInfo("https://github.com/my/module", "", "v1.0.0"),
+				},
+			},
+			Requirements: []*internal.Requirement{
+				{ModulePath: "github.com/a/b", Version: "v1.2.3"},
+				{ModulePath: "golang.org/x/c", Version: "v0.1.0", Indirect: true},
+				{ModulePath: "example.com/d/v2", Version: "v2.0.0-20200101000000-abcdef123456"},
+			},
+			Directories: []*internal.DirectoryNew{
+				{
+					Path:   "github.com/my/module",
+					V1Path: "github.com/my/module",
+				},
+				{
+					Path:   "github.com/my/module/foo",
+					V1Path: "github.com/my/module/foo",
+					Package: &internal.PackageNew{
+						Name: "foo",
+						Documentation: &internal.Documentation{
+							Synopsis: "Package foo",
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
 var moduleAlternative = &testModule{
 	mod: &proxy.TestModule{
 		ModulePath: "github.com/my/module",
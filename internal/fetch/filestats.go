@@ -0,0 +1,57 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// fileStats returns statistics about the files in the module zip r. Lines are
+// counted in every .go file in the zip that is no larger than MaxFileSize,
+// including those in testdata and vendor directories.
+func fileStats(r *zip.Reader) (_ *internal.FileStats, err error) {
+	defer derrors.Wrap(&err, "fileStats(zipReader)")
+
+	fs := &internal.FileStats{}
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		fs.NumFiles++
+		if !strings.HasSuffix(f.Name, ".go") {
+			fs.NumNonGoFiles++
+			continue
+		}
+		if f.UncompressedSize64 > MaxFileSize {
+			continue
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		n := countLines(b)
+		if strings.HasSuffix(f.Name, "_test.go") {
+			fs.TestLines += n
+		} else {
+			fs.GoLines += n
+		}
+	}
+	return fs, nil
+}
+
+// countLines returns the number of lines in b. A final line without a
+// trailing newline is counted.
+func countLines(b []byte) int {
+	n := bytes.Count(b, []byte("\n"))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestFileStats(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name, contents string
+	}{
+		{"m.com@v1.0.0/go.mod", "module m.com\n"},
+		{"m.com@v1.0.0/LICENSE", "license\ntext\n"},
+		{"m.com@v1.0.0/a.go", "package a\n\nfunc A() {}\n"},
+		{"m.com@v1.0.0/sub/b.go", "package sub\n\n// B is b.\nfunc B() {}"}, // no final newline
+		{"m.com@v1.0.0/a_test.go", "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n"},
+		{"m.com@v1.0.0/empty.go", ""},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fileStats(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.FileStats{
+		NumFiles:      6,
+		NumNonGoFiles: 2,
+		GoLines:       7,
+		TestLines:     5,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("fileStats mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// packageImportComment returns the path in the import comment of the first
+// of files, in order of file name, that has one. It returns the empty string
+// if none of them do.
+func packageImportComment(fset *token.FileSet, files map[string]*ast.File) string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if p := importComment(fset, files[name]); p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+// importComment returns the path in the import comment of f, a comment of
+// the form
+//
+//	// import "path"
+//
+// or
+//
+//	/* import "path" */
+//
+// following the package name on the same line. It returns the empty string if
+// f has no such comment.
+func importComment(fset *token.FileSet, f *ast.File) string {
+	line := fset.Position(f.Name.Pos()).Line
+	for _, cg := range f.Comments {
+		c := cg.List[0]
+		if c.Pos() < f.Name.End() {
+			continue
+		}
+		if fset.Position(c.Pos()).Line != line {
+			return ""
+		}
+		text := c.Text
+		if strings.HasPrefix(text, "//") {
+			text = text[2:]
+		} else {
+			text = strings.TrimSuffix(text[2:], "*/")
+		}
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, "import ") && !strings.HasPrefix(text, "import\t") {
+			return ""
+		}
+		p, err := strconv.Unquote(strings.TrimSpace(text[len("import"):]))
+		if err != nil {
+			return ""
+		}
+		return p
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestImportComment(t *testing.T) {
+	for _, test := range []struct {
+		name, src, want string
+	}{
+		{"none", "package p\n", ""},
+		{"line", "package p // import \"example.com/p\"\n", "example.com/p"},
+		{"block", "package p /* import \"example.com/p\" */\n", "example.com/p"},
+		{"tab", "package p //\timport \"example.com/p\"\n", "example.com/p"},
+		{"raw string", "package p // import `example.com/p`\n", "example.com/p"},
+		{"other comment", "package p // a package\n", ""},
+		{"next line", "package p\n// import \"example.com/p\"\n", ""},
+		{"doc comment", "// import \"example.com/p\"\npackage p\n", ""},
+		{"unquoted", "package p // import example.com/p\n", ""},
+		{"importer", "package p // importer \"example.com/p\"\n", ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", test.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := importComment(fset, f); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPackageImportComment(t *testing.T) {
+	fset := token.NewFileSet()
+	files := map[string]*ast.File{}
+	for name, src := range map[string]string{
+		"a.go": "package p\n",
+		"b.go": "package p // import \"example.com/b\"\n",
+		"c.go": "package p // import \"example.com/c\"\n",
+	} {
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = f
+	}
+	if got, want := packageImportComment(fset, files), "example.com/b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+)
+
+// packageSymbols returns the exported symbols documented in d, in the order
+// in which they appear in the documentation.
+func packageSymbols(fset *token.FileSet, d *doc.Package) []*internal.Symbol {
+	var syms []*internal.Symbol
+	syms = append(syms, valueSymbols(fset, d.Consts, internal.SymbolKindConstant, "")...)
+	syms = append(syms, valueSymbols(fset, d.Vars, internal.SymbolKindVariable, "")...)
+	syms = append(syms, funcSymbols(fset, d.Funcs, "")...)
+	for _, t := range d.Types {
+		if !ast.IsExported(t.Name) {
+			continue
+		}
+		syms = append(syms, &internal.Symbol{
+			Name:     t.Name,
+			Kind:     internal.SymbolKindType,
+			Synopsis: typeSynopsis(fset, t),
+			Doc:      t.Doc,
+		})
+		syms = append(syms, valueSymbols(fset, t.Consts, internal.SymbolKindConstant, t.Name)...)
+		syms = append(syms, valueSymbols(fset, t.Vars, internal.SymbolKindVariable, t.Name)...)
+		syms = append(syms, funcSymbols(fset, t.Funcs, t.Name)...)
+		syms = append(syms, funcSymbols(fset, t.Methods, t.Name)...)
+	}
+	return syms
+}
+
+// valueSymbols returns a symbol for each exported name declared in values.
+func valueSymbols(fset *token.FileSet, values []*doc.Value, kind internal.SymbolKind, parent string) []*internal.Symbol {
+	var syms []*internal.Symbol
+	for _, v := range values {
+		for _, spec := range v.Decl.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for _, ident := range vs.Names {
+				if !ast.IsExported(ident.Name) {
+					continue
+				}
+				synopsis := v.Decl.Tok.String() + " " + ident.Name
+				if vs.Type != nil {
+					synopsis += " " + nodeString(fset, vs.Type)
+				}
+				syms = append(syms, &internal.Symbol{
+					Name:       ident.Name,
+					Kind:       kind,
+					Synopsis:   synopsis,
+					ParentName: parent,
+					Doc:        v.Doc,
+				})
+			}
+		}
+	}
+	return syms
+}
+
+// funcSymbols returns a symbol for each exported function or method in funcs.
+// Methods are named "Type.Method".
+func funcSymbols(fset *token.FileSet, funcs []*doc.Func, parent string) []*internal.Symbol {
+	var syms []*internal.Symbol
+	for _, f := range funcs {
+		if !ast.IsExported(f.Name) {
+			continue
+		}
+		s := &internal.Symbol{
+			Name:       f.Name,
+			Kind:       internal.SymbolKindFunction,
+			ParentName: parent,
+			Doc:        f.Doc,
+			Synopsis: nodeString(fset, &ast.FuncDecl{
+				Recv: f.Decl.Recv,
+				Name: f.Decl.Name,
+				Type: f.Decl.Type,
+			}),
+		}
+		if f.Recv != "" {
+			s.Name = parent + "." + f.Name
+			s.Kind = internal.SymbolKindMethod
+		}
+		syms = append(syms, s)
+	}
+	return syms
+}
+
+// typeSynopsis returns a one-line declaration of t, eliding the bodies of
+// struct and interface types.
+func typeSynopsis(fset *token.FileSet, t *doc.Type) string {
+	for _, spec := range t.Decl.Specs {
+		ts := spec.(*ast.TypeSpec)
+		if ts.Name.Name != t.Name {
+			continue
+		}
+		var def string
+		switch ts.Type.(type) {
+		case *ast.StructType:
+			def = "struct"
+		case *ast.InterfaceType:
+			def = "interface"
+		default:
+			def = nodeString(fset, ts.Type)
+		}
+		if ts.Assign.IsValid() {
+			return "type " + t.Name + " = " + def
+		}
+		return "type " + t.Name + " " + def
+	}
+	return "type " + t.Name
+}
+
+// nodeString formats n as Go source on a single line.
+func nodeString(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, n); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+)
+
+func TestPackageSymbols(t *testing.T) {
+	const src = `
+// Package p is a package.
+package p
+
+import "io"
+
+// Constants.
+const (
+	A, b = 1, 2
+	C int = 3
+)
+
+var V = "v"
+
+var internal int
+
+// F is a function.
+func F(x int,
+	y string) error { return nil }
+
+// T is a type.
+type T struct{ x int }
+
+const TZero T = T{}
+
+func NewT() *T { return nil }
+
+func (t *T) M(w io.Writer) {}
+
+func (T) m() {}
+
+type I interface{ M(io.Writer) }
+
+type Alias = T
+
+type N int
+
+type unexported int
+
+func (unexported) Exported() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := doc.NewFromFiles(fset, []*ast.File{f}, "example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := packageSymbols(fset, d)
+	want := []*internal.Symbol{
+		{Name: "A", Kind: internal.SymbolKindConstant, Synopsis: "const A", Doc: "Constants.\n"},
+		{Name: "C", Kind: internal.SymbolKindConstant, Synopsis: "const C int", Doc: "Constants.\n"},
+		{Name: "V", Kind: internal.SymbolKindVariable, Synopsis: "var V"},
+		{Name: "F", Kind: internal.SymbolKindFunction, Synopsis: "func F(x int, y string) error", Doc: "F is a function.\n"},
+		{Name: "Alias", Kind: internal.SymbolKindType, Synopsis: "type Alias = T"},
+		{Name: "I", Kind: internal.SymbolKindType, Synopsis: "type I interface"},
+		{Name: "N", Kind: internal.SymbolKindType, Synopsis: "type N int"},
+		{Name: "T", Kind: internal.SymbolKindType, Synopsis: "type T struct", Doc: "T is a type.\n"},
+		{Name: "TZero", Kind: internal.SymbolKindConstant, Synopsis: "const TZero T", ParentName: "T"},
+		{Name: "NewT", Kind: internal.SymbolKindFunction, Synopsis: "func NewT() *T", ParentName: "T"},
+		{Name: "T.M", Kind: internal.SymbolKindMethod, Synopsis: "func (t *T) M(w io.Writer)", ParentName: "T"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("packageSymbols mismatch (-want +got):\n%s", diff)
+	}
+}
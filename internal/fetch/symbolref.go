@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+)
+
+// packageSymbolReferences returns the references in files to the exported
+// symbols of the packages they import, sorted by import path and symbol.
+//
+// References are found syntactically, without type checking, so the result is
+// best-effort. A reference is a selector expression pkg.Name, where pkg is the
+// name under which a file imports a package and Name is exported. If an import
+// is not named, the package name is guessed from the import path, as by
+// guessPackageName. Methods and fields reached through values of imported
+// types are not found, and local identifiers that shadow an import name are
+// mistaken for it.
+func packageSymbolReferences(files map[string]*ast.File) []*internal.SymbolReference {
+	counts := map[[2]string]int{} // from (import path, symbol) to count
+	for _, f := range files {
+		names := map[string]string{} // from name in f to import path
+		for _, spec := range f.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			var name string
+			if spec.Name != nil {
+				name = spec.Name.Name
+			} else {
+				name = guessPackageName(importPath)
+			}
+			if name == "" || name == "_" || name == "." {
+				continue
+			}
+			names[name] = importPath
+		}
+		if len(names) == 0 {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			x, ok := sel.X.(*ast.Ident)
+			if !ok || !sel.Sel.IsExported() {
+				return true
+			}
+			if importPath, ok := names[x.Name]; ok {
+				counts[[2]string{importPath, sel.Sel.Name}]++
+			}
+			return true
+		})
+	}
+	var refs []*internal.SymbolReference
+	for k, n := range counts {
+		refs = append(refs, &internal.SymbolReference{ImportPath: k[0], Symbol: k[1], Count: n})
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].ImportPath != refs[j].ImportPath {
+			return refs[i].ImportPath < refs[j].ImportPath
+		}
+		return refs[i].Symbol < refs[j].Symbol
+	})
+	return refs
+}
+
+// guessPackageName returns the likely name of the package with the given
+// import path: its last element, without a major version suffix such as "/v2"
+// or ".v2", and without a "go-" prefix or ".go" suffix. It returns the empty
+// string if the result is not an identifier.
+func guessPackageName(importPath string) string {
+	name := path.Base(importPath)
+	if isMajorVersion(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	if i := strings.Index(name, ".v"); i > 0 && isMajorVersion(name[i+1:]) {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.TrimSuffix(name, ".go")
+	if !token.IsIdentifier(name) {
+		return ""
+	}
+	return name
+}
+
+// isMajorVersion reports whether s has the form "vN" for a number N.
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
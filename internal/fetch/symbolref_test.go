@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestPackageSymbolReferences(t *testing.T) {
+	fset := token.NewFileSet()
+	files := map[string]*ast.File{}
+	for name, src := range map[string]string{
+		"a.go": `package p
+
+import (
+	"fmt"
+	str "strings"
+	"example.com/yaml.v2"
+	_ "example.com/side"
+)
+
+func F() {
+	fmt.Println(str.ToUpper("a"), str.ToUpper("b"))
+	_ = yaml.Marshal
+	_ = fmt.sprintf
+}`,
+		"b.go": `package p
+
+import "fmt"
+
+var s = fmt.Sprint(fmt.Println)
+`,
+	} {
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = f
+	}
+	got := packageSymbolReferences(files)
+	want := []*internal.SymbolReference{
+		{ImportPath: "example.com/yaml.v2", Symbol: "Marshal", Count: 1},
+		{ImportPath: "fmt", Symbol: "Println", Count: 2},
+		{ImportPath: "fmt", Symbol: "Sprint", Count: 1},
+		{ImportPath: "strings", Symbol: "ToUpper", Count: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGuessPackageName(t *testing.T) {
+	for _, test := range []struct {
+		importPath, want string
+	}{
+		{"fmt", "fmt"},
+		{"net/http", "http"},
+		{"example.com/mod/v2", "mod"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"github.com/a/go-foo", "foo"},
+		{"github.com/a/foo.go", "foo"},
+		{"example.com/a-b", ""},
+		{"v2", "v2"},
+	} {
+		if got := guessPackageName(test.importPath); got != test.want {
+			t.Errorf("guessPackageName(%q) = %q, want %q", test.importPath, got, test.want)
+		}
+	}
+}
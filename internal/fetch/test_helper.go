@@ -8,6 +8,7 @@ import (
 	"archive/zip"
 	"context"
 	"net/http"
+	"runtime"
 	"sort"
 	"strings"
 	"testing"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/fetch/dochtml"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/proxy"
@@ -77,6 +79,10 @@ func cleanFetchResult(fr *FetchResult, detector *licenses.Detector) *FetchResult
 				GOOS:              dir.Package.Documentation.GOOS,
 				GOARCH:            dir.Package.Documentation.GOARCH,
 				IsRedistributable: dir.IsRedistributable,
+				DocBuildInfo: &internal.DocBuildInfo{
+					GoVersion:       runtime.Version(),
+					RendererVersion: dochtml.RendererVersion,
+				},
 			})
 			if shouldSetPVS {
 				fr.PackageVersionStates = append(
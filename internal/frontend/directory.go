@@ -92,7 +92,7 @@ func fetchDirectoryDetails(ctx context.Context, ds internal.DataSource, dirPath
 	}
 
 	if dirPath == stdlib.ModulePath {
-		pkgs, err := ds.GetPackagesInModule(ctx, stdlib.ModulePath, mi.Version)
+		pkgs, err := ds.GetPackagesInModule(ctx, stdlib.ModulePath, mi.Version, internal.PathOptions{})
 		if err != nil {
 			return nil, err
 		}
@@ -170,7 +170,7 @@ func (s *Server) servePackagePageNew(w http.ResponseWriter, r *http.Request, ful
 		}
 		return pathFoundAtLatestError(ctx, "package", fullPath, inVersion)
 	}
-	vdir, err := s.ds.GetDirectoryNew(ctx, fullPath, modulePath, version)
+	vdir, err := s.ds.GetDirectoryNew(ctx, fullPath, modulePath, version, internal.PathOptions{})
 	if err != nil {
 		return err
 	}
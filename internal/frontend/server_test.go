@@ -931,7 +931,7 @@ func newTestServer(t *testing.T, proxyModules []*proxy.TestModule, experimentNam
 		exps = append(exps, &internal.Experiment{Name: n, Rollout: 100})
 		set[n] = true
 	}
-	q := queue.NewInMemory(ctx, proxyClient, sourceClient, testDB, 1, FetchAndUpdateState, experiment.NewSet(set))
+	q := queue.NewInMemory(ctx, proxyClient, sourceClient, testDB, 1, FetchAndUpdateState, experiment.NewSet(set), nil)
 	s, err := NewServer(ServerConfig{
 		DataSource:           testDB,
 		Queue:                q,
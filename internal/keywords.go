@@ -0,0 +1,74 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxKeywords is the largest number of keywords returned by ModuleKeywords.
+const MaxKeywords = 20
+
+// keywordStopWords are the words of synopses that are too common to describe
+// what a module is about.
+var keywordStopWords = func() map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(`
+		about all also and any are based but can command contains different
+		does each for from functions given has have how implementation
+		implements into its library more not one other our package packages
+		provides set such support supports than that the their these this
+		tool type types use used useful uses using various via was what when
+		which will with you your`) {
+		m[w] = true
+	}
+	return m
+}()
+
+// ModuleKeywords returns keywords describing a module, derived from the names
+// and synopses of its packages pkgs: the names of the packages other than
+// main, and the words of at least three letters in the synopses, except for
+// common English words and the words "package" and "provides". Keywords are
+// lower case and appear once. They are sorted by the number of packages they
+// come from, most first, then alphabetically, and there are at most
+// MaxKeywords of them. The result is never nil.
+func ModuleKeywords(pkgs []*LegacyPackage) []string {
+	counts := map[string]int{}
+	for _, p := range pkgs {
+		words := map[string]bool{}
+		if p.Name != "" && p.Name != "main" {
+			words[strings.ToLower(p.Name)] = true
+		}
+		for _, w := range strings.FieldsFunc(p.Synopsis, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			w = strings.ToLower(w)
+			if len(w) < 3 || keywordStopWords[w] || strings.IndexFunc(w, unicode.IsLetter) < 0 {
+				continue
+			}
+			words[w] = true
+		}
+		for w := range words {
+			counts[w]++
+		}
+	}
+	keywords := []string{}
+	for w := range counts {
+		keywords = append(keywords, w)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		ki, kj := keywords[i], keywords[j]
+		if counts[ki] != counts[kj] {
+			return counts[ki] > counts[kj]
+		}
+		return ki < kj
+	})
+	if len(keywords) > MaxKeywords {
+		keywords = keywords[:MaxKeywords]
+	}
+	return keywords
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestModuleKeywords(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		pkgs []*LegacyPackage
+		want []string
+	}{
+		{"no packages", nil, []string{}},
+		{
+			"one package",
+			[]*LegacyPackage{{Name: "yaml", Synopsis: "Package yaml implements YAML support for the Go language."}},
+			[]string{"language", "yaml"},
+		},
+		{
+			"several packages",
+			[]*LegacyPackage{
+				{Name: "http2", Synopsis: "Package http2 implements the HTTP/2 protocol."},
+				{Name: "hpack", Synopsis: "Package hpack implements HPACK, a compression format for HTTP/2."},
+				{Name: "main", Synopsis: "Command h2i is an HTTP/2 debugging tool, version 2."},
+			},
+			[]string{"http", "compression", "debugging", "format", "h2i", "hpack", "http2", "protocol", "version"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := ModuleKeywords(test.pkgs)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"sort"
+
+	"golang.org/x/pkgsite/internal/licenses"
+)
+
+// A Badge summarizes the licenses of a module version, for embedding in a
+// README badge.
+type Badge struct {
+	// License is the SPDX ID of the primary license of the module version, or
+	// UnknownLicense if it has no license that could be classified.
+	License string `json:"license"`
+	// Color is a hint for the color of the badge: BadgeColorRedistributable,
+	// BadgeColorRestricted or BadgeColorUnknown.
+	Color string `json:"color"`
+	// IsRedistributable reports whether the module version is
+	// redistributable.
+	IsRedistributable bool `json:"isRedistributable"`
+}
+
+// UnknownLicense is the license type of a license file that could not be
+// classified.
+const UnknownLicense = "UNKNOWN"
+
+// The colors of a Badge.
+const (
+	// BadgeColorRedistributable is the color of a redistributable module.
+	BadgeColorRedistributable = "green"
+	// BadgeColorRestricted is the color of a module whose license is known
+	// but does not allow redistribution.
+	BadgeColorRestricted = "orange"
+	// BadgeColorUnknown is the color of a module without a known license.
+	BadgeColorUnknown = "lightgrey"
+)
+
+// NewLicenseBadge returns the Badge of a module version with the given
+// top-level licenses. The primary license is the first classified license
+// type of the license files in order of file path, so that LICENSE comes
+// before LICENSE.md, for instance.
+func NewLicenseBadge(lics []*licenses.Metadata, isRedistributable bool) *Badge {
+	lics = append([]*licenses.Metadata{}, lics...)
+	sort.Slice(lics, func(i, j int) bool { return lics[i].FilePath < lics[j].FilePath })
+	b := &Badge{License: UnknownLicense, IsRedistributable: isRedistributable}
+	for _, l := range lics {
+		if len(l.Types) > 0 && l.Types[0] != UnknownLicense {
+			b.License = l.Types[0]
+			break
+		}
+	}
+	switch {
+	case isRedistributable:
+		b.Color = BadgeColorRedistributable
+	case b.License != UnknownLicense:
+		b.Color = BadgeColorRestricted
+	default:
+		b.Color = BadgeColorUnknown
+	}
+	return b
+}
+
+// A LicenseFile is a license file in a module zip.
+type LicenseFile struct {
+	// FilePath is the '/'-separated path to the file, relative to the module
+	// root.
+	FilePath string
+	Contents []byte
+}
+
+// UndetectedLicenseFiles returns the files of lics whose contents could not
+// be classified as any license type, in order of file path. The contents of
+// a file that could not be read are empty.
+func UndetectedLicenseFiles(lics []*licenses.License) []LicenseFile {
+	var files []LicenseFile
+	for _, l := range lics {
+		if len(l.Types) == 1 && l.Types[0] == UnknownLicense {
+			files = append(files, LicenseFile{FilePath: l.FilePath, Contents: l.Contents})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
+	return files
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/licenses"
+)
+
+func TestNewLicenseBadge(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		lics              []*licenses.Metadata
+		isRedistributable bool
+		want              *Badge
+	}{
+		{
+			"MIT",
+			[]*licenses.Metadata{{Types: []string{"MIT"}, FilePath: "LICENSE"}},
+			true,
+			&Badge{License: "MIT", Color: BadgeColorRedistributable, IsRedistributable: true},
+		},
+		{
+			"first by file path",
+			[]*licenses.Metadata{
+				{Types: []string{"Apache-2.0"}, FilePath: "LICENSE.md"},
+				{Types: []string{"UNKNOWN"}, FilePath: "COPYING"},
+				{Types: []string{"BSD-3-Clause"}, FilePath: "LICENSE"},
+			},
+			true,
+			&Badge{License: "BSD-3-Clause", Color: BadgeColorRedistributable, IsRedistributable: true},
+		},
+		{
+			"not redistributable",
+			[]*licenses.Metadata{{Types: []string{"CC-BY-NC-4.0"}, FilePath: "LICENSE"}},
+			false,
+			&Badge{License: "CC-BY-NC-4.0", Color: BadgeColorRestricted},
+		},
+		{
+			"unknown license",
+			[]*licenses.Metadata{{Types: []string{"UNKNOWN"}, FilePath: "LICENSE"}},
+			false,
+			&Badge{License: UnknownLicense, Color: BadgeColorUnknown},
+		},
+		{"no license", nil, false, &Badge{License: UnknownLicense, Color: BadgeColorUnknown}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := NewLicenseBadge(test.lics, test.isRedistributable)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUndetectedLicenseFiles(t *testing.T) {
+	lics := []*licenses.License{
+		{Metadata: &licenses.Metadata{Types: []string{"MIT"}, FilePath: "LICENSE"}, Contents: []byte("mit")},
+		{Metadata: &licenses.Metadata{Types: []string{UnknownLicense}, FilePath: "sub/LICENSE"}, Contents: []byte("sub")},
+		{Metadata: &licenses.Metadata{Types: []string{UnknownLicense}, FilePath: "COPYING"}, Contents: []byte("copying")},
+	}
+	want := []LicenseFile{
+		{FilePath: "COPYING", Contents: []byte("copying")},
+		{FilePath: "sub/LICENSE", Contents: []byte("sub")},
+	}
+	if diff := cmp.Diff(want, UndetectedLicenseFiles(lics)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got := UndetectedLicenseFiles(lics[:1]); got != nil {
+		t.Errorf("got %v for recognized licenses only, want nil", got)
+	}
+}
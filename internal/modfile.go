@@ -0,0 +1,161 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"sort"
+
+	"golang.org/x/mod/modfile"
+)
+
+// A ModFile is the structure of a go.mod file, with the fields of
+// golang.org/x/mod/modfile.File except for its syntax tree.
+type ModFile struct {
+	// ModulePath is the path in the module directive.
+	ModulePath string
+	// GoVersion is the version in the go directive, or the empty string if
+	// there is none.
+	GoVersion string
+	Require   []*Requirement
+	Exclude   []*ModVersion
+	Replace   []*ModReplace
+}
+
+// A ModVersion is a module path and version, as in a go.mod file. The version
+// is empty for the left side of a replace directive that applies to all
+// versions, and for the right side of one whose replacement is a directory.
+type ModVersion struct {
+	Path    string
+	Version string
+}
+
+// A ModReplace is a replace directive in a go.mod file.
+type ModReplace struct {
+	Old ModVersion
+	New ModVersion
+}
+
+// ParseModFile parses the contents of a go.mod file. If the file cannot be
+// parsed completely, such as because it has a directive that is newer than
+// the parser, it is parsed as the go command parses the go.mod files of
+// dependencies: only the module, go and require directives are read, and
+// Exclude and Replace are empty.
+func ParseModFile(data []byte) (*ModFile, error) {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		f, err = modfile.ParseLax("go.mod", data, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mf := &ModFile{}
+	if f.Module != nil {
+		mf.ModulePath = f.Module.Mod.Path
+	}
+	if f.Go != nil {
+		mf.GoVersion = f.Go.Version
+	}
+	for _, r := range f.Require {
+		mf.Require = append(mf.Require, &Requirement{
+			ModulePath: r.Mod.Path,
+			Version:    r.Mod.Version,
+			Indirect:   r.Indirect,
+		})
+	}
+	for _, e := range f.Exclude {
+		mf.Exclude = append(mf.Exclude, &ModVersion{Path: e.Mod.Path, Version: e.Mod.Version})
+	}
+	for _, r := range f.Replace {
+		mf.Replace = append(mf.Replace, &ModReplace{
+			Old: ModVersion{Path: r.Old.Path, Version: r.Old.Version},
+			New: ModVersion{Path: r.New.Path, Version: r.New.Version},
+		})
+	}
+	return mf, nil
+}
+
+// A Replace is a replace directive in a go.mod file, flattened for display.
+// OldVersion is empty if the directive applies to all versions of Old.
+// NewVersion is empty if New is a directory in the filesystem rather than a
+// module path.
+type Replace struct {
+	Old        string
+	OldVersion string
+	New        string
+	NewVersion string
+}
+
+// ReplaceDirectives returns the replace directives of mf, in the order they
+// appear in the file.
+func (mf *ModFile) ReplaceDirectives() []Replace {
+	var rs []Replace
+	for _, r := range mf.Replace {
+		rs = append(rs, Replace{
+			Old:        r.Old.Path,
+			OldVersion: r.Old.Version,
+			New:        r.New.Path,
+			NewVersion: r.New.Version,
+		})
+	}
+	return rs
+}
+
+// A ModDiff is the difference between the require directives of two go.mod
+// files. Each of its fields is sorted by module path.
+type ModDiff struct {
+	// Added are the requirements of the second file on modules that the first
+	// does not require.
+	Added []*Requirement
+	// Removed are the requirements of the first file on modules that the
+	// second does not require.
+	Removed []*Requirement
+	// Changed are the modules required by both files with a different
+	// version, or marked as indirect in only one of them.
+	Changed []*RequirementChange
+}
+
+// A RequirementChange is a change to the requirement on a module between two
+// go.mod files.
+type RequirementChange struct {
+	ModulePath string
+	From, To   *Requirement
+}
+
+// DiffModFiles returns the difference between the require directives of from
+// and to. If a file requires a module more than once, the last requirement is
+// used, as by the go command.
+func DiffModFiles(from, to *ModFile) *ModDiff {
+	byPath := func(mf *ModFile) map[string]*Requirement {
+		m := map[string]*Requirement{}
+		for _, r := range mf.Require {
+			m[r.ModulePath] = r
+		}
+		return m
+	}
+	fromReqs, toReqs := byPath(from), byPath(to)
+	d := &ModDiff{}
+	for path, r := range toReqs {
+		fr, ok := fromReqs[path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, r)
+		case fr.Version != r.Version || fr.Indirect != r.Indirect:
+			d.Changed = append(d.Changed, &RequirementChange{ModulePath: path, From: fr, To: r})
+		}
+	}
+	for path, r := range fromReqs {
+		if _, ok := toReqs[path]; !ok {
+			d.Removed = append(d.Removed, r)
+		}
+	}
+	sortRequirements(d.Added)
+	sortRequirements(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].ModulePath < d.Changed[j].ModulePath })
+	return d
+}
+
+func sortRequirements(rs []*Requirement) {
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ModulePath < rs[j].ModulePath })
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseModFile(t *testing.T) {
+	for _, test := range []struct {
+		name, contents string
+		want           *ModFile
+	}{
+		{
+			name: "all directives",
+			contents: `module example.com/m
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.2.0 // indirect
+)
+
+exclude example.com/a v1.1.0
+
+replace (
+	example.com/a => example.com/fork v1.0.1
+	example.com/b v1.2.0 => ../b
+)
+`,
+			want: &ModFile{
+				ModulePath: "example.com/m",
+				GoVersion:  "1.14",
+				Require: []*Requirement{
+					{ModulePath: "example.com/a", Version: "v1.0.0"},
+					{ModulePath: "example.com/b", Version: "v1.2.0", Indirect: true},
+				},
+				Exclude: []*ModVersion{{Path: "example.com/a", Version: "v1.1.0"}},
+				Replace: []*ModReplace{
+					{Old: ModVersion{Path: "example.com/a"}, New: ModVersion{Path: "example.com/fork", Version: "v1.0.1"}},
+					{Old: ModVersion{Path: "example.com/b", Version: "v1.2.0"}, New: ModVersion{Path: "../b"}},
+				},
+			},
+		},
+		{
+			// The unknown directive makes only the module, go and require
+			// directives usable.
+			name: "unknown directive",
+			contents: `module example.com/m
+
+require example.com/a v1.0.0
+
+replace example.com/a => ../a
+
+future v1.0.0
+`,
+			want: &ModFile{
+				ModulePath: "example.com/m",
+				Require:    []*Requirement{{ModulePath: "example.com/a", Version: "v1.0.0"}},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseModFile([]byte(test.contents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := ParseModFile([]byte("module (")); err == nil {
+		t.Error("got nil error for a malformed go.mod file")
+	}
+}
+
+func TestReplaceDirectives(t *testing.T) {
+	mf, err := ParseModFile([]byte(`module example.com/m
+
+replace (
+	example.com/a => example.com/fork v1.0.1
+	example.com/b v1.2.0 => ../b
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Replace{
+		{Old: "example.com/a", New: "example.com/fork", NewVersion: "v1.0.1"},
+		{Old: "example.com/b", OldVersion: "v1.2.0", New: "../b"},
+	}
+	if diff := cmp.Diff(want, mf.ReplaceDirectives()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffModFiles(t *testing.T) {
+	from := &ModFile{Require: []*Requirement{
+		{ModulePath: "example.com/bumped", Version: "v1.0.0"},
+		{ModulePath: "example.com/removed", Version: "v1.0.0"},
+		{ModulePath: "example.com/same", Version: "v1.0.0"},
+		{ModulePath: "example.com/direct", Version: "v1.0.0", Indirect: true},
+	}}
+	to := &ModFile{Require: []*Requirement{
+		{ModulePath: "example.com/same", Version: "v1.0.0"},
+		{ModulePath: "example.com/direct", Version: "v1.0.0"},
+		{ModulePath: "example.com/bumped", Version: "v1.2.0"},
+		{ModulePath: "example.com/added", Version: "v0.1.0"},
+	}}
+	want := &ModDiff{
+		Added:   []*Requirement{{ModulePath: "example.com/added", Version: "v0.1.0"}},
+		Removed: []*Requirement{{ModulePath: "example.com/removed", Version: "v1.0.0"}},
+		Changed: []*RequirementChange{
+			{
+				ModulePath: "example.com/bumped",
+				From:       &Requirement{ModulePath: "example.com/bumped", Version: "v1.0.0"},
+				To:         &Requirement{ModulePath: "example.com/bumped", Version: "v1.2.0"},
+			},
+			{
+				ModulePath: "example.com/direct",
+				From:       &Requirement{ModulePath: "example.com/direct", Version: "v1.0.0", Indirect: true},
+				To:         &Requirement{ModulePath: "example.com/direct", Version: "v1.0.0"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, DiffModFiles(from, to)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := DiffModFiles(from, from); got.Added != nil || got.Removed != nil || got.Changed != nil {
+		t.Errorf("DiffModFiles(from, from) = %+v, want empty diff", got)
+	}
+}
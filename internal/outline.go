@@ -0,0 +1,100 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "sort"
+
+// An Outline is the table of contents of the documentation of a package. It
+// has the structure of the index of the documentation HTML, and its IDs are
+// the IDs of the corresponding elements of that HTML.
+type Outline struct {
+	// Sections are the sections of the documentation that have entries, in
+	// the order Constants, Variables, Functions, Types.
+	Sections []*OutlineSection
+}
+
+// An OutlineSection is a section of an Outline.
+type OutlineSection struct {
+	// Title is the heading of the section, such as "Functions".
+	Title string
+	// ID is the ID of the heading of the section, such as "pkg-constants".
+	// It is empty for the Functions and Types sections, which have no
+	// heading of their own in the documentation HTML.
+	ID string
+	// Entries are the symbols in the section, sorted by name. The Constants
+	// and Variables sections have no entries, since the documentation HTML
+	// has no element for individual constants and variables.
+	Entries []*OutlineEntry
+}
+
+// An OutlineEntry is a symbol in an Outline.
+type OutlineEntry struct {
+	// Name is the name of the symbol, as in Symbol.Name.
+	Name string
+	// ID is the ID of the element that documents the symbol. It is the same
+	// as Name.
+	ID       string
+	Synopsis string
+	// Children are, for a type, the functions that return it and then its
+	// methods, each sorted by name.
+	Children []*OutlineEntry
+}
+
+// BuildOutline returns the Outline of the documentation of a package with the
+// given exported symbols. Methods of types that are not among symbols are
+// omitted.
+func BuildOutline(symbols []*Symbol) *Outline {
+	var (
+		hasConsts, hasVars bool
+		funcs, types       []*OutlineEntry
+		typeByName         = map[string]*OutlineEntry{}
+		typeFuncs          = map[string][]*OutlineEntry{}
+		methods            = map[string][]*OutlineEntry{}
+	)
+	for _, s := range symbols {
+		e := &OutlineEntry{Name: s.Name, ID: s.Name, Synopsis: s.Synopsis}
+		switch s.Kind {
+		case SymbolKindConstant:
+			// Constants associated with a type are documented with the type.
+			hasConsts = hasConsts || s.ParentName == ""
+		case SymbolKindVariable:
+			hasVars = hasVars || s.ParentName == ""
+		case SymbolKindFunction:
+			if s.ParentName == "" {
+				funcs = append(funcs, e)
+			} else {
+				typeFuncs[s.ParentName] = append(typeFuncs[s.ParentName], e)
+			}
+		case SymbolKindType:
+			types = append(types, e)
+			typeByName[s.Name] = e
+		case SymbolKindMethod:
+			methods[s.ParentName] = append(methods[s.ParentName], e)
+		}
+	}
+	for name, t := range typeByName {
+		t.Children = append(sortedEntries(typeFuncs[name]), sortedEntries(methods[name])...)
+	}
+
+	o := &Outline{}
+	if hasConsts {
+		o.Sections = append(o.Sections, &OutlineSection{Title: "Constants", ID: "pkg-constants"})
+	}
+	if hasVars {
+		o.Sections = append(o.Sections, &OutlineSection{Title: "Variables", ID: "pkg-variables"})
+	}
+	if len(funcs) > 0 {
+		o.Sections = append(o.Sections, &OutlineSection{Title: "Functions", Entries: sortedEntries(funcs)})
+	}
+	if len(types) > 0 {
+		o.Sections = append(o.Sections, &OutlineSection{Title: "Types", Entries: sortedEntries(types)})
+	}
+	return o
+}
+
+func sortedEntries(es []*OutlineEntry) []*OutlineEntry {
+	sort.Slice(es, func(i, j int) bool { return es[i].Name < es[j].Name })
+	return es
+}
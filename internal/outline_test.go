@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildOutline(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		symbols []*Symbol
+		want    *Outline
+	}{
+		{"no symbols", nil, &Outline{}},
+		{
+			"all sections",
+			[]*Symbol{
+				{Name: "A", Kind: SymbolKindConstant, Synopsis: "const A"},
+				{Name: "F", Kind: SymbolKindFunction, Synopsis: "func F()"},
+				{Name: "NewT", Kind: SymbolKindFunction, Synopsis: "func NewT() *T", ParentName: "T"},
+				{Name: "T", Kind: SymbolKindType, Synopsis: "type T struct"},
+				{Name: "T.B", Kind: SymbolKindMethod, Synopsis: "func (T) B()", ParentName: "T"},
+				{Name: "T.A", Kind: SymbolKindMethod, Synopsis: "func (T) A()", ParentName: "T"},
+				{Name: "V", Kind: SymbolKindVariable, Synopsis: "var V"},
+			},
+			&Outline{Sections: []*OutlineSection{
+				{Title: "Constants", ID: "pkg-constants"},
+				{Title: "Variables", ID: "pkg-variables"},
+				{Title: "Functions", Entries: []*OutlineEntry{
+					{Name: "F", ID: "F", Synopsis: "func F()"},
+				}},
+				{Title: "Types", Entries: []*OutlineEntry{
+					{Name: "T", ID: "T", Synopsis: "type T struct", Children: []*OutlineEntry{
+						{Name: "NewT", ID: "NewT", Synopsis: "func NewT() *T"},
+						{Name: "T.A", ID: "T.A", Synopsis: "func (T) A()"},
+						{Name: "T.B", ID: "T.B", Synopsis: "func (T) B()"},
+					}},
+				}},
+			}},
+		},
+		{
+			// A constant of a type is documented with the type, so there is
+			// no Constants section.
+			"type constant",
+			[]*Symbol{
+				{Name: "Red", Kind: SymbolKindConstant, Synopsis: "const Red", ParentName: "Color"},
+				{Name: "Color", Kind: SymbolKindType, Synopsis: "type Color int"},
+			},
+			&Outline{Sections: []*OutlineSection{
+				{Title: "Types", Entries: []*OutlineEntry{
+					{Name: "Color", ID: "Color", Synopsis: "type Color int"},
+				}},
+			}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := BuildOutline(test.symbols)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
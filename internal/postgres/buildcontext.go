@@ -0,0 +1,18 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+
+	"golang.org/x/pkgsite/internal"
+)
+
+// SupportedBuildContexts returns internal.BuildContexts, the build contexts
+// that the worker generates documentation for. They are not stored in the
+// database, since the worker uses the same fixed list for every module.
+func (db *DB) SupportedBuildContexts(ctx context.Context) ([]internal.BuildContext, error) {
+	return append([]internal.BuildContext{}, internal.BuildContexts...), nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A StaleVersion is a module version that was last processed by an older app
+// version, along with the popularity of its module.
+type StaleVersion struct {
+	ModulePath string
+	Version    string
+	// ImportedByCount is the largest imported-by count of a package of the
+	// module in search_documents when the campaign started, or zero if the
+	// module had none.
+	ImportedByCount int
+}
+
+// staleStatuses are the statuses of the module versions that
+// GetStaleVersions returns: those that UpdateModuleVersionStatesForReprocessing
+// would mark for reprocessing, and those it already has.
+var staleStatuses = func() []int {
+	var ss []int
+	for _, s := range []int{
+		http.StatusOK,
+		derrors.ToHTTPStatus(derrors.HasIncompletePackages),
+		derrors.ToHTTPStatus(derrors.BadModule),
+		derrors.ToHTTPStatus(derrors.AlternativeModule),
+	} {
+		ss = append(ss, s, derrors.ToReprocessStatus(s))
+	}
+	return ss
+}()
+
+// GetStaleVersions returns up to limit module versions in the
+// module_version_states table that were last processed by an app version
+// before appVersion, ordered by descending popularity of their module, then
+// by module path and version. If after is non-nil, only the versions that come
+// after it in that order are returned, so that a caller can page through them.
+//
+// Popularity is read from the snapshot taken by StartCampaign for the named
+// campaign, so the order does not change while the campaign pages through
+// the versions, even if the popularity of a module does.
+func (db *DB) GetStaleVersions(ctx context.Context, campaign, appVersion string, after *StaleVersion, limit int) (_ []*StaleVersion, err error) {
+	defer derrors.Wrap(&err, "DB.GetStaleVersions(ctx, %q, %q, %d)", campaign, appVersion, limit)
+
+	args := []interface{}{appVersion, pq.Array(staleStatuses), limit, campaign}
+	var afterClause string
+	if after != nil {
+		afterClause = `
+		WHERE imported_by_count < $5
+		OR (imported_by_count = $5 AND (module_path, version) > ($6, $7))`
+		args = append(args, after.ImportedByCount, after.ModulePath, after.Version)
+	}
+	query := fmt.Sprintf(`
+		SELECT module_path, version, imported_by_count
+		FROM (
+			SELECT s.module_path, s.version, COALESCE(p.imported_by_count, 0) AS imported_by_count
+			FROM module_version_states s
+			LEFT JOIN reprocessing_campaign_modules p
+			ON p.module_path = s.module_path
+			AND p.campaign_name = $4
+			WHERE s.app_version < $1
+			AND s.status = ANY($2)
+		) v
+		%s
+		ORDER BY imported_by_count DESC, module_path, version
+		LIMIT $3;`, afterClause)
+	var vs []*StaleVersion
+	collect := func(rows *sql.Rows) error {
+		var v StaleVersion
+		if err := rows.Scan(&v.ModulePath, &v.Version, &v.ImportedByCount); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		vs = append(vs, &v)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, args...); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// CampaignState is the progress of a reprocessing campaign, as stored in the
+// reprocessing_campaigns table.
+type CampaignState struct {
+	Name       string
+	AppVersion string
+	// Cursor is the last module version that the campaign enqueued or
+	// skipped, or nil if there is none yet.
+	Cursor      *StaleVersion
+	NumEnqueued int
+	NumSkipped  int
+	Done        bool
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetCampaignState returns the state of the reprocessing campaign with the
+// given name. If there is no such campaign, an error wrapping
+// derrors.NotFound is returned.
+func (db *DB) GetCampaignState(ctx context.Context, name string) (_ *CampaignState, err error) {
+	defer derrors.Wrap(&err, "DB.GetCampaignState(ctx, %q)", name)
+
+	s := CampaignState{Name: name}
+	var cursor StaleVersion
+	err = db.db.QueryRow(ctx, `
+		SELECT
+			app_version,
+			cursor_imported_by_count,
+			cursor_module_path,
+			cursor_version,
+			num_enqueued,
+			num_skipped,
+			done,
+			started_at,
+			updated_at
+		FROM reprocessing_campaigns
+		WHERE name = $1;`, name).Scan(
+		&s.AppVersion, &cursor.ImportedByCount, &cursor.ModulePath, &cursor.Version,
+		&s.NumEnqueued, &s.NumSkipped, &s.Done, &s.StartedAt, &s.UpdatedAt)
+	switch err {
+	case sql.ErrNoRows:
+		return nil, fmt.Errorf("campaign %q: %w", name, derrors.NotFound)
+	case nil:
+		if cursor.ModulePath != "" {
+			s.Cursor = &cursor
+		}
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+}
+
+// StartCampaign records s as the state of the reprocessing campaign s.Name,
+// replacing any earlier campaign with that name, and snapshots the popularity
+// of every module for GetStaleVersions to order by.
+func (db *DB) StartCampaign(ctx context.Context, s *CampaignState) (err error) {
+	defer derrors.Wrap(&err, "DB.StartCampaign(ctx, %q)", s.Name)
+
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if err := saveCampaignState(ctx, tx, s); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM reprocessing_campaign_modules
+			WHERE campaign_name = $1;`, s.Name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO reprocessing_campaign_modules (campaign_name, module_path, imported_by_count)
+			SELECT $1, module_path, MAX(imported_by_count)
+			FROM search_documents
+			GROUP BY module_path;`, s.Name)
+		return err
+	})
+}
+
+// SaveCampaignState inserts or replaces the state of the reprocessing
+// campaign s.Name. Its UpdatedAt is ignored; the row records the current
+// time instead.
+func (db *DB) SaveCampaignState(ctx context.Context, s *CampaignState) (err error) {
+	defer derrors.Wrap(&err, "DB.SaveCampaignState(ctx, %q)", s.Name)
+	return saveCampaignState(ctx, db.db, s)
+}
+
+func saveCampaignState(ctx context.Context, ddb *database.DB, s *CampaignState) error {
+	var cursor StaleVersion
+	if s.Cursor != nil {
+		cursor = *s.Cursor
+	}
+	_, err := ddb.Exec(ctx, `
+		INSERT INTO reprocessing_campaigns (
+			name,
+			app_version,
+			cursor_imported_by_count,
+			cursor_module_path,
+			cursor_version,
+			num_enqueued,
+			num_skipped,
+			done,
+			started_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (name) DO UPDATE SET
+			app_version = excluded.app_version,
+			cursor_imported_by_count = excluded.cursor_imported_by_count,
+			cursor_module_path = excluded.cursor_module_path,
+			cursor_version = excluded.cursor_version,
+			num_enqueued = excluded.num_enqueued,
+			num_skipped = excluded.num_skipped,
+			done = excluded.done,
+			started_at = excluded.started_at,
+			updated_at = CURRENT_TIMESTAMP;`,
+		s.Name, s.AppVersion, cursor.ImportedByCount, cursor.ModulePath, cursor.Version,
+		s.NumEnqueued, s.NumSkipped, s.Done, s.StartedAt)
+	return err
+}
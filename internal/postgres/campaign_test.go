@@ -0,0 +1,122 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetStaleVersions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []struct {
+		modulePath, version, appVersion string
+		status, importedBy              int
+	}{
+		{"a.com", "v1.0.0", "20200101t000000", http.StatusOK, 10},
+		{"a.com", "v1.1.0", "20200101t000000", http.StatusOK, 10},
+		{"b.com", "v1.0.0", "20200101t000000", derrors.ToReprocessStatus(http.StatusOK), 5},
+		{"c.com", "v1.0.0", "20200101t000000", http.StatusOK, 0},
+		// Processed by the current app version.
+		{"d.com", "v1.0.0", "20200601t000000", http.StatusOK, 20},
+		// Failed.
+		{"e.com", "v1.0.0", "20200101t000000", http.StatusInternalServerError, 20},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(m.modulePath, m.version, "p")); err != nil {
+			t.Fatal(err)
+		}
+		if err := testDB.UpsertModuleVersionState(ctx, m.modulePath, m.version, m.appVersion, time.Now(), m.status, m.modulePath, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `
+			UPDATE search_documents SET imported_by_count = $2 WHERE module_path = $1;`,
+			m.modulePath, m.importedBy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := testDB.StartCampaign(ctx, &CampaignState{Name: "c", AppVersion: "20200601t000000", StartedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	var got []*StaleVersion
+	var after *StaleVersion
+	for {
+		vs, err := testDB.GetStaleVersions(ctx, "c", "20200601t000000", after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vs) == 0 {
+			break
+		}
+		got = append(got, vs...)
+		after = vs[len(vs)-1]
+		// A module that becomes more popular than the cursor after the
+		// campaign started is still returned, in its original place.
+		if _, err := testDB.db.Exec(ctx, `
+			UPDATE search_documents SET imported_by_count = 100 WHERE module_path = 'c.com';`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []*StaleVersion{
+		{ModulePath: "a.com", Version: "v1.0.0", ImportedByCount: 10},
+		{ModulePath: "a.com", Version: "v1.1.0", ImportedByCount: 10},
+		{ModulePath: "b.com", Version: "v1.0.0", ImportedByCount: 5},
+		{ModulePath: "c.com", Version: "v1.0.0", ImportedByCount: 0},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCampaignState(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	if _, err := testDB.GetCampaignState(ctx, "c"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want %v", err, derrors.NotFound)
+	}
+
+	started := time.Now().Truncate(time.Second)
+	for _, want := range []*CampaignState{
+		{Name: "c", AppVersion: "20200601t000000", StartedAt: started},
+		{
+			Name:        "c",
+			AppVersion:  "20200601t000000",
+			Cursor:      &StaleVersion{ModulePath: "a.com", Version: "v1.0.0", ImportedByCount: 10},
+			NumEnqueued: 3,
+			NumSkipped:  1,
+			Done:        true,
+			StartedAt:   started,
+		},
+	} {
+		if err := testDB.SaveCampaignState(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := testDB.GetCampaignState(ctx, "c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UpdatedAt.IsZero() {
+			t.Error("got zero UpdatedAt")
+		}
+		if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(CampaignState{}, "UpdatedAt")); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
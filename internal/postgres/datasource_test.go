@@ -0,0 +1,50 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/datasourcetest"
+)
+
+func TestDataSource_Conformance(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, datasourcetest.Modules())
+	defer teardownProxy()
+	defer ResetTestDB(testDB, t)
+
+	// seed stores the modules as the worker would after fetching them.
+	seed := func(internal.DataSource) {
+		ResetTestDB(testDB, t)
+		setIndexStatsLastFetched(time.Time{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true,
+		}))
+		for _, m := range datasourcetest.Modules() {
+			res := fetch.FetchModule(ctx, m.ModulePath, m.Version, client, source.NewClientForTesting())
+			if res.Error != nil {
+				t.Fatal(res.Error)
+			}
+			if err := testDB.InsertModule(ctx, res.Module); err != nil {
+				t.Fatal(err)
+			}
+			if err := testDB.UpsertModuleVersionState(ctx, res.ModulePath, res.ResolvedVersion, "",
+				time.Time{}, res.Status, res.GoModPath, nil, res.PackageVersionStates); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	datasourcetest.RunConformanceTests(t, func() internal.DataSource { return testDB }, seed)
+}
@@ -10,22 +10,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
+	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/version"
 )
 
 // GetPackagesInModule returns packages contained in the module version
 // specified by modulePath and version. The returned packages will be sorted
-// by their package path.
-func (db *DB) GetPackagesInModule(ctx context.Context, modulePath, version string) (_ []*internal.LegacyPackage, err error) {
+// by their package path. Packages inside a vendor directory are omitted
+// unless opts.IncludeVendored is set.
+func (db *DB) GetPackagesInModule(ctx context.Context, modulePath, version string, opts internal.PathOptions) (_ []*internal.LegacyPackage, err error) {
 	query := `SELECT
 		path,
 		name,
@@ -42,10 +47,21 @@ func (db *DB) GetPackagesInModule(ctx context.Context, modulePath, version strin
 	WHERE
 		module_path = $1
 		AND version = $2
+		AND ($3 OR path !~ ` + vendoredPathPattern + `)
 	ORDER BY path;`
 
 	var packages []*internal.LegacyPackage
-	collect := func(rows *sql.Rows) error {
+	if err := db.db.RunQuery(ctx, query, collectLegacyPackages(&packages), modulePath, version, opts.IncludeVendored); err != nil {
+		return nil, fmt.Errorf("DB.GetPackagesInModule(ctx, %q, %q): %w", modulePath, version, err)
+	}
+	return packages, nil
+}
+
+// collectLegacyPackages returns a function for RunQuery that appends to
+// *packages the package in each row of the columns selected by
+// GetPackagesInModule.
+func collectLegacyPackages(packages *[]*internal.LegacyPackage) func(*sql.Rows) error {
+	return func(rows *sql.Rows) error {
 		var (
 			p                          internal.LegacyPackage
 			licenseTypes, licensePaths []string
@@ -60,16 +76,122 @@ func (db *DB) GetPackagesInModule(ctx context.Context, modulePath, version strin
 			return err
 		}
 		p.Licenses = lics
-		packages = append(packages, &p)
+		*packages = append(*packages, &p)
 		return nil
 	}
+}
 
-	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
-		return nil, fmt.Errorf("DB.GetPackagesInModule(ctx, %q, %q): %w", modulePath, version, err)
+// vendoredPathPattern is a quoted POSIX regular expression that matches the
+// paths for which internal.IsVendored is true.
+const vendoredPathPattern = `'(^|/)vendor/'`
+
+// GetImportablePackages returns the packages in the module version specified
+// by modulePath and version for which internal.IsImportable is true: those
+// that are not named main, and have no path element equal to "internal" or
+// "testdata". The returned packages are sorted by package path. As with
+// GetPackagesInModule, an unknown module version has no packages.
+func (db *DB) GetImportablePackages(ctx context.Context, modulePath, version string) (_ []*internal.LegacyPackage, err error) {
+	defer derrors.Wrap(&err, "DB.GetImportablePackages(ctx, %q, %q)", modulePath, version)
+	pkgs, err := db.GetPackagesInModule(ctx, modulePath, version, internal.PathOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return importablePackages(pkgs), nil
+}
+
+// importablePackages returns the packages in pkgs that can be imported from
+// other modules, in the same order.
+func importablePackages(pkgs []*internal.LegacyPackage) []*internal.LegacyPackage {
+	var importable []*internal.LegacyPackage
+	for _, p := range pkgs {
+		if internal.IsImportable(p.Path, p.Name) {
+			importable = append(importable, p)
+		}
+	}
+	return importable
+}
+
+// GetSiblingPackages returns the packages of the module version specified by
+// modulePath and version, other than pkgPath, whose immediate parent directory
+// is the same as that of pkgPath, sorted by path. Only the fields read by
+// GetPackagesInModule are populated. As with GetPackagesInModule, an unknown
+// module version has no packages.
+func (db *DB) GetSiblingPackages(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.LegacyPackage, err error) {
+	defer derrors.Wrap(&err, "DB.GetSiblingPackages(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	if pkgPath == "" || modulePath == "" || version == "" {
+		return nil, fmt.Errorf("pkgPath, modulePath and version must all be non-empty: %w", derrors.InvalidArgument)
+	}
+	// The siblings of pkgPath are the paths that begin with the prefix and
+	// have no slash after it. The prefix is compared with left rather than
+	// LIKE, so that characters such as "_" in it are not treated as
+	// wildcards.
+	var prefix string
+	if dir := path.Dir(pkgPath); dir != "." {
+		prefix = dir + "/"
+	}
+	query := `
+		SELECT
+			path,
+			name,
+			synopsis,
+			v1_path,
+			license_types,
+			license_paths,
+			redistributable,
+			documentation,
+			goos,
+			goarch
+		FROM packages
+		WHERE
+			module_path = $1
+			AND version = $2
+			AND path <> $3
+			AND left(path, length($4)) = $4
+			AND strpos(substr(path, length($4) + 1), '/') = 0
+		ORDER BY path;`
+	var packages []*internal.LegacyPackage
+	if err := db.db.RunQuery(ctx, query, collectLegacyPackages(&packages), modulePath, version, pkgPath, prefix); err != nil {
+		return nil, err
 	}
 	return packages, nil
 }
 
+// GetPrimaryPackage returns the package of the module version specified by
+// modulePath and version that a page for the module should show by default:
+// the package whose path is modulePath, or if there is none, the package with
+// the fewest path elements, with ties broken by path. Only that package is
+// read from the database.
+//
+// If the module version does not exist or has no packages, an error wrapping
+// derrors.NotFound is returned.
+func (db *DB) GetPrimaryPackage(ctx context.Context, modulePath, version string) (_ *internal.LegacyPackage, err error) {
+	defer derrors.Wrap(&err, "DB.GetPrimaryPackage(ctx, %q, %q)", modulePath, version)
+
+	var pkgPath string
+	err = db.db.QueryRow(ctx, `
+		SELECT path
+		FROM packages
+		WHERE module_path = $1 AND version = $2
+		ORDER BY
+			path = $1 DESC,
+			length(path) - length(replace(path, '/', '')),
+			path
+		LIMIT 1;`, modulePath, version).Scan(&pkgPath)
+	switch err {
+	case sql.ErrNoRows:
+		return nil, fmt.Errorf("no packages in %s@%s: %w", modulePath, version, derrors.NotFound)
+	case nil:
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	vp, err := db.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return &vp.LegacyPackage, nil
+}
+
 // GetTaggedVersionsForPackageSeries returns a list of tagged versions sorted in
 // descending semver order. This list includes tagged versions of packages that
 // have the same v1path.
@@ -132,6 +254,7 @@ func getPackageVersions(ctx context.Context, db *DB, pkgPath string, versionType
 		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime); err != nil {
 			return nil, fmt.Errorf("row.Scan(): %v", err)
 		}
+		mi.IsPrerelease = semver.Prerelease(mi.Version) != ""
 		versionHistory = append(versionHistory, &mi)
 	}
 
@@ -195,6 +318,7 @@ func getModuleVersions(ctx context.Context, db *DB, modulePath string, versionTy
 		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime); err != nil {
 			return err
 		}
+		mi.IsPrerelease = semver.Prerelease(mi.Version) != ""
 		vinfos = append(vinfos, &mi)
 		return nil
 	}
@@ -204,6 +328,40 @@ func getModuleVersions(ctx context.Context, db *DB, modulePath string, versionTy
 	return vinfos, nil
 }
 
+// GetVersionsMatching returns the versions of the module with path modulePath
+// that satisfy constraint, sorted in descending semver order. The constraint
+// is evaluated over all stored versions of the module.
+func (db *DB) GetVersionsMatching(ctx context.Context, modulePath, constraint string) (_ []*internal.LegacyModuleInfo, err error) {
+	defer derrors.Wrap(&err, "DB.GetVersionsMatching(ctx, %q, %q)", modulePath, constraint)
+
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, derrors.InvalidArgument)
+	}
+	query := `
+		SELECT module_path, version, commit_time
+		FROM modules
+		WHERE module_path = $1
+		ORDER BY sort_version DESC`
+	var vinfos []*internal.LegacyModuleInfo
+	collect := func(rows *sql.Rows) error {
+		var mi internal.LegacyModuleInfo
+		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		if !c.Match(mi.Version) {
+			return nil
+		}
+		mi.IsPrerelease = semver.Prerelease(mi.Version) != ""
+		vinfos = append(vinfos, &mi)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath); err != nil {
+		return nil, err
+	}
+	return vinfos, nil
+}
+
 // GetImports fetches and returns all of the imports for the package with
 // pkgPath, modulePath and version.
 //
@@ -241,6 +399,64 @@ func (db *DB) GetImports(ctx context.Context, pkgPath, modulePath, version strin
 	return imports, nil
 }
 
+// GetImportsGrouped returns the imports of the package specified by pkgPath,
+// modulePath and version, split into standard library imports and all other
+// imports. Each group is sorted, and is empty rather than nil when the package
+// has no imports of that kind.
+func (db *DB) GetImportsGrouped(ctx context.Context, pkgPath, modulePath, version string) (std, external []string, err error) {
+	defer derrors.Wrap(&err, "DB.GetImportsGrouped(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+	imports, err := db.GetImports(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	std, external = []string{}, []string{}
+	for _, p := range imports {
+		if stdlib.Contains(p) {
+			std = append(std, p)
+		} else {
+			external = append(external, p)
+		}
+	}
+	return std, external, nil
+}
+
+// GetInternalImports returns the imports of the package specified by pkgPath,
+// modulePath and version whose paths are modulePath or begin with modulePath
+// followed by a slash, sorted by path. Imports of packages in nested modules
+// are included, since their paths have that prefix.
+func (db *DB) GetInternalImports(ctx context.Context, pkgPath, modulePath, version string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "DB.GetInternalImports(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	if pkgPath == "" || version == "" || modulePath == "" {
+		return nil, fmt.Errorf("pkgPath, modulePath and version must all be non-empty: %w", derrors.InvalidArgument)
+	}
+	// The prefix is compared with left rather than LIKE, so that characters
+	// such as "_" in modulePath are not treated as wildcards.
+	query := `
+		SELECT to_path
+		FROM imports
+		WHERE
+			from_path = $1
+			AND from_version = $2
+			AND from_module_path = $3
+			AND (to_path = $3 OR left(to_path, length($3) + 1) = $3 || '/')
+		ORDER BY
+			to_path;`
+	imports := []string{}
+	collect := func(rows *sql.Rows) error {
+		var toPath string
+		if err := rows.Scan(&toPath); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		imports = append(imports, toPath)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pkgPath, version, modulePath); err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
+
 // GetImportedBy fetches and returns all of the packages that import the
 // package with path.
 // The returned error may be checked with derrors.IsInvalidArgument to
@@ -305,6 +521,72 @@ func (db *DB) GetModuleLicenses(ctx context.Context, modulePath, version string)
 	return collectLicenses(rows)
 }
 
+// GetLicenseBadge returns the internal.Badge for the module version specified
+// by modulePath and version, built from its top-level licenses and whether it
+// is redistributable.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetLicenseBadge(ctx context.Context, modulePath, version string) (_ *internal.Badge, err error) {
+	defer derrors.Wrap(&err, "DB.GetLicenseBadge(ctx, %q, %q)", modulePath, version)
+
+	mi, err := db.GetModuleInfo(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	lics, err := db.GetModuleLicenses(ctx, mi.ModulePath, mi.Version)
+	if err != nil {
+		return nil, err
+	}
+	var metas []*licenses.Metadata
+	for _, l := range lics {
+		metas = append(metas, l.Metadata)
+	}
+	return internal.NewLicenseBadge(metas, mi.IsRedistributable), nil
+}
+
+// GetLicenseFiles returns all license files in the module zip for the given
+// module path and version, including those in subdirectories. Unlike
+// GetModuleLicenses, it is intended for displaying the full text of each
+// file, so callers that only need license types should prefer
+// GetModuleLicenses or GetPackageLicenses.
+// It returns an InvalidArgument error if the module path or version is invalid.
+func (db *DB) GetLicenseFiles(ctx context.Context, modulePath, version string) (_ []*licenses.License, err error) {
+	defer derrors.Wrap(&err, "GetLicenseFiles(ctx, %q, %q)", modulePath, version)
+
+	if modulePath == "" || version == "" {
+		return nil, fmt.Errorf("neither modulePath nor version can be empty: %w", derrors.InvalidArgument)
+	}
+	query := `
+	SELECT
+		types, file_path, contents, coverage
+	FROM
+		licenses
+	WHERE
+		module_path = $1 AND version = $2
+    `
+	rows, err := db.db.Query(ctx, query, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collectLicenses(rows)
+}
+
+// GetUndetectedLicenseFiles returns the license files in the module zip for
+// the given module path and version, including those in subdirectories,
+// whose types could not be detected, as stored in the licenses table.
+// It returns an InvalidArgument error if the module path or version is invalid.
+func (db *DB) GetUndetectedLicenseFiles(ctx context.Context, modulePath, version string) (_ []internal.LicenseFile, err error) {
+	defer derrors.Wrap(&err, "GetUndetectedLicenseFiles(ctx, %q, %q)", modulePath, version)
+
+	lics, err := db.GetLicenseFiles(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return internal.UndetectedLicenseFiles(lics), nil
+}
+
 // GetPackageLicenses returns all licenses associated with the given package path and
 // version.
 // It returns an InvalidArgument error if the module path or version is invalid.
@@ -474,6 +756,456 @@ func (db *DB) GetModuleInfo(ctx context.Context, modulePath string, version stri
 	return &mi, nil
 }
 
+// GetModuleReadmeForPackage returns the README of the module version that
+// contains the package pkgPath, read from the modules table. The module
+// version is specified by modulePath and version; if modulePath is
+// internal.UnknownModulePath, the longest module path with a package at
+// pkgPath in version is used.
+//
+// If the package does not exist, or the module version has no README, an error
+// wrapping derrors.NotFound is returned.
+func (db *DB) GetModuleReadmeForPackage(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.Readme, err error) {
+	defer derrors.Wrap(&err, "DB.GetModuleReadmeForPackage(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	if pkgPath == "" || modulePath == "" || version == "" {
+		return nil, fmt.Errorf("none of pkgPath, modulePath, or version can be empty: %w", derrors.InvalidArgument)
+	}
+	query := `
+		SELECT m.readme_file_path, m.readme_contents
+		FROM packages p
+		INNER JOIN modules m
+		ON p.module_path = m.module_path AND p.version = m.version
+		WHERE
+			p.path = $1
+			AND p.version = $2
+			AND ($3 = '' OR p.module_path = $3)
+		ORDER BY length(p.module_path) DESC
+		LIMIT 1;`
+	inModulePath := modulePath
+	if modulePath == internal.UnknownModulePath {
+		inModulePath = ""
+	}
+	var readme internal.Readme
+	err = db.db.QueryRow(ctx, query, pkgPath, version, inModulePath).Scan(
+		database.NullIsEmpty(&readme.Filepath), database.NullIsEmpty(&readme.Contents))
+	switch err {
+	case sql.ErrNoRows:
+		return nil, fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
+	case nil:
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	if readme.Filepath == "" {
+		return nil, fmt.Errorf("README of module containing %s@%s: %w", pkgPath, version, derrors.NotFound)
+	}
+	return &readme, nil
+}
+
+// GetReadmeHash returns the hash, as computed by internal.Readme.Hash, of the
+// README of the module version specified by modulePath and version, read from
+// the modules table.
+//
+// If the module version does not exist or has no README, an error wrapping
+// derrors.NotFound is returned.
+func (db *DB) GetReadmeHash(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.GetReadmeHash(ctx, %q, %q)", modulePath, version)
+
+	var readme internal.Readme
+	err = db.db.QueryRow(ctx, `
+		SELECT readme_file_path, readme_contents
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version).Scan(
+		database.NullIsEmpty(&readme.Filepath), database.NullIsEmpty(&readme.Contents))
+	switch err {
+	case sql.ErrNoRows:
+		return "", fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	case nil:
+	default:
+		return "", fmt.Errorf("row.Scan(): %v", err)
+	}
+	if readme.Filepath == "" {
+		return "", fmt.Errorf("README of %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return readme.Hash(), nil
+}
+
+// GetModuleByCommit returns the LegacyModuleInfo for the highest
+// pseudo-version of the module with path modulePath whose revision starts
+// with commitHash, as normalized by version.CommitHashPrefix.
+//
+// The returned error wraps derrors.InvalidArgument if commitHash is not a
+// valid commit hash prefix, and derrors.NotFound if no pseudo-version
+// matches.
+func (db *DB) GetModuleByCommit(ctx context.Context, modulePath, commitHash string) (_ *internal.LegacyModuleInfo, err error) {
+	defer derrors.Wrap(&err, "GetModuleByCommit(ctx, %q, %q)", modulePath, commitHash)
+
+	prefix, ok := version.CommitHashPrefix(commitHash)
+	if !ok {
+		return nil, fmt.Errorf("invalid commit hash %q: %w", commitHash, derrors.InvalidArgument)
+	}
+	query := `
+		SELECT version
+		FROM modules
+		WHERE
+			module_path = $1
+			AND version_type = 'pseudo'
+			AND substring(version from '-([0-9a-f]+)(\+incompatible)?$') LIKE $2 || '%'
+		ORDER BY sort_version DESC
+		LIMIT 1;`
+	var v string
+	if err := db.db.QueryRow(ctx, query, modulePath, prefix).Scan(&v); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("commit %s of module %s: %w", commitHash, modulePath, derrors.NotFound)
+		}
+		return nil, err
+	}
+	return db.GetModuleInfo(ctx, modulePath, v)
+}
+
+// GetModulesByOwner returns the latest version of each module whose source
+// repository is at host and belongs to owner, such as github.com and golang
+// for https://github.com/golang/tools. The owner is parsed from the repo URL
+// in the module's source info, so modules without source info never match.
+// As in GetModuleInfo with internal.LatestVersion, the latest version is the
+// highest release version if there is one. Modules are ranked by the highest
+// imported-by count of their packages, then by module path.
+//
+// The modules are found with the idx_modules_repo_owner expression index, so
+// the expression that computes the host and owner from the repo URL must
+// match the one in the index exactly.
+func (db *DB) GetModulesByOwner(ctx context.Context, host, owner string, limit, offset int) (_ []*internal.ModuleInfo, err error) {
+	defer derrors.Wrap(&err, "DB.GetModulesByOwner(ctx, %q, %q, %d, %d)", host, owner, limit, offset)
+
+	if host == "" || owner == "" {
+		return nil, fmt.Errorf("host and owner cannot be empty: %w", derrors.InvalidArgument)
+	}
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit and offset must be non-negative: %w", derrors.InvalidArgument)
+	}
+	query := `
+		SELECT
+			m.module_path,
+			m.version,
+			m.commit_time,
+			m.version_type,
+			m.source_info,
+			m.redistributable,
+			m.has_go_mod
+		FROM (
+			SELECT DISTINCT ON (module_path) *
+			FROM modules
+			WHERE lower(substring(source_info->>'RepoURL' from '^(?:[a-z]+://)?([^/]+/[^/]+)/')) = $1
+			ORDER BY
+				module_path,
+				version_type = 'release' DESC,
+				sort_version DESC
+		) m
+		LEFT JOIN LATERAL (
+			SELECT MAX(imported_by_count) AS imported_by_count
+			FROM search_documents
+			WHERE module_path = m.module_path
+		) s ON true
+		ORDER BY
+			COALESCE(s.imported_by_count, 0) DESC,
+			m.module_path
+		LIMIT $2
+		OFFSET $3;`
+
+	var modules []*internal.ModuleInfo
+	collect := func(rows *sql.Rows) error {
+		var (
+			mi       internal.ModuleInfo
+			hasGoMod sql.NullBool
+		)
+		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime, &mi.VersionType,
+			jsonbScanner{&mi.SourceInfo}, &mi.IsRedistributable, &hasGoMod); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		setHasGoMod(&mi, hasGoMod)
+		modules = append(modules, &mi)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, strings.ToLower(host+"/"+owner), limit, offset); err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// GetLatestVersions returns a map from each module path in modulePaths to its
+// latest version. As in GetModuleInfo with internal.LatestVersion, that is the
+// highest release version if there is one, and otherwise the highest
+// prerelease version. Module paths with no versions in the database are
+// absent from the map.
+func (db *DB) GetLatestVersions(ctx context.Context, modulePaths []string) (_ map[string]string, err error) {
+	defer derrors.Wrap(&err, "GetLatestVersions(ctx, %v)", modulePaths)
+
+	query := `
+		SELECT DISTINCT ON (module_path) module_path, version
+		FROM modules
+		WHERE module_path = ANY($1)
+		ORDER BY
+			module_path,
+			version_type = 'release' DESC,
+			sort_version DESC;`
+	latest := map[string]string{}
+	collect := func(rows *sql.Rows) error {
+		var modulePath, version string
+		if err := rows.Scan(&modulePath, &version); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		latest[modulePath] = version
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pq.Array(modulePaths)); err != nil {
+		return nil, err
+	}
+	return latest, nil
+}
+
+// GetFetchTime returns the time at which the module version specified by
+// modulePath and version was last successfully fetched and stored. This is
+// distinct from the commit time of the version.
+//
+// If the version has never been stored, an error wrapping derrors.NotFound
+// is returned.
+func (db *DB) GetFetchTime(ctx context.Context, modulePath, version string) (_ time.Time, err error) {
+	defer derrors.Wrap(&err, "GetFetchTime(ctx, %q, %q)", modulePath, version)
+
+	var fetchedAt time.Time
+	row := db.db.QueryRow(ctx, `
+		SELECT updated_at
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version)
+	if err := row.Scan(&fetchedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+		}
+		return time.Time{}, fmt.Errorf("row.Scan(): %v", err)
+	}
+	return fetchedAt, nil
+}
+
+// GetProcessingInfo returns the time at which the module version specified by
+// modulePath and version was last successfully fetched and stored, from the
+// modules table, and the app version of the worker that last processed it,
+// from the module_version_states table. FetcherVersion is empty if the module
+// version has no state.
+//
+// If the version has never been stored, an error wrapping derrors.NotFound
+// is returned.
+func (db *DB) GetProcessingInfo(ctx context.Context, modulePath, version string) (_ *internal.ProcessingInfo, err error) {
+	defer derrors.Wrap(&err, "GetProcessingInfo(ctx, %q, %q)", modulePath, version)
+
+	var (
+		info       internal.ProcessingInfo
+		appVersion sql.NullString
+	)
+	row := db.db.QueryRow(ctx, `
+		SELECT m.updated_at, s.app_version
+		FROM modules m
+		LEFT JOIN module_version_states s
+		ON s.module_path = m.module_path AND s.version = m.version
+		WHERE m.module_path = $1 AND m.version = $2;`, modulePath, version)
+	if err := row.Scan(&info.FetchedAt, &appVersion); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	info.FetcherVersion = appVersion.String
+	return &info, nil
+}
+
+// GetCommitSHA returns the full hash of the commit that the module version
+// specified by modulePath and version was built from, which is stored with its
+// source info.
+//
+// If the version has never been stored, or its commit hash was not reported by
+// the proxy, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetCommitSHA(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetCommitSHA(ctx, %q, %q)", modulePath, version)
+
+	var hash sql.NullString
+	row := db.db.QueryRow(ctx, `
+		SELECT source_info->>'CommitHash'
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version)
+	if err := row.Scan(&hash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+		}
+		return "", fmt.Errorf("row.Scan(): %v", err)
+	}
+	if hash.String == "" {
+		return "", fmt.Errorf("no commit hash for %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return hash.String, nil
+}
+
+// GetContentHash returns the hash of the data displayed for the module version
+// specified by modulePath and version, computed when it was fetched. It
+// changes when reprocessing the module version changes that data.
+//
+// If the version has never been stored, or was stored before content hashes
+// were computed, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetContentHash(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetContentHash(ctx, %q, %q)", modulePath, version)
+
+	var hash sql.NullString
+	row := db.db.QueryRow(ctx, `
+		SELECT content_hash
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version)
+	if err := row.Scan(&hash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+		}
+		return "", fmt.Errorf("row.Scan(): %v", err)
+	}
+	if !hash.Valid {
+		return "", fmt.Errorf("no content hash for %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return hash.String, nil
+}
+
+// GetFileStats returns statistics about the files in the module version
+// specified by modulePath and version, computed when it was fetched.
+//
+// If the version has never been stored, or was stored before file statistics
+// were computed, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetFileStats(ctx context.Context, modulePath, version string) (_ *internal.FileStats, err error) {
+	defer derrors.Wrap(&err, "GetFileStats(ctx, %q, %q)", modulePath, version)
+
+	var fs *internal.FileStats
+	row := db.db.QueryRow(ctx, `
+		SELECT file_stats
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version)
+	if err := row.Scan(jsonbScanner{&fs}); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	if fs == nil {
+		return nil, fmt.Errorf("file stats for %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return fs, nil
+}
+
+// GetDocSizes returns the size in bytes of the documentation HTML stored in
+// the packages table for each package in the module version specified by
+// modulePath and version, keyed by package path. The sizes are computed by
+// the database, so the documentation itself is not read.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetDocSizes(ctx context.Context, modulePath, version string) (_ map[string]int, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocSizes(ctx, %q, %q)", modulePath, version)
+
+	query := `
+		SELECT p.path, COALESCE(octet_length(p.documentation), 0)
+		FROM modules m
+		LEFT JOIN packages p
+		ON p.module_path = m.module_path AND p.version = m.version
+		WHERE m.module_path = $1 AND m.version = $2;`
+	var (
+		found bool
+		sizes = map[string]int{}
+	)
+	collect := func(rows *sql.Rows) error {
+		var (
+			path sql.NullString
+			size int
+		)
+		if err := rows.Scan(&path, &size); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		found = true
+		if path.Valid {
+			sizes[path.String] = size
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return sizes, nil
+}
+
+// GetModuleDocCoverage returns the number of packages of the module version
+// specified by modulePath and version with and without a package doc comment,
+// counted by the database from the synopses in the packages table.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetModuleDocCoverage(ctx context.Context, modulePath, version string) (_ *internal.ModuleDocCoverage, err error) {
+	defer derrors.Wrap(&err, "DB.GetModuleDocCoverage(ctx, %q, %q)", modulePath, version)
+
+	// Grouping by module yields no row, rather than zero counts, if the
+	// module version does not exist.
+	var c internal.ModuleDocCoverage
+	row := db.db.QueryRow(ctx, `
+		SELECT
+			COUNT(p.path) FILTER (WHERE p.synopsis != ''),
+			COUNT(p.path) FILTER (WHERE COALESCE(p.synopsis, '') = '')
+		FROM modules m
+		LEFT JOIN packages p
+		ON p.module_path = m.module_path AND p.version = m.version
+		WHERE m.module_path = $1 AND m.version = $2
+		GROUP BY m.module_path, m.version;`, modulePath, version)
+	if err := row.Scan(&c.Documented, &c.Undocumented); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	return &c, nil
+}
+
+// GetModuleKeywords returns the keywords of the module version specified by
+// modulePath and version, computed by internal.ModuleKeywords from the names
+// and synopses of its packages in the packages table. Nothing is stored for
+// them, so they reflect the current rules for deriving keywords.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetModuleKeywords(ctx context.Context, modulePath, version string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "DB.GetModuleKeywords(ctx, %q, %q)", modulePath, version)
+
+	query := `
+		SELECT p.name, p.synopsis
+		FROM modules m
+		LEFT JOIN packages p
+		ON p.module_path = m.module_path AND p.version = m.version
+		WHERE m.module_path = $1 AND m.version = $2;`
+	var (
+		found bool
+		pkgs  []*internal.LegacyPackage
+	)
+	collect := func(rows *sql.Rows) error {
+		var name, synopsis sql.NullString
+		if err := rows.Scan(&name, &synopsis); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		found = true
+		if name.Valid {
+			pkgs = append(pkgs, &internal.LegacyPackage{Name: name.String, Synopsis: synopsis.String})
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return internal.ModuleKeywords(pkgs), nil
+}
+
 func setHasGoMod(mi *internal.ModuleInfo, nb sql.NullBool) {
 	// The safe default value for HasGoMod is true, because search will penalize modules that don't have one.
 	// This is temporary: when has_go_mod is fully populated, we'll make it NOT NULL.
@@ -511,3 +1243,60 @@ func (s jsonbScanner) Scan(value interface{}) (err error) {
 	vptr.Elem().Set(v.Elem())
 	return nil
 }
+
+// GetDocBuildInfo returns the build info stored in the packages table for the
+// documentation of the package specified by pkgPath, modulePath and version.
+//
+// If the package does not exist, or was stored before build info was
+// recorded, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetDocBuildInfo(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.DocBuildInfo, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocBuildInfo(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	var goVersion, rendererVersion sql.NullString
+	err = db.db.QueryRow(ctx, `
+		SELECT doc_go_version, doc_renderer_version
+		FROM packages
+		WHERE path = $1 AND module_path = $2 AND version = $3;`,
+		pkgPath, modulePath, version).Scan(&goVersion, &rendererVersion)
+	switch err {
+	case sql.ErrNoRows:
+		return nil, fmt.Errorf("package %s in %s@%s: %w", pkgPath, modulePath, version, derrors.NotFound)
+	case nil:
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	if !goVersion.Valid {
+		return nil, fmt.Errorf("doc build info of %s in %s@%s: %w", pkgPath, modulePath, version, derrors.NotFound)
+	}
+	return &internal.DocBuildInfo{
+		GoVersion:       goVersion.String,
+		RendererVersion: rendererVersion.String,
+	}, nil
+}
+
+// GetDocGenGoVersion returns the Go toolchain version stored in the packages
+// table for the documentation of the module version specified by modulePath
+// and version. The packages of a module version are all documented by the same
+// fetch, so they have the same version.
+//
+// If the module version has no packages, or was stored before the version was
+// recorded, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetDocGenGoVersion(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocGenGoVersion(ctx, %q, %q)", modulePath, version)
+
+	var goVersion string
+	err = db.db.QueryRow(ctx, `
+		SELECT doc_go_version
+		FROM packages
+		WHERE module_path = $1 AND version = $2 AND doc_go_version IS NOT NULL
+		LIMIT 1;`,
+		modulePath, version).Scan(&goVersion)
+	switch err {
+	case sql.ErrNoRows:
+		return "", fmt.Errorf("doc Go version of %s@%s: %w", modulePath, version, derrors.NotFound)
+	case nil:
+		return goVersion, nil
+	default:
+		return "", fmt.Errorf("row.Scan(): %v", err)
+	}
+}
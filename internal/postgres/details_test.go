@@ -9,15 +9,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -172,6 +177,560 @@ func TestPostgres_GetImportsAndImportedBy(t *testing.T) {
 	}
 }
 
+func TestPostgres_GetImportsGrouped(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("path.to/foo", "v1.1.0", "bar", "noimports")
+	m.LegacyPackages[0].Imports = []string{"path.to/foo/noimports", "fmt", "github.com/a/b", "net/http"}
+	m.LegacyPackages[1].Imports = nil
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path                  string
+		wantStd, wantExternal []string
+	}{
+		{
+			path:         "path.to/foo/bar",
+			wantStd:      []string{"fmt", "net/http"},
+			wantExternal: []string{"github.com/a/b", "path.to/foo/noimports"},
+		},
+		{
+			path:         "path.to/foo/noimports",
+			wantStd:      []string{},
+			wantExternal: []string{},
+		},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			gotStd, gotExternal, err := testDB.GetImportsGrouped(ctx, test.path, m.ModulePath, m.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.wantStd, gotStd); diff != "" {
+				t.Errorf("std mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantExternal, gotExternal); diff != "" {
+				t.Errorf("external mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetInternalImports(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	// The "_" in the module path must not match other characters.
+	m := sample.Module("path_to/foo", "v1.1.0", "bar", "noimports")
+	m.LegacyPackages[0].Imports = []string{
+		"fmt",
+		"github.com/a/b",
+		"path_to/foo",
+		"path_to/foo/noimports",
+		"path_to/foobar",
+		"pathXto/foo/baz",
+	}
+	m.LegacyPackages[1].Imports = nil
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"path_to/foo/bar", []string{"path_to/foo", "path_to/foo/noimports"}},
+		{"path_to/foo/noimports", []string{}},
+	} {
+		got, err := testDB.GetInternalImports(ctx, test.path, m.ModulePath, m.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetInternalImports(%q) mismatch (-want +got):\n%s", test.path, diff)
+		}
+	}
+}
+
+func TestGetLatestVersions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, mv := range []struct{ modulePath, version string }{
+		{"a.com/m", "v1.0.0"},
+		{"a.com/m", "v1.1.0"},
+		// A prerelease is not latest when there is a release.
+		{"a.com/m", "v1.2.0-beta.1"},
+		{"b.com/pre", "v0.1.0-alpha"},
+		{"b.com/pre", "v0.2.0-alpha"},
+		// Another module whose path has a.com/m as a prefix.
+		{"a.com/m/v2", "v2.0.0"},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(mv.modulePath, mv.version, "pkg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetLatestVersions(ctx, []string{"a.com/m", "b.com/pre", "unknown.com/m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.com/m":   "v1.1.0",
+		"b.com/pre": "v0.2.0-alpha",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetLatestVersions mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = testDB.GetLatestVersions(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetLatestVersions(nil) = %v, want empty", got)
+	}
+}
+
+func TestGetModuleByCommit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "a.com/m"
+	for _, v := range []string{
+		"v1.0.0",
+		"v0.0.0-20200601000000-0123456789ab",
+		// The same commit, after v1.0.0 was tagged.
+		"v1.0.1-0.20200601000000-0123456789ab",
+		"v1.0.1-0.20200602000000-abcdef012345",
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(modulePath, v, "pkg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		hash, want string
+	}{
+		{"0123456", "v1.0.1-0.20200601000000-0123456789ab"},
+		{"ABCDEF0123", "v1.0.1-0.20200602000000-abcdef012345"},
+		{"abcdef0123456789abcdef0123456789abcdef01", "v1.0.1-0.20200602000000-abcdef012345"},
+	} {
+		mi, err := testDB.GetModuleByCommit(ctx, modulePath, test.hash)
+		if err != nil {
+			t.Fatalf("GetModuleByCommit(%q): %v", test.hash, err)
+		}
+		if mi.ModulePath != modulePath || mi.Version != test.want {
+			t.Errorf("GetModuleByCommit(%q) = %s@%s, want %s@%s", test.hash, mi.ModulePath, mi.Version, modulePath, test.want)
+		}
+	}
+
+	if _, err := testDB.GetModuleByCommit(ctx, modulePath, "fedcba9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("unknown commit: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := testDB.GetModuleByCommit(ctx, modulePath, "g123456"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("invalid hash: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestGetModulesByOwner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	// Sample modules have source info whose repo URL is https:// followed by
+	// the module path.
+	for _, mv := range [][2]string{
+		{"github.com/org1/popular", "v1.0.0"},
+		{"github.com/org1/popular", "v1.1.0"},
+		{"github.com/org1/popular", "v1.2.0-pre"},
+		{"github.com/org1/unpopular", "v0.1.0"},
+		{"github.com/org1/unpopular/v2", "v2.0.0"},
+		{"github.com/org2/other", "v1.0.0"},
+		{"github.com/org10/other", "v1.0.0"},
+		{"gitlab.com/org1/other", "v1.0.0"},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(mv[0], mv[1], "pkg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = 10 WHERE module_path = $1`,
+		"github.com/org1/popular"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name          string
+		host, owner   string
+		limit, offset int
+		want          []string
+	}{
+		{
+			name:  "ranked by popularity",
+			host:  "github.com",
+			owner: "org1",
+			limit: 10,
+			want: []string{
+				"github.com/org1/popular@v1.1.0",
+				"github.com/org1/unpopular@v0.1.0",
+				"github.com/org1/unpopular/v2@v2.0.0",
+			},
+		},
+		{
+			name:  "other owner",
+			host:  "github.com",
+			owner: "org2",
+			limit: 10,
+			want:  []string{"github.com/org2/other@v1.0.0"},
+		},
+		{
+			name:  "case-insensitive",
+			host:  "GitHub.com",
+			owner: "ORG2",
+			limit: 10,
+			want:  []string{"github.com/org2/other@v1.0.0"},
+		},
+		{
+			name:   "limit and offset",
+			host:   "github.com",
+			owner:  "org1",
+			limit:  1,
+			offset: 1,
+			want:   []string{"github.com/org1/unpopular@v0.1.0"},
+		},
+		{
+			name:  "unknown owner",
+			host:  "github.com",
+			owner: "org3",
+			limit: 10,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mods, err := testDB.GetModulesByOwner(ctx, test.host, test.owner, test.limit, test.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range mods {
+				got = append(got, m.ModulePath+"@"+m.Version)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := testDB.GetModulesByOwner(ctx, "github.com", "", 10, 0); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("empty owner: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+	for _, lo := range [][2]int{{-1, 0}, {1, -1}} {
+		if _, err := testDB.GetModulesByOwner(ctx, "github.com", "org1", lo[0], lo[1]); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("GetModulesByOwner(ctx, %q, %q, %d, %d): got error %v, want InvalidArgument", "github.com", "org1", lo[0], lo[1], err)
+		}
+	}
+}
+
+func TestGetFetchTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.DefaultModule()
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	var want time.Time
+	if err := testDB.db.QueryRow(ctx, `SELECT updated_at FROM modules WHERE module_path = $1 AND version = $2`,
+		m.ModulePath, m.Version).Scan(&want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetFetchTime(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got.Equal(m.CommitTime) {
+		t.Errorf("got commit time %v, want fetch time", got)
+	}
+
+	if _, err := testDB.GetFetchTime(ctx, m.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetProcessingInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.DefaultModule()
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	fetchedAt, err := testDB.GetFetchTime(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a module version state, the fetcher version is unknown.
+	got, err := testDB.GetProcessingInfo(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&internal.ProcessingInfo{FetchedAt: fetchedAt}); !cmp.Equal(got, want) {
+		t.Errorf("without state: got %+v, want %+v", got, want)
+	}
+
+	const appVersion = "20200101t000000"
+	if err := testDB.UpsertModuleVersionState(ctx, m.ModulePath, m.Version, appVersion, time.Now(), http.StatusOK, m.ModulePath, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err = testDB.GetProcessingInfo(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&internal.ProcessingInfo{FetchedAt: fetchedAt, FetcherVersion: appVersion}); !cmp.Equal(got, want) {
+		t.Errorf("with state: got %+v, want %+v", got, want)
+	}
+
+	if _, err := testDB.GetProcessingInfo(ctx, m.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetContentHash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, "v1.0.0", "")
+	m.ContentHash = "hash1"
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetContentHash(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hash1" {
+		t.Errorf("got %q, want %q", got, "hash1")
+	}
+
+	// Reprocessing the module version updates the hash.
+	m.ContentHash = "hash2"
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err = testDB.GetContentHash(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hash2" {
+		t.Errorf("after reprocessing: got %q, want %q", got, "hash2")
+	}
+
+	// A module version stored without a hash has none.
+	if err := testDB.InsertModule(ctx, sample.Module(sample.ModulePath, "v1.1.0", "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.GetContentHash(ctx, m.ModulePath, "v1.1.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("no hash: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := testDB.GetContentHash(ctx, m.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetCommitSHA(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	const (
+		pseudo = "v0.0.0-20200101000000-abcdef123456"
+		hash   = "abcdef1234567890abcdef1234567890abcdef12"
+	)
+	m := sample.Module(sample.ModulePath, pseudo, "")
+	m.SourceInfo = m.SourceInfo.WithCommitHash(hash)
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetCommitSHA(ctx, m.ModulePath, pseudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != hash {
+		t.Errorf("got %q, want %q", got, hash)
+	}
+
+	// A module version stored without a commit hash has none.
+	if err := testDB.InsertModule(ctx, sample.Module(sample.ModulePath, "v1.0.0", "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.GetCommitSHA(ctx, m.ModulePath, "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("no hash: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := testDB.GetCommitSHA(ctx, m.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetDocSizes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("m.com", "v1.0.0", "small", "large", "empty")
+	for _, p := range m.LegacyPackages {
+		switch p.Path {
+		case "m.com/small":
+			p.DocumentationHTML = "<p>doc</p>"
+		case "m.com/large":
+			p.DocumentationHTML = strings.Repeat("<p>€</p>", 1000)
+		case "m.com/empty":
+			p.DocumentationHTML = ""
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetDocSizes(ctx, "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"m.com/small": 10,
+		// Sizes are in bytes, and "€" is three bytes long.
+		"m.com/large": 10000,
+		"m.com/empty": 0,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := testDB.GetDocSizes(ctx, "m.com", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetModuleKeywords(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("m.com", "v1.0.0", "yaml", "json")
+	for _, p := range m.LegacyPackages {
+		p.Synopsis = "Package " + p.Name + " implements encoding and decoding of " + strings.ToUpper(p.Name) + "."
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	// A module with no packages has no keywords.
+	if err := testDB.InsertModule(ctx, sample.Module("n.com", "v1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       []string
+	}{
+		{"m.com", []string{"decoding", "encoding", "json", "yaml"}},
+		{"n.com", []string{}},
+	} {
+		got, err := testDB.GetModuleKeywords(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+
+	if _, err := testDB.GetModuleKeywords(ctx, "m.com", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetModuleDocCoverage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	// Two of the module's four packages lack a package doc comment.
+	m := sample.Module("m.com", "v1.0.0", "a", "b", "c", "d")
+	for _, p := range m.LegacyPackages {
+		if p.Name == "c" || p.Name == "d" {
+			p.Synopsis = ""
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertModule(ctx, sample.Module("n.com", "v1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       *internal.ModuleDocCoverage
+	}{
+		{"m.com", &internal.ModuleDocCoverage{Documented: 2, Undocumented: 2}},
+		// A module with no packages has no coverage.
+		{"n.com", &internal.ModuleDocCoverage{}},
+	} {
+		got, err := testDB.GetModuleDocCoverage(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+
+	if _, err := testDB.GetModuleDocCoverage(ctx, "m.com", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetFileStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, "v1.0.0", "")
+	m.FileStats = &internal.FileStats{NumFiles: 4, NumNonGoFiles: 1, GoLines: 120, TestLines: 30}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetFileStats(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m.FileStats, got); diff != "" {
+		t.Errorf("GetFileStats mismatch (-want +got):\n%s", diff)
+	}
+
+	// A module version stored without file stats has none.
+	if err := testDB.InsertModule(ctx, sample.Module(sample.ModulePath, "v1.1.0", "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.GetFileStats(ctx, m.ModulePath, "v1.1.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("no stats: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := testDB.GetFileStats(ctx, m.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
 func TestPostgres_GetTaggedAndPseudoVersions(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -215,6 +774,7 @@ func TestPostgres_GetTaggedAndPseudoVersions(t *testing.T) {
 						Version:    "v2.0.1-beta",
 						CommitTime: sample.CommitTime,
 					},
+					IsPrerelease: true,
 				},
 				{
 					ModuleInfo: internal.ModuleInfo{
@@ -229,6 +789,7 @@ func TestPostgres_GetTaggedAndPseudoVersions(t *testing.T) {
 						Version:    "v1.0.0-alpha.1",
 						CommitTime: sample.CommitTime,
 					},
+					IsPrerelease: true,
 				},
 			},
 		},
@@ -267,6 +828,7 @@ func TestPostgres_GetTaggedAndPseudoVersions(t *testing.T) {
 							Version:    fmt.Sprintf("v0.0.0-201806111833%02d-d8887717615a", tc.numPseudo-i),
 							CommitTime: sample.CommitTime,
 						},
+						IsPrerelease: true,
 					})
 				}
 			}
@@ -313,6 +875,58 @@ func TestPostgres_GetTaggedAndPseudoVersions(t *testing.T) {
 	}
 }
 
+func TestGetVersionsMatching(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "path.to/foo"
+	for _, v := range []string{"v1.1.0", "v1.2.0-rc.1", "v1.2.0", "v1.3.1", "v2.0.0-alpha"} {
+		if err := testDB.InsertModule(ctx, sample.Module(modulePath, v, "bar")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A module whose versions are in the same series is not included.
+	if err := testDB.InsertModule(ctx, sample.Module(modulePath+"/v2", "v2.0.0", "bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		constraint string
+		want       []string
+	}{
+		{">=1.2.0 <2.0.0", []string{"v1.3.1", "v1.2.0"}},
+		{"<1.2.0", []string{"v1.2.0-rc.1", "v1.1.0"}},
+		{"1.1.0 || >=2.0.0-alpha", []string{"v2.0.0-alpha", "v1.1.0"}},
+		{">=3.0.0", nil},
+	} {
+		t.Run(test.constraint, func(t *testing.T) {
+			mis, err := testDB.GetVersionsMatching(ctx, modulePath, test.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, mi := range mis {
+				if mi.ModulePath != modulePath {
+					t.Errorf("got module path %q, want %q", mi.ModulePath, modulePath)
+				}
+				if want := strings.Contains(mi.Version, "-"); mi.IsPrerelease != want {
+					t.Errorf("%s: IsPrerelease = %t, want %t", mi.Version, mi.IsPrerelease, want)
+				}
+				got = append(got, mi.Version)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := testDB.GetVersionsMatching(ctx, modulePath, ">= latest"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
 func TestGetPackagesInVersion(t *testing.T) {
 	testVersion := sample.Module("test.module", "v1.2.3", "", "foo")
 
@@ -335,7 +949,7 @@ func TestGetPackagesInVersion(t *testing.T) {
 				t.Error(err)
 			}
 
-			got, err := testDB.GetPackagesInModule(ctx, tc.pkgPath, tc.module.Version)
+			got, err := testDB.GetPackagesInModule(ctx, tc.pkgPath, tc.module.Version, internal.PathOptions{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -353,6 +967,172 @@ func TestGetPackagesInVersion(t *testing.T) {
 	}
 }
 
+func TestGetImportablePackages(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	m := sample.Module("test.module", "v1.2.3", "", "foo", "internal/bar", "foo/internal", "foo/testdata/x", "cmd/tool")
+	for _, p := range m.LegacyPackages {
+		if p.Path == "test.module/cmd/tool" {
+			p.Name = "main"
+		}
+	}
+	for _, d := range m.Directories {
+		if d.Path == "test.module/cmd/tool" {
+			d.Package.Name = "main"
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := testDB.GetImportablePackages(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pkgs {
+		got = append(got, p.Path)
+	}
+	want := []string{"test.module", "test.module/foo"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetImportablePackages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetModuleReadmeForPackage(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	noReadme := sample.Module("a.com/noreadme", "v1.0.0", "p")
+	noReadme.LegacyReadmeFilePath = ""
+	noReadme.LegacyReadmeContents = ""
+	for _, m := range []*internal.Module{
+		sample.Module("a.com/m", "v1.0.0", "deep/er", "nested/p"),
+		// a.com/m/nested/p is also in this nested module, which is preferred
+		// when the module path is unknown.
+		sample.Module("a.com/m/nested", "v1.0.0", "p"),
+		noReadme,
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := testDB.db.Exec(ctx, `
+		UPDATE modules SET readme_contents = 'nested readme'
+		WHERE module_path = 'a.com/m/nested'`); err != nil {
+		t.Fatal(err)
+	}
+
+	moduleReadme := &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: sample.ReadmeContents}
+	nestedReadme := &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: "nested readme"}
+	for _, test := range []struct {
+		name, pkgPath, modulePath string
+		want                      *internal.Readme // nil means NotFound
+	}{
+		{"package", "a.com/m/deep/er", "a.com/m", moduleReadme},
+		{"unknown module", "a.com/m/deep/er", internal.UnknownModulePath, moduleReadme},
+		{"outer module", "a.com/m/nested/p", "a.com/m", moduleReadme},
+		{"longest module", "a.com/m/nested/p", internal.UnknownModulePath, nestedReadme},
+		{"no README", "a.com/noreadme/p", "a.com/noreadme", nil},
+		{"not a package", "a.com/m/deep", "a.com/m", nil},
+		{"wrong module", "a.com/m/deep/er", "a.com/noreadme", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetModuleReadmeForPackage(ctx, test.pkgPath, test.modulePath, "v1.0.0")
+			if test.want == nil {
+				if !errors.Is(err, derrors.NotFound) {
+					t.Fatalf("got error %v, want %v", err, derrors.NotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetSiblingPackages(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, m := range []*internal.Module{
+		sample.Module("sib_ling.com", "v1.0.0", "", "a", "b", "c/d", "c/e", "c/e/f"),
+		sample.Module(stdlib.ModulePath, "v1.13.4", "errors", "fmt", "net/http", "net/url"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		pkgPath, modulePath, version string
+		want                         []string
+	}{
+		{"sib_ling.com", "sib_ling.com", "v1.0.0", nil},
+		{"sib_ling.com/a", "sib_ling.com", "v1.0.0", []string{"sib_ling.com/b"}},
+		{"sib_ling.com/c/d", "sib_ling.com", "v1.0.0", []string{"sib_ling.com/c/e"}},
+		{"sib_ling.com/c/e/f", "sib_ling.com", "v1.0.0", nil},
+		{"fmt", stdlib.ModulePath, "v1.13.4", []string{"errors"}},
+		{"net/http", stdlib.ModulePath, "v1.13.4", []string{"net/url"}},
+		{"sib_ling.com/a", "sib_ling.com", "v9.9.9", nil},
+	} {
+		pkgs, err := testDB.GetSiblingPackages(ctx, test.pkgPath, test.modulePath, test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range pkgs {
+			got = append(got, p.Path)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetSiblingPackages(%q, %q, %q) mismatch (-want +got):\n%s",
+				test.pkgPath, test.modulePath, test.version, diff)
+		}
+	}
+}
+
+func TestGetPrimaryPackage(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, m := range []*internal.Module{
+		sample.Module("root.com", "v1.0.0", "a", "", "b/c"),
+		sample.Module("noroot.com", "v1.0.0", "z/y", "x", "b", "a/b"),
+		sample.Module("empty.com", "v1.0.0"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		modulePath, want string
+	}{
+		{"root.com", "root.com"},
+		{"noroot.com", "noroot.com/b"},
+	} {
+		pkg, err := testDB.GetPrimaryPackage(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Path != test.want {
+			t.Errorf("GetPrimaryPackage(%q): got %q, want %q", test.modulePath, pkg.Path, test.want)
+		}
+	}
+	for _, modulePath := range []string{"empty.com", "missing.com"} {
+		if _, err := testDB.GetPrimaryPackage(ctx, modulePath, "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetPrimaryPackage(%q): got error %v, want %v", modulePath, err, derrors.NotFound)
+		}
+	}
+}
+
 func TestGetPackageLicenses(t *testing.T) {
 	modulePath := "test.module"
 	testModule := sample.Module(modulePath, "v1.2.3", "", "foo")
@@ -429,6 +1209,167 @@ func TestGetModuleLicenses(t *testing.T) {
 	}
 }
 
+func TestGetLicenseBadge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	mit := sample.Module("mit.com/m", "v1.0.0", "foo")
+	unknown := sample.Module("unknown.com/m", "v1.0.0", "foo")
+	unknown.IsRedistributable = false
+	unknown.Licenses = []*licenses.License{{
+		Metadata: &licenses.Metadata{Types: []string{"UNKNOWN"}, FilePath: "LICENSE"},
+		Contents: []byte(`All rights reserved.`),
+	}}
+	for _, m := range []*internal.Module{mit, unknown} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       *internal.Badge
+	}{
+		{"mit.com/m", &internal.Badge{License: "MIT", Color: internal.BadgeColorRedistributable, IsRedistributable: true}},
+		{"unknown.com/m", &internal.Badge{License: internal.UnknownLicense, Color: internal.BadgeColorUnknown}},
+	} {
+		got, err := testDB.GetLicenseBadge(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+
+	if _, err := testDB.GetLicenseBadge(ctx, "mit.com/m", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a missing version, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetLicenseFiles(t *testing.T) {
+	modulePath := "test.module"
+	testModule := sample.Module(modulePath, "v1.2.3", "", "foo", "bar")
+	testModule.LegacyPackages[0].Licenses = []*licenses.Metadata{{Types: []string{"ISC"}, FilePath: "LICENSE"}}
+	testModule.LegacyPackages[1].Licenses = []*licenses.Metadata{{Types: []string{"MIT"}, FilePath: "foo/LICENSE"}}
+	testModule.LegacyPackages[2].Licenses = []*licenses.Metadata{{Types: []string{"GPL2"}, FilePath: "bar/LICENSE.txt"}}
+
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	testModule.Licenses = nil
+	for _, p := range testModule.LegacyPackages {
+		testModule.Licenses = append(testModule.Licenses, &licenses.License{
+			Metadata: p.Licenses[0],
+			Contents: []byte("contents of " + p.Licenses[0].FilePath),
+		})
+	}
+
+	if err := testDB.InsertModule(ctx, testModule); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetLicenseFiles(ctx, modulePath, testModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// We want every license file, including those in subdirectories.
+	wantLicenses := []*licenses.License{testModule.Licenses[2], testModule.Licenses[1], testModule.Licenses[0]}
+	if diff := cmp.Diff(wantLicenses, got); diff != "" {
+		t.Errorf("testDB.GetLicenseFiles(ctx, %q, %q) mismatch (-want +got):\n%s", modulePath, testModule.Version, diff)
+	}
+}
+
+func TestGetUndetectedLicenseFiles(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	recognized := sample.Module("recognized.com/m", "v1.0.0", "", "foo")
+	recognized.LegacyPackages[0].Licenses = []*licenses.Metadata{{Types: []string{"MIT"}, FilePath: "LICENSE"}}
+	recognized.LegacyPackages[1].Licenses = []*licenses.Metadata{{Types: []string{"MIT"}, FilePath: "LICENSE"}}
+	undetected := sample.Module("undetected.com/m", "v1.0.0", "", "foo")
+	undetected.LegacyPackages[0].Licenses = []*licenses.Metadata{{Types: []string{"MIT"}, FilePath: "LICENSE"}}
+	undetected.LegacyPackages[1].Licenses = []*licenses.Metadata{{Types: []string{"UNKNOWN"}, FilePath: "foo/COPYING"}}
+	for _, m := range []*internal.Module{recognized, undetected} {
+		m.Licenses = nil
+		for _, p := range m.LegacyPackages {
+			m.Licenses = append(m.Licenses, &licenses.License{
+				Metadata: p.Licenses[0],
+				Contents: []byte("contents of " + p.Licenses[0].FilePath),
+			})
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       []internal.LicenseFile
+	}{
+		{"recognized.com/m", nil},
+		{"undetected.com/m", []internal.LicenseFile{{FilePath: "foo/COPYING", Contents: []byte("contents of foo/COPYING")}}},
+	} {
+		got, err := testDB.GetUndetectedLicenseFiles(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetUndetectedLicenseFiles(ctx, %q) mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+}
+
+func TestGetReadmeHash(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, test := range []struct {
+		version, readme string
+	}{
+		{"v1.0.0", "# README\n"},
+		{"v1.1.0", "# README\n"},
+		{"v1.2.0", "# README\n\nNow with more words.\n"},
+		{"v1.3.0", ""},
+	} {
+		m := sample.Module("readme.com/m", test.version, "")
+		m.LegacyReadmeFilePath = ""
+		m.LegacyReadmeContents = ""
+		if test.readme != "" {
+			m.LegacyReadmeFilePath = "README.md"
+			m.LegacyReadmeContents = test.readme
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash := func(version string) string {
+		t.Helper()
+		h, err := testDB.GetReadmeHash(ctx, "readme.com/m", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	if h1, h2 := hash("v1.0.0"), hash("v1.1.0"); h1 != h2 {
+		t.Errorf("identical READMEs: got different hashes %q and %q", h1, h2)
+	}
+	if h1, h3 := hash("v1.0.0"), hash("v1.2.0"); h1 == h3 {
+		t.Errorf("different READMEs: got the same hash %q", h1)
+	}
+	for _, version := range []string{"v1.3.0", "v2.0.0"} {
+		if _, err := testDB.GetReadmeHash(ctx, "readme.com/m", version); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetReadmeHash(%q): got error %v, want %v", version, err, derrors.NotFound)
+		}
+	}
+}
+
 func TestJSONBScanner(t *testing.T) {
 	type S struct{ A int }
 
@@ -456,3 +1397,114 @@ func TestJSONBScanner(t *testing.T) {
 		t.Errorf("got %#v, want nil", got2)
 	}
 }
+
+func TestGetPackagesInModuleVendored(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true}))
+	defer ResetTestDB(testDB, t)
+
+	const (
+		modulePath = "example.com/vend"
+		version    = "v1.0.0"
+		vendorPath = modulePath + "/vendor/example.com/dep"
+	)
+	m := sample.Module(modulePath, version, "", "vendor/example.com/dep")
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		opts internal.PathOptions
+		want []string
+	}{
+		{internal.PathOptions{}, []string{modulePath}},
+		{internal.PathOptions{IncludeVendored: true}, []string{modulePath, vendorPath}},
+	} {
+		pkgs, err := testDB.GetPackagesInModule(ctx, modulePath, version, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range pkgs {
+			got = append(got, p.Path)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetPackagesInModule(%+v) mismatch (-want +got):\n%s", test.opts, diff)
+		}
+	}
+
+	if _, err := testDB.GetDirectoryNew(ctx, vendorPath, modulePath, version, internal.PathOptions{}); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetDirectoryNew(%q): got error %v, want %v", vendorPath, err, derrors.NotFound)
+	}
+	dir, err := testDB.GetDirectoryNew(ctx, vendorPath, modulePath, version, internal.PathOptions{IncludeVendored: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Path != vendorPath {
+		t.Errorf("GetDirectoryNew(%q) with IncludeVendored: got path %q", vendorPath, dir.Path)
+	}
+}
+
+func TestGetDocBuildInfo(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	want := &internal.DocBuildInfo{GoVersion: "go1.14.4", RendererVersion: "1"}
+	m := sample.Module("a.com/m", "v1.0.0", "built", "unknown")
+	for _, p := range m.LegacyPackages {
+		if p.Path == "a.com/m/built" {
+			p.DocBuildInfo = want
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetDocBuildInfo(ctx, "a.com/m/built", "a.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, pkgPath := range []string{"a.com/m/unknown", "a.com/m/missing"} {
+		if _, err := testDB.GetDocBuildInfo(ctx, pkgPath, "a.com/m", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetDocBuildInfo(%q): got error %v, want %v", pkgPath, err, derrors.NotFound)
+		}
+	}
+}
+
+func TestGetDocGenGoVersion(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	built := sample.Module("a.com/m", "v1.0.0", "p1", "p2")
+	for _, p := range built.LegacyPackages {
+		p.DocBuildInfo = &internal.DocBuildInfo{GoVersion: "go1.14.4", RendererVersion: "1"}
+	}
+	for _, m := range []*internal.Module{built, sample.Module("a.com/m", "v0.9.0", "p1")} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetDocGenGoVersion(ctx, "a.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "go1.14.4"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, version := range []string{"v0.9.0", "v1.1.0"} {
+		if _, err := testDB.GetDocGenGoVersion(ctx, "a.com/m", version); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetDocGenGoVersion(%q): got error %v, want %v", version, err, derrors.NotFound)
+		}
+	}
+}
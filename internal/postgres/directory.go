@@ -15,12 +15,17 @@ import (
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/version"
 )
 
 // GetDirectoryNew returns a directory from the database, along with all of the
 // data associated with that directory, including the package, imports, readme,
-// documentation, and licenses.
-func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version string) (_ *internal.VersionedDirectory, err error) {
+// documentation, and licenses. A directory inside a vendor directory is not
+// found unless opts.IncludeVendored is set. If opts.IncludeSubdirectories is
+// set, the directories below it are listed as well. Links to standard library
+// packages in the documentation point to the release of the standard library
+// in the database chosen for opts.GoVersion.
+func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version string, opts internal.PathOptions) (_ *internal.VersionedDirectory, err error) {
 	query := `
 		SELECT
 			m.module_path,
@@ -49,7 +54,8 @@ func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version str
 		WHERE
 			p.path = $1
 			AND m.module_path = $2
-			AND m.version = $3;`
+			AND m.version = $3
+			AND ($4 OR p.path !~ ` + vendoredPathPattern + `);`
 	var (
 		mi                         internal.ModuleInfo
 		dir                        internal.DirectoryNew
@@ -58,7 +64,7 @@ func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version str
 		licenseTypes, licensePaths []string
 		pathID                     int
 	)
-	row := db.db.QueryRow(ctx, query, path, modulePath, version)
+	row := db.db.QueryRow(ctx, query, path, modulePath, version, opts.IncludeVendored)
 	if err := row.Scan(
 		&mi.ModulePath,
 		&mi.Version,
@@ -91,6 +97,11 @@ func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version str
 	}
 	dir.Licenses = lics
 	if pkg.Name != "" {
+		tag, err := db.stdlibLinkTag(ctx, opts.GoVersion)
+		if err != nil {
+			return nil, err
+		}
+		doc.HTML = internal.LinkStdlibAtTag(doc.HTML, tag)
 		dir.Package = &pkg
 		pkg.Path = dir.Path
 		pkg.Documentation = &doc
@@ -131,12 +142,56 @@ func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version str
 	if readme.Filepath != "" {
 		dir.Readme = &readme
 	}
+	if opts.IncludeSubdirectories {
+		dir.Subdirectories, err = db.getSubdirectories(ctx, path, modulePath, version, opts.IncludeVendored)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &internal.VersionedDirectory{
 		ModuleInfo:   mi,
 		DirectoryNew: dir,
 	}, nil
 }
 
+// getSubdirectories returns the directories below dirPath in the module
+// version specified by modulePath and version, sorted by path, along with
+// whether each has a README.
+func (db *DB) getSubdirectories(ctx context.Context, dirPath, modulePath, version string, includeVendored bool) (_ []*internal.Subdirectory, err error) {
+	defer derrors.Wrap(&err, "getSubdirectories(ctx, %q, %q, %q)", dirPath, modulePath, version)
+
+	// The prefix is compared with left rather than LIKE, so that characters
+	// such as "_" in dirPath are not treated as wildcards.
+	query := `
+		SELECT
+			p.path,
+			r.path_id IS NOT NULL
+		FROM modules m
+		INNER JOIN paths p
+		ON p.module_id = m.id
+		LEFT JOIN readmes r
+		ON r.path_id = p.id
+		WHERE
+			m.module_path = $1
+			AND m.version = $2
+			AND left(p.path, length($3) + 1) = $3 || '/'
+			AND ($4 OR p.path !~ ` + vendoredPathPattern + `)
+		ORDER BY p.path;`
+	var subdirs []*internal.Subdirectory
+	collect := func(rows *sql.Rows) error {
+		var sd internal.Subdirectory
+		if err := rows.Scan(&sd.Path, &sd.HasReadme); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		subdirs = append(subdirs, &sd)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version, dirPath, includeVendored); err != nil {
+		return nil, err
+	}
+	return subdirs, nil
+}
+
 // GetDirectory returns the directory corresponding to the provided dirPath,
 // modulePath, and version. The directory will contain all packages for that
 // version, in sorted order by package path.
@@ -421,3 +476,23 @@ func directoryQueryWithModulePath(dirPath, modulePath, version string, fields in
 				AND p.module_path = $2
 				AND p.version = $3;`, directoryColumns(fields)), []interface{}{dirPath, modulePath, version}
 }
+
+// stdlibLinkTag returns the tag of the Go release that links to standard
+// library packages should point to for goVersion, chosen by
+// stdlib.LinkTagForGoVersion among the releases of the standard library in the
+// database.
+func (db *DB) stdlibLinkTag(ctx context.Context, goVersion string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.stdlibLinkTag(ctx, %q)", goVersion)
+	if goVersion == "" {
+		return "", nil
+	}
+	mis, err := getModuleVersions(ctx, db, stdlib.ModulePath, []version.Type{version.TypeRelease})
+	if err != nil {
+		return "", err
+	}
+	var known []string
+	for _, mi := range mis {
+		known = append(known, mi.Version)
+	}
+	return stdlib.LinkTagForGoVersion(goVersion, known), nil
+}
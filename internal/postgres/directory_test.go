@@ -418,7 +418,7 @@ func TestGetDirectoryNew(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := testDB.GetDirectoryNew(ctx, tc.dirPath, tc.modulePath, tc.version)
+			got, err := testDB.GetDirectoryNew(ctx, tc.dirPath, tc.modulePath, tc.version, internal.PathOptions{})
 			if tc.wantNotFoundErr {
 				if !errors.Is(err, derrors.NotFound) {
 					t.Fatalf("want %v; got = \n%+v, %v", derrors.NotFound, got, err)
@@ -446,6 +446,83 @@ func TestGetDirectoryNew(t *testing.T) {
 	}
 }
 
+func TestGetDirectoryNewSubdirectories(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true}))
+
+	defer ResetTestDB(testDB, t)
+
+	// The module root has a README (from sample.Module), as do a.com/m/dir,
+	// which has no package, and a.com/m/dir/p. a.com/m/c and
+	// a.com/m/vendor/v.com/v have none.
+	m := sample.Module("a.com/m", "v1.2.3", "dir/p", "c", "vendor/v.com/v")
+	findDirectory(m, "a.com/m/dir").Readme = &internal.Readme{Filepath: "README.md", Contents: "dir readme"}
+	findDirectory(m, "a.com/m/dir/p").Readme = &internal.Readme{Filepath: "README.md", Contents: "pkg readme"}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name, dirPath string
+		opts          internal.PathOptions
+		want          []*internal.Subdirectory
+	}{
+		{
+			name:    "module root",
+			dirPath: "a.com/m",
+			opts:    internal.PathOptions{IncludeSubdirectories: true},
+			want: []*internal.Subdirectory{
+				{Path: "a.com/m/c", HasReadme: false},
+				{Path: "a.com/m/dir", HasReadme: true},
+				{Path: "a.com/m/dir/p", HasReadme: true},
+			},
+		},
+		{
+			name:    "module root with vendored",
+			dirPath: "a.com/m",
+			opts:    internal.PathOptions{IncludeSubdirectories: true, IncludeVendored: true},
+			want: []*internal.Subdirectory{
+				{Path: "a.com/m/c", HasReadme: false},
+				{Path: "a.com/m/dir", HasReadme: true},
+				{Path: "a.com/m/dir/p", HasReadme: true},
+				{Path: "a.com/m/vendor", HasReadme: false},
+				{Path: "a.com/m/vendor/v.com", HasReadme: false},
+				{Path: "a.com/m/vendor/v.com/v", HasReadme: false},
+			},
+		},
+		{
+			name:    "directory",
+			dirPath: "a.com/m/dir",
+			opts:    internal.PathOptions{IncludeSubdirectories: true},
+			want:    []*internal.Subdirectory{{Path: "a.com/m/dir/p", HasReadme: true}},
+		},
+		{
+			name:    "leaf",
+			dirPath: "a.com/m/c",
+			opts:    internal.PathOptions{IncludeSubdirectories: true},
+			want:    nil,
+		},
+		{
+			name:    "not requested",
+			dirPath: "a.com/m",
+			want:    nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetDirectoryNew(ctx, test.dirPath, "a.com/m", "v1.2.3", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got.Subdirectories); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func findDirectory(m *internal.Module, path string) *internal.DirectoryNew {
 	for _, d := range m.Directories {
 		if d.Path == path {
@@ -455,6 +532,49 @@ func findDirectory(m *internal.Module, path string) *internal.DirectoryNew {
 	return nil
 }
 
+func TestGetDirectoryNewGoVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true}))
+
+	defer ResetTestDB(testDB, t)
+
+	for _, v := range []string{"v1.12.9", "v1.13.0"} {
+		if err := testDB.InsertModule(ctx, sample.Module(stdlib.ModulePath, v, "io")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := sample.Module("a.com/m", "v1.0.0", "p")
+	d := findDirectory(m, "a.com/m/p")
+	// Documentation fetched before links were versioned at read time may
+	// have a version in its links.
+	d.Package.Documentation.HTML = `<a href="/pkg/io@go1.11#Reader">io.Reader</a>`
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		goVersion, want string
+	}{
+		{"", `<a href="/pkg/io#Reader">io.Reader</a>`},
+		{"1.12", `<a href="/pkg/io@go1.12.9#Reader">io.Reader</a>`},
+		// The latest known release, and any newer version, point to the
+		// latest.
+		{"1.13", `<a href="/pkg/io#Reader">io.Reader</a>`},
+		{"1.99", `<a href="/pkg/io#Reader">io.Reader</a>`},
+	} {
+		got, err := testDB.GetDirectoryNew(ctx, d.Path, m.ModulePath, m.Version, internal.PathOptions{GoVersion: test.goVersion})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Package.Documentation.HTML != test.want {
+			t.Errorf("GoVersion %q: got %s, want %s", test.goVersion, got.Package.Documentation.HTML, test.want)
+		}
+	}
+}
+
 func TestGetDirectoryFieldSet(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -0,0 +1,59 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetImportPathMismatches returns the packages of the module version specified
+// by modulePath and version whose import comment, stored in the import_comment
+// column of the paths table, differs from their path, sorted by path.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetImportPathMismatches(ctx context.Context, modulePath, version string) (_ []*internal.ImportPathMismatch, err error) {
+	defer derrors.Wrap(&err, "DB.GetImportPathMismatches(ctx, %q, %q)", modulePath, version)
+
+	query := `
+		SELECT p.path, p.import_comment
+		FROM modules m
+		LEFT JOIN paths p
+		ON p.module_id = m.id
+		AND p.import_comment IS NOT NULL
+		AND p.import_comment <> p.path
+		WHERE m.module_path = $1 AND m.version = $2
+		ORDER BY p.path;`
+	var (
+		found      bool
+		mismatches []*internal.ImportPathMismatch
+	)
+	collect := func(rows *sql.Rows) error {
+		var path, comment sql.NullString
+		if err := rows.Scan(&path, &comment); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		found = true
+		if path.Valid {
+			mismatches = append(mismatches, &internal.ImportPathMismatch{
+				PackagePath:   path.String,
+				ImportComment: comment.String,
+			})
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return mismatches, nil
+}
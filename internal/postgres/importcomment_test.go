@@ -0,0 +1,58 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetImportPathMismatches(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	var (
+		fooPath = sample.ModulePath + "/foo"
+		barPath = sample.ModulePath + "/bar"
+	)
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar", "baz")
+	for _, d := range m.Directories {
+		switch d.Path {
+		case fooPath:
+			// An import comment that matches the path is not a mismatch.
+			d.Package.ImportComment = fooPath
+		case barPath:
+			d.Package.ImportComment = "vanity.example.com/bar"
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetImportPathMismatches(ctx, sample.ModulePath, sample.VersionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.ImportPathMismatch{{PackagePath: barPath, ImportComment: "vanity.example.com/bar"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := testDB.GetImportPathMismatches(ctx, sample.ModulePath, "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing module: got error %v, want %v", err, derrors.NotFound)
+	}
+}
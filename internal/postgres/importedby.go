@@ -0,0 +1,290 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// importedByFlushBatchSize is the maximum number of packages whose counts are
+// updated by a single UPDATE statement.
+const importedByFlushBatchSize = 1000
+
+// An importedByBuffer accumulates changes to the imported_by_count column of
+// search_documents in memory, and writes them to the database in batches.
+//
+// Updating the counts of the imported packages in the same transaction as each
+// insert would serialize concurrent inserts of modules that import the same
+// popular packages on the rows of those packages. Buffering the changes
+// turns many single-row updates into a few batched ones, and moves them out
+// of the insert transactions.
+type importedByBuffer struct {
+	db *database.DB
+
+	mu     sync.Mutex
+	deltas map[string]int // from package path to change in count
+	// gen is incremented when a recomputation of all counts starts and when
+	// it ends, and recomputing is true in between. Together they let add
+	// drop the changes of inserts that overlap a recomputation.
+	gen         int64
+	recomputing bool
+
+	// flushMu is held while flushing, so that flushes write their batches
+	// one at a time and in path order, and while recomputing all counts.
+	flushMu sync.Mutex
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// BufferImportedByCounts makes db maintain the imported_by_count column of
+// search_documents as modules are inserted. The changes to the counts are
+// buffered in memory and written every interval, when FlushImportedByCounts
+// is called, and when db is closed.
+//
+// The counts read from search_documents are therefore eventually consistent:
+// they may lag the inserted modules by up to interval. Callers that need
+// every completed insert to be reflected must call FlushImportedByCounts
+// first. UpdateSearchDocumentsImportedByCount still recomputes all counts
+// from scratch, correcting any drift. The changes of inserts that run while
+// it does are dropped rather than risk counting them twice, so counts can lag
+// until the next recomputation.
+//
+// BufferImportedByCounts must be called at most once, before db is used.
+func (db *DB) BufferImportedByCounts(interval time.Duration) {
+	b := &importedByBuffer{
+		db:     db.db,
+		deltas: map[string]int{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	db.importedBy = b
+	go b.run(interval)
+}
+
+// FlushImportedByCounts writes the imported-by count changes buffered since
+// the last flush to search_documents. It does nothing if
+// BufferImportedByCounts was not called.
+func (db *DB) FlushImportedByCounts(ctx context.Context) error {
+	if db.importedBy == nil {
+		return nil
+	}
+	return db.importedBy.flush(ctx)
+}
+
+func (b *importedByBuffer) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			ctx := context.Background()
+			if err := b.flush(ctx); err != nil {
+				log.Errorf(ctx, "importedByBuffer: %v", err)
+			}
+		}
+	}
+}
+
+// close stops the periodic flushes and flushes the remaining changes. Calls
+// after the first do nothing and return the first call's error.
+func (b *importedByBuffer) close(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		b.closeErr = b.flush(ctx)
+	})
+	return b.closeErr
+}
+
+// generation returns the current generation of b, to be passed to add when
+// the insert that starts now has committed.
+func (b *importedByBuffer) generation() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gen
+}
+
+// add adds deltas, the changes made by an insert that started at generation
+// gen, to the buffered changes. If a recomputation of all counts started
+// since then, or is running, they are dropped: the recomputation may have
+// seen the insert, and adding them would count it twice.
+func (b *importedByBuffer) add(gen int64, deltas map[string]int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen || b.recomputing {
+		return
+	}
+	b.mergeLocked(deltas)
+}
+
+func (b *importedByBuffer) mergeLocked(deltas map[string]int) {
+	for path, d := range deltas {
+		b.deltas[path] += d
+		if b.deltas[path] == 0 {
+			delete(b.deltas, path)
+		}
+	}
+}
+
+// take removes the buffered changes from b and returns them.
+func (b *importedByBuffer) take() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	deltas := b.deltas
+	b.deltas = map[string]int{}
+	return deltas
+}
+
+// recompute calls f, which recomputes all counts from the imports_unique
+// table, after flushing the buffered changes. No other flush runs until f
+// returns, so that none is overwritten by counts computed before it. Changes
+// of inserts that overlap the call are dropped; see add.
+func (b *importedByBuffer) recompute(ctx context.Context, f func() error) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	b.setRecomputing(true)
+	defer b.setRecomputing(false)
+	if err := b.flushLocked(ctx); err != nil {
+		return err
+	}
+	return f()
+}
+
+func (b *importedByBuffer) setRecomputing(r bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.gen++
+	b.recomputing = r
+}
+
+// flush writes the buffered changes to search_documents, in batches of
+// importedByFlushBatchSize packages sorted by path so that concurrent writers
+// lock rows in the same order. If a batch fails, it and the batches after it
+// are returned to the buffer, to be retried by the next flush.
+func (b *importedByBuffer) flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	return b.flushLocked(ctx)
+}
+
+func (b *importedByBuffer) flushLocked(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "importedByBuffer.flush(ctx)")
+
+	deltas := b.take()
+	if len(deltas) == 0 {
+		return nil
+	}
+	var paths []string
+	for path := range deltas {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for start := 0; start < len(paths); start += importedByFlushBatchSize {
+		end := start + importedByFlushBatchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+		if err := updateImportedByDeltas(ctx, b.db, paths[start:end], deltas); err != nil {
+			unflushed := map[string]int{}
+			for _, path := range paths[start:] {
+				unflushed[path] = deltas[path]
+			}
+			b.mu.Lock()
+			b.mergeLocked(unflushed)
+			b.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// updateImportedByDeltas adds deltas[p] to the imported_by_count of each
+// package p in paths. A count that would fall below zero, which means that
+// the buffered changes have drifted from imports_unique, is set to zero and
+// logged.
+func updateImportedByDeltas(ctx context.Context, db *database.DB, paths []string, deltas map[string]int) error {
+	ds := make([]int64, len(paths))
+	for i, p := range paths {
+		ds[i] = int64(deltas[p])
+	}
+	var negative []string
+	err := db.RunQuery(ctx, `
+		WITH c AS (
+			SELECT s.package_path, s.imported_by_count + d.delta AS count
+			FROM search_documents s
+			INNER JOIN (
+				SELECT unnest($1::text[]) AS package_path, unnest($2::integer[]) AS delta
+			) d
+			ON s.package_path = d.package_path
+			ORDER BY s.package_path
+			FOR UPDATE OF s
+		)
+		UPDATE search_documents s
+		SET
+			imported_by_count = GREATEST(c.count, 0),
+			imported_by_count_updated_at = CURRENT_TIMESTAMP
+		FROM c
+		WHERE s.package_path = c.package_path
+		RETURNING s.package_path, c.count;`, func(rows *sql.Rows) error {
+		var (
+			path  string
+			count int
+		)
+		if err := rows.Scan(&path, &count); err != nil {
+			return err
+		}
+		if count < 0 {
+			negative = append(negative, path)
+		}
+		return nil
+	}, pq.Array(paths), pq.Array(ds))
+	if err != nil {
+		return err
+	}
+	if len(negative) > 0 {
+		log.Errorf(ctx, "updateImportedByDeltas: imported-by counts of %d packages fell below zero and were reset to zero, starting with %q",
+			len(negative), negative[0])
+	}
+	return nil
+}
+
+// importedByDeltas returns the change to the imported-by count of each
+// package when the (from_path, to_path) import pairs in old are replaced by
+// those in new. Pairs are counted as in computeImportedByCounts. Counts that
+// don't change are omitted.
+func importedByDeltas(fromModulePath string, old, new map[[2]string]bool) map[string]int {
+	deltas := map[string]int{}
+	for pair := range old {
+		if !new[pair] && countsAsImportedBy(fromModulePath, pair[1]) {
+			deltas[pair[1]]--
+		}
+	}
+	for pair := range new {
+		if !old[pair] && countsAsImportedBy(fromModulePath, pair[1]) {
+			deltas[pair[1]]++
+		}
+	}
+	for path, d := range deltas {
+		if d == 0 {
+			delete(deltas, path)
+		}
+	}
+	return deltas
+}
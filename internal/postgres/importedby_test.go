@@ -0,0 +1,208 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestImportedByDeltas(t *testing.T) {
+	pairs := func(ps ...string) map[[2]string]bool {
+		m := map[[2]string]bool{}
+		for i := 0; i < len(ps); i += 2 {
+			m[[2]string{ps[i], ps[i+1]}] = true
+		}
+		return m
+	}
+	old := pairs(
+		"m.com/a", "x.com/kept",
+		"m.com/a", "x.com/dropped",
+		"m.com/b", "x.com/moved",
+		"m.com/a", "m.com/b", // same module; not counted
+	)
+	new := pairs(
+		"m.com/a", "x.com/kept",
+		"m.com/a", "x.com/moved",
+		"m.com/a", "x.com/added",
+		"m.com/b", "x.com/added",
+		"m.com/b", "m.com/a",
+		"m.com/a", "fmt",
+	)
+	got := importedByDeltas("m.com", old, new)
+	want := map[string]int{
+		"x.com/dropped": -1,
+		"x.com/added":   2,
+		"fmt":           1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBufferImportedByCounts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	// Use a separate DB, so the buffer doesn't affect other tests.
+	db := New(testDB.Underlying())
+	db.BufferImportedByCounts(time.Hour)
+
+	insert := func(suffix string, imports ...string) {
+		t.Helper()
+		m := sample.Module("mod.com/"+suffix, sample.VersionString, suffix)
+		pkg := m.LegacyPackages[0]
+		pkg.Imports = nil
+		for _, imp := range imports {
+			pkg.Imports = append(pkg.Imports, fmt.Sprintf("mod.com/%s/%[1]s", imp))
+		}
+		if err := db.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkCount := func(suffix string, want int) {
+		t.Helper()
+		path := fmt.Sprintf("mod.com/%s/%[1]s", suffix)
+		sd, err := getSearchDocument(ctx, db, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sd.importedByCount != want {
+			t.Errorf("importedByCount for %q = %d, want %d", path, sd.importedByCount, want)
+		}
+	}
+
+	insert("A")
+	insert("B", "A")
+	insert("C", "A")
+	// The changes are not visible until they are flushed.
+	checkCount("A", 0)
+	if err := db.FlushImportedByCounts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkCount("A", 2)
+
+	// A new version of B that no longer imports A decrements A's count.
+	m := sample.Module("mod.com/B", "v1.1.0", "B")
+	m.LegacyPackages[0].Imports = nil
+	if err := db.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	// Closing flushes the remaining changes. Close the buffer rather than db,
+	// which would close testDB's connection.
+	if err := db.importedBy.close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkCount("A", 1)
+
+	// The buffered counts agree with a full recalculation.
+	if _, err := testDB.UpdateSearchDocumentsImportedByCount(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkCount("A", 1)
+}
+
+func TestImportedByBufferDropsChangesDuringRecompute(t *testing.T) {
+	ctx := context.Background()
+	b := &importedByBuffer{deltas: map[string]int{}}
+
+	before := b.generation()
+	if err := b.recompute(ctx, func() error {
+		// An insert that commits while the counts are recomputed may be
+		// included in them.
+		b.add(b.generation(), map[string]int{"a": 1})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// So may an insert that started before the recomputation.
+	b.add(before, map[string]int{"b": 1})
+	b.add(b.generation(), map[string]int{"c": 1})
+
+	want := map[string]int{"c": 1}
+	if diff := cmp.Diff(want, b.take()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBufferImportedByCountsConcurrentRecompute(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	db := New(testDB.Underlying())
+	db.BufferImportedByCounts(time.Millisecond)
+
+	insert := func(suffix string, imports ...string) error {
+		m := sample.Module("mod.com/"+suffix, sample.VersionString, suffix)
+		pkg := m.LegacyPackages[0]
+		pkg.Imports = nil
+		for _, imp := range imports {
+			pkg.Imports = append(pkg.Imports, fmt.Sprintf("mod.com/%s/%[1]s", imp))
+		}
+		return db.InsertModule(ctx, m)
+	}
+	count := func() int {
+		t.Helper()
+		sd, err := getSearchDocument(ctx, db, "mod.com/A/A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sd.importedByCount
+	}
+
+	if err := insert("A"); err != nil {
+		t.Fatal(err)
+	}
+	const n = 20
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() { errc <- insert(fmt.Sprintf("I%d", i), "A") }()
+	}
+	recomputeDone := make(chan error, 1)
+	go func() {
+		for i := 0; i < 5; i++ {
+			if _, err := db.UpdateSearchDocumentsImportedByCount(ctx); err != nil {
+				recomputeDone <- err
+				return
+			}
+		}
+		recomputeDone <- nil
+	}()
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-recomputeDone; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.importedBy.close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Closing again does nothing.
+	if err := db.importedBy.close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// No import is counted twice. Imports whose changes were dropped during
+	// a recomputation are counted by the next one.
+	if got := count(); got > n {
+		t.Errorf("after inserts: importedByCount = %d, want at most %d", got, n)
+	}
+	if _, err := db.UpdateSearchDocumentsImportedByCount(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != n {
+		t.Errorf("after recomputing: importedByCount = %d, want %d", got, n)
+	}
+}
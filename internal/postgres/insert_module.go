@@ -75,7 +75,25 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 	defer span.End()
 
 	logMemory(ctx, "at start of saveModule")
-	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+	// importedByDeltas holds the changes to imported-by counts caused by this
+	// insert, if they are being maintained. They are buffered only after the
+	// transaction commits.
+	var (
+		importedByDeltas map[string]int
+		importedByGen    int64
+	)
+	if db.importedBy != nil {
+		importedByGen = db.importedBy.generation()
+	}
+	if db.blobs != nil {
+		// Blobs written for a transaction that fails are left behind, to be
+		// overwritten when the module version is inserted again.
+		if err := putSourceFiles(ctx, db.blobs, m); err != nil {
+			return err
+		}
+	}
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		importedByDeltas = nil
 		moduleID, err := insertModule(ctx, tx, m)
 		if err != nil {
 			return err
@@ -87,13 +105,16 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 		}
 
 		logMemory(ctx, "after insertLicenses")
+		if err := insertRequirements(ctx, tx, m, moduleID); err != nil {
+			return err
+		}
 		if err := insertPackages(ctx, tx, m); err != nil {
 			return err
 		}
 		logMemory(ctx, "after insertPackages")
 
 		if experiment.IsActive(ctx, internal.ExperimentInsertDirectories) {
-			if err := insertDirectories(ctx, tx, m, moduleID); err != nil {
+			if err := insertDirectories(ctx, tx, m, moduleID, db.blobs == nil); err != nil {
 				return err
 			}
 		}
@@ -119,7 +140,11 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 			return nil
 		}
 
-		if err := insertImportsUnique(ctx, tx, m); err != nil {
+		deltas, err := insertImportsUnique(ctx, tx, m, db.importedBy != nil)
+		if err != nil {
+			return err
+		}
+		if err := insertSymbolReferences(ctx, tx, m); err != nil {
 			return err
 		}
 
@@ -153,8 +178,19 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 			return err
 		}
 		// Insert the module's packages into search_documents.
-		return UpsertSearchDocuments(ctx, tx, m)
+		if err := UpsertSearchDocuments(ctx, tx, m); err != nil {
+			return err
+		}
+		importedByDeltas = deltas
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if db.importedBy != nil {
+		db.importedBy.add(importedByGen, importedByDeltas)
+	}
+	return nil
 }
 
 func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ int, err error) {
@@ -165,6 +201,10 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 	if err != nil {
 		return 0, err
 	}
+	fileStatsJSON, err := json.Marshal(m.FileStats)
+	if err != nil {
+		return 0, err
+	}
 	var moduleID int
 	err = db.QueryRow(ctx,
 		`INSERT INTO modules(
@@ -178,15 +218,21 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 			series_path,
 			source_info,
 			redistributable,
-			has_go_mod)
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10, $11)
+			has_go_mod,
+			content_hash,
+			file_stats,
+			go_mod)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10, $11, NULLIF($12, ''), $13, $14)
 		ON CONFLICT
 			(module_path, version)
 		DO UPDATE SET
 			readme_file_path=excluded.readme_file_path,
 			readme_contents=excluded.readme_contents,
 			source_info=excluded.source_info,
-			redistributable=excluded.redistributable
+			redistributable=excluded.redistributable,
+			content_hash=excluded.content_hash,
+			file_stats=excluded.file_stats,
+			go_mod=excluded.go_mod
 		RETURNING id`,
 		m.ModulePath,
 		m.Version,
@@ -199,6 +245,9 @@ func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ i
 		sourceInfoJSON,
 		m.IsRedistributable,
 		m.HasGoMod,
+		m.ContentHash,
+		fileStatsJSON,
+		m.GoMod,
 	).Scan(&moduleID)
 	if err != nil {
 		return 0, err
@@ -235,6 +284,26 @@ func insertLicenses(ctx context.Context, db *database.DB, m *internal.Module, mo
 	return nil
 }
 
+// insertRequirements replaces the requirements of the module with moduleID
+// with those in m.
+func insertRequirements(ctx context.Context, db *database.DB, m *internal.Module, moduleID int) (err error) {
+	ctx, span := trace.StartSpan(ctx, "insertRequirements")
+	defer span.End()
+	defer derrors.Wrap(&err, "insertRequirements(ctx, %q, %q)", m.ModulePath, m.Version)
+	if _, err := db.Exec(ctx, `DELETE FROM requirements WHERE module_id = $1`, moduleID); err != nil {
+		return err
+	}
+	var values []interface{}
+	for _, r := range m.Requirements {
+		values = append(values, moduleID, r.ModulePath, r.Version, r.Indirect)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	cols := []string{"module_id", "module_path", "version", "indirect"}
+	return db.BulkUpsert(ctx, "requirements", cols, values, []string{"module_id", "module_path"})
+}
+
 func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (err error) {
 	ctx, span := trace.StartSpan(ctx, "insertPackages")
 	defer span.End()
@@ -276,6 +345,11 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 				}
 			}
 		}
+		var docGoVersion, docRendererVersion interface{}
+		if p.DocBuildInfo != nil {
+			docGoVersion = p.DocBuildInfo.GoVersion
+			docRendererVersion = p.DocBuildInfo.RendererVersion
+		}
 		pkgValues = append(pkgValues,
 			p.Path,
 			p.Synopsis,
@@ -290,6 +364,8 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 			p.GOOS,
 			p.GOARCH,
 			m.CommitTime,
+			docGoVersion,
+			docRendererVersion,
 		)
 		for _, i := range p.Imports {
 			importValues = append(importValues, p.Path, m.ModulePath, m.Version, i)
@@ -311,6 +387,8 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 			"goos",
 			"goarch",
 			"commit_time",
+			"doc_go_version",
+			"doc_renderer_version",
 		}
 		if err := db.BulkUpsert(ctx, "packages", pkgCols, pkgValues, uniqueCols); err != nil {
 			return err
@@ -333,33 +411,86 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 
 // insertImportsUnique inserts and removes rows from the imports_unique table. It should only
 // be called if the given module's version is the latest.
-func insertImportsUnique(ctx context.Context, tx *database.DB, m *internal.Module) (err error) {
+//
+// If computeDeltas is true, insertImportsUnique also returns the changes to
+// the imported-by counts of the imported packages caused by replacing the
+// module's previous rows.
+func insertImportsUnique(ctx context.Context, tx *database.DB, m *internal.Module, computeDeltas bool) (_ map[string]int, err error) {
 	ctx, span := trace.StartSpan(ctx, "insertImportsUnique")
 	defer span.End()
 	defer derrors.Wrap(&err, "insertImportsUnique(%q, %q)", m.ModulePath, m.Version)
 
 	// Remove the previous rows for this module. We'll replace them with
 	// new ones below.
-	if _, err := tx.Exec(ctx,
-		`DELETE FROM imports_unique WHERE from_module_path = $1`,
-		m.ModulePath); err != nil {
-		return err
+	const deleteStmt = `DELETE FROM imports_unique WHERE from_module_path = $1`
+	oldPairs := map[[2]string]bool{}
+	if computeDeltas {
+		collect := func(rows *sql.Rows) error {
+			var from, to string
+			if err := rows.Scan(&from, &to); err != nil {
+				return err
+			}
+			oldPairs[[2]string{from, to}] = true
+			return nil
+		}
+		if err := tx.RunQuery(ctx, deleteStmt+` RETURNING from_path, to_path`, collect, m.ModulePath); err != nil {
+			return nil, err
+		}
+	} else if _, err := tx.Exec(ctx, deleteStmt, m.ModulePath); err != nil {
+		return nil, err
 	}
 
 	var values []interface{}
+	newPairs := map[[2]string]bool{}
 	for _, p := range m.LegacyPackages {
 		for _, i := range p.Imports {
 			values = append(values, p.Path, m.ModulePath, i)
+			newPairs[[2]string{p.Path, i}] = true
 		}
 	}
+	var deltas map[string]int
+	if computeDeltas {
+		deltas = importedByDeltas(m.ModulePath, oldPairs, newPairs)
+	}
 	if len(values) == 0 {
-		return nil
+		return deltas, nil
 	}
 	cols := []string{"from_path", "from_module_path", "to_path"}
-	return tx.BulkUpsert(ctx, "imports_unique", cols, values, cols)
+	if err := tx.BulkUpsert(ctx, "imports_unique", cols, values, cols); err != nil {
+		return nil, err
+	}
+	return deltas, nil
 }
 
-func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module, moduleID int) (err error) {
+// insertSymbolReferences replaces the rows of the symbol_references table for
+// the module with those for m. Like insertImportsUnique, it should only be
+// called if m is the latest version of its module.
+func insertSymbolReferences(ctx context.Context, tx *database.DB, m *internal.Module) (err error) {
+	ctx, span := trace.StartSpan(ctx, "insertSymbolReferences")
+	defer span.End()
+	defer derrors.Wrap(&err, "insertSymbolReferences(%q, %q)", m.ModulePath, m.Version)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM symbol_references WHERE from_module_path = $1`, m.ModulePath); err != nil {
+		return err
+	}
+	var values []interface{}
+	for _, p := range m.LegacyPackages {
+		for _, r := range p.SymbolReferences {
+			values = append(values, r.ImportPath, r.Symbol, p.Path, m.ModulePath, r.Count)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	cols := []string{"to_path", "symbol", "from_path", "from_module_path", "count"}
+	return tx.BulkUpsert(ctx, "symbol_references", cols, values, cols[:4])
+}
+
+// insertDirectories inserts the directories of m. The source files of its
+// packages are inserted into the source_files table only if storeSources is
+// true. Rows previously in source_files for the directories of m are deleted
+// either way.
+func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module, moduleID int, storeSources bool) (err error) {
 	defer derrors.Wrap(&err, "insertDirectories(ctx, tx, %q, %q)", m.ModulePath, m.Version)
 	ctx, span := trace.StartSpan(ctx, "insertDirectories")
 	defer span.End()
@@ -387,6 +518,7 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 		pathToReadme  = map[string]*internal.Readme{}
 		pathToDoc     = map[string]*internal.Documentation{}
 		pathToImports = map[string][]string{}
+		pathToSources = map[string]map[string][]byte{}
 	)
 	for _, d := range m.Directories {
 		var licenseTypes, licensePaths []string
@@ -405,9 +537,15 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 				}
 			}
 		}
-		var name string
+		var (
+			name          string
+			importComment sql.NullString
+		)
 		if d.Package != nil {
 			name = d.Package.Name
+			if d.Package.ImportComment != "" {
+				importComment = sql.NullString{String: d.Package.ImportComment, Valid: true}
+			}
 		}
 		pathValues = append(pathValues,
 			d.Path,
@@ -417,6 +555,7 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 			pq.Array(licenseTypes),
 			pq.Array(licensePaths),
 			d.IsRedistributable,
+			importComment,
 		)
 		if d.Readme != nil {
 			pathToReadme[d.Path] = d.Readme
@@ -429,6 +568,9 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 			if len(d.Package.Imports) > 0 {
 				pathToImports[d.Path] = d.Package.Imports
 			}
+			if storeSources && len(d.Package.SourceFiles) > 0 {
+				pathToSources[d.Path] = d.Package.SourceFiles
+			}
 		}
 	}
 
@@ -441,6 +583,7 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 			"license_types",
 			"license_paths",
 			"redistributable",
+			"import_comment",
 		}
 		logMemory(ctx, "before inserting into paths")
 
@@ -493,13 +636,89 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 				continue
 			}
 			id := pathToID[path]
-			docValues = append(docValues, id, doc.GOOS, doc.GOARCH, doc.Synopsis, makeValidUnicode(doc.HTML))
+			docValues = append(docValues, id, doc.GOOS, doc.GOARCH, doc.Synopsis, makeValidUnicode(doc.HTML), makeValidUnicode(doc.Doc))
 		}
 		uniqueCols := []string{"path_id", "goos", "goarch"}
-		docCols := append(uniqueCols, "synopsis", "html")
+		docCols := append(uniqueCols, "synopsis", "html", "doc")
 		if err := db.BulkUpsert(ctx, "documentation", docCols, docValues, uniqueCols); err != nil {
 			return err
 		}
+
+		logMemory(ctx, "before inserting into symbols")
+		// Delete the symbols of each documentation first, so that symbols
+		// removed since the module version was last inserted don't remain.
+		if err := deleteDocRows(ctx, db, "symbols", paths, pathToID, pathToDoc); err != nil {
+			return err
+		}
+		var symbolValues []interface{}
+		for _, path := range paths {
+			doc, ok := pathToDoc[path]
+			if !ok {
+				continue
+			}
+			id := pathToID[path]
+			for _, s := range doc.Symbols {
+				symbolValues = append(symbolValues, id, doc.GOOS, doc.GOARCH, s.Name, s.Kind, s.Synopsis, s.ParentName, makeValidUnicode(s.Doc))
+			}
+		}
+		symbolUniqueCols := []string{"path_id", "goos", "goarch", "name"}
+		symbolCols := append(symbolUniqueCols, "kind", "synopsis", "parent_name", "doc")
+		if err := db.BulkUpsert(ctx, "symbols", symbolCols, symbolValues, symbolUniqueCols); err != nil {
+			return err
+		}
+
+		logMemory(ctx, "before inserting into playground_examples")
+		if err := deleteDocRows(ctx, db, "playground_examples", paths, pathToID, pathToDoc); err != nil {
+			return err
+		}
+		var exampleValues []interface{}
+		for _, path := range paths {
+			doc, ok := pathToDoc[path]
+			if !ok {
+				continue
+			}
+			id := pathToID[path]
+			for _, ex := range doc.PlaygroundExamples {
+				exampleValues = append(exampleValues, id, doc.GOOS, doc.GOARCH, ex.Name, makeValidUnicode(ex.Code))
+			}
+		}
+		exampleUniqueCols := []string{"path_id", "goos", "goarch", "name"}
+		exampleCols := append(exampleUniqueCols, "code")
+		if err := db.BulkUpsert(ctx, "playground_examples", exampleCols, exampleValues, exampleUniqueCols); err != nil {
+			return err
+		}
+	}
+
+	logMemory(ctx, "before inserting into source_files")
+	// Delete the source files of every path first, so that files removed
+	// since the module version was last inserted, and files of packages that
+	// are no longer redistributable, don't remain.
+	var pathIDs []int64
+	for _, path := range paths {
+		pathIDs = append(pathIDs, int64(pathToID[path]))
+	}
+	if len(pathIDs) > 0 {
+		if _, err := db.Exec(ctx, `DELETE FROM source_files WHERE path_id = ANY($1);`, pq.Array(pathIDs)); err != nil {
+			return err
+		}
+	}
+	if len(pathToSources) > 0 {
+		var sourceValues []interface{}
+		for _, path := range paths {
+			files, ok := pathToSources[path]
+			if !ok {
+				continue
+			}
+			id := pathToID[path]
+			for name, contents := range files {
+				sourceValues = append(sourceValues, id, name, contents)
+			}
+		}
+		sourceUniqueCols := []string{"path_id", "file_name"}
+		sourceCols := append(sourceUniqueCols, "contents")
+		if err := db.BulkUpsert(ctx, "source_files", sourceCols, sourceValues, sourceUniqueCols); err != nil {
+			return err
+		}
 	}
 
 	logMemory(ctx, "before inserting into package_imports")
@@ -518,6 +737,36 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 	return db.BulkUpsert(ctx, "package_imports", importCols, importValues, importCols)
 }
 
+// deleteDocRows deletes the rows of table, which is keyed by path_id, goos and
+// goarch like the documentation table, for the documentation in pathToDoc of
+// the paths in paths.
+func deleteDocRows(ctx context.Context, db *database.DB, table string, paths []string, pathToID map[string]int, pathToDoc map[string]*internal.Documentation) error {
+	var (
+		ids              []int64
+		gooses, goarches []string
+	)
+	for _, path := range paths {
+		doc, ok := pathToDoc[path]
+		if !ok {
+			continue
+		}
+		ids = append(ids, int64(pathToID[path]))
+		gooses = append(gooses, doc.GOOS)
+		goarches = append(goarches, doc.GOARCH)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s t
+		USING (
+			SELECT unnest($1::integer[]) AS path_id, unnest($2::text[]) AS goos, unnest($3::text[]) AS goarch
+		) d
+		WHERE t.path_id = d.path_id AND t.goos = d.goos AND t.goarch = d.goarch;`, table),
+		pq.Array(ids), pq.Array(gooses), pq.Array(goarches))
+	return err
+}
+
 // lock obtains an exclusive, transaction-scoped advisory lock on modulePath.
 func lock(ctx context.Context, tx *database.DB, modulePath string) (err error) {
 	defer derrors.Wrap(&err, "lock(%s)", modulePath)
@@ -634,7 +883,7 @@ func (db *DB) compareLicenses(ctx context.Context, m *internal.Module) (err erro
 // are packages in the packages table that are not present in m.LegacyPackages.
 func (db *DB) comparePackages(ctx context.Context, m *internal.Module) (err error) {
 	defer derrors.Wrap(&err, "comparePackages(ctx, %q, %q)", m.ModulePath, m.Version)
-	dbPackages, err := db.GetPackagesInModule(ctx, m.ModulePath, m.Version)
+	dbPackages, err := db.GetPackagesInModule(ctx, m.ModulePath, m.Version, internal.PathOptions{IncludeVendored: true})
 	if err != nil {
 		return err
 	}
@@ -679,6 +928,12 @@ func removeNonDistributableData(m *internal.Module) {
 			// Prune derived information that can't be stored.
 			p.Synopsis = ""
 			p.DocumentationHTML = ""
+			p.SourceFiles = nil
+		}
+	}
+	for _, d := range m.Directories {
+		if d.Package != nil && !d.IsRedistributable {
+			d.Package.SourceFiles = nil
 		}
 	}
 	if !m.IsRedistributable {
@@ -702,8 +957,12 @@ func (db *DB) DeleteModule(ctx context.Context, modulePath, version string) (err
 		if err != sql.ErrNoRows || err == nil {
 			return err
 		}
-		// No versions of this module exist; remove it from imports_unique.
-		_, err = db.db.Exec(ctx, `DELETE FROM imports_unique WHERE from_module_path = $1`, modulePath)
+		// No versions of this module exist; remove it from imports_unique
+		// and symbol_references.
+		if _, err := db.db.Exec(ctx, `DELETE FROM imports_unique WHERE from_module_path = $1`, modulePath); err != nil {
+			return err
+		}
+		_, err = db.db.Exec(ctx, `DELETE FROM symbol_references WHERE from_module_path = $1`, modulePath)
 		return err
 	})
 }
@@ -111,7 +111,7 @@ func checkModule(ctx context.Context, t *testing.T, want *internal.Module) {
 	}
 
 	for _, dir := range want.Directories {
-		got, err := testDB.GetDirectoryNew(ctx, dir.Path, want.ModulePath, want.Version)
+		got, err := testDB.GetDirectoryNew(ctx, dir.Path, want.ModulePath, want.Version, internal.PathOptions{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -0,0 +1,109 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A FetchIntent is a fetch recorded in the fetch_outbox table, to be
+// scheduled on a queue once the transaction that recorded it commits.
+type FetchIntent struct {
+	ModulePath           string
+	Version              string
+	Suffix               string
+	TaskIDChangeInterval time.Duration
+}
+
+// InsertFetchIntent records fi in the fetch_outbox table as part of tx. The
+// intent becomes visible to RelayFetchIntents only if tx commits, so a caller
+// that also writes its own state in tx schedules the fetch if and only if
+// that state is committed.
+func InsertFetchIntent(ctx context.Context, tx *sql.Tx, fi *FetchIntent) (err error) {
+	defer derrors.Wrap(&err, "InsertFetchIntent(ctx, tx, %q, %q)", fi.ModulePath, fi.Version)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO fetch_outbox (module_path, version, suffix, task_id_change_interval)
+		VALUES ($1, $2, $3, $4);`,
+		fi.ModulePath, fi.Version, fi.Suffix, int64(fi.TaskIDChangeInterval))
+	return err
+}
+
+// RelayFetchIntents calls schedule on up to limit of the fetch intents in the
+// fetch_outbox table that are not done, oldest first, and marks each one for
+// which schedule succeeds as done. It stops at the first failure and returns
+// its error; the failed intent and the ones after it are left for a later
+// call. It returns the number of intents marked done.
+//
+// The intents are locked while they are relayed, so concurrent calls relay
+// different intents. If marking the intents done fails after they were
+// scheduled, a later call schedules them again, so schedule should tolerate
+// duplicates.
+func (db *DB) RelayFetchIntents(ctx context.Context, limit int, schedule func(context.Context, *FetchIntent) error) (n int, err error) {
+	defer derrors.Wrap(&err, "DB.RelayFetchIntents(ctx, %d)", limit)
+
+	var scheduleErr error
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		type pending struct {
+			id int64
+			fi FetchIntent
+		}
+		var intents []pending
+		collect := func(rows *sql.Rows) error {
+			var (
+				p        pending
+				interval int64
+			)
+			if err := rows.Scan(&p.id, &p.fi.ModulePath, &p.fi.Version, &p.fi.Suffix, &interval); err != nil {
+				return fmt.Errorf("row.Scan(): %v", err)
+			}
+			p.fi.TaskIDChangeInterval = time.Duration(interval)
+			intents = append(intents, p)
+			return nil
+		}
+		query := `
+			SELECT id, module_path, version, suffix, task_id_change_interval
+			FROM fetch_outbox
+			WHERE done_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED;`
+		if err := tx.RunQuery(ctx, query, collect, limit); err != nil {
+			return err
+		}
+
+		var done []int64
+		for _, p := range intents {
+			if err := schedule(ctx, &p.fi); err != nil {
+				scheduleErr = fmt.Errorf("scheduling %s@%s: %w", p.fi.ModulePath, p.fi.Version, err)
+				break
+			}
+			done = append(done, p.id)
+		}
+		if len(done) == 0 {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE fetch_outbox
+			SET done_at = CURRENT_TIMESTAMP
+			WHERE id = ANY($1);`, pq.Array(done))
+		if err != nil {
+			return err
+		}
+		n = len(done)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, scheduleErr
+}
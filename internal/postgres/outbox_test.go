@@ -0,0 +1,91 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/testing/dbtest"
+)
+
+func TestRelayFetchIntents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	// Callers record intents with their own connection to the database.
+	conn, err := sql.Open("postgres", dbtest.DBConnURI("discovery_postgres_test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	insert := func(commit bool, fis ...*FetchIntent) {
+		t.Helper()
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, fi := range fis {
+			if err := InsertFetchIntent(ctx, tx, fi); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var relayed []FetchIntent
+	relay := func(limit int, fail string) int {
+		t.Helper()
+		n, err := testDB.RelayFetchIntents(ctx, limit, func(_ context.Context, fi *FetchIntent) error {
+			if fi.ModulePath == fail {
+				return errors.New("bad")
+			}
+			relayed = append(relayed, *fi)
+			return nil
+		})
+		if (err != nil) != (fail != "") {
+			t.Fatalf("RelayFetchIntents: got error %v, want failure: %t", err, fail != "")
+		}
+		return n
+	}
+
+	a := &FetchIntent{ModulePath: "a.com", Version: "v1.0.0"}
+	b := &FetchIntent{ModulePath: "b.com", Version: "v1.0.0", Suffix: "reprocess", TaskIDChangeInterval: time.Hour}
+	c := &FetchIntent{ModulePath: "c.com", Version: "v1.0.0"}
+	insert(false, &FetchIntent{ModulePath: "rolled.back", Version: "v1.0.0"})
+	insert(true, a, b)
+	insert(true, c)
+
+	// The intent for b.com fails, so only a.com is done.
+	if got := relay(10, "b.com"); got != 1 {
+		t.Errorf("first relay: got %d done, want 1", got)
+	}
+	if got := relay(1, ""); got != 1 {
+		t.Errorf("second relay: got %d done, want 1", got)
+	}
+	if got := relay(10, ""); got != 1 {
+		t.Errorf("third relay: got %d done, want 1", got)
+	}
+	if got := relay(10, ""); got != 0 {
+		t.Errorf("relay with nothing pending: got %d done, want 0", got)
+	}
+	want := []FetchIntent{*a, *b, *c}
+	if diff := cmp.Diff(want, relayed); diff != "" {
+		t.Errorf("relayed intents mismatch (-want +got):\n%s", diff)
+	}
+}
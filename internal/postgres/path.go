@@ -61,6 +61,52 @@ func (db *DB) GetPathInfo(ctx context.Context, path, inModulePath, inVersion str
 	}
 }
 
+// ClassifyPath returns the kind of the best entity with the given path, picked
+// by the rules of GetPathInfo, with a single query. A path that is the module
+// path is classified as internal.PathKindModuleRoot even if it contains a
+// package. If there is no such path, internal.PathKindNotFound is returned
+// with a nil error.
+func (db *DB) ClassifyPath(ctx context.Context, path, modulePath, version string) (_ internal.PathKind, err error) {
+	defer derrors.Wrap(&err, "DB.ClassifyPath(ctx, %q, %q, %q)", path, modulePath, version)
+
+	var constraints []string
+	args := []interface{}{path}
+	if modulePath != internal.UnknownModulePath {
+		constraints = append(constraints, fmt.Sprintf("AND m.module_path = $%d", len(args)+1))
+		args = append(args, modulePath)
+	}
+	if version != internal.LatestVersion {
+		constraints = append(constraints, fmt.Sprintf("AND m.version = $%d", len(args)+1))
+		args = append(args, version)
+	}
+	query := fmt.Sprintf(`
+		SELECT p.path = m.module_path, p.name != ''
+		FROM paths p
+		INNER JOIN modules m ON (p.module_id = m.id)
+		WHERE p.path = $1
+		%s
+		ORDER BY
+			m.version_type = 'release' DESC,
+			m.sort_version DESC,
+			m.module_path DESC
+		LIMIT 1
+	`, strings.Join(constraints, " "))
+	var isModuleRoot, isPackage bool
+	err = db.db.QueryRow(ctx, query, args...).Scan(&isModuleRoot, &isPackage)
+	switch {
+	case err == sql.ErrNoRows:
+		return internal.PathKindNotFound, nil
+	case err != nil:
+		return "", err
+	case isModuleRoot:
+		return internal.PathKindModuleRoot, nil
+	case isPackage:
+		return internal.PathKindPackage, nil
+	default:
+		return internal.PathKindDirectory, nil
+	}
+}
+
 type dbPath struct {
 	id              int64
 	path            string
@@ -150,3 +196,51 @@ func (db *DB) GetStdlibPathsWithSuffix(ctx context.Context, suffix string) (path
 	}
 	return paths, nil
 }
+
+// maxSuggestionCandidates is the number of paths that SuggestSimilarPaths
+// reads from the database before ranking them.
+const maxSuggestionCandidates = 100
+
+// SuggestSimilarPaths returns at most limit paths that are similar to path, as
+// defined by internal.SimilarPaths. It is intended for suggestions when path
+// is not found.
+//
+// Candidates are the paths that are trigram-similar to path or are in its
+// directory, read using the idx_paths_path_trgm index.
+func (db *DB) SuggestSimilarPaths(ctx context.Context, path string, limit int) (_ []string, err error) {
+	defer derrors.Wrap(&err, "DB.SuggestSimilarPaths(ctx, %q, %d)", path, limit)
+
+	// dirPattern matches the paths in the directory containing path. It is
+	// empty, and matches nothing, if path has no directory.
+	var dirPattern string
+	if i := strings.LastIndexByte(path, '/'); i > 0 {
+		dirPattern = likeEscaper.Replace(path[:i+1]) + "%"
+	}
+	query := `
+		SELECT path
+		FROM (
+			SELECT DISTINCT path
+			FROM paths
+			WHERE path % $1
+			OR ($2 != '' AND path LIKE $2)
+		) p
+		ORDER BY similarity(path, $1) DESC, path
+		LIMIT $3
+	`
+	var candidates []string
+	err = db.db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		candidates = append(candidates, p)
+		return nil
+	}, path, dirPattern, maxSuggestionCandidates)
+	if err != nil {
+		return nil, err
+	}
+	return internal.SimilarPaths(path, candidates, limit), nil
+}
+
+// likeEscaper escapes the characters that are special in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -159,6 +159,43 @@ func TestGetPathInfo(t *testing.T) {
 	}
 }
 
+func TestClassifyPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	// m.com contains the packages m.com, m.com/dir/a and m.com/b, and so the
+	// directory m.com/dir.
+	m := sample.Module("m.com", "v1.0.0", "", "dir/a", "b")
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path, module, version string
+		want                  internal.PathKind
+	}{
+		{"m.com", "m.com", "v1.0.0", internal.PathKindModuleRoot},
+		{"m.com/dir/a", "m.com", "v1.0.0", internal.PathKindPackage},
+		{"m.com/dir", "m.com", "v1.0.0", internal.PathKindDirectory},
+		{"m.com/dir", internal.UnknownModulePath, internal.LatestVersion, internal.PathKindDirectory},
+		{"m.com/c", "m.com", "v1.0.0", internal.PathKindNotFound},
+		{"m.com/b", "m.com", "v2.0.0", internal.PathKindNotFound},
+	} {
+		got, err := testDB.ClassifyPath(ctx, test.path, test.module, test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("ClassifyPath(%q, %q, %q) = %q, want %q", test.path, test.module, test.version, got, test.want)
+		}
+	}
+}
+
 func TestGetStdlibPaths(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -207,3 +244,41 @@ func TestGetStdlibPaths(t *testing.T) {
 		t.Errorf("got %v, want %v", got, want)
 	}
 }
+
+func TestSuggestSimilarPaths(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []*internal.Module{
+		sample.Module("github.com/foo/bar", "v1.0.0", "baz", "baz/qux"),
+		sample.Module("github.com/foo/bar", "v1.1.0", "baz"),
+		sample.Module("golang.org/x/tools", "v1.0.0", "cmd/godoc"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		path  string
+		limit int
+		want  []string
+	}{
+		{"githubb.com/foo/bar/baz", 5, []string{"github.com/foo/bar/baz"}},
+		{"github.com/foo/bar/bax", 1, []string{"github.com/foo/bar/baz"}},
+		{"github.com/foo/bar/bax", 5, []string{"github.com/foo/bar/baz", "github.com/foo/bar/baz/qux"}},
+		{"example.com/unrelated", 5, []string{}},
+	} {
+		got, err := testDB.SuggestSimilarPaths(ctx, test.path, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("SuggestSimilarPaths(%q, %d) mismatch (-want +got):\n%s", test.path, test.limit, diff)
+		}
+	}
+}
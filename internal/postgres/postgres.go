@@ -5,20 +5,38 @@
 package postgres
 
 import (
+	"context"
+
+	"golang.org/x/pkgsite/internal/blobstore"
 	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
 )
 
 type DB struct {
 	db *database.DB
+
+	// importedBy buffers changes to imported-by counts. It is nil unless
+	// BufferImportedByCounts was called.
+	importedBy *importedByBuffer
+
+	// blobs stores the source files of packages in place of the
+	// source_files table. It is nil unless UseBlobStore was called.
+	blobs blobstore.BlobStore
 }
 
 // New returns a new postgres DB.
 func New(db *database.DB) *DB {
-	return &DB{db}
+	return &DB{db: db}
 }
 
-// Close closes a DB.
+// Close closes a DB, first flushing any buffered imported-by count changes.
 func (db *DB) Close() error {
+	if db.importedBy != nil {
+		ctx := context.Background()
+		if err := db.importedBy.close(ctx); err != nil {
+			log.Errorf(ctx, "DB.Close: %v", err)
+		}
+	}
 	return db.db.Close()
 }
 
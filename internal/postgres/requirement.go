@@ -0,0 +1,272 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetDependentModules returns up to limit modules whose go.mod file requires
+// modulePath, most popular first. The popularity of a module is the largest
+// imported-by count of any of its packages in search_documents; ties are
+// broken by module path.
+//
+// Each module is returned once, at the highest version that requires
+// modulePath.
+func (db *DB) GetDependentModules(ctx context.Context, modulePath string, limit int) (_ []*internal.ModuleInfo, err error) {
+	defer derrors.Wrap(&err, "DB.GetDependentModules(ctx, %q, %d)", modulePath, limit)
+
+	if modulePath == "" {
+		return nil, fmt.Errorf("modulePath cannot be empty: %w", derrors.InvalidArgument)
+	}
+	query := `
+		SELECT
+			m.module_path,
+			m.version,
+			m.commit_time,
+			m.version_type,
+			m.source_info,
+			m.redistributable,
+			m.has_go_mod
+		FROM (
+			SELECT DISTINCT ON (m.module_path) m.*
+			FROM requirements r
+			INNER JOIN modules m
+			ON r.module_id = m.id
+			WHERE
+				r.module_path = $1
+				AND m.module_path <> $1
+			ORDER BY m.module_path, m.sort_version DESC
+		) m
+		LEFT JOIN LATERAL (
+			SELECT MAX(imported_by_count) AS imported_by_count
+			FROM search_documents
+			WHERE module_path = m.module_path
+		) s ON true
+		ORDER BY
+			COALESCE(s.imported_by_count, 0) DESC,
+			m.module_path
+		LIMIT $2;`
+
+	var modules []*internal.ModuleInfo
+	collect := func(rows *sql.Rows) error {
+		var (
+			mi       internal.ModuleInfo
+			hasGoMod sql.NullBool
+		)
+		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime, &mi.VersionType,
+			jsonbScanner{&mi.SourceInfo}, &mi.IsRedistributable, &hasGoMod); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		setHasGoMod(&mi, hasGoMod)
+		modules = append(modules, &mi)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, limit); err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// GetDependencyDepth returns the depth of the requirement graph of the module
+// version specified by modulePath and version, as described by
+// internal.DependencyDepth. It makes one query for each level of the graph,
+// and so at most internal.MaxDependencyDepth+1 in all. Required module
+// versions that are not in the modules table have no requirements.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetDependencyDepth(ctx context.Context, modulePath, version string) (_ int, err error) {
+	defer derrors.Wrap(&err, "DB.GetDependencyDepth(ctx, %q, %q)", modulePath, version)
+
+	reqs, err := db.GetRequirements(ctx, modulePath, version)
+	if err != nil {
+		return 0, err
+	}
+	return internal.DependencyDepth(ctx, modulePath, version, reqs, db.requirementsOf)
+}
+
+// requirementsOf returns the requirements of all the module versions in mvs,
+// which are stored as Requirements. Module versions that do not exist are
+// ignored.
+func (db *DB) requirementsOf(ctx context.Context, mvs []*internal.Requirement) ([]*internal.Requirement, error) {
+	var paths, versions []string
+	for _, mv := range mvs {
+		paths = append(paths, mv.ModulePath)
+		versions = append(versions, mv.Version)
+	}
+	query := `
+		SELECT r.module_path, r.version, r.indirect
+		FROM modules m
+		INNER JOIN requirements r
+		ON r.module_id = m.id
+		WHERE (m.module_path, m.version) IN (
+			SELECT * FROM unnest($1::text[], $2::text[])
+		);`
+	var reqs []*internal.Requirement
+	collect := func(rows *sql.Rows) error {
+		var r internal.Requirement
+		if err := rows.Scan(&r.ModulePath, &r.Version, &r.Indirect); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		reqs = append(reqs, &r)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pq.Array(paths), pq.Array(versions)); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// GetRequirements returns the modules required by the go.mod file of the
+// module version specified by modulePath and version, sorted by module path.
+// A module version with no requirements yields an empty slice.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetRequirements(ctx context.Context, modulePath, version string) (_ []*internal.Requirement, err error) {
+	defer derrors.Wrap(&err, "DB.GetRequirements(ctx, %q, %q)", modulePath, version)
+
+	query := `
+		SELECT r.module_path, r.version, r.indirect
+		FROM modules m
+		LEFT JOIN requirements r
+		ON r.module_id = m.id
+		WHERE
+			m.module_path = $1
+			AND m.version = $2
+		ORDER BY r.module_path;`
+	var (
+		found bool
+		reqs  = []*internal.Requirement{}
+	)
+	collect := func(rows *sql.Rows) error {
+		var (
+			path, vers sql.NullString
+			indirect   sql.NullBool
+		)
+		if err := rows.Scan(&path, &vers, &indirect); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		found = true
+		if path.Valid {
+			reqs = append(reqs, &internal.Requirement{
+				ModulePath: path.String,
+				Version:    vers.String,
+				Indirect:   indirect.Bool,
+			})
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return reqs, nil
+}
+
+// GetParsedModFile returns the go.mod file of the module version specified by
+// modulePath and version, parsed by internal.ParseModFile from the go_mod
+// column of the modules table.
+//
+// If the module version does not exist, has no go.mod file, or was stored
+// before go.mod files were, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetParsedModFile(ctx context.Context, modulePath, version string) (_ *internal.ModFile, err error) {
+	defer derrors.Wrap(&err, "DB.GetParsedModFile(ctx, %q, %q)", modulePath, version)
+
+	var goMod []byte
+	err = db.db.QueryRow(ctx, `
+		SELECT go_mod
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version).Scan(&goMod)
+	switch err {
+	case sql.ErrNoRows:
+		return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	case nil:
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	if goMod == nil {
+		return nil, fmt.Errorf("go.mod file of %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return internal.ParseModFile(goMod)
+}
+
+// GetModFileDiff returns the difference between the require directives of the
+// go.mod files of versions fromVersion and toVersion of the module with path
+// modulePath, both parsed as by GetParsedModFile.
+//
+// If either version does not exist, has no go.mod file, or was stored before
+// go.mod files were, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetModFileDiff(ctx context.Context, modulePath, fromVersion, toVersion string) (_ *internal.ModDiff, err error) {
+	defer derrors.Wrap(&err, "DB.GetModFileDiff(ctx, %q, %q, %q)", modulePath, fromVersion, toVersion)
+
+	from, err := db.GetParsedModFile(ctx, modulePath, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := db.GetParsedModFile(ctx, modulePath, toVersion)
+	if err != nil {
+		return nil, err
+	}
+	return internal.DiffModFiles(from, to), nil
+}
+
+// GetReplaceDirectives returns the replace directives of the go.mod file of
+// the module version specified by modulePath and version, parsed as by
+// GetParsedModFile.
+//
+// If the module version does not exist, has no go.mod file, or was stored
+// before go.mod files were, an error wrapping derrors.NotFound is returned.
+func (db *DB) GetReplaceDirectives(ctx context.Context, modulePath, version string) (_ []internal.Replace, err error) {
+	defer derrors.Wrap(&err, "DB.GetReplaceDirectives(ctx, %q, %q)", modulePath, version)
+
+	mf, err := db.GetParsedModFile(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return mf.ReplaceDirectives(), nil
+}
+
+// GetGoDirective returns the version in the go directive of the go.mod file of
+// the module version specified by modulePath and version, read from the go_mod
+// column of the modules table. If the module version has no go.mod file, was
+// stored before go.mod files were, or its go.mod file has no go directive, the
+// empty string is returned.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetGoDirective(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.GetGoDirective(ctx, %q, %q)", modulePath, version)
+
+	var goMod []byte
+	err = db.db.QueryRow(ctx, `
+		SELECT go_mod
+		FROM modules
+		WHERE module_path = $1 AND version = $2;`, modulePath, version).Scan(&goMod)
+	switch err {
+	case sql.ErrNoRows:
+		return "", fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	case nil:
+	default:
+		return "", fmt.Errorf("row.Scan(): %v", err)
+	}
+	if goMod == nil {
+		return "", nil
+	}
+	mf, err := internal.ParseModFile(goMod)
+	if err != nil {
+		return "", err
+	}
+	return mf.GoVersion, nil
+}
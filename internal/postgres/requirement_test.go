@@ -0,0 +1,351 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetDependentModules(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const target = "example.com/target"
+	for _, mod := range []struct {
+		modulePath, version, requires string
+	}{
+		{target, "v1.0.0", ""},
+		{"example.com/popular", "v1.0.0", target},
+		{"example.com/popular", "v1.1.0", target},
+		{"example.com/popular", "v1.2.0", ""},
+		{"example.com/unpopular", "v1.0.0", target},
+		{"example.com/other", "v1.0.0", "example.com/else"},
+	} {
+		m := sample.Module(mod.modulePath, mod.version, "")
+		if mod.requires != "" {
+			m.Requirements = []*internal.Requirement{{ModulePath: mod.requires, Version: "v1.0.0"}}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = 10 WHERE module_path = $1`,
+		"example.com/popular"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		modulePath string
+		limit      int
+		want       []string
+	}{
+		{
+			name:       "ranked by popularity",
+			modulePath: target,
+			limit:      10,
+			want:       []string{"example.com/popular@v1.1.0", "example.com/unpopular@v1.0.0"},
+		},
+		{
+			name:       "limit",
+			modulePath: target,
+			limit:      1,
+			want:       []string{"example.com/popular@v1.1.0"},
+		},
+		{
+			name:       "no dependents",
+			modulePath: "example.com/popular",
+			limit:      10,
+			want:       nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mods, err := testDB.GetDependentModules(ctx, test.modulePath, test.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, mi := range mods {
+				got = append(got, mi.ModulePath+"@"+mi.Version)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetRequirements(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	reqs := []*internal.Requirement{
+		{ModulePath: "golang.org/x/text", Version: "v0.3.0", Indirect: true},
+		{ModulePath: "example.com/dep", Version: "v1.2.0"},
+	}
+	m := sample.Module("example.com/reqs", "v1.0.0", "")
+	m.Requirements = reqs
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertModule(ctx, sample.Module("example.com/noreqs", "v1.0.0", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       []*internal.Requirement
+	}{
+		{"example.com/reqs", []*internal.Requirement{reqs[1], reqs[0]}},
+		{"example.com/noreqs", []*internal.Requirement{}},
+	} {
+		t.Run(test.modulePath, func(t *testing.T) {
+			got, err := testDB.GetRequirements(ctx, test.modulePath, "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := testDB.GetRequirements(ctx, "example.com/reqs", "v9.0.0")
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("got error %v, want %v", err, derrors.NotFound)
+		}
+	})
+}
+
+func TestGetDependencyDepth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	// a.com requires b.com, which requires c.com, which requires d.com, which
+	// is not stored, and a.com, making a cycle.
+	req := func(path string) *internal.Requirement {
+		return &internal.Requirement{ModulePath: path, Version: "v1.0.0"}
+	}
+	for path, reqs := range map[string][]*internal.Requirement{
+		"a.com": {req("b.com")},
+		"b.com": {req("c.com")},
+		"c.com": {req("d.com"), req("a.com")},
+		"e.com": nil,
+	} {
+		m := sample.Module(path, "v1.0.0", "")
+		m.Requirements = reqs
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		modulePath string
+		want       int
+	}{
+		{"a.com", 3},
+		{"b.com", 2},
+		{"c.com", 2},
+		{"e.com", 0},
+	} {
+		got, err := testDB.GetDependencyDepth(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetDependencyDepth(%q) = %d, want %d", test.modulePath, got, test.want)
+		}
+	}
+
+	if _, err := testDB.GetDependencyDepth(ctx, "a.com", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing version: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetParsedModFile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	withGoMod := sample.Module("a.com/m", "v1.0.0", "")
+	withGoMod.GoMod = []byte(`module a.com/m
+
+go 1.14
+
+require b.com/m v1.2.0
+
+exclude b.com/m v1.1.0
+
+replace b.com/m v1.2.0 => c.com/fork v1.2.1
+`)
+	for _, m := range []*internal.Module{withGoMod, sample.Module("d.com/m", "v1.0.0", "")} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetParsedModFile(ctx, "a.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.ModFile{
+		ModulePath: "a.com/m",
+		GoVersion:  "1.14",
+		Require:    []*internal.Requirement{{ModulePath: "b.com/m", Version: "v1.2.0"}},
+		Exclude:    []*internal.ModVersion{{Path: "b.com/m", Version: "v1.1.0"}},
+		Replace: []*internal.ModReplace{{
+			Old: internal.ModVersion{Path: "b.com/m", Version: "v1.2.0"},
+			New: internal.ModVersion{Path: "c.com/fork", Version: "v1.2.1"},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, mv := range [][2]string{{"d.com/m", "v1.0.0"}, {"a.com/m", "v2.0.0"}} {
+		_, err := testDB.GetParsedModFile(ctx, mv[0], mv[1])
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("GetParsedModFile(%q, %q): got error %v, want %v", mv[0], mv[1], err, derrors.NotFound)
+		}
+	}
+}
+
+func TestGetModFileDiff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	v1 := sample.Module("a.com/m", "v1.0.0", "")
+	v1.GoMod = []byte(`module a.com/m
+
+require b.com/m v1.2.0
+`)
+	v2 := sample.Module("a.com/m", "v1.1.0", "")
+	v2.GoMod = []byte(`module a.com/m
+
+require (
+	b.com/m v1.3.0
+	c.com/m v0.1.0
+)
+`)
+	for _, m := range []*internal.Module{v1, v2} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetModFileDiff(ctx, "a.com/m", "v1.0.0", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.ModDiff{
+		Added: []*internal.Requirement{{ModulePath: "c.com/m", Version: "v0.1.0"}},
+		Changed: []*internal.RequirementChange{{
+			ModulePath: "b.com/m",
+			From:       &internal.Requirement{ModulePath: "b.com/m", Version: "v1.2.0"},
+			To:         &internal.Requirement{ModulePath: "b.com/m", Version: "v1.3.0"},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := testDB.GetModFileDiff(ctx, "a.com/m", "v1.0.0", "v2.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetModFileDiff(missing version): got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetReplaceDirectives(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("a.com/m", "v1.0.0", "")
+	m.GoMod = []byte(`module a.com/m
+
+require (
+	b.com/m v1.2.0
+	c.com/m v0.1.0
+)
+
+replace (
+	b.com/m v1.2.0 => d.com/fork v1.2.1
+	c.com/m => ../c
+)
+`)
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetReplaceDirectives(ctx, "a.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []internal.Replace{
+		{Old: "b.com/m", OldVersion: "v1.2.0", New: "d.com/fork", NewVersion: "v1.2.1"},
+		{Old: "c.com/m", New: "../c"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := testDB.GetReplaceDirectives(ctx, "a.com/m", "v2.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetGoDirective(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	withGo := sample.Module("a.com/m", "v1.0.0", "")
+	withGo.GoMod = []byte("module a.com/m\n\ngo 1.14\n")
+	withoutGo := sample.Module("b.com/m", "v1.0.0", "")
+	withoutGo.GoMod = []byte("module b.com/m\n\nrequire c.com/m v1.2.0\n")
+	noGoMod := sample.Module("d.com/m", "v1.0.0", "")
+	for _, m := range []*internal.Module{withGo, withoutGo, noGoMod} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		modulePath, want string
+	}{
+		{"a.com/m", "1.14"},
+		{"b.com/m", ""},
+		{"d.com/m", ""},
+	} {
+		got, err := testDB.GetGoDirective(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetGoDirective(%q) = %q, want %q", test.modulePath, got, test.want)
+		}
+	}
+	if _, err := testDB.GetGoDirective(ctx, "a.com/m", "v2.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetGoDirective(missing version): got error %v, want %v", err, derrors.NotFound)
+	}
+}
@@ -137,6 +137,193 @@ func (db *DB) Search(ctx context.Context, q string, limit, offset int) (_ []*int
 	return results, nil
 }
 
+// SearchStream calls fn on the results of a search for q, one at a time and
+// in rank order, as they are read from the database, so that a large result
+// set is never held in memory. It stops after limit results have been read,
+// or when fn returns an error, in which case that error is returned.
+//
+// SearchStream always performs a deep search. Excluded paths are skipped but
+// still count towards limit, so fewer than limit results may be passed to fn
+// even if more exist. The NumResults field of each result is not set.
+func (db *DB) SearchStream(ctx context.Context, q string, limit int, fn func(*internal.SearchResult) error) (err error) {
+	defer derrors.Wrap(&err, "DB.SearchStream(ctx, %q, %d)", q, limit)
+
+	query := fmt.Sprintf(`
+		SELECT *
+		FROM (
+			SELECT
+				package_path,
+				version,
+				module_path,
+				name,
+				synopsis,
+				license_types,
+				commit_time,
+				imported_by_count,
+				(%s) AS score
+				FROM
+					search_documents
+				WHERE tsv_search_tokens @@ websearch_to_tsquery($1)
+				ORDER BY
+					score DESC,
+					commit_time DESC,
+					package_path
+		) r
+		WHERE r.score > 0.1
+		LIMIT $2`, scoreExpr)
+	collect := func(rows *sql.Rows) error {
+		var (
+			r            internal.SearchResult
+			licenseTypes []string
+		)
+		if err := rows.Scan(&r.PackagePath, &r.Version, &r.ModulePath, &r.Name,
+			database.NullIsEmpty(&r.Synopsis), pq.Array(&licenseTypes), &r.CommitTime,
+			&r.NumImportedBy, &r.Score); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		for _, l := range licenseTypes {
+			if l != "" {
+				r.Licenses = append(r.Licenses, l)
+			}
+		}
+		ex, err := db.IsExcluded(ctx, r.PackagePath)
+		if err != nil {
+			return err
+		}
+		if ex {
+			return nil
+		}
+		return fn(&r)
+	}
+	return db.db.RunQuery(ctx, query, collect, q, limit)
+}
+
+// GetRecentlyUpdatedPackages returns at most limit packages whose latest
+// version was stored after since, according to the version_updated_at column
+// of search_documents. Results are ordered by that time, newest first, then by
+// package path. Reprocessing a version a package already has does not count as
+// an update.
+//
+// Excluded paths are skipped but still count towards limit, so fewer than limit
+// results may be returned even if more exist. The Score and NumResults fields
+// of the results are not set.
+func (db *DB) GetRecentlyUpdatedPackages(ctx context.Context, since time.Time, limit int) (_ []*internal.SearchResult, err error) {
+	defer derrors.Wrap(&err, "DB.GetRecentlyUpdatedPackages(ctx, %s, %d)", since, limit)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative: %w", derrors.InvalidArgument)
+	}
+
+	query := `
+		SELECT
+			package_path,
+			version,
+			module_path,
+			name,
+			synopsis,
+			license_types,
+			commit_time,
+			imported_by_count
+		FROM search_documents
+		WHERE version_updated_at > $1
+		ORDER BY
+			version_updated_at DESC,
+			package_path
+		LIMIT $2`
+	var results []*internal.SearchResult
+	collect := func(rows *sql.Rows) error {
+		var (
+			r            internal.SearchResult
+			licenseTypes []string
+		)
+		if err := rows.Scan(&r.PackagePath, &r.Version, &r.ModulePath, &r.Name,
+			database.NullIsEmpty(&r.Synopsis), pq.Array(&licenseTypes), &r.CommitTime,
+			&r.NumImportedBy); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		for _, l := range licenseTypes {
+			if l != "" {
+				r.Licenses = append(r.Licenses, l)
+			}
+		}
+		ex, err := db.IsExcluded(ctx, r.PackagePath)
+		if err != nil {
+			return err
+		}
+		if !ex {
+			results = append(results, &r)
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, since, limit); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetPackagesByPrefix returns the latest version of each package whose import
+// path is prefix or is under prefix, from the search_documents table, in
+// order of package path. At most limit packages are returned, after skipping
+// the first offset. A prefix matches whole path elements, so "golang.org/x"
+// matches "golang.org/x/net" but not "golang.org/xerrors".
+//
+// Excluded paths are skipped but still count towards limit, so fewer than limit
+// results may be returned even if more exist. The Score and NumResults fields
+// of the results are not set.
+func (db *DB) GetPackagesByPrefix(ctx context.Context, prefix string, limit, offset int) (_ []*internal.SearchResult, err error) {
+	defer derrors.Wrap(&err, "DB.GetPackagesByPrefix(ctx, %q, %d, %d)", prefix, limit, offset)
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit and offset must be non-negative: %w", derrors.InvalidArgument)
+	}
+
+	// The LIKE pattern lets the query use the text_pattern_ops index on
+	// package_path.
+	query := `
+		SELECT
+			package_path,
+			version,
+			module_path,
+			name,
+			synopsis,
+			license_types,
+			commit_time,
+			imported_by_count
+		FROM search_documents
+		WHERE package_path = $1 OR package_path LIKE $2
+		ORDER BY package_path
+		LIMIT $3
+		OFFSET $4`
+	var results []*internal.SearchResult
+	collect := func(rows *sql.Rows) error {
+		var (
+			r            internal.SearchResult
+			licenseTypes []string
+		)
+		if err := rows.Scan(&r.PackagePath, &r.Version, &r.ModulePath, &r.Name,
+			database.NullIsEmpty(&r.Synopsis), pq.Array(&licenseTypes), &r.CommitTime,
+			&r.NumImportedBy); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		for _, l := range licenseTypes {
+			if l != "" {
+				r.Licenses = append(r.Licenses, l)
+			}
+		}
+		ex, err := db.IsExcluded(ctx, r.PackagePath)
+		if err != nil {
+			return err
+		}
+		if !ex {
+			results = append(results, &r)
+		}
+		return nil
+	}
+	pattern := likeEscaper.Replace(prefix) + "/%"
+	if err := db.db.RunQuery(ctx, query, collect, prefix, pattern, limit, offset); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 // Penalties to search scores, applied as multipliers to the score.
 const (
 	// Module license is non-redistributable.
@@ -641,10 +828,27 @@ func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before tim
 // It does so by completely recalculating the imported-by counts
 // from the imports_unique table.
 //
+// Imported-by count changes buffered by BufferImportedByCounts are flushed
+// first, and no flush runs until the recalculated counts are written, so that
+// buffered changes are not applied on top of counts that already include
+// them. The changes of modules inserted meanwhile are dropped for the same
+// reason.
+//
 // UpdateSearchDocumentsImportedByCount returns the number of rows updated.
 func (db *DB) UpdateSearchDocumentsImportedByCount(ctx context.Context) (nUpdated int64, err error) {
 	defer derrors.Wrap(&err, "UpdateSearchDocumentsImportedByCount(ctx)")
 
+	if db.importedBy == nil {
+		return db.updateSearchDocumentsImportedByCount(ctx)
+	}
+	err = db.importedBy.recompute(ctx, func() error {
+		nUpdated, err = db.updateSearchDocumentsImportedByCount(ctx)
+		return err
+	})
+	return nUpdated, err
+}
+
+func (db *DB) updateSearchDocumentsImportedByCount(ctx context.Context) (nUpdated int64, err error) {
 	searchPackages, err := db.getSearchPackages(ctx)
 	if err != nil {
 		return 0, err
@@ -712,10 +916,7 @@ func (db *DB) computeImportedByCounts(ctx context.Context, searchDocsPackages ma
 		if !searchDocsPackages[from] {
 			continue
 		}
-		// Don't count an importer if it's in the same module as what it's importing.
-		// Approximate that check by seeing if from_module_path is a prefix of to_path.
-		// (In some cases, e.g. when to_path is in a nested module, that is not correct.)
-		if (fromMod == stdlib.ModulePath && stdlib.Contains(to)) || strings.HasPrefix(to+"/", fromMod+"/") {
+		if !countsAsImportedBy(fromMod, to) {
 			continue
 		}
 		counts[to]++
@@ -726,6 +927,19 @@ func (db *DB) computeImportedByCounts(ctx context.Context, searchDocsPackages ma
 	return counts, nil
 }
 
+// countsAsImportedBy reports whether an import of toPath by a package in the
+// module fromModulePath counts toward the imported-by count of toPath.
+//
+// An importer doesn't count if it's in the same module as what it's importing.
+// Approximate that check by seeing if fromModulePath is a prefix of toPath.
+// (In some cases, e.g. when toPath is in a nested module, that is not correct.)
+func countsAsImportedBy(fromModulePath, toPath string) bool {
+	if fromModulePath == stdlib.ModulePath && stdlib.Contains(toPath) {
+		return false
+	}
+	return !strings.HasPrefix(toPath+"/", fromModulePath+"/")
+}
+
 func insertImportedByCounts(ctx context.Context, db *database.DB, counts map[string]int) (err error) {
 	defer derrors.Wrap(&err, "insertImportedByCounts(ctx, db, counts)")
 
@@ -958,3 +1172,59 @@ func (db *DB) DeleteOlderVersionFromSearchDocuments(ctx context.Context, moduleP
 		return nil
 	})
 }
+
+// SearchByLicense returns the latest version of packages that are covered by
+// a license of type spdxID, such as "MIT". Results are ordered by the number
+// of packages that import them, most popular first. Excluded paths are
+// omitted.
+func (db *DB) SearchByLicense(ctx context.Context, spdxID string, limit, offset int) (_ []*internal.PackageMeta, err error) {
+	defer derrors.Wrap(&err, "DB.SearchByLicense(ctx, %q, %d, %d)", spdxID, limit, offset)
+
+	query := `
+		SELECT
+			package_path,
+			module_path,
+			version,
+			name,
+			synopsis,
+			license_types,
+			imported_by_count
+		FROM search_documents
+		WHERE license_types @> ARRAY[$1]::text[]
+		ORDER BY imported_by_count DESC, package_path
+		LIMIT $2
+		OFFSET $3;`
+	var pkgs []*internal.PackageMeta
+	collect := func(rows *sql.Rows) error {
+		var (
+			pm           internal.PackageMeta
+			licenseTypes []string
+		)
+		if err := rows.Scan(&pm.Path, &pm.ModulePath, &pm.Version, &pm.Name,
+			database.NullIsEmpty(&pm.Synopsis), pq.Array(&licenseTypes), &pm.NumImportedBy); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		for _, l := range licenseTypes {
+			if l != "" {
+				pm.Licenses = append(pm.Licenses, l)
+			}
+		}
+		pkgs = append(pkgs, &pm)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, spdxID, limit, offset); err != nil {
+		return nil, err
+	}
+	// Filter out excluded paths.
+	var results []*internal.PackageMeta
+	for _, pm := range pkgs {
+		ex, err := db.IsExcluded(ctx, pm.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !ex {
+			results = append(results, pm)
+		}
+	}
+	return results, nil
+}
@@ -23,6 +23,7 @@ import (
 	"go.opencensus.io/stats/view"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -1060,3 +1061,226 @@ func TestDeleteOlderVersionFromSearch(t *testing.T) {
 	insert(mod)
 	check(mod)
 }
+
+func TestSearchByLicense(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	gpl := []*licenses.Metadata{{Types: []string{"GPL-3.0"}, FilePath: "LICENSE"}}
+	for _, test := range []struct {
+		modulePath      string
+		licenses        []*licenses.Metadata
+		importedByCount int
+	}{
+		{"mit.com/less", sample.LicenseMetadata, 1},
+		{"mit.com/more", sample.LicenseMetadata, 5},
+		{"gpl.com/most", gpl, 10},
+	} {
+		m := sample.Module(test.modulePath, sample.VersionString, "p")
+		m.LegacyPackages[0].Licenses = test.licenses
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = $1 WHERE package_path = $2`,
+			test.importedByCount, m.LegacyPackages[0].Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.SearchByLicense(ctx, "MIT", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.PackageMeta{
+		{
+			Path:          "mit.com/more/p",
+			ModulePath:    "mit.com/more",
+			Version:       sample.VersionString,
+			Name:          "p",
+			Synopsis:      sample.Synopsis,
+			Licenses:      []string{"MIT"},
+			NumImportedBy: 5,
+		},
+		{
+			Path:          "mit.com/less/p",
+			ModulePath:    "mit.com/less",
+			Version:       sample.VersionString,
+			Name:          "p",
+			Synopsis:      sample.Synopsis,
+			Licenses:      []string{"MIT"},
+			NumImportedBy: 1,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("testDB.SearchByLicense(ctx, %q, 10, 0) mismatch (-want +got):\n%s", "MIT", diff)
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, test := range []struct {
+		modulePath      string
+		importedByCount int
+	}{
+		{"stream.com/low", 1},
+		{"stream.com/high", 100},
+		{"stream.com/mid", 10},
+		{"stream.com/excluded", 1000},
+	} {
+		m := sample.Module(test.modulePath, sample.VersionString, "p")
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = $1 WHERE package_path = $2`,
+			test.importedByCount, m.LegacyPackages[0].Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := testDB.InsertExcludedPrefix(ctx, "stream.com/excluded", "user", "reason"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		limit int
+		want  []string
+	}{
+		{limit: 10, want: []string{"stream.com/high/p", "stream.com/mid/p", "stream.com/low/p"}},
+		{limit: 3, want: []string{"stream.com/high/p", "stream.com/mid/p"}},
+	} {
+		var got []string
+		err := testDB.SearchStream(ctx, "stream", test.limit, func(r *internal.SearchResult) error {
+			got = append(got, r.PackagePath)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("SearchStream(ctx, %q, %d) mismatch (-want +got):\n%s", "stream", test.limit, diff)
+		}
+	}
+
+	t.Run("stop early", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var got []string
+		err := testDB.SearchStream(ctx, "stream", 10, func(r *internal.SearchResult) error {
+			got = append(got, r.PackagePath)
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("got error %v, want %v", err, errStop)
+		}
+		if want := []string{"stream.com/high/p"}; !cmp.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestGetRecentlyUpdatedPackages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	base := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		modulePath string
+		updated    time.Time
+	}{
+		{"recent.com/old", base},
+		{"recent.com/new", base.Add(2 * time.Hour)},
+		{"recent.com/mid", base.Add(time.Hour)},
+		{"recent.com/tie", base.Add(time.Hour)},
+		{"recent.com/excluded", base.Add(3 * time.Hour)},
+	} {
+		m := sample.Module(test.modulePath, sample.VersionString, "p")
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET version_updated_at = $1 WHERE module_path = $2`,
+			test.updated, test.modulePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := testDB.InsertExcludedPrefix(ctx, "recent.com/excluded", "user", "reason"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		since time.Time
+		limit int
+		want  []string
+	}{
+		{base.Add(-time.Hour), 10, []string{"recent.com/new/p", "recent.com/mid/p", "recent.com/tie/p", "recent.com/old/p"}},
+		{base, 10, []string{"recent.com/new/p", "recent.com/mid/p", "recent.com/tie/p"}},
+		{base, 3, []string{"recent.com/new/p", "recent.com/mid/p"}},
+		{base.Add(3 * time.Hour), 10, nil},
+	} {
+		results, err := testDB.GetRecentlyUpdatedPackages(ctx, test.since, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.PackagePath)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetRecentlyUpdatedPackages(ctx, %s, %d) mismatch (-want +got):\n%s", test.since, test.limit, diff)
+		}
+	}
+	if _, err := testDB.GetRecentlyUpdatedPackages(ctx, time.Time{}, -1); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("negative limit: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestGetPackagesByPrefix(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []*internal.Module{
+		sample.Module("prefix.com/x", "v1.0.0", "p"),
+		sample.Module("prefix.com/x", "v1.1.0", "p", "q"),
+		sample.Module("prefix.com/x/sub", sample.VersionString, "r"),
+		sample.Module("prefix.com/xy", sample.VersionString, "p"),
+		sample.Module("other.com/x", sample.VersionString, "p"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		prefix        string
+		limit, offset int
+		want          []string
+	}{
+		{"prefix.com/x", 10, 0, []string{"prefix.com/x/p@v1.1.0", "prefix.com/x/q@v1.1.0", "prefix.com/x/sub/r@" + sample.VersionString}},
+		{"prefix.com/x", 1, 1, []string{"prefix.com/x/q@v1.1.0"}},
+		{"prefix.com/x/p", 10, 0, []string{"prefix.com/x/p@v1.1.0"}},
+		// The prefix must match whole path elements, and "_" is not a
+		// wildcard.
+		{"prefix.com/x_", 10, 0, nil},
+		{"prefix.com", 2, 3, []string{"prefix.com/xy/p@" + sample.VersionString}},
+	} {
+		results, err := testDB.GetPackagesByPrefix(ctx, test.prefix, test.limit, test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.PackagePath+"@"+r.Version)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetPackagesByPrefix(ctx, %q, %d, %d) mismatch (-want +got):\n%s", test.prefix, test.limit, test.offset, diff)
+		}
+	}
+	for _, lo := range [][2]int{{-1, 0}, {1, -1}} {
+		if _, err := testDB.GetPackagesByPrefix(ctx, "prefix.com", lo[0], lo[1]); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("GetPackagesByPrefix(ctx, %q, %d, %d): got error %v, want InvalidArgument", "prefix.com", lo[0], lo[1], err)
+		}
+	}
+}
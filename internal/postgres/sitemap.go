@@ -0,0 +1,72 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetSitemapEntries returns at most limit of the paths in the packages table
+// and the module paths in the modules table, in order of path, skipping the
+// first offset of them. Each path appears once. Its LastModified is the
+// updated_at time of the module version that GetPathInfo would choose for it
+// as latest: the highest release version, or if there is none, the highest
+// version.
+//
+// Since the paths are ordered, successive pages do not overlap as long as no
+// paths are added. Excluded paths are skipped but still count towards limit,
+// so a page may have fewer than limit entries even if more exist.
+//
+// limit must be positive and at most internal.MaxSitemapEntries, and offset
+// must not be negative.
+func (db *DB) GetSitemapEntries(ctx context.Context, limit, offset int) (_ []internal.SitemapEntry, err error) {
+	defer derrors.Wrap(&err, "DB.GetSitemapEntries(ctx, %d, %d)", limit, offset)
+
+	if limit <= 0 || limit > internal.MaxSitemapEntries || offset < 0 {
+		return nil, fmt.Errorf("limit %d, offset %d: %w", limit, offset, derrors.InvalidArgument)
+	}
+	query := `
+		SELECT DISTINCT ON (e.path) e.path, e.updated_at
+		FROM (
+			SELECT p.path, m.module_path, m.version_type, m.sort_version, m.updated_at
+			FROM packages p
+			INNER JOIN modules m
+			ON p.module_path = m.module_path AND p.version = m.version
+			UNION ALL
+			SELECT module_path, module_path, version_type, sort_version, updated_at
+			FROM modules
+		) e
+		ORDER BY
+			e.path,
+			e.version_type = 'release' DESC,
+			e.sort_version DESC,
+			e.module_path DESC
+		LIMIT $1
+		OFFSET $2;`
+	var entries []internal.SitemapEntry
+	collect := func(rows *sql.Rows) error {
+		var e internal.SitemapEntry
+		if err := rows.Scan(&e.Path, &e.LastModified); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		ex, err := db.IsExcluded(ctx, e.Path)
+		if err != nil {
+			return err
+		}
+		if !ex {
+			entries = append(entries, e)
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, limit, offset); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetSitemapEntries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	var (
+		t1 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		t2 = time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+		t3 = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	)
+	for _, test := range []struct {
+		m         *internal.Module
+		updatedAt time.Time
+	}{
+		// v1.0.0 and v1.2.0-pre of a.com/m are fetched after v1.1.0, but
+		// v1.1.0 is the latest version, since releases come first.
+		{sample.Module("a.com/m", "v1.0.0", "foo"), t3},
+		{sample.Module("a.com/m", "v1.1.0", "foo", "bar"), t2},
+		{sample.Module("a.com/m", "v1.2.0-pre", "foo"), t3},
+		{sample.Module("b.com/m", "v1.0.0", ""), t1},
+	} {
+		if err := testDB.InsertModule(ctx, test.m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `UPDATE modules SET updated_at = $1 WHERE module_path = $2 AND version = $3`,
+			test.updatedAt, test.m.ModulePath, test.m.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []internal.SitemapEntry{
+		{Path: "a.com/m", LastModified: t2},
+		{Path: "a.com/m/bar", LastModified: t2},
+		{Path: "a.com/m/foo", LastModified: t2},
+		{Path: "b.com/m", LastModified: t1},
+	}
+	got, err := testDB.GetSitemapEntries(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// Pages of every size cover the entries exactly once, in order.
+	for limit := 1; limit <= len(want); limit++ {
+		var paged []internal.SitemapEntry
+		for offset := 0; offset < len(want); offset += limit {
+			page, err := testDB.GetSitemapEntries(ctx, limit, offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page...)
+		}
+		if diff := cmp.Diff(want, paged); diff != "" {
+			t.Errorf("limit %d: mismatch (-want +got):\n%s", limit, diff)
+		}
+	}
+
+	for _, args := range [][2]int{{0, 0}, {internal.MaxSitemapEntries + 1, 0}, {10, -1}} {
+		if _, err := testDB.GetSitemapEntries(ctx, args[0], args[1]); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("GetSitemapEntries(ctx, %d, %d): got error %v, want %v", args[0], args[1], err, derrors.InvalidArgument)
+		}
+	}
+}
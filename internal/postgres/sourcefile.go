@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blobstore"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// UseBlobStore makes db store the source files of the packages of the module
+// versions it inserts in bs, instead of in the source_files table, to keep
+// large contents out of the database. GetSourceFile reads from bs first, and
+// falls back to source_files for module versions inserted before bs was used.
+//
+// UseBlobStore must be called at most once, before db is used.
+func (db *DB) UseBlobStore(bs blobstore.BlobStore) {
+	db.blobs = bs
+}
+
+// GetSourceFile returns the contents of the .go file named fileName in the
+// directory of the package specified by pkgPath, modulePath and version,
+// from the blob store if UseBlobStore was called and the file is there, and
+// otherwise from the source_files table.
+//
+// If the package or the file does not exist, an error wrapping
+// derrors.NotFound is returned. Files of packages that are not
+// redistributable are not found either, even if the blob store still has
+// them from when the package was redistributable.
+func (db *DB) GetSourceFile(ctx context.Context, pkgPath, modulePath, version, fileName string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "DB.GetSourceFile(ctx, %q, %q, %q, %q)", pkgPath, modulePath, version, fileName)
+
+	var (
+		pathID          int
+		redistributable bool
+	)
+	row := db.db.QueryRow(ctx, `
+		SELECT p.id, p.redistributable
+		FROM paths p
+		INNER JOIN modules m
+		ON p.module_id = m.id
+		WHERE
+			p.path = $1
+			AND m.module_path = $2
+			AND m.version = $3
+			AND p.name != '';`, pkgPath, modulePath, version)
+	if err := row.Scan(&pathID, &redistributable); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	if !redistributable {
+		return nil, fmt.Errorf("package %s@%s is not redistributable: %w", pkgPath, version, derrors.NotFound)
+	}
+	if db.blobs != nil {
+		contents, err := db.blobs.Get(ctx, modulePath, version, sourceBlobName(modulePath, pkgPath, fileName))
+		if err == nil {
+			return contents, nil
+		}
+		if !errors.Is(err, derrors.NotFound) {
+			return nil, err
+		}
+	}
+	var contents []byte
+	row = db.db.QueryRow(ctx, `
+		SELECT contents
+		FROM source_files
+		WHERE path_id = $1 AND file_name = $2;`, pathID, fileName)
+	if err := row.Scan(&contents); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("file %q: %w", fileName, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	return contents, nil
+}
+
+// putSourceFiles stores the source files of the packages of m in bs.
+func putSourceFiles(ctx context.Context, bs blobstore.BlobStore, m *internal.Module) (err error) {
+	defer derrors.Wrap(&err, "putSourceFiles(ctx, %q, %q)", m.ModulePath, m.Version)
+	for _, d := range m.Directories {
+		if d.Package == nil {
+			continue
+		}
+		for name, contents := range d.Package.SourceFiles {
+			if err := bs.Put(ctx, m.ModulePath, m.Version, sourceBlobName(m.ModulePath, d.Path, name), contents); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sourceBlobName returns the name of the blob for the file named fileName in
+// the directory of the package with path pkgPath in the module with path
+// modulePath: the path of the file relative to the module root. Standard
+// library packages are not under their module path, so their files are named
+// by their full package path.
+func sourceBlobName(modulePath, pkgPath, fileName string) string {
+	if pkgPath == modulePath {
+		return fileName
+	}
+	if strings.HasPrefix(pkgPath, modulePath+"/") {
+		return pkgPath[len(modulePath)+1:] + "/" + fileName
+	}
+	return pkgPath + "/" + fileName
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blobstore"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetSourceFile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	const fooGo = "package foo\n\nconst A = 1\n"
+	var (
+		fooPath = sample.ModulePath + "/foo"
+		barPath = sample.ModulePath + "/bar"
+	)
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar")
+	for _, d := range m.Directories {
+		switch d.Path {
+		case fooPath:
+			d.Package.SourceFiles = map[string][]byte{"foo.go": []byte(fooGo)}
+		case barPath:
+			// The files of packages that are not redistributable are not stored.
+			d.IsRedistributable = false
+			d.Package.SourceFiles = map[string][]byte{"bar.go": []byte("package bar\n")}
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetSourceFile(ctx, fooPath, sample.ModulePath, sample.VersionString, "foo.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != fooGo {
+		t.Errorf("GetSourceFile = %q, want %q", got, fooGo)
+	}
+
+	for _, test := range []struct {
+		name, pkgPath, fileName string
+	}{
+		{"missing file", fooPath, "missing.go"},
+		{"missing package", sample.ModulePath + "/missing", "foo.go"},
+		{"not redistributable", barPath, "bar.go"},
+	} {
+		_, err := testDB.GetSourceFile(ctx, test.pkgPath, sample.ModulePath, sample.VersionString, test.fileName)
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, derrors.NotFound)
+		}
+	}
+}
+
+func TestGetSourceFileAfterReprocessing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	var (
+		fooPath = sample.ModulePath + "/foo"
+		barPath = sample.ModulePath + "/bar"
+	)
+	insert := func(fooFiles []string, barRedistributable bool) {
+		t.Helper()
+		m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar")
+		for _, d := range m.Directories {
+			switch d.Path {
+			case fooPath:
+				d.Package.SourceFiles = map[string][]byte{}
+				for _, f := range fooFiles {
+					d.Package.SourceFiles[f] = []byte("package foo\n")
+				}
+			case barPath:
+				d.IsRedistributable = barRedistributable
+				d.Package.SourceFiles = map[string][]byte{"bar.go": []byte("package bar\n")}
+			}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert([]string{"foo.go", "old.go"}, true)
+	if _, err := testDB.GetSourceFile(ctx, barPath, sample.ModulePath, sample.VersionString, "bar.go"); err != nil {
+		t.Fatal(err)
+	}
+	// Reprocessing the module version drops old.go, and bar is no longer
+	// redistributable.
+	insert([]string{"foo.go"}, false)
+
+	if _, err := testDB.GetSourceFile(ctx, fooPath, sample.ModulePath, sample.VersionString, "foo.go"); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name, pkgPath, fileName string
+	}{
+		{"removed file", fooPath, "old.go"},
+		{"no longer redistributable", barPath, "bar.go"},
+	} {
+		_, err := testDB.GetSourceFile(ctx, test.pkgPath, sample.ModulePath, sample.VersionString, test.fileName)
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, derrors.NotFound)
+		}
+	}
+}
+
+func TestGetSourceFileBlobStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	fooPath := sample.ModulePath + "/foo"
+	newModule := func(version, contents string) *internal.Module {
+		m := sample.Module(sample.ModulePath, version, "foo")
+		for _, d := range m.Directories {
+			if d.Path == fooPath {
+				d.Package.SourceFiles = map[string][]byte{"foo.go": []byte(contents)}
+			}
+		}
+		return m
+	}
+
+	// v1.0.0 is inserted before the blob store is used, so its file is only
+	// in source_files.
+	if err := testDB.InsertModule(ctx, newModule("v1.0.0", "package foo // 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	bs := blobstore.NewInMemory()
+	db := New(testDB.db)
+	db.UseBlobStore(bs)
+	if err := db.InsertModule(ctx, newModule("v1.1.0", "package foo // 2\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := bs.Get(ctx, sample.ModulePath, "v1.1.0", "foo/foo.go"); err != nil || string(got) != "package foo // 2\n" {
+		t.Errorf("blob of v1.1.0: Get = %q, %v; want %q, nil", got, err, "package foo // 2\n")
+	}
+	var n int
+	if err := testDB.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM source_files s
+		INNER JOIN paths p ON s.path_id = p.id
+		INNER JOIN modules m ON p.module_id = m.id
+		WHERE m.version = 'v1.1.0';`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d rows in source_files for v1.1.0, want 0", n)
+	}
+
+	for _, test := range []struct {
+		version, want string
+	}{
+		{"v1.0.0", "package foo // 1\n"},
+		{"v1.1.0", "package foo // 2\n"},
+	} {
+		got, err := db.GetSourceFile(ctx, fooPath, sample.ModulePath, test.version, "foo.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("GetSourceFile(%s) = %q, want %q", test.version, got, test.want)
+		}
+	}
+	if _, err := db.GetSourceFile(ctx, fooPath, sample.ModulePath, "v1.1.0", "missing.go"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing file: got error %v, want %v", err, derrors.NotFound)
+	}
+
+	// After v1.1.0 is reprocessed with foo no longer redistributable, its
+	// blob is left behind but not returned.
+	m := newModule("v1.1.0", "package foo // 2\n")
+	for _, d := range m.Directories {
+		if d.Path == fooPath {
+			d.IsRedistributable = false
+		}
+	}
+	if err := db.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetSourceFile(ctx, fooPath, sample.ModulePath, "v1.1.0", "foo.go"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("not redistributable: got error %v, want %v", err, derrors.NotFound)
+	}
+}
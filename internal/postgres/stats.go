@@ -0,0 +1,62 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// In-memory copy of the result of the last successful GetIndexStats query.
+// The counts scan whole tables, so they are only recomputed after
+// indexStatsExpiration.
+var indexStats struct {
+	mu          sync.Mutex
+	stats       internal.IndexStats
+	lastFetched time.Time
+}
+
+func setIndexStatsLastFetched(t time.Time) {
+	indexStats.mu.Lock()
+	indexStats.lastFetched = t
+	indexStats.mu.Unlock()
+}
+
+const indexStatsExpiration = 5 * time.Minute
+
+// GetIndexStats returns counts of the distinct module paths and the module
+// versions in the modules table, and of the distinct package paths in the
+// packages table. The counts are cached for a few minutes.
+func (db *DB) GetIndexStats(ctx context.Context) (_ *internal.IndexStats, err error) {
+	defer derrors.Wrap(&err, "DB.GetIndexStats(ctx)")
+
+	indexStats.mu.Lock()
+	if time.Since(indexStats.lastFetched) < indexStatsExpiration {
+		stats := indexStats.stats
+		indexStats.mu.Unlock()
+		return &stats, nil
+	}
+	indexStats.mu.Unlock()
+
+	var stats internal.IndexStats
+	err = db.db.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(DISTINCT module_path) FROM modules),
+			(SELECT COUNT(*) FROM modules),
+			(SELECT COUNT(DISTINCT path) FROM packages);`,
+	).Scan(&stats.TotalModules, &stats.TotalModuleVersions, &stats.TotalPackages)
+	if err != nil {
+		return nil, err
+	}
+	indexStats.mu.Lock()
+	indexStats.stats = stats
+	indexStats.lastFetched = time.Now()
+	indexStats.mu.Unlock()
+	return &stats, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetIndexStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []*internal.Module{
+		sample.Module("a.com/m", "v1.0.0", "foo"),
+		sample.Module("a.com/m", "v1.1.0", "foo", "bar"),
+		sample.Module("b.com/m", "v1.0.0", ""),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(want *internal.IndexStats) {
+		t.Helper()
+		got, err := testDB.GetIndexStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	}
+	want := &internal.IndexStats{TotalModules: 2, TotalModuleVersions: 3, TotalPackages: 3}
+	check(want)
+
+	// The counts are cached.
+	if err := testDB.InsertModule(ctx, sample.Module("c.com/m", "v1.0.0", "")); err != nil {
+		t.Fatal(err)
+	}
+	check(want)
+
+	setIndexStatsLastFetched(time.Time{})
+	check(&internal.IndexStats{TotalModules: 3, TotalModuleVersions: 4, TotalPackages: 4})
+}
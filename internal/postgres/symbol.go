@@ -0,0 +1,593 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetSymbolCounts returns the number of exported symbols of each kind in the
+// package specified by pkgPath, modulePath and version, computed from the
+// symbols table. Kinds with no symbols are omitted, so a package with no
+// exported symbols yields an empty map.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetSymbolCounts(ctx context.Context, pkgPath, modulePath, version string) (_ map[internal.SymbolKind]int, err error) {
+	defer derrors.Wrap(&err, "DB.GetSymbolCounts(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	pathID, err := db.getPackagePathID(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT kind, COUNT(DISTINCT name)
+		FROM symbols
+		WHERE path_id = $1
+		GROUP BY kind;`
+	counts := map[internal.SymbolKind]int{}
+	collect := func(rows *sql.Rows) error {
+		var (
+			kind internal.SymbolKind
+			n    int
+		)
+		if err := rows.Scan(&kind, &n); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		counts[kind] = n
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pathID); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetSymbols returns the exported symbols in the package specified by pkgPath,
+// modulePath and version, sorted by name, from the symbols table. If a symbol
+// is stored for more than one GOOS/GOARCH pair, it is returned once.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetSymbols(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.Symbol, err error) {
+	defer derrors.Wrap(&err, "DB.GetSymbols(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	pathID, err := db.getPackagePathID(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT DISTINCT ON (name) name, kind, synopsis, parent_name, doc
+		FROM symbols
+		WHERE path_id = $1
+		ORDER BY name, goos, goarch;`
+	var syms []*internal.Symbol
+	collect := func(rows *sql.Rows) error {
+		var s internal.Symbol
+		if err := rows.Scan(&s.Name, &s.Kind, &s.Synopsis, &s.ParentName, &s.Doc); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		syms = append(syms, &s)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pathID); err != nil {
+		return nil, err
+	}
+	return syms, nil
+}
+
+// GetDocOutline returns the outline of the documentation of the package
+// specified by pkgPath, modulePath and version, built by internal.BuildOutline
+// from the symbols returned by GetSymbols. The doc comments are not used.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetDocOutline(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.Outline, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocOutline(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	syms, err := db.GetSymbols(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return internal.BuildOutline(syms), nil
+}
+
+// GetDocText returns the plain text of the documentation of the package
+// specified by pkgPath, modulePath and version, as described by
+// internal.DocText. It is assembled from the package doc comment in the
+// documentation table and the doc comments in the symbols table, for the
+// first GOOS/GOARCH pair in alphabetical order, with symbols sorted by name.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned. A package without documentation yields the empty string.
+func (db *DB) GetDocText(ctx context.Context, pkgPath, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocText(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	pathID, err := db.getPackagePathID(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	var goos, goarch, pkgDoc string
+	err = db.db.QueryRow(ctx, `
+		SELECT goos, goarch, doc
+		FROM documentation
+		WHERE path_id = $1
+		ORDER BY goos, goarch
+		LIMIT 1;`, pathID).Scan(&goos, &goarch, &pkgDoc)
+	switch err {
+	case sql.ErrNoRows:
+		return "", nil
+	case nil:
+	default:
+		return "", err
+	}
+	query := `
+		SELECT synopsis, doc
+		FROM symbols
+		WHERE path_id = $1 AND goos = $2 AND goarch = $3
+		ORDER BY name;`
+	var syms []*internal.Symbol
+	collect := func(rows *sql.Rows) error {
+		var s internal.Symbol
+		if err := rows.Scan(&s.Synopsis, &s.Doc); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		syms = append(syms, &s)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pathID, goos, goarch); err != nil {
+		return "", err
+	}
+	return internal.DocText(pkgDoc, syms), nil
+}
+
+// GetDocSection returns the documentation of the package specified by
+// pkgPath, modulePath and version, for the first GOOS/GOARCH pair in
+// alphabetical order, with its HTML cut down to the named section as by
+// internal.DocSection. The section is extracted by the query, so the rest of
+// the documentation HTML is never read. A package without documentation
+// yields an empty Documentation.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetDocSection(ctx context.Context, pkgPath, modulePath, version, section string) (_ *internal.Documentation, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocSection(ctx, %q, %q, %q, %q)", pkgPath, modulePath, version, section)
+
+	start, ok := internal.DocSectionStart(section)
+	if !ok {
+		return nil, fmt.Errorf("unknown documentation section %q: %w", section, derrors.InvalidArgument)
+	}
+	pathID, err := db.getPackagePathID(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var doc internal.Documentation
+	err = db.db.QueryRow(ctx, `
+		SELECT
+			goos,
+			goarch,
+			synopsis,
+			CASE
+				WHEN strpos(html, $2) = 0 THEN ''
+				ELSE split_part(substr(html, strpos(html, $2)), $3, 1) || $3
+			END
+		FROM documentation
+		WHERE path_id = $1
+		ORDER BY goos, goarch
+		LIMIT 1;`, pathID, start, internal.DocSectionEnd).Scan(
+		&doc.GOOS, &doc.GOARCH, &doc.Synopsis, database.NullIsEmpty(&doc.HTML))
+	switch err {
+	case sql.ErrNoRows:
+		return &internal.Documentation{}, nil
+	case nil:
+		return &doc, nil
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+}
+
+// GetPlaygroundExamples returns the runnable examples of the package specified
+// by pkgPath, modulePath and version, sorted by name, from the
+// playground_examples table, for the first GOOS/GOARCH pair in alphabetical
+// order. A package without documentation or examples yields an empty slice.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetPlaygroundExamples(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.PlaygroundExample, err error) {
+	defer derrors.Wrap(&err, "DB.GetPlaygroundExamples(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	pathID, err := db.getPackagePathID(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT e.name, e.code
+		FROM playground_examples e
+		INNER JOIN (
+			SELECT goos, goarch
+			FROM documentation
+			WHERE path_id = $1
+			ORDER BY goos, goarch
+			LIMIT 1
+		) d
+		ON e.goos = d.goos AND e.goarch = d.goarch
+		WHERE e.path_id = $1
+		ORDER BY e.name;`
+	var exs []*internal.PlaygroundExample
+	collect := func(rows *sql.Rows) error {
+		var ex internal.PlaygroundExample
+		if err := rows.Scan(&ex.Name, &ex.Code); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		exs = append(exs, &ex)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pathID); err != nil {
+		return nil, err
+	}
+	return exs, nil
+}
+
+// GetDocDiffAcrossPlatforms returns, for each GOOS/GOARCH pair in the
+// documentation table for the package specified by pkgPath, modulePath and
+// version, the names of the symbols in the symbols table that are stored only
+// for that pair. A package without documentation yields an empty map.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetDocDiffAcrossPlatforms(ctx context.Context, pkgPath, modulePath, version string) (_ map[string][]string, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocDiffAcrossPlatforms(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	pathID, err := db.getPackagePathID(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT d.goos, d.goarch, s.name
+		FROM documentation d
+		LEFT JOIN symbols s
+		ON s.path_id = d.path_id AND s.goos = d.goos AND s.goarch = d.goarch
+		WHERE d.path_id = $1;`
+	symbolsByContext := map[string][]string{}
+	collect := func(rows *sql.Rows) error {
+		var (
+			bc   internal.BuildContext
+			name sql.NullString
+		)
+		if err := rows.Scan(&bc.GOOS, &bc.GOARCH, &name); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		// A build context without symbols has a single row with a NULL name.
+		names := symbolsByContext[bc.String()]
+		if name.Valid {
+			names = append(names, name.String)
+		}
+		symbolsByContext[bc.String()] = names
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pathID); err != nil {
+		return nil, err
+	}
+	return internal.PlatformOnlySymbols(symbolsByContext), nil
+}
+
+// GetDocumentationWithLinks returns the documentation HTML of the package
+// specified by pkgPath, modulePath and version, with references to the
+// exported symbols of the packages it imports turned into links by
+// internal.LinkDocReferences. A symbol is linked if the symbols table has it
+// for any stored version of the imported package.
+//
+// If the package does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetDocumentationWithLinks(ctx context.Context, pkgPath, modulePath, version string) (_ template.HTML, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocumentationWithLinks(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	pkg, err := db.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	imports, err := db.GetImports(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	query := `
+		SELECT DISTINCT p.path, p.name, s.name
+		FROM paths p
+		INNER JOIN symbols s
+		ON s.path_id = p.id
+		WHERE p.path = ANY($1);`
+	targets := map[string]*internal.LinkTarget{}
+	collect := func(rows *sql.Rows) error {
+		var path, name, symbol string
+		if err := rows.Scan(&path, &name, &symbol); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		t, ok := targets[path]
+		if !ok {
+			t = &internal.LinkTarget{Path: path, Name: name, Symbols: map[string]bool{}}
+			targets[path] = t
+		}
+		t.Symbols[symbol] = true
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pq.Array(imports)); err != nil {
+		return "", err
+	}
+	var ts []*internal.LinkTarget
+	for _, t := range targets {
+		ts = append(ts, t)
+	}
+	return internal.LinkDocReferences(pkg.DocumentationHTML, ts), nil
+}
+
+// GetAllDocs returns the documentation of every package in the module version
+// specified by modulePath and version, keyed by package path, in a single
+// query. If a package has documentation for more than one GOOS/GOARCH pair,
+// the first pair in alphabetical order is used. A package without
+// documentation, such as one whose documentation failed to render, maps to
+// nil; the reason is recorded in package_version_states, not returned here.
+//
+// The result holds the HTML and symbols of the whole module version in
+// memory, which can be tens of megabytes for large modules. Callers that can
+// process one package at a time should use StreamAllDocs instead.
+//
+// If the module version does not exist, an error wrapping derrors.NotFound is
+// returned.
+func (db *DB) GetAllDocs(ctx context.Context, modulePath, version string) (_ map[string]*internal.Documentation, err error) {
+	defer derrors.Wrap(&err, "DB.GetAllDocs(ctx, %q, %q)", modulePath, version)
+
+	docs := map[string]*internal.Documentation{}
+	err = db.StreamAllDocs(ctx, modulePath, version, func(pkgPath string, doc *internal.Documentation) error {
+		docs[pkgPath] = doc
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// StreamAllDocs is like GetAllDocs, but calls f with the documentation of
+// each package in turn, in order of package path, instead of collecting it
+// in a map. Only the documentation of one package is held in memory at a
+// time. If f returns an error, StreamAllDocs stops and returns it.
+func (db *DB) StreamAllDocs(ctx context.Context, modulePath, version string, f func(pkgPath string, doc *internal.Documentation) error) (err error) {
+	defer derrors.Wrap(&err, "DB.StreamAllDocs(ctx, %q, %q)", modulePath, version)
+
+	// The module is left-joined to its packages so that a module version
+	// without packages yields one row with a NULL path, and can be told
+	// apart from one that does not exist.
+	query := `
+		SELECT DISTINCT ON (p.path)
+			p.path, d.goos, d.goarch, d.synopsis, d.html, d.doc,
+			(
+				SELECT json_agg(json_build_object(
+					'Name', s.name,
+					'Kind', s.kind,
+					'Synopsis', s.synopsis,
+					'ParentName', s.parent_name,
+					'Doc', s.doc) ORDER BY s.name)
+				FROM symbols s
+				WHERE s.path_id = d.path_id AND s.goos = d.goos AND s.goarch = d.goarch
+			)
+		FROM modules m
+		LEFT JOIN paths p
+		ON p.module_id = m.id AND p.name != ''
+		LEFT JOIN documentation d
+		ON d.path_id = p.id
+		WHERE m.module_path = $1 AND m.version = $2
+		ORDER BY p.path, d.goos, d.goarch;`
+	found := false
+	collect := func(rows *sql.Rows) error {
+		found = true
+		var (
+			pkgPath, goos, goarch, synopsis, html, doc sql.NullString
+			symbols                                    []byte
+		)
+		if err := rows.Scan(&pkgPath, &goos, &goarch, &synopsis, &html, &doc, &symbols); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		if !pkgPath.Valid {
+			return nil
+		}
+		if !goos.Valid {
+			return f(pkgPath.String, nil)
+		}
+		d := &internal.Documentation{
+			GOOS:     goos.String,
+			GOARCH:   goarch.String,
+			Synopsis: synopsis.String,
+			HTML:     html.String,
+			Doc:      doc.String,
+		}
+		if symbols != nil {
+			if err := json.Unmarshal(symbols, &d.Symbols); err != nil {
+				return fmt.Errorf("symbols of %q: %v", pkgPath.String, err)
+			}
+		}
+		return f(pkgPath.String, d)
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("module %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return nil
+}
+
+// GetSymbolPresence reports which of the named symbols are exported by each
+// stored version of the package with path pkgPath, using the symbols table.
+// Only the internal.MaxSymbolPresenceVersions highest versions are included.
+// A package that is not stored at any version yields an empty map.
+func (db *DB) GetSymbolPresence(ctx context.Context, pkgPath string, symbols []string) (_ map[string]map[string]bool, err error) {
+	defer derrors.Wrap(&err, "DB.GetSymbolPresence(ctx, %q, %q)", pkgPath, symbols)
+
+	query := `
+		SELECT v.version, s.name
+		FROM (
+			SELECT p.id, m.version
+			FROM paths p
+			INNER JOIN modules m
+			ON p.module_id = m.id
+			WHERE
+				p.path = $1
+				AND p.name != ''
+			ORDER BY m.sort_version DESC, m.module_path DESC
+			LIMIT $3
+		) v
+		LEFT JOIN symbols s
+		ON s.path_id = v.id
+		AND s.name = ANY($2);`
+	presence := map[string]map[string]bool{}
+	collect := func(rows *sql.Rows) error {
+		var (
+			version string
+			name    sql.NullString
+		)
+		if err := rows.Scan(&version, &name); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		present, ok := presence[version]
+		if !ok {
+			present = map[string]bool{}
+			for _, s := range symbols {
+				present[s] = false
+			}
+			presence[version] = present
+		}
+		if name.Valid {
+			present[name.String] = true
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pkgPath, pq.Array(symbols), internal.MaxSymbolPresenceVersions); err != nil {
+		return nil, err
+	}
+	return presence, nil
+}
+
+// GetSymbolUsages returns at most limit of the packages that refer to the
+// exported symbol of the package with path pkgPath, from the
+// symbol_references table, which holds the references of the latest version
+// of each module. Packages with the most references come first, with ties
+// broken by package path. The references are found syntactically at fetch
+// time, so the result is best-effort.
+func (db *DB) GetSymbolUsages(ctx context.Context, pkgPath, symbol string, limit int) (_ []*internal.SymbolUsage, err error) {
+	defer derrors.Wrap(&err, "DB.GetSymbolUsages(ctx, %q, %q, %d)", pkgPath, symbol, limit)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative: %w", derrors.InvalidArgument)
+	}
+
+	query := `
+		SELECT from_path, from_module_path, count
+		FROM symbol_references
+		WHERE to_path = $1 AND symbol = $2
+		ORDER BY count DESC, from_path, from_module_path
+		LIMIT $3;`
+	var usages []*internal.SymbolUsage
+	collect := func(rows *sql.Rows) error {
+		var u internal.SymbolUsage
+		if err := rows.Scan(&u.PackagePath, &u.ModulePath, &u.Count); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		usages = append(usages, &u)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pkgPath, symbol, limit); err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetStabilitySignal returns the internal.Stability of the package with path
+// pkgPath, computed from the symbols table for the
+// internal.MaxStabilityVersions highest tagged versions of the package. If a
+// symbol is stored for more than one GOOS/GOARCH pair, the synopsis for the
+// first pair in alphabetical order is used.
+//
+// If the package is not stored at any tagged version, an error wrapping
+// derrors.NotFound is returned.
+func (db *DB) GetStabilitySignal(ctx context.Context, pkgPath string) (_ *internal.Stability, err error) {
+	defer derrors.Wrap(&err, "DB.GetStabilitySignal(ctx, %q)", pkgPath)
+
+	query := `
+		SELECT v.version, s.name, s.synopsis
+		FROM (
+			SELECT p.id, m.version
+			FROM paths p
+			INNER JOIN modules m
+			ON p.module_id = m.id
+			WHERE
+				p.path = $1
+				AND p.name != ''
+				AND m.version_type != 'pseudo'
+			ORDER BY m.sort_version DESC, m.module_path DESC
+			LIMIT $2
+		) v
+		LEFT JOIN symbols s
+		ON s.path_id = v.id
+		ORDER BY v.version, s.name, s.goos, s.goarch;`
+	apis := map[string]map[string]string{}
+	collect := func(rows *sql.Rows) error {
+		var (
+			version        string
+			name, synopsis sql.NullString
+		)
+		if err := rows.Scan(&version, &name, &synopsis); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		api, ok := apis[version]
+		if !ok {
+			api = map[string]string{}
+			apis[version] = api
+		}
+		if _, ok := api[name.String]; name.Valid && !ok {
+			api[name.String] = synopsis.String
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, pkgPath, internal.MaxStabilityVersions); err != nil {
+		return nil, err
+	}
+	if len(apis) == 0 {
+		return nil, fmt.Errorf("package %s: %w", pkgPath, derrors.NotFound)
+	}
+	return internal.ComputeStability(apis), nil
+}
+
+// getPackagePathID returns the id in the paths table of the package specified
+// by pkgPath, modulePath and version.
+func (db *DB) getPackagePathID(ctx context.Context, pkgPath, modulePath, version string) (_ int, err error) {
+	defer derrors.Wrap(&err, "getPackagePathID(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	query := `
+		SELECT p.id
+		FROM paths p
+		INNER JOIN modules m
+		ON p.module_id = m.id
+		WHERE
+			p.path = $1
+			AND m.module_path = $2
+			AND m.version = $3
+			AND p.name != '';`
+	var pathID int
+	if err := db.db.QueryRow(ctx, query, pkgPath, modulePath, version).Scan(&pathID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
+		}
+		return 0, err
+	}
+	return pathID, nil
+}
@@ -0,0 +1,727 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetSymbolCounts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar")
+	for _, d := range m.Directories {
+		if d.Path != sample.ModulePath+"/foo" {
+			continue
+		}
+		d.Package.Documentation.Symbols = []*internal.Symbol{
+			{Name: "A", Kind: internal.SymbolKindConstant, Synopsis: "const A"},
+			{Name: "B", Kind: internal.SymbolKindConstant, Synopsis: "const B"},
+			{Name: "V", Kind: internal.SymbolKindVariable, Synopsis: "var V"},
+			{Name: "F", Kind: internal.SymbolKindFunction, Synopsis: "func F()"},
+			{Name: "T", Kind: internal.SymbolKindType, Synopsis: "type T struct"},
+			{Name: "NewT", Kind: internal.SymbolKindFunction, Synopsis: "func NewT() *T", ParentName: "T"},
+			{Name: "T.M", Kind: internal.SymbolKindMethod, Synopsis: "func (T) M()", ParentName: "T"},
+			{Name: "T.N", Kind: internal.SymbolKindMethod, Synopsis: "func (T) N()", ParentName: "T"},
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		pkgPath string
+		want    map[internal.SymbolKind]int
+	}{
+		{
+			name:    "mix of kinds",
+			pkgPath: sample.ModulePath + "/foo",
+			want: map[internal.SymbolKind]int{
+				internal.SymbolKindConstant: 2,
+				internal.SymbolKindVariable: 1,
+				internal.SymbolKindFunction: 2,
+				internal.SymbolKindType:     1,
+				internal.SymbolKindMethod:   2,
+			},
+		},
+		{
+			name:    "no exported symbols",
+			pkgPath: sample.ModulePath + "/bar",
+			want:    map[internal.SymbolKind]int{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetSymbolCounts(ctx, test.pkgPath, sample.ModulePath, sample.VersionString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("not a package", func(t *testing.T) {
+		_, err := testDB.GetSymbolCounts(ctx, sample.ModulePath, sample.ModulePath, sample.VersionString)
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("got error %v, want %v", err, derrors.NotFound)
+		}
+	})
+}
+
+func TestGetSymbols(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	syms := []*internal.Symbol{
+		{Name: "T", Kind: internal.SymbolKindType, Synopsis: "type T struct"},
+		{Name: "A", Kind: internal.SymbolKindConstant, Synopsis: "const A"},
+		{Name: "T.M", Kind: internal.SymbolKindMethod, Synopsis: "func (T) M()", ParentName: "T"},
+		{Name: "NewT", Kind: internal.SymbolKindFunction, Synopsis: "func NewT() *T", ParentName: "T"},
+	}
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar")
+	for _, d := range m.Directories {
+		if d.Path == sample.ModulePath+"/foo" {
+			d.Package.Documentation.Symbols = syms
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		pkgPath string
+		want    []*internal.Symbol
+	}{
+		{
+			name:    "sorted by name",
+			pkgPath: sample.ModulePath + "/foo",
+			want:    []*internal.Symbol{syms[1], syms[3], syms[0], syms[2]},
+		},
+		{
+			name:    "no exported symbols",
+			pkgPath: sample.ModulePath + "/bar",
+			want:    nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetSymbols(ctx, test.pkgPath, sample.ModulePath, sample.VersionString)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("not a package", func(t *testing.T) {
+		_, err := testDB.GetSymbols(ctx, sample.ModulePath, sample.ModulePath, sample.VersionString)
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("got error %v, want %v", err, derrors.NotFound)
+		}
+	})
+}
+
+func TestGetSymbolsAfterReprocessing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	pkgPath := sample.ModulePath + "/foo"
+	insert := func(syms []*internal.Symbol) {
+		t.Helper()
+		m := sample.Module(sample.ModulePath, sample.VersionString, "foo")
+		for _, d := range m.Directories {
+			if d.Path == pkgPath {
+				d.Package.Documentation.Symbols = syms
+			}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &internal.Symbol{Name: "A", Kind: internal.SymbolKindConstant, Synopsis: "const A"}
+	b := &internal.Symbol{Name: "B", Kind: internal.SymbolKindConstant, Synopsis: "const B"}
+	insert([]*internal.Symbol{a, b})
+	// Reprocessing the module version drops B.
+	insert([]*internal.Symbol{a})
+
+	got, err := testDB.GetSymbols(ctx, pkgPath, sample.ModulePath, sample.VersionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*internal.Symbol{a}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetDocText(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo", "bar")
+	for _, d := range m.Directories {
+		if d.Path == sample.ModulePath+"/foo" {
+			d.Package.Documentation.Doc = "Package foo does things.\n"
+			d.Package.Documentation.Symbols = []*internal.Symbol{
+				{Name: "New", Kind: internal.SymbolKindFunction, Synopsis: "func New() int", Doc: "New returns a number.\n"},
+				{Name: "A", Kind: internal.SymbolKindConstant, Synopsis: "const A"},
+			}
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		pkgPath string
+		want    string
+	}{
+		{sample.ModulePath + "/foo", "Package foo does things.\n\nfunc New() int\nNew returns a number."},
+		{sample.ModulePath + "/bar", ""},
+	} {
+		got, err := testDB.GetDocText(ctx, test.pkgPath, sample.ModulePath, sample.VersionString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetDocText(%q) = %q, want %q", test.pkgPath, got, test.want)
+		}
+	}
+
+	_, err := testDB.GetDocText(ctx, sample.ModulePath, sample.ModulePath, sample.VersionString)
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("not a package: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetDocSection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	const (
+		overview  = `<section class="Documentation-overview"><p>Package foo.</p></section>`
+		functions = `<section class="Documentation-functions"><h3 id="New">func New</h3></section>`
+	)
+	m := sample.Module(sample.ModulePath, sample.VersionString, "foo")
+	for _, d := range m.Directories {
+		if d.Package != nil {
+			d.Package.Documentation.HTML = "<div>" + overview + functions + "</div>"
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgPath := sample.ModulePath + "/foo"
+	for _, test := range []struct {
+		section, want string
+	}{
+		{"overview", overview},
+		{"functions", functions},
+		{"types", ""},
+	} {
+		got, err := testDB.GetDocSection(ctx, pkgPath, sample.ModulePath, sample.VersionString, test.section)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.HTML != test.want {
+			t.Errorf("GetDocSection(%q): got HTML %q, want %q", test.section, got.HTML, test.want)
+		}
+		if got.Synopsis != sample.Synopsis {
+			t.Errorf("GetDocSection(%q): got synopsis %q, want %q", test.section, got.Synopsis, sample.Synopsis)
+		}
+	}
+
+	if _, err := testDB.GetDocSection(ctx, pkgPath, sample.ModulePath, sample.VersionString, "index"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("unknown section: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+	if _, err := testDB.GetDocSection(ctx, sample.ModulePath, sample.ModulePath, sample.VersionString, "overview"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("not a package: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetPlaygroundExamples(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	want := []*internal.PlaygroundExample{
+		{Name: "", Code: "package main\n\nfunc main() {}\n"},
+		{Name: "F", Code: "package main\n\nimport \"m.com/a\"\n\nfunc main() {\n\ta.F()\n}\n"},
+	}
+	m := sample.Module("m.com", "v1.0.0", "a")
+	for _, d := range m.Directories {
+		if d.Package != nil {
+			d.Package.Documentation.PlaygroundExamples = want
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetPlaygroundExamples(ctx, "m.com/a", "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.GetPlaygroundExamples(ctx, "m.com/b", "m.com", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing package: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetPlaygroundExamplesAfterReprocessing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	insert := func(examples []*internal.PlaygroundExample) {
+		t.Helper()
+		m := sample.Module("m.com", "v1.0.0", "a")
+		for _, d := range m.Directories {
+			if d.Package != nil {
+				d.Package.Documentation.PlaygroundExamples = examples
+			}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := &internal.PlaygroundExample{Name: "F", Code: "package main\n\nfunc main() {}\n"}
+	g := &internal.PlaygroundExample{Name: "G", Code: "package main\n\nfunc main() {}\n"}
+	insert([]*internal.PlaygroundExample{f, g})
+	// Reprocessing the module version drops G.
+	insert([]*internal.PlaygroundExample{f})
+
+	got, err := testDB.GetPlaygroundExamples(ctx, "m.com/a", "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*internal.PlaygroundExample{f}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetDocOutline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("m.com", "v1.0.0", "a")
+	m.Directories[1].Package.Documentation.Symbols = []*internal.Symbol{
+		{Name: "F", Kind: internal.SymbolKindFunction, Synopsis: "func F()"},
+		{Name: "T", Kind: internal.SymbolKindType, Synopsis: "type T struct{}"},
+		{Name: "T.M", Kind: internal.SymbolKindMethod, Synopsis: "func (T) M()", ParentName: "T", Doc: "M does nothing.\n"},
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetDocOutline(ctx, "m.com/a", "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.Outline{Sections: []*internal.OutlineSection{
+		{Title: "Functions", Entries: []*internal.OutlineEntry{
+			{Name: "F", ID: "F", Synopsis: "func F()"},
+		}},
+		{Title: "Types", Entries: []*internal.OutlineEntry{
+			{Name: "T", ID: "T", Synopsis: "type T struct{}", Children: []*internal.OutlineEntry{
+				{Name: "T.M", ID: "T.M", Synopsis: "func (T) M()"},
+			}},
+		}},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = testDB.GetDocOutline(ctx, "m.com/missing", "m.com", "v1.0.0")
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a missing package, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetDocDiffAcrossPlatforms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("m.com", "v1.0.0", "a")
+	m.Directories[1].Package.Documentation.Symbols = []*internal.Symbol{
+		{Name: "F", Kind: internal.SymbolKindFunction, Synopsis: "func F()"},
+		{Name: "Fd", Kind: internal.SymbolKindFunction, Synopsis: "func Fd() int"},
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetDocDiffAcrossPlatforms(ctx, "m.com/a", "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string][]string{"linux/amd64": {}}, got); diff != "" {
+		t.Errorf("one build context: mismatch (-want +got):\n%s", diff)
+	}
+
+	// Store documentation for windows/amd64, which has a windows-only
+	// function and lacks Fd.
+	pathID, err := testDB.getPackagePathID(ctx, "m.com/a", "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.db.Exec(ctx, `
+		INSERT INTO documentation (path_id, goos, goarch, synopsis, html, doc)
+		VALUES ($1, 'windows', 'amd64', '', '', '')`, pathID); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"F", "LoadDLL"} {
+		if _, err := testDB.db.Exec(ctx, `
+			INSERT INTO symbols (path_id, goos, goarch, name, kind, synopsis, parent_name, doc)
+			VALUES ($1, 'windows', 'amd64', $2, $3, $4, '', '')`,
+			pathID, name, internal.SymbolKindFunction, "func "+name+"()"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = testDB.GetDocDiffAcrossPlatforms(ctx, "m.com/a", "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"linux/amd64":   {"Fd"},
+		"windows/amd64": {"LoadDLL"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("two build contexts: mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = testDB.GetDocDiffAcrossPlatforms(ctx, "m.com/missing", "m.com", "v1.0.0")
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a missing package, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetAllDocs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("m.com", "v1.0.0", "a", "b")
+	want := map[string]*internal.Documentation{}
+	for _, d := range m.Directories {
+		switch d.Path {
+		case "m.com/a":
+			d.Package.Documentation.Symbols = []*internal.Symbol{
+				{Name: "T", Kind: internal.SymbolKindType, Synopsis: "type T struct{}"},
+				{Name: "T.M", Kind: internal.SymbolKindMethod, Synopsis: "func (T) M()", ParentName: "T", Doc: "M does nothing.\n"},
+			}
+			want[d.Path] = d.Package.Documentation
+		case "m.com/b":
+			// The documentation of b failed to render.
+			d.Package.Documentation = nil
+			want[d.Path] = nil
+		}
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testDB.GetAllDocs(ctx, "m.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// StreamAllDocs stops at the first error from f.
+	stop := errors.New("stop")
+	var paths []string
+	err = testDB.StreamAllDocs(ctx, "m.com", "v1.0.0", func(pkgPath string, _ *internal.Documentation) error {
+		paths = append(paths, pkgPath)
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("StreamAllDocs: got error %v, want %v", err, stop)
+	}
+	if diff := cmp.Diff([]string{"m.com/a"}, paths); diff != "" {
+		t.Errorf("StreamAllDocs paths mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = testDB.GetAllDocs(ctx, "m.com", "v2.0.0")
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a missing module version, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetSymbolPresence(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	pkgPath := sample.ModulePath + "/foo"
+	for _, test := range []struct {
+		version string
+		symbols []string
+	}{
+		{"v1.0.0", []string{"A"}},
+		{"v1.1.0", []string{"A", "New"}},
+		{"v1.2.0", []string{"A", "New", "T.M"}},
+	} {
+		m := sample.Module(sample.ModulePath, test.version, "foo")
+		for _, d := range m.Directories {
+			if d.Path != pkgPath {
+				continue
+			}
+			d.Package.Documentation.Symbols = nil
+			for _, name := range test.symbols {
+				d.Package.Documentation.Symbols = append(d.Package.Documentation.Symbols,
+					&internal.Symbol{Name: name, Kind: internal.SymbolKindFunction, Synopsis: "func " + name + "()"})
+			}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetSymbolPresence(ctx, pkgPath, []string{"A", "New", "Missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]bool{
+		"v1.0.0": {"A": true, "New": false, "Missing": false},
+		"v1.1.0": {"A": true, "New": true, "Missing": false},
+		"v1.2.0": {"A": true, "New": true, "Missing": false},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = testDB.GetSymbolPresence(ctx, "not.stored/pkg", []string{"A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v for a package that is not stored, want empty map", got)
+	}
+}
+
+func TestGetSymbolUsages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, test := range []struct {
+		modulePath, version string
+		refs                []*internal.SymbolReference
+	}{
+		{"a.com/m", "v1.0.0", []*internal.SymbolReference{{ImportPath: "fmt", Symbol: "Println", Count: 1}}},
+		// The latest version of a.com/m replaces the references of v1.0.0.
+		{"a.com/m", "v1.1.0", []*internal.SymbolReference{{ImportPath: "fmt", Symbol: "Println", Count: 3}}},
+		{"b.com/m", "v1.0.0", []*internal.SymbolReference{
+			{ImportPath: "fmt", Symbol: "Println", Count: 2},
+			{ImportPath: "fmt", Symbol: "Sprint", Count: 5},
+		}},
+		{"c.com/m", "v1.0.0", []*internal.SymbolReference{{ImportPath: "fmt", Symbol: "Println", Count: 1}}},
+	} {
+		m := sample.Module(test.modulePath, test.version, "p")
+		for _, p := range m.LegacyPackages {
+			p.SymbolReferences = test.refs
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetSymbolUsages(ctx, "fmt", "Println", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.SymbolUsage{
+		{PackagePath: "a.com/m/p", ModulePath: "a.com/m", Count: 3},
+		{PackagePath: "b.com/m/p", ModulePath: "b.com/m", Count: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = testDB.GetSymbolUsages(ctx, "fmt", "Errorf", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v for a symbol with no references, want none", got)
+	}
+	if _, err := testDB.GetSymbolUsages(ctx, "fmt", "Println", -1); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("negative limit: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestGetStabilitySignal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	// The API of foo has been stable since v1.0.0. The API of bar gained a
+	// symbol in v1.1.0 and changed a signature in v1.2.0.
+	stable := []*internal.Symbol{{Name: "A", Kind: internal.SymbolKindFunction, Synopsis: "func A()"}}
+	for _, test := range []struct {
+		version string
+		barSyms []*internal.Symbol
+	}{
+		{"v1.0.0", stable},
+		{"v1.1.0", []*internal.Symbol{
+			{Name: "A", Kind: internal.SymbolKindFunction, Synopsis: "func A()"},
+			{Name: "B", Kind: internal.SymbolKindFunction, Synopsis: "func B()"},
+		}},
+		{"v1.2.0", []*internal.Symbol{
+			{Name: "A", Kind: internal.SymbolKindFunction, Synopsis: "func A(int)"},
+			{Name: "B", Kind: internal.SymbolKindFunction, Synopsis: "func B()"},
+		}},
+		// Pseudo-versions are not considered.
+		{"v1.2.1-0.20200601000000-0123456789ab", nil},
+	} {
+		m := sample.Module(sample.ModulePath, test.version, "foo", "bar")
+		for _, d := range m.Directories {
+			switch d.Path {
+			case sample.ModulePath + "/foo":
+				d.Package.Documentation.Symbols = stable
+			case sample.ModulePath + "/bar":
+				d.Package.Documentation.Symbols = test.barSyms
+			}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		pkgPath string
+		want    *internal.Stability
+	}{
+		{sample.ModulePath + "/foo", &internal.Stability{Versions: 3}},
+		{sample.ModulePath + "/bar", &internal.Stability{Versions: 3, Changes: 2, ChurnRate: 1, LastBreakingVersion: "v1.2.0"}},
+	} {
+		got, err := testDB.GetStabilitySignal(ctx, test.pkgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetStabilitySignal(%q) mismatch (-want +got):\n%s", test.pkgPath, diff)
+		}
+	}
+
+	if _, err := testDB.GetStabilitySignal(ctx, "not.stored/pkg"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a package that is not stored, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestGetDocumentationWithLinks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	dep := sample.Module("dep.com/m", "v1.0.0", "dep")
+	for _, d := range dep.Directories {
+		if d.Path == "dep.com/m/dep" {
+			d.Package.Documentation.Symbols = []*internal.Symbol{
+				{Name: "Exported", Kind: internal.SymbolKindFunction, Synopsis: "func Exported()"},
+			}
+		}
+	}
+	user := sample.Module("user.com/m", "v1.0.0", "user")
+	p := user.LegacyPackages[0]
+	p.Imports = []string{"dep.com/m/dep", "missing.com/pkg"}
+	p.DocumentationHTML = "<p>Wraps dep.Exported, but not dep.Missing or pkg.Thing.</p>"
+	for _, m := range []*internal.Module{dep, user} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetDocumentationWithLinks(ctx, p.Path, "user.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := template.HTML(`<p>Wraps <a href="/pkg/dep.com/m/dep#Exported">dep.Exported</a>, but not dep.Missing or pkg.Thing.</p>`)
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	_, err = testDB.GetDocumentationWithLinks(ctx, "user.com/m/missing", "user.com/m", "v1.0.0")
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a missing package, want %v", err, derrors.NotFound)
+	}
+}
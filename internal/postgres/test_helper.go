@@ -108,7 +108,10 @@ func ResetTestDB(db *DB, t *testing.T) {
 			TRUNCATE modules CASCADE;
 			TRUNCATE version_map;
 			TRUNCATE imports_unique;
-			TRUNCATE experiments;`); err != nil {
+			TRUNCATE symbol_references;
+			TRUNCATE experiments;
+			TRUNCATE fetch_outbox;
+			TRUNCATE reprocessing_campaigns CASCADE;`); err != nil {
 			return err
 		}
 		if _, err := tx.Exec(ctx, `TRUNCATE module_version_states CASCADE;`); err != nil {
@@ -118,6 +121,7 @@ func ResetTestDB(db *DB, t *testing.T) {
 			return err
 		}
 		setExcludedPrefixesLastFetched(time.Time{})
+		setIndexStatsLastFetched(time.Time{})
 		return nil
 	}); err != nil {
 		t.Fatalf("error resetting test DB: %v", err)
@@ -96,3 +96,34 @@ func (db *DB) GetVersionMap(ctx context.Context, modulePath, requestedVersion st
 		return nil, err
 	}
 }
+
+// ResolveBranch returns the version in the version_map table for a request of
+// modulePath at branch, if that version is in the modules table. Since the
+// version_map table has one entry per requested version, which is updated on
+// each fetch, it is the version that the branch resolved to on its latest
+// fetch.
+func (db *DB) ResolveBranch(ctx context.Context, modulePath, branch string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.ResolveBranch(ctx, %q, %q)", modulePath, branch)
+	if !version.IsBranch(branch) {
+		return "", fmt.Errorf("%q is not a branch name: %w", branch, derrors.InvalidArgument)
+	}
+
+	query := `
+		SELECT vm.resolved_version
+		FROM version_map vm
+		INNER JOIN modules m
+		ON m.module_path = vm.module_path AND m.version = vm.resolved_version
+		WHERE
+			vm.module_path = $1
+			AND vm.requested_version = $2;`
+	var resolved string
+	err = db.db.QueryRow(ctx, query, modulePath, branch).Scan(&resolved)
+	switch err {
+	case nil:
+		return resolved, nil
+	case sql.ErrNoRows:
+		return "", fmt.Errorf("branch %s of %s: %w", branch, modulePath, derrors.NotFound)
+	default:
+		return "", err
+	}
+}
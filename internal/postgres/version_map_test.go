@@ -6,10 +6,12 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
@@ -79,3 +81,63 @@ func TestUpsertVersionMap(t *testing.T) {
 	vm.Status = 200
 	upsertAndVerifyVersionMap(vm)
 }
+
+func TestResolveBranch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	const (
+		modulePath = "github.com/module"
+		pseudo1    = "v0.0.0-20200101000000-0123456789ab"
+		pseudo2    = "v0.0.0-20200201000000-abcdef012345"
+	)
+	for _, v := range []string{pseudo1, pseudo2} {
+		if err := testDB.InsertModule(ctx, sample.Module(modulePath, v, "foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, vm := range []*internal.VersionMap{
+		// master was fetched at pseudo1, then at pseudo2.
+		{ModulePath: modulePath, RequestedVersion: "master", ResolvedVersion: pseudo1, Status: 200},
+		{ModulePath: modulePath, RequestedVersion: "master", ResolvedVersion: pseudo2, Status: 200},
+		{ModulePath: modulePath, RequestedVersion: "dev", ResolvedVersion: pseudo1, Status: 200},
+		// The version that feature resolved to was not stored.
+		{ModulePath: modulePath, RequestedVersion: "feature", ResolvedVersion: "v0.0.0-20200301000000-fedcba987654", Status: 500},
+		{ModulePath: modulePath, RequestedVersion: "gone", Status: 404},
+	} {
+		if err := testDB.UpsertVersionMap(ctx, vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		branch  string
+		want    string
+		wantErr error
+	}{
+		{"master", pseudo2, nil},
+		{"dev", pseudo1, nil},
+		{"feature", "", derrors.NotFound},
+		{"gone", "", derrors.NotFound},
+		{"unknown", "", derrors.NotFound},
+		{"latest", "", derrors.InvalidArgument},
+		{pseudo1, "", derrors.InvalidArgument},
+	} {
+		t.Run(test.branch, func(t *testing.T) {
+			got, err := testDB.ResolveBranch(ctx, modulePath, test.branch)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("got error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
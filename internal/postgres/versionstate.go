@@ -402,3 +402,77 @@ func (db *DB) GetVersionStats(ctx context.Context) (_ *VersionStats, err error)
 	}
 	return stats, nil
 }
+
+// GetFailedVersions returns the versions of the module with path modulePath in
+// the module_version_states table whose status is not a success, highest
+// version first. A status is a success if it is 2xx, as for
+// derrors.FromHTTPStatus, or if it marks a module version that had a 2xx
+// status for reprocessing. Versions that have been seen in the index but not
+// yet fetched have a status of 0, and are omitted.
+//
+// As in RecentlyFetched, a version's fetch time is when its state was last
+// updated, or if it has never been updated, when it was inserted.
+func (db *DB) GetFailedVersions(ctx context.Context, modulePath string) (_ []internal.FailedVersion, err error) {
+	defer derrors.Wrap(&err, "DB.GetFailedVersions(ctx, %q)", modulePath)
+
+	query := `
+		SELECT version, status, error, COALESCE(last_processed_at, created_at)
+		FROM module_version_states
+		WHERE
+			module_path = $1
+			AND status >= 300
+			AND status <> ALL($2)
+		ORDER BY sort_version DESC`
+	okReprocess := []int{
+		derrors.ToHTTPStatus(derrors.ReprocessStatusOK),
+		derrors.ToHTTPStatus(derrors.ReprocessHasIncompletePackages),
+	}
+	var failed []internal.FailedVersion
+	collect := func(rows *sql.Rows) error {
+		var f internal.FailedVersion
+		if err := rows.Scan(&f.Version, &f.Status, &f.Error, &f.FetchedAt); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		failed = append(failed, f)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, pq.Array(okReprocess)); err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// RecentlyFetched returns at most limit of the module versions in the
+// module_version_states table that have been fetched, most recently fetched
+// first, with ties broken by module path and version. Unsuccessful fetches are
+// included. Module versions that have been seen in the index but not yet
+// fetched have a status of 0, and are omitted.
+//
+// A module version's fetch time is when its state was last updated, or if it
+// has never been updated, when it was inserted.
+func (db *DB) RecentlyFetched(ctx context.Context, limit int) (_ []*internal.FetchEvent, err error) {
+	defer derrors.Wrap(&err, "DB.RecentlyFetched(ctx, %d)", limit)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative: %w", derrors.InvalidArgument)
+	}
+
+	query := `
+		SELECT module_path, version, status, error, COALESCE(last_processed_at, created_at) AS fetched_at
+		FROM module_version_states
+		WHERE status <> 0
+		ORDER BY fetched_at DESC, module_path, sort_version DESC
+		LIMIT $1`
+	var events []*internal.FetchEvent
+	collect := func(rows *sql.Rows) error {
+		var e internal.FetchEvent
+		if err := rows.Scan(&e.ModulePath, &e.Version, &e.Status, &e.Error, &e.FetchedAt); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		events = append(events, &e)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, limit); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -136,3 +137,92 @@ func TestModuleVersionState(t *testing.T) {
 		t.Errorf("testDB.GetVersionStats(ctx) mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestGetFailedVersions(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const modulePath = "m.com/a"
+	now := sample.NowTruncated()
+	// A version seen in the index but not fetched is not reported.
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: modulePath, Version: "v0.9.0", Timestamp: now}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct {
+		modulePath, version string
+		status              int
+		fetchErr            error
+	}{
+		{modulePath, "v1.0.0", 200, nil},
+		{modulePath, "v1.1.0", 404, errors.New("not found")},
+		{modulePath, "v1.2.0", 290, nil},
+		{modulePath, "v1.3.0", 490, errors.New("bad module")},
+		{modulePath, "v1.4.0", 520, errors.New("reprocess")},
+		{modulePath, "v1.5.0", 540, errors.New("reprocess bad module")},
+		{"m.com/b", "v1.0.0", 500, errors.New("other module")},
+	} {
+		if err := testDB.UpsertModuleVersionState(ctx, s.modulePath, s.version, "", now, s.status, s.modulePath, s.fetchErr, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetFailedVersions(ctx, modulePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []internal.FailedVersion{
+		{Version: "v1.5.0", Status: 540, Error: "reprocess bad module"},
+		{Version: "v1.3.0", Status: 490, Error: "bad module"},
+		{Version: "v1.1.0", Status: 404, Error: "not found"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(internal.FailedVersion{}, "FetchedAt")); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRecentlyFetched(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	now := sample.NowTruncated()
+	// A version seen in the index but not fetched is not reported.
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: "m.com/unfetched", Version: "v1.0.0", Timestamp: now}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct {
+		modulePath string
+		status     int
+		fetchErr   error
+		fetchedAt  time.Time
+	}{
+		{"m.com/a", 200, nil, now.Add(-3 * time.Hour)},
+		{"m.com/b", 404, errors.New("not found"), now.Add(-1 * time.Hour)},
+		{"m.com/c", 200, nil, now.Add(-2 * time.Hour)},
+	} {
+		if err := testDB.UpsertModuleVersionState(ctx, s.modulePath, "v1.0.0", "", now, s.status, s.modulePath, s.fetchErr, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `
+			UPDATE module_version_states SET last_processed_at = $1
+			WHERE module_path = $2`, s.fetchedAt, s.modulePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.RecentlyFetched(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.FetchEvent{
+		{ModulePath: "m.com/b", Version: "v1.0.0", Status: 404, Error: "not found", FetchedAt: now.Add(-1 * time.Hour)},
+		{ModulePath: "m.com/c", Version: "v1.0.0", Status: 200, FetchedAt: now.Add(-2 * time.Hour)},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.RecentlyFetched(ctx, -1); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("negative limit: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"go.opencensus.io/plugin/ochttp"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
@@ -32,6 +33,10 @@ type Client struct {
 	// URL of the module proxy web server
 	url string
 
+	// mirrors, if non-nil, holds the URLs of equivalent proxies to spread
+	// requests over instead of url. See NewWithMirrors.
+	mirrors *mirrorSet
+
 	// client used for HTTP requests. It is mutable for testing purposes.
 	httpClient *http.Client
 }
@@ -40,6 +45,18 @@ type Client struct {
 type VersionInfo struct {
 	Version string
 	Time    time.Time
+	// Origin describes where the version came from. Only some proxies
+	// report it.
+	Origin *Origin `json:",omitempty"`
+}
+
+// An Origin describes the version control commit a module version was
+// downloaded from.
+type Origin struct {
+	VCS  string `json:",omitempty"` // version control system, such as "git"
+	URL  string `json:",omitempty"` // URL of the repository
+	Hash string `json:",omitempty"` // full commit hash
+	Ref  string `json:",omitempty"` // tag or branch, if any
 }
 
 // New constructs a *Client using the provided rawurl, which is expected to
@@ -72,6 +89,42 @@ func (c *Client) GetInfo(ctx context.Context, modulePath, requestedVersion strin
 	return &v, nil
 }
 
+// ExistsInfo reports whether the proxy has an .info file for modulePath at
+// version, which may be internal.LatestVersion. It makes a HEAD request to
+// $GOPROXY/<module>/@v/<version>.info, so it is cheaper than GetInfo when only
+// existence matters. If the proxy does not allow HEAD requests, it makes a GET
+// request instead, without reading the body. 404 Not Found and 410 Gone
+// responses are reported as false, not as errors.
+func (c *Client) ExistsInfo(ctx context.Context, modulePath, version string) (_ bool, err error) {
+	defer derrors.Wrap(&err, "proxy.Client.ExistsInfo(%q, %q)", modulePath, version)
+
+	u, err := c.escapedURL(modulePath, version, "info")
+	if err != nil {
+		return false, err
+	}
+	r, err := ctxhttp.Head(ctx, c.httpClient, u)
+	if err != nil {
+		return false, fmt.Errorf("ctxhttp.Head(ctx, client, %q): %v", u, err)
+	}
+	r.Body.Close()
+	if r.StatusCode == http.StatusMethodNotAllowed || r.StatusCode == http.StatusNotImplemented {
+		r, err = ctxhttp.Get(ctx, c.httpClient, u)
+		if err != nil {
+			return false, fmt.Errorf("ctxhttp.Get(ctx, client, %q): %v", u, err)
+		}
+		r.Body.Close()
+	}
+	switch {
+	case 200 <= r.StatusCode && r.StatusCode < 300:
+		return true, nil
+	case r.StatusCode == http.StatusNotFound,
+		r.StatusCode == http.StatusGone:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%q: unexpected status %d %s", u, r.StatusCode, r.Status)
+	}
+}
+
 // GetMod makes a request to $GOPROXY/<module>/@v/<resolvedVersion>.mod and returns the raw data.
 func (c *Client) GetMod(ctx context.Context, modulePath, resolvedVersion string) (_ []byte, err error) {
 	defer derrors.Wrap(&err, "proxy.Client.GetMod(%q, %q)", modulePath, resolvedVersion)
@@ -100,6 +153,112 @@ func (c *Client) GetZip(ctx context.Context, requestedPath, requestedVersion str
 	return zipReader, nil
 }
 
+// A ZipHashMismatchError is returned by VerifyZipHash when the hash of a zip
+// does not match the hash recorded in the checksum database.
+type ZipHashMismatchError struct {
+	ModulePath, Version string
+	// Got is the hash computed from the zip contents, and Want is the hash
+	// recorded in the checksum database.
+	Got, Want string
+}
+
+func (e *ZipHashMismatchError) Error() string {
+	return fmt.Sprintf("zip hash mismatch for %s@%s: got %s, want %s", e.ModulePath, e.Version, e.Got, e.Want)
+}
+
+// VerifyZipHash computes the dirhash (h1:) of zipData the way the go command
+// does, and compares it against the hash for modulePath@version recorded in
+// the checksum database, which is looked up through the proxy's
+// $GOPROXY/sumdb/<sumdb>/lookup endpoint. It reports whether the hashes
+// match. If they do not, the returned error is a *ZipHashMismatchError.
+//
+// The signature of the checksum database response is not verified; the proxy
+// is trusted to relay it faithfully.
+func (c *Client) VerifyZipHash(ctx context.Context, modulePath, version string, zipData []byte) (_ bool, err error) {
+	defer derrors.Wrap(&err, "proxy.Client.VerifyZipHash(%q, %q)", modulePath, version)
+
+	got, err := zipHash(zipData)
+	if err != nil {
+		return false, err
+	}
+	want, err := c.lookupZipHash(ctx, modulePath, version)
+	if err != nil {
+		return false, err
+	}
+	if got != want {
+		return false, &ZipHashMismatchError{ModulePath: modulePath, Version: version, Got: got, Want: want}
+	}
+	return true, nil
+}
+
+// sumDBName is the name of the checksum database queried by VerifyZipHash.
+const sumDBName = "sum.golang.org"
+
+// lookupZipHash returns the zip hash recorded for modulePath@version in the
+// checksum database.
+func (c *Client) lookupZipHash(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "Client.lookupZipHash(%q, %q)", modulePath, version)
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", fmt.Errorf("path: %v: %w", err, derrors.InvalidArgument)
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("version: %v: %w", err, derrors.InvalidArgument)
+	}
+	u := fmt.Sprintf("%s/sumdb/%s/lookup/%s@%s", c.baseURL(), sumDBName, escapedPath, escapedVersion)
+	var hash string
+	err = c.executeRequest(ctx, u, func(body io.Reader) error {
+		// The lookup response contains lines of the form
+		//   <module> <version> <hash>
+		//   <module> <version>/go.mod <hash>
+		// followed by a signed tree head, which we ignore.
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 3 && fields[0] == modulePath && fields[1] == version {
+				hash = fields[2]
+				break
+			}
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return "", err
+	}
+	if hash == "" {
+		return "", fmt.Errorf("no zip hash in checksum database response: %w", derrors.NotFound)
+	}
+	return hash, nil
+}
+
+// zipHash returns the h1: dirhash of the module zip contained in zipData.
+// It is equivalent to dirhash.HashZip, but operates on the zip in memory.
+func zipHash(zipData []byte) (_ string, err error) {
+	defer derrors.Wrap(&err, "zipHash")
+
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return "", fmt.Errorf("zip.NewReader: %v", err)
+	}
+	var (
+		names []string
+		files = make(map[string]*zip.File)
+	)
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		files[f.Name] = f
+	}
+	return dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		f := files[name]
+		if f == nil {
+			return nil, fmt.Errorf("file %q not found in zip", name)
+		}
+		return f.Open()
+	})
+}
+
 func (c *Client) escapedURL(modulePath, version, suffix string) (_ string, err error) {
 	defer func() {
 		derrors.Wrap(&err, "Client.escapedURL(%q, %q, %q)", modulePath, version, suffix)
@@ -116,13 +275,13 @@ func (c *Client) escapedURL(modulePath, version, suffix string) (_ string, err e
 		if suffix != "info" {
 			return "", fmt.Errorf("cannot ask for latest with suffix %q", suffix)
 		}
-		return fmt.Sprintf("%s/%s/@latest", c.url, escapedPath), nil
+		return fmt.Sprintf("%s/%s/@latest", c.baseURL(), escapedPath), nil
 	}
 	escapedVersion, err := module.EscapeVersion(version)
 	if err != nil {
 		return "", fmt.Errorf("version: %v: %w", err, derrors.InvalidArgument)
 	}
-	return fmt.Sprintf("%s/%s/@v/%s.%s", c.url, escapedPath, escapedVersion, suffix), nil
+	return fmt.Sprintf("%s/%s/@v/%s.%s", c.baseURL(), escapedPath, escapedVersion, suffix), nil
 }
 
 func (c *Client) readBody(ctx context.Context, modulePath, version, suffix string) (_ []byte, err error) {
@@ -151,7 +310,7 @@ func (c *Client) ListVersions(ctx context.Context, modulePath string) ([]string,
 	if err != nil {
 		return nil, fmt.Errorf("module.EscapePath(%q): %w", modulePath, derrors.InvalidArgument)
 	}
-	u := fmt.Sprintf("%s/%s/@v/list", c.url, escapedPath)
+	u := fmt.Sprintf("%s/%s/@v/list", c.baseURL(), escapedPath)
 	var versions []string
 	collect := func(body io.Reader) error {
 		scanner := bufio.NewScanner(body)
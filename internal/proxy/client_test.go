@@ -7,6 +7,8 @@ package proxy
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
@@ -158,6 +160,77 @@ func TestGetInfoVersionDoesNotExist(t *testing.T) {
 	}
 }
 
+func TestExistsInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	client, teardownProxy := SetupTestProxy(t, []*TestModule{sampleModule})
+	defer teardownProxy()
+
+	for _, test := range []struct {
+		path, version string
+		want          bool
+	}{
+		{"github.com/my/module", "v1.0.0", true},
+		{"github.com/my/module", internal.LatestVersion, true},
+		{"github.com/my/module", "v3.0.0", false},
+		{"github.com/no/module", "v1.0.0", false},
+	} {
+		got, err := client.ExistsInfo(ctx, test.path, test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("ExistsInfo(ctx, %q, %q) = %t, want %t", test.path, test.version, got, test.want)
+		}
+	}
+}
+
+func TestExistsInfoStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, test := range []struct {
+		name      string
+		head, get int // status codes for HEAD and GET requests
+		want      bool
+		wantErr   bool
+	}{
+		{"ok", http.StatusOK, http.StatusOK, true, false},
+		{"gone", http.StatusGone, http.StatusGone, false, false},
+		{"no HEAD", http.StatusMethodNotAllowed, http.StatusOK, true, false},
+		{"no HEAD, missing", http.StatusMethodNotAllowed, http.StatusNotFound, false, false},
+		{"server error", http.StatusInternalServerError, http.StatusOK, false, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var methods []string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				if r.Method == http.MethodHead {
+					w.WriteHeader(test.head)
+					return
+				}
+				w.WriteHeader(test.get)
+				fmt.Fprint(w, `{"Version":"v1.0.0"}`)
+			})
+			client, teardown := TestProxyServer(t, mux)
+			defer teardown()
+
+			got, err := client.ExistsInfo(ctx, "m.com", "v1.0.0")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+			if methods[0] != http.MethodHead {
+				t.Errorf("first request used %s, want HEAD", methods[0])
+			}
+		})
+	}
+}
+
 func TestGetMod(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -308,3 +381,62 @@ func TestEncodedURL(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyZipHash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	client, teardownProxy := SetupTestProxy(t, []*TestModule{sampleModule})
+	defer teardownProxy()
+
+	path := "github.com/my/module"
+	version := "v1.0.0"
+
+	goodZip := cleanTestModule(t, &TestModule{
+		ModulePath: sampleModule.ModulePath,
+		Version:    sampleModule.Version,
+		Files:      sampleModule.Files,
+	}).zip
+	ok, err := client.VerifyZipHash(ctx, path, version, goodZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Errorf("VerifyZipHash(ctx, %q, %q, goodZip) = false, want true", path, version)
+	}
+
+	files := map[string]string{}
+	for name, contents := range sampleModule.Files {
+		files[name] = contents
+	}
+	files["bar/bar.go"] = "package bar\n\n// Tampered.\nfunc Bar() string { return \"tampered\" }"
+	badZip := cleanTestModule(t, &TestModule{
+		ModulePath: sampleModule.ModulePath,
+		Version:    sampleModule.Version,
+		Files:      files,
+	}).zip
+	ok, err = client.VerifyZipHash(ctx, path, version, badZip)
+	if ok {
+		t.Errorf("VerifyZipHash(ctx, %q, %q, badZip) = true, want false", path, version)
+	}
+	var mismatch *ZipHashMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got error %v, want *ZipHashMismatchError", err)
+	}
+	if mismatch.Got == mismatch.Want {
+		t.Errorf("got equal hashes %q in mismatch error", mismatch.Got)
+	}
+}
+
+func TestVerifyZipHashNonExist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	client, teardownProxy := SetupTestProxy(t, []*TestModule{sampleModule})
+	defer teardownProxy()
+
+	zip := cleanTestModule(t, &TestModule{ModulePath: "my.mod/nonexistmodule"}).zip
+	if _, err := client.VerifyZipHash(ctx, "my.mod/nonexistmodule", "v1.0.0", zip); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got %v, want %v", err, derrors.NotFound)
+	}
+}
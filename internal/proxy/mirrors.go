@@ -0,0 +1,191 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// NewWithMirrors constructs a *Client that spreads its requests over several
+// mirrors of the same module proxy, given by rawurls as for New.
+//
+// The mirrors are equivalent peers, unlike the proxies in a comma-separated
+// GOPROXY list, where a later proxy is asked only when an earlier one answers
+// 404 or 410. Each request is sent to a single mirror, chosen round-robin
+// among the healthy ones, and its response is final, whatever its status.
+//
+// All mirrors are considered healthy until CheckMirrors, usually called
+// periodically by RunHealthChecks, finds otherwise. If no mirror is healthy,
+// requests are spread over all of them.
+func NewWithMirrors(rawurls []string) (_ *Client, err error) {
+	defer derrors.Wrap(&err, "proxy.NewWithMirrors(%q)", rawurls)
+
+	if len(rawurls) == 0 {
+		return nil, errors.New("no mirrors")
+	}
+	var urls []string
+	for _, rawurl := range rawurls {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, fmt.Errorf("url.Parse: %v", err)
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("%s: scheme must be https (got %s)", rawurl, u.Scheme)
+		}
+		urls = append(urls, strings.TrimRight(rawurl, "/"))
+	}
+	return &Client{
+		url:        urls[0],
+		mirrors:    newMirrorSet(urls),
+		httpClient: &http.Client{Transport: &ochttp.Transport{}},
+	}, nil
+}
+
+// A MirrorStatus describes the health of one mirror of a Client.
+type MirrorStatus struct {
+	URL     string
+	Healthy bool
+	// LastChecked is the time of the last health check of the mirror, or
+	// zero if it has not been checked.
+	LastChecked time.Time
+	// Err is the reason the last health check failed.
+	Err error
+}
+
+// Mirrors returns the status of the mirrors of c, in the order they were
+// passed to NewWithMirrors. For a Client constructed with New, it returns its
+// single URL, which is always reported healthy.
+func (c *Client) Mirrors() []MirrorStatus {
+	if c.mirrors == nil {
+		return []MirrorStatus{{URL: c.url, Healthy: true}}
+	}
+	return c.mirrors.status()
+}
+
+// mirrorHealthTimeout bounds the time a mirror has to answer a health check.
+const mirrorHealthTimeout = 10 * time.Second
+
+// CheckMirrors checks the health of each mirror of c, concurrently, and routes
+// later requests only to the healthy ones. A mirror is healthy if a GET
+// request for its URL succeeds within ten seconds with a status below 500;
+// the content of the response does not matter. It does nothing for a Client
+// constructed with New.
+func (c *Client) CheckMirrors(ctx context.Context) {
+	if c.mirrors == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, mirrorHealthTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, u := range c.mirrors.urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			err := c.checkMirror(ctx, u)
+			if err != nil {
+				log.Errorf(ctx, "proxy mirror %s is unhealthy: %v", u, err)
+			}
+			c.mirrors.setHealth(i, err)
+		}(i, u)
+	}
+	wg.Wait()
+}
+
+// checkMirror returns an error if the mirror at u is unhealthy.
+func (c *Client) checkMirror(ctx context.Context, u string) error {
+	r, err := ctxhttp.Get(ctx, c.httpClient, u+"/")
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode >= 500 {
+		return fmt.Errorf("status %d %s", r.StatusCode, r.Status)
+	}
+	return nil
+}
+
+// RunHealthChecks calls CheckMirrors every interval, starting immediately,
+// until ctx is done.
+func (c *Client) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.CheckMirrors(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// baseURL returns the URL of the proxy to send the next request to.
+func (c *Client) baseURL() string {
+	if c.mirrors == nil {
+		return c.url
+	}
+	return c.mirrors.next()
+}
+
+// A mirrorSet chooses among the mirrors of a Client.
+type mirrorSet struct {
+	urls []string
+
+	mu     sync.Mutex
+	health []MirrorStatus
+	last   int // index of the last mirror chosen
+}
+
+func newMirrorSet(urls []string) *mirrorSet {
+	s := &mirrorSet{urls: urls, last: -1}
+	for _, u := range urls {
+		s.health = append(s.health, MirrorStatus{URL: u, Healthy: true})
+	}
+	return s
+}
+
+// next returns the URL of the first healthy mirror after the one chosen last,
+// or of the next mirror if none is healthy.
+func (s *mirrorSet) next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.urls)
+	for i := 1; i <= n; i++ {
+		j := (s.last + i) % n
+		if s.health[j].Healthy {
+			s.last = j
+			return s.urls[j]
+		}
+	}
+	s.last = (s.last + 1) % n
+	return s.urls[s.last]
+}
+
+// setHealth records the result of a health check of mirror i.
+func (s *mirrorSet) setHealth(i int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health[i].Healthy = err == nil
+	s.health[i].Err = err
+	s.health[i].LastChecked = time.Now()
+}
+
+func (s *mirrorSet) status() []MirrorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MirrorStatus(nil), s.health...)
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testMirror is a mirror of a test proxy that counts the requests for module
+// data it serves, and can be made to fail every request.
+type testMirror struct {
+	proxy http.Handler
+
+	mu       sync.Mutex
+	down     bool
+	requests int
+}
+
+func (m *testMirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	down := m.down
+	if r.URL.Path != "/" {
+		m.requests++
+	}
+	m.mu.Unlock()
+	if down {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	m.proxy.ServeHTTP(w, r)
+}
+
+func (m *testMirror) setDown(down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.down = down
+}
+
+// takeRequests returns the number of requests for module data since the last
+// call.
+func (m *testMirror) takeRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.requests
+	m.requests = 0
+	return n
+}
+
+func TestMirrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	proxyMux := TestProxy([]*TestModule{cleanTestModule(t, sampleModule)})
+	mirrors := []*testMirror{{proxy: proxyMux}, {proxy: proxyMux}}
+	var urls []string
+	for _, m := range mirrors {
+		srv := httptest.NewTLSServer(m)
+		defer srv.Close()
+		urls = append(urls, srv.URL)
+	}
+	client, err := NewWithMirrors(urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.httpClient = &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	// getInfos makes n requests and returns the number each mirror served.
+	getInfos := func(n int) []int {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if _, err := client.GetInfo(ctx, sampleModule.ModulePath, sampleModule.Version); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return []int{mirrors[0].takeRequests(), mirrors[1].takeRequests()}
+	}
+	healthy := func() []bool {
+		var hs []bool
+		for _, s := range client.Mirrors() {
+			hs = append(hs, s.Healthy)
+		}
+		return hs
+	}
+
+	// Requests are spread over both healthy mirrors.
+	client.CheckMirrors(ctx)
+	if diff := cmp.Diff([]bool{true, true}, healthy()); diff != "" {
+		t.Errorf("both up: health mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{2, 2}, getInfos(4)); diff != "" {
+		t.Errorf("both up: requests mismatch (-want +got):\n%s", diff)
+	}
+
+	// Once a health check finds the first mirror down, the second one
+	// serves every request.
+	mirrors[0].setDown(true)
+	client.CheckMirrors(ctx)
+	if diff := cmp.Diff([]bool{false, true}, healthy()); diff != "" {
+		t.Errorf("first down: health mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{0, 4}, getInfos(4)); diff != "" {
+		t.Errorf("first down: requests mismatch (-want +got):\n%s", diff)
+	}
+
+	// When it recovers, it serves requests again.
+	mirrors[0].setDown(false)
+	client.CheckMirrors(ctx)
+	if diff := cmp.Diff([]int{2, 2}, getInfos(4)); diff != "" {
+		t.Errorf("recovered: requests mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMirrorSetAllUnhealthy(t *testing.T) {
+	s := newMirrorSet([]string{"a", "b"})
+	s.setHealth(0, context.DeadlineExceeded)
+	s.setHealth(1, context.DeadlineExceeded)
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, s.next())
+	}
+	if diff := cmp.Diff([]string{"a", "b", "a", "b"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewWithMirrors(t *testing.T) {
+	for _, urls := range [][]string{
+		nil,
+		{"https://a.com", "http://b.com"},
+	} {
+		if _, err := NewWithMirrors(urls); err == nil {
+			t.Errorf("NewWithMirrors(%q): got nil error, want error", urls)
+		}
+	}
+}
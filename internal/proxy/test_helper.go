@@ -23,7 +23,10 @@ type TestModule struct {
 	ModulePath string
 	Version    string
 	Files      map[string]string
+	// CommitHash, if set, is reported as the hash of the version's origin.
+	CommitHash string
 	zip        []byte
+	zipHash    string
 }
 
 func goMod(m *TestModule) string {
@@ -97,9 +100,11 @@ func TestProxy(modules []*TestModule) *http.ServeMux {
 		handle(fmt.Sprintf("/%s/@latest", modPath), strings.NewReader(defaultInfo(latest(modVersions))))
 		handle(fmt.Sprintf("/%s/@v/master.info", modPath), strings.NewReader(defaultInfo(master(modVersions))))
 		for _, m := range modVersions {
-			handle(fmt.Sprintf("/%s/@v/%s.info", m.ModulePath, m.Version), strings.NewReader(defaultInfo(m.Version)))
+			handle(fmt.Sprintf("/%s/@v/%s.info", m.ModulePath, m.Version), strings.NewReader(versionInfo(m)))
 			handle(fmt.Sprintf("/%s/@v/%s.mod", m.ModulePath, m.Version), strings.NewReader(goMod(m)))
 			handle(fmt.Sprintf("/%s/@v/%s.zip", m.ModulePath, m.Version), bytes.NewReader(m.zip))
+			handle(fmt.Sprintf("/sumdb/%s/lookup/%s@%s", sumDBName, m.ModulePath, m.Version),
+				strings.NewReader(sumDBLookup(m)))
 		}
 	}
 	return mux
@@ -111,6 +116,15 @@ func defaultInfo(version string) string {
 	return fmt.Sprintf("{\n\t\"Version\": %q,\n\t\"Time\": %q\n}", version, versionTime)
 }
 
+// versionInfo returns the info for m, with an origin if m has a commit hash.
+func versionInfo(m *TestModule) string {
+	if m.CommitHash == "" {
+		return defaultInfo(m.Version)
+	}
+	return fmt.Sprintf("{\n\t\"Version\": %q,\n\t\"Time\": %q,\n\t\"Origin\": {\"VCS\": \"git\", \"Hash\": %q}\n}",
+		m.Version, versionTime, m.CommitHash)
+}
+
 func versionList(modVersions []*TestModule) string {
 	var vList []string
 	for _, v := range modVersions {
@@ -119,6 +133,12 @@ func versionList(modVersions []*TestModule) string {
 	return strings.Join(vList, "\n")
 }
 
+// sumDBLookup returns a checksum database lookup response for m. The signed
+// tree head is omitted.
+func sumDBLookup(m *TestModule) string {
+	return fmt.Sprintf("1\n%s %s %s\n\n", m.ModulePath, m.Version, m.zipHash)
+}
+
 // defaultGoMod creates a bare-bones go.mod contents.
 func defaultGoMod(modulePath string) string {
 	return fmt.Sprintf("module %s\n\ngo 1.12", modulePath)
@@ -140,5 +160,9 @@ func cleanTestModule(t *testing.T, m *TestModule) *TestModule {
 		t.Fatal(err)
 	}
 	m.zip = zip
+	m.zipHash, err = zipHash(zip)
+	if err != nil {
+		t.Fatal(err)
+	}
 	return m
 }
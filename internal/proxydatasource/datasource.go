@@ -10,6 +10,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
 	"path"
 	"sort"
 	"strings"
@@ -23,6 +26,7 @@ import (
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/version"
 )
 
@@ -30,9 +34,19 @@ var _ internal.DataSource = (*DataSource)(nil)
 
 // New returns a new direct proxy datasource.
 func New(proxyClient *proxy.Client) *DataSource {
+	return newDataSource(proxyClient, source.NewClient(1*time.Minute))
+}
+
+// NewForTesting returns a new direct proxy datasource that does not make
+// network requests to look up source information.
+func NewForTesting(proxyClient *proxy.Client) *DataSource {
+	return newDataSource(proxyClient, source.NewClientForTesting())
+}
+
+func newDataSource(proxyClient *proxy.Client, sourceClient *source.Client) *DataSource {
 	return &DataSource{
 		proxyClient:          proxyClient,
-		sourceClient:         source.NewClient(1 * time.Minute),
+		sourceClient:         sourceClient,
 		versionCache:         make(map[versionKey]*versionEntry),
 		modulePathToVersions: make(map[string][]string),
 		packagePathToModules: make(map[string][]string),
@@ -62,8 +76,9 @@ type versionKey struct {
 
 // versionEntry holds the result of a call to worker.FetchModule.
 type versionEntry struct {
-	module *internal.Module
-	err    error
+	module    *internal.Module
+	err       error
+	fetchedAt time.Time
 }
 
 // GetDirectory returns packages contained in the given subdirectory of a module version.
@@ -89,21 +104,330 @@ func (ds *DataSource) GetDirectory(ctx context.Context, dirPath, modulePath, ver
 	}, nil
 }
 
-// GetDirectoryNew returns information about a directory at a path.
-func (ds *DataSource) GetDirectoryNew(ctx context.Context, dirPath, modulePath, version string) (_ *internal.VersionedDirectory, err error) {
+// GetCommitSHA returns the full hash of the commit the module version was built
+// from, if the proxy reported it when the module version was fetched.
+func (ds *DataSource) GetCommitSHA(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetCommitSHA(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	hash := m.SourceInfo.CommitHash()
+	if hash == "" {
+		return "", fmt.Errorf("no commit hash for %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return hash, nil
+}
+
+// GetContentHash returns the hash of the module version's data computed when
+// it was fetched from the proxy.
+func (ds *DataSource) GetContentHash(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetContentHash(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	return m.ContentHash, nil
+}
+
+// GetDependencyDepth returns the depth of the requirement graph of the module
+// version, as described by internal.DependencyDepth. Only the requirements of
+// module versions that have already been fetched are known; it does not fetch
+// the modules that the module version requires.
+func (ds *DataSource) GetDependencyDepth(ctx context.Context, modulePath, version string) (_ int, err error) {
+	defer derrors.Wrap(&err, "GetDependencyDepth(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return 0, err
+	}
+	return internal.DependencyDepth(ctx, modulePath, version, m.Requirements, ds.requirementsOf)
+}
+
+// requirementsOf returns the requirements of the module versions in mvs that
+// are in the cache.
+func (ds *DataSource) requirementsOf(ctx context.Context, mvs []*internal.Requirement) ([]*internal.Requirement, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	var reqs []*internal.Requirement
+	for _, mv := range mvs {
+		if e, ok := ds.versionCache[versionKey{mv.ModulePath, mv.Version}]; ok && e.module != nil {
+			reqs = append(reqs, e.module.Requirements...)
+		}
+	}
+	return reqs, nil
+}
+
+// GetDirectoryNew returns information about a directory at a path. If
+// opts.IncludeSubdirectories is set, the directories below it are listed as
+// well.
+func (ds *DataSource) GetDirectoryNew(ctx context.Context, dirPath, modulePath, version string, opts internal.PathOptions) (_ *internal.VersionedDirectory, err error) {
+	if !opts.IncludeVendored && internal.IsVendored(dirPath) {
+		return nil, fmt.Errorf("directory %s is vendored: %w", dirPath, derrors.NotFound)
+	}
 	m, err := ds.getModule(ctx, modulePath, version)
 	if err != nil {
 		return nil, err
 	}
-	return &internal.VersionedDirectory{
+	vdir := &internal.VersionedDirectory{
 		ModuleInfo: m.ModuleInfo,
 		DirectoryNew: internal.DirectoryNew{
 			Path:   dirPath,
 			V1Path: internal.V1Path(modulePath, strings.TrimPrefix(dirPath, modulePath+"/")),
 		},
+	}
+	if opts.IncludeSubdirectories {
+		for _, d := range m.Directories {
+			if !strings.HasPrefix(d.Path, dirPath+"/") || (!opts.IncludeVendored && internal.IsVendored(d.Path)) {
+				continue
+			}
+			vdir.Subdirectories = append(vdir.Subdirectories, &internal.Subdirectory{
+				Path:      d.Path,
+				HasReadme: d.Readme != nil,
+			})
+		}
+		sort.Slice(vdir.Subdirectories, func(i, j int) bool {
+			return vdir.Subdirectories[i].Path < vdir.Subdirectories[j].Path
+		})
+	}
+	return vdir, nil
+}
+
+// GetDocBuildInfo returns how the fetcher built into this binary generated the
+// package's documentation.
+func (ds *DataSource) GetDocBuildInfo(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.DocBuildInfo, err error) {
+	defer derrors.Wrap(&err, "GetDocBuildInfo(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	if vp.DocBuildInfo == nil {
+		return nil, fmt.Errorf("doc build info of %s in %s@%s: %w", pkgPath, modulePath, version, derrors.NotFound)
+	}
+	return vp.DocBuildInfo, nil
+}
+
+// GetDocGenGoVersion returns the version of the Go toolchain that this binary
+// was built with, which generated the module's documentation.
+func (ds *DataSource) GetDocGenGoVersion(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetDocGenGoVersion(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range m.LegacyPackages {
+		if p.DocBuildInfo != nil {
+			return p.DocBuildInfo.GoVersion, nil
+		}
+	}
+	return "", fmt.Errorf("doc Go version of %s@%s: %w", modulePath, version, derrors.NotFound)
+}
+
+// GetDocDiffAcrossPlatforms returns the result of internal.PlatformOnlySymbols
+// for the package. Since a package is documented only for the first build
+// context in internal.BuildContexts that it has files for, the result has that
+// build context with an empty list.
+func (ds *DataSource) GetDocDiffAcrossPlatforms(ctx context.Context, pkgPath, modulePath, version string) (_ map[string][]string, err error) {
+	defer derrors.Wrap(&err, "GetDocDiffAcrossPlatforms(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range vp.Symbols {
+		names = append(names, s.Name)
+	}
+	bc := internal.BuildContext{GOOS: vp.GOOS, GOARCH: vp.GOARCH}
+	return internal.PlatformOnlySymbols(map[string][]string{bc.String(): names}), nil
+}
+
+// GetDocText returns the plain text of the package documentation, with
+// symbols in the order in which they appear in the documentation.
+func (ds *DataSource) GetDocText(ctx context.Context, pkgPath, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetDocText(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	return internal.DocText(vp.Doc, vp.Symbols), nil
+}
+
+// GetDocSection returns the package documentation with only the named section
+// of its HTML.
+func (ds *DataSource) GetDocSection(ctx context.Context, pkgPath, modulePath, version, section string) (_ *internal.Documentation, err error) {
+	defer derrors.Wrap(&err, "GetDocSection(%q, %q, %q, %q)", pkgPath, modulePath, version, section)
+	if _, ok := internal.DocSectionStart(section); !ok {
+		return nil, fmt.Errorf("unknown documentation section %q: %w", section, derrors.InvalidArgument)
+	}
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	html, _ := internal.DocSection(vp.DocumentationHTML, section)
+	return &internal.Documentation{
+		GOOS:     vp.GOOS,
+		GOARCH:   vp.GOARCH,
+		Synopsis: vp.Synopsis,
+		HTML:     html,
 	}, nil
 }
 
+// GetPlaygroundExamples returns the runnable examples found in the package
+// documentation when the module was fetched.
+func (ds *DataSource) GetPlaygroundExamples(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.PlaygroundExample, err error) {
+	defer derrors.Wrap(&err, "GetPlaygroundExamples(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return vp.PlaygroundExamples, nil
+}
+
+// GetAllDocs returns the documentation of every package in the module
+// version, keyed by package path. It shares the cached module, so callers must
+// not modify the result.
+func (ds *DataSource) GetAllDocs(ctx context.Context, modulePath, version string) (_ map[string]*internal.Documentation, err error) {
+	defer derrors.Wrap(&err, "GetAllDocs(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	docs := map[string]*internal.Documentation{}
+	for _, d := range m.Directories {
+		if d.Package != nil {
+			docs[d.Package.Path] = d.Package.Documentation
+		}
+	}
+	return docs, nil
+}
+
+// GetDocSizes returns the size in bytes of the documentation HTML of each
+// package in the module zip, keyed by package path.
+func (ds *DataSource) GetDocSizes(ctx context.Context, modulePath, version string) (_ map[string]int, err error) {
+	defer derrors.Wrap(&err, "GetDocSizes(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int{}
+	for _, p := range m.LegacyPackages {
+		sizes[p.Path] = len(p.DocumentationHTML)
+	}
+	return sizes, nil
+}
+
+// GetModuleDocCoverage returns the number of packages of the module version
+// with and without a package doc comment.
+func (ds *DataSource) GetModuleDocCoverage(ctx context.Context, modulePath, version string) (_ *internal.ModuleDocCoverage, err error) {
+	defer derrors.Wrap(&err, "GetModuleDocCoverage(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var c internal.ModuleDocCoverage
+	for _, p := range m.LegacyPackages {
+		if p.Synopsis != "" {
+			c.Documented++
+		} else {
+			c.Undocumented++
+		}
+	}
+	return &c, nil
+}
+
+// GetModuleKeywords returns the keywords derived from the packages of the
+// module version.
+func (ds *DataSource) GetModuleKeywords(ctx context.Context, modulePath, version string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetModuleKeywords(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ModuleKeywords(m.LegacyPackages), nil
+}
+
+// GetDocumentationWithLinks returns the package documentation HTML, with
+// references to the exported symbols of the packages it imports turned into
+// links by internal.LinkDocReferences. Only imported packages in module
+// versions that have already been fetched are linked; a symbol is linked if
+// any of those versions has it.
+func (ds *DataSource) GetDocumentationWithLinks(ctx context.Context, pkgPath, modulePath, version string) (_ template.HTML, err error) {
+	defer derrors.Wrap(&err, "GetDocumentationWithLinks(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	imported := map[string]bool{}
+	for _, p := range vp.Imports {
+		imported[p] = true
+	}
+	targets := map[string]*internal.LinkTarget{}
+	ds.mu.RLock()
+	for _, e := range ds.versionCache {
+		if e.module == nil {
+			continue
+		}
+		for _, p := range e.module.LegacyPackages {
+			if !imported[p.Path] {
+				continue
+			}
+			t, ok := targets[p.Path]
+			if !ok {
+				t = &internal.LinkTarget{Path: p.Path, Name: p.Name, Symbols: map[string]bool{}}
+				targets[p.Path] = t
+			}
+			for _, s := range p.Symbols {
+				t.Symbols[s.Name] = true
+			}
+		}
+	}
+	ds.mu.RUnlock()
+	var ts []*internal.LinkTarget
+	for _, t := range targets {
+		ts = append(ts, t)
+	}
+	return internal.LinkDocReferences(vp.DocumentationHTML, ts), nil
+}
+
+// GetFetchTime returns the time at which the module version was fetched from
+// the proxy and cached. It does not fetch the module version if it is not
+// already cached.
+func (ds *DataSource) GetFetchTime(ctx context.Context, modulePath, version string) (_ time.Time, err error) {
+	defer derrors.Wrap(&err, "GetFetchTime(%q, %q)", modulePath, version)
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	e, ok := ds.versionCache[versionKey{modulePath, version}]
+	if !ok || e.module == nil {
+		return time.Time{}, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return e.fetchedAt, nil
+}
+
+// GetProcessingInfo returns the time at which the module version was fetched
+// from the proxy and cached. The proxy data source does not record a fetcher
+// version, so FetcherVersion is empty. Like GetFetchTime, it does not fetch
+// the module version if it is not already cached.
+func (ds *DataSource) GetProcessingInfo(ctx context.Context, modulePath, version string) (_ *internal.ProcessingInfo, err error) {
+	defer derrors.Wrap(&err, "GetProcessingInfo(%q, %q)", modulePath, version)
+	fetchedAt, err := ds.GetFetchTime(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return &internal.ProcessingInfo{FetchedAt: fetchedAt}, nil
+}
+
+// GetFileStats returns statistics about the files in the module zip.
+func (ds *DataSource) GetFileStats(ctx context.Context, modulePath, version string) (_ *internal.FileStats, err error) {
+	defer derrors.Wrap(&err, "GetFileStats(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	if m.FileStats == nil {
+		return nil, fmt.Errorf("file stats for %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return m.FileStats, nil
+}
+
 // GetImports returns package imports as extracted from the module zip.
 func (ds *DataSource) GetImports(ctx context.Context, pkgPath, modulePath, version string) (_ []string, err error) {
 	defer derrors.Wrap(&err, "GetImports(%q, %q, %q)", pkgPath, modulePath, version)
@@ -114,6 +438,375 @@ func (ds *DataSource) GetImports(ctx context.Context, pkgPath, modulePath, versi
 	return vp.Imports, nil
 }
 
+// GetRequirements returns the modules required by the go.mod file in the
+// module zip for modulePath and version.
+func (ds *DataSource) GetRequirements(ctx context.Context, modulePath, version string) (_ []*internal.Requirement, err error) {
+	defer derrors.Wrap(&err, "GetRequirements(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	reqs := append([]*internal.Requirement{}, m.Requirements...)
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].ModulePath < reqs[j].ModulePath })
+	return reqs, nil
+}
+
+// GetModuleReadmeForPackage returns the README at the root of the module zip
+// that contains pkgPath. If modulePath is internal.UnknownModulePath, the
+// module is found as for GetPathInfo.
+func (ds *DataSource) GetModuleReadmeForPackage(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.Readme, err error) {
+	defer derrors.Wrap(&err, "GetModuleReadmeForPackage(%q, %q, %q)", pkgPath, modulePath, version)
+	modulePath, version, isPackage, err := ds.GetPathInfo(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	if !isPackage {
+		return nil, fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
+	}
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	if m.LegacyReadmeFilePath == "" {
+		return nil, fmt.Errorf("README of %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return &internal.Readme{Filepath: m.LegacyReadmeFilePath, Contents: m.LegacyReadmeContents}, nil
+}
+
+// GetReadmeHash returns the hash, as computed by internal.Readme.Hash, of the
+// README at the root of the module zip.
+func (ds *DataSource) GetReadmeHash(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetReadmeHash(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	if m.LegacyReadmeFilePath == "" {
+		return "", fmt.Errorf("README of %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	readme := &internal.Readme{Filepath: m.LegacyReadmeFilePath, Contents: m.LegacyReadmeContents}
+	return readme.Hash(), nil
+}
+
+// GetParsedModFile returns the go.mod file in the module zip, parsed by
+// internal.ParseModFile.
+func (ds *DataSource) GetParsedModFile(ctx context.Context, modulePath, version string) (_ *internal.ModFile, err error) {
+	defer derrors.Wrap(&err, "GetParsedModFile(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	if m.GoMod == nil {
+		return nil, fmt.Errorf("go.mod file of %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return internal.ParseModFile(m.GoMod)
+}
+
+// GetModFileDiff returns the difference between the require directives of the
+// go.mod files in the zips of versions fromVersion and toVersion of the
+// module. Each version is fetched from the proxy if it is not already cached.
+func (ds *DataSource) GetModFileDiff(ctx context.Context, modulePath, fromVersion, toVersion string) (_ *internal.ModDiff, err error) {
+	defer derrors.Wrap(&err, "GetModFileDiff(%q, %q, %q)", modulePath, fromVersion, toVersion)
+	from, err := ds.GetParsedModFile(ctx, modulePath, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := ds.GetParsedModFile(ctx, modulePath, toVersion)
+	if err != nil {
+		return nil, err
+	}
+	return internal.DiffModFiles(from, to), nil
+}
+
+// GetReplaceDirectives returns the replace directives of the go.mod file in
+// the module zip.
+func (ds *DataSource) GetReplaceDirectives(ctx context.Context, modulePath, version string) (_ []internal.Replace, err error) {
+	defer derrors.Wrap(&err, "GetReplaceDirectives(%q, %q)", modulePath, version)
+	mf, err := ds.GetParsedModFile(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return mf.ReplaceDirectives(), nil
+}
+
+// GetGoDirective returns the version in the go directive of the go.mod file
+// in the module zip, or the empty string if it has no go.mod file or the file
+// has no go directive.
+func (ds *DataSource) GetGoDirective(ctx context.Context, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "GetGoDirective(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return "", err
+	}
+	if m.GoMod == nil {
+		return "", nil
+	}
+	mf, err := internal.ParseModFile(m.GoMod)
+	if err != nil {
+		return "", err
+	}
+	return mf.GoVersion, nil
+}
+
+// GetImportPathMismatches returns the packages in the module zip whose import
+// comment differs from their path, sorted by path.
+func (ds *DataSource) GetImportPathMismatches(ctx context.Context, modulePath, version string) (_ []*internal.ImportPathMismatch, err error) {
+	defer derrors.Wrap(&err, "GetImportPathMismatches(%q, %q)", modulePath, version)
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []*internal.ImportPathMismatch
+	for _, p := range m.LegacyPackages {
+		if p.ImportComment != "" && p.ImportComment != p.Path {
+			mismatches = append(mismatches, &internal.ImportPathMismatch{
+				PackagePath:   p.Path,
+				ImportComment: p.ImportComment,
+			})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].PackagePath < mismatches[j].PackagePath })
+	return mismatches, nil
+}
+
+// GetSourceFile returns the contents of a .go file in the package directory,
+// as read from the module zip.
+func (ds *DataSource) GetSourceFile(ctx context.Context, pkgPath, modulePath, version, fileName string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "GetSourceFile(%q, %q, %q, %q)", pkgPath, modulePath, version, fileName)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	contents, ok := vp.SourceFiles[fileName]
+	if !ok {
+		return nil, fmt.Errorf("file %q: %w", fileName, derrors.NotFound)
+	}
+	return contents, nil
+}
+
+// GetSymbolCounts returns the number of exported symbols of each kind in the
+// package documentation extracted from the module zip.
+func (ds *DataSource) GetSymbolCounts(ctx context.Context, pkgPath, modulePath, version string) (_ map[internal.SymbolKind]int, err error) {
+	defer derrors.Wrap(&err, "GetSymbolCounts(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[internal.SymbolKind]int{}
+	for _, s := range vp.Symbols {
+		counts[s.Kind]++
+	}
+	return counts, nil
+}
+
+// GetSymbols returns the exported symbols in the package documentation
+// extracted from the module zip, sorted by name.
+func (ds *DataSource) GetSymbols(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.Symbol, err error) {
+	defer derrors.Wrap(&err, "GetSymbols(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	syms := append([]*internal.Symbol{}, vp.Symbols...)
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Name < syms[j].Name })
+	return syms, nil
+}
+
+// GetDocOutline returns the outline of the package documentation, built from
+// its exported symbols.
+func (ds *DataSource) GetDocOutline(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.Outline, err error) {
+	defer derrors.Wrap(&err, "GetDocOutline(%q, %q, %q)", pkgPath, modulePath, version)
+	vp, err := ds.GetPackage(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return internal.BuildOutline(vp.Symbols), nil
+}
+
+// GetSymbolPresence reports which of the named symbols are exported by each
+// tagged version of the package, as listed by the proxy. Only the
+// internal.MaxSymbolPresenceVersions highest versions are considered, and
+// each of them is fetched from the proxy if it is not already cached.
+// Versions of the module that do not contain the package are omitted.
+func (ds *DataSource) GetSymbolPresence(ctx context.Context, pkgPath string, symbols []string) (_ map[string]map[string]bool, err error) {
+	defer derrors.Wrap(&err, "GetSymbolPresence(%q, %q)", pkgPath, symbols)
+	versions, err := ds.listPackageVersions(ctx, pkgPath, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > internal.MaxSymbolPresenceVersions {
+		versions = versions[:internal.MaxSymbolPresenceVersions]
+	}
+	presence := map[string]map[string]bool{}
+	for _, mi := range versions {
+		vp, err := ds.GetPackage(ctx, pkgPath, mi.ModulePath, mi.Version)
+		if errors.Is(err, derrors.NotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		exported := map[string]bool{}
+		for _, s := range vp.Symbols {
+			exported[s.Name] = true
+		}
+		present := map[string]bool{}
+		for _, name := range symbols {
+			present[name] = exported[name]
+		}
+		presence[mi.Version] = present
+	}
+	return presence, nil
+}
+
+// GetSymbolUsages returns at most limit of the packages that refer to the
+// exported symbol of the package with path pkgPath, most references first,
+// with ties broken by package path. Only the highest cached version of each
+// module that has already been fetched is considered, so the result is
+// best-effort.
+func (ds *DataSource) GetSymbolUsages(ctx context.Context, pkgPath, symbol string, limit int) (_ []*internal.SymbolUsage, err error) {
+	defer derrors.Wrap(&err, "GetSymbolUsages(%q, %q, %d)", pkgPath, symbol, limit)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative: %w", derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	latest := map[string]*internal.Module{}
+	for _, e := range ds.versionCache {
+		if e.module == nil {
+			continue
+		}
+		if m := latest[e.module.ModulePath]; m == nil || semver.Compare(e.module.Version, m.Version) > 0 {
+			latest[e.module.ModulePath] = e.module
+		}
+	}
+	ds.mu.RUnlock()
+
+	var usages []*internal.SymbolUsage
+	for _, m := range latest {
+		for _, p := range m.LegacyPackages {
+			for _, r := range p.SymbolReferences {
+				if r.ImportPath == pkgPath && r.Symbol == symbol {
+					usages = append(usages, &internal.SymbolUsage{
+						PackagePath: p.Path,
+						ModulePath:  m.ModulePath,
+						Count:       r.Count,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Count != usages[j].Count {
+			return usages[i].Count > usages[j].Count
+		}
+		return usages[i].PackagePath < usages[j].PackagePath
+	})
+	if len(usages) > limit {
+		usages = usages[:limit]
+	}
+	return usages, nil
+}
+
+// GetStabilitySignal returns the internal.Stability of the package, computed
+// from the package documentation of the internal.MaxStabilityVersions highest
+// tagged versions listed by the proxy. Each of them is fetched from the proxy
+// if it is not already cached.
+func (ds *DataSource) GetStabilitySignal(ctx context.Context, pkgPath string) (_ *internal.Stability, err error) {
+	defer derrors.Wrap(&err, "GetStabilitySignal(%q)", pkgPath)
+	versions, err := ds.listPackageVersions(ctx, pkgPath, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > internal.MaxStabilityVersions {
+		versions = versions[:internal.MaxStabilityVersions]
+	}
+	apis := map[string]map[string]string{}
+	for _, mi := range versions {
+		vp, err := ds.GetPackage(ctx, pkgPath, mi.ModulePath, mi.Version)
+		if errors.Is(err, derrors.NotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		api := map[string]string{}
+		for _, s := range vp.Symbols {
+			api[s.Name] = s.Synopsis
+		}
+		apis[mi.Version] = api
+	}
+	if len(apis) == 0 {
+		return nil, fmt.Errorf("package %s: %w", pkgPath, derrors.NotFound)
+	}
+	return internal.ComputeStability(apis), nil
+}
+
+// GetIndexStats returns counts of the modules, module versions and packages
+// that have been successfully fetched from the proxy.
+func (ds *DataSource) GetIndexStats(ctx context.Context) (*internal.IndexStats, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	// A module version requested as a query such as "latest" is cached under
+	// the query as well as its version, so count the versions it resolved to.
+	modules := map[string]bool{}
+	versions := map[versionKey]bool{}
+	packages := map[string]bool{}
+	for _, e := range ds.versionCache {
+		if e.module == nil {
+			continue
+		}
+		modules[e.module.ModulePath] = true
+		versions[versionKey{e.module.ModulePath, e.module.Version}] = true
+		for _, p := range e.module.LegacyPackages {
+			packages[p.Path] = true
+		}
+	}
+	return &internal.IndexStats{
+		TotalModules:        len(modules),
+		TotalModuleVersions: len(versions),
+		TotalPackages:       len(packages),
+	}, nil
+}
+
+// GetImportsGrouped returns package imports as extracted from the module zip,
+// split into standard library imports and all other imports.
+func (ds *DataSource) GetImportsGrouped(ctx context.Context, pkgPath, modulePath, version string) (std, external []string, err error) {
+	defer derrors.Wrap(&err, "GetImportsGrouped(%q, %q, %q)", pkgPath, modulePath, version)
+	imports, err := ds.GetImports(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	std, external = []string{}, []string{}
+	for _, p := range imports {
+		if stdlib.Contains(p) {
+			std = append(std, p)
+		} else {
+			external = append(external, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(external)
+	return std, external, nil
+}
+
+// GetInternalImports returns the package imports extracted from the module zip
+// whose paths are within modulePath, sorted by path.
+func (ds *DataSource) GetInternalImports(ctx context.Context, pkgPath, modulePath, version string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetInternalImports(%q, %q, %q)", pkgPath, modulePath, version)
+	imports, err := ds.GetImports(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	internalImports := []string{}
+	for _, p := range imports {
+		if strings.HasPrefix(p+"/", modulePath+"/") {
+			internalImports = append(internalImports, p)
+		}
+	}
+	sort.Strings(internalImports)
+	return internalImports, nil
+}
+
 // GetModuleLicenses returns root-level licenses detected within the module zip
 // for modulePath and version.
 func (ds *DataSource) GetModuleLicenses(ctx context.Context, modulePath, version string) (_ []*licenses.License, err error) {
@@ -131,6 +824,46 @@ func (ds *DataSource) GetModuleLicenses(ctx context.Context, modulePath, version
 	return filtered, nil
 }
 
+// GetLicenseBadge returns the internal.Badge for the module version, built
+// from the licenses detected at the root of the module zip.
+func (ds *DataSource) GetLicenseBadge(ctx context.Context, modulePath, version string) (_ *internal.Badge, err error) {
+	defer derrors.Wrap(&err, "GetLicenseBadge(%q, %q)", modulePath, version)
+	v, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var metas []*licenses.Metadata
+	for _, lic := range v.Licenses {
+		if !strings.Contains(lic.FilePath, "/") {
+			metas = append(metas, lic.Metadata)
+		}
+	}
+	return internal.NewLicenseBadge(metas, v.IsRedistributable), nil
+}
+
+// GetLicenseFiles returns all licenses detected within the module zip for
+// modulePath and version, including those in subdirectories, with their
+// contents.
+func (ds *DataSource) GetLicenseFiles(ctx context.Context, modulePath, version string) (_ []*licenses.License, err error) {
+	defer derrors.Wrap(&err, "GetLicenseFiles(%q, %q)", modulePath, version)
+	v, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return v.Licenses, nil
+}
+
+// GetUndetectedLicenseFiles returns the license files within the module zip
+// for modulePath and version whose types could not be detected.
+func (ds *DataSource) GetUndetectedLicenseFiles(ctx context.Context, modulePath, version string) (_ []internal.LicenseFile, err error) {
+	defer derrors.Wrap(&err, "GetUndetectedLicenseFiles(%q, %q)", modulePath, version)
+	v, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return internal.UndetectedLicenseFiles(v.Licenses), nil
+}
+
 // GetPackage returns a LegacyVersionedPackage for the given pkgPath and version. If
 // such a package exists in the cache, it will be returned without querying the
 // proxy. Otherwise, the proxy is queried to find the longest module path at
@@ -177,13 +910,93 @@ func (ds *DataSource) GetPackageLicenses(ctx context.Context, pkgPath, modulePat
 }
 
 // GetPackagesInModule returns LegacyPackages contained in the module zip corresponding to modulePath and version.
-func (ds *DataSource) GetPackagesInModule(ctx context.Context, modulePath, version string) (_ []*internal.LegacyPackage, err error) {
+// Vendored packages are omitted unless opts.IncludeVendored is set.
+func (ds *DataSource) GetPackagesInModule(ctx context.Context, modulePath, version string, opts internal.PathOptions) (_ []*internal.LegacyPackage, err error) {
 	defer derrors.Wrap(&err, "GetPackagesInModule(%q, %q)", modulePath, version)
 	v, err := ds.getModule(ctx, modulePath, version)
 	if err != nil {
 		return nil, err
 	}
-	return v.LegacyPackages, nil
+	if opts.IncludeVendored {
+		return v.LegacyPackages, nil
+	}
+	var pkgs []*internal.LegacyPackage
+	for _, p := range v.LegacyPackages {
+		if !internal.IsVendored(p.Path) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
+
+// GetImportablePackages returns the LegacyPackages in the module zip that can
+// be imported by other modules, as reported by internal.IsImportable.
+func (ds *DataSource) GetImportablePackages(ctx context.Context, modulePath, version string) (_ []*internal.LegacyPackage, err error) {
+	defer derrors.Wrap(&err, "GetImportablePackages(%q, %q)", modulePath, version)
+	v, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []*internal.LegacyPackage
+	for _, p := range v.LegacyPackages {
+		if internal.IsImportable(p.Path, p.Name) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
+
+// GetSiblingPackages returns the LegacyPackages in the module zip, other than
+// pkgPath, whose immediate parent directory is the same as that of pkgPath,
+// sorted by path.
+func (ds *DataSource) GetSiblingPackages(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.LegacyPackage, err error) {
+	defer derrors.Wrap(&err, "GetSiblingPackages(%q, %q, %q)", pkgPath, modulePath, version)
+	v, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(pkgPath)
+	var pkgs []*internal.LegacyPackage
+	for _, p := range v.LegacyPackages {
+		if p.Path != pkgPath && path.Dir(p.Path) == dir {
+			pkgs = append(pkgs, p)
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path })
+	return pkgs, nil
+}
+
+// GetPrimaryPackage returns the package in the module zip whose path is the
+// module path, or if there is none, the package with the fewest path elements,
+// with ties broken by path.
+func (ds *DataSource) GetPrimaryPackage(ctx context.Context, modulePath, version string) (_ *internal.LegacyPackage, err error) {
+	defer derrors.Wrap(&err, "GetPrimaryPackage(%q, %q)", modulePath, version)
+	v, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	var primary *internal.LegacyPackage
+	for _, p := range v.LegacyPackages {
+		if primary == nil || primaryBefore(modulePath, p.Path, primary.Path) {
+			primary = p
+		}
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("no packages in %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return primary, nil
+}
+
+// primaryBefore reports whether the package with path p1 is preferred over the
+// one with path p2 as the primary package of the module with path modulePath.
+func primaryBefore(modulePath, p1, p2 string) bool {
+	if (p1 == modulePath) != (p2 == modulePath) {
+		return p1 == modulePath
+	}
+	if d1, d2 := strings.Count(p1, "/"), strings.Count(p2, "/"); d1 != d2 {
+		return d1 < d2
+	}
+	return p1 < p2
 }
 
 // GetPseudoVersionsForModule returns versions from the the proxy /list
@@ -208,6 +1021,32 @@ func (ds *DataSource) GetTaggedVersionsForModule(ctx context.Context, modulePath
 	return ds.listModuleVersions(ctx, modulePath, false)
 }
 
+// GetVersionsMatching returns the versions of the module with path modulePath
+// from the proxy /list endpoint that satisfy constraint, sorted in descending
+// semver order.
+func (ds *DataSource) GetVersionsMatching(ctx context.Context, modulePath, constraint string) (_ []*internal.LegacyModuleInfo, err error) {
+	defer derrors.Wrap(&err, "GetVersionsMatching(%q, %q)", modulePath, constraint)
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, derrors.InvalidArgument)
+	}
+	return ds.listMatchingModuleVersions(ctx, modulePath, c.Match)
+}
+
+// ResolveBranch returns the version that the proxy resolves modulePath at
+// branch to.
+func (ds *DataSource) ResolveBranch(ctx context.Context, modulePath, branch string) (_ string, err error) {
+	defer derrors.Wrap(&err, "ResolveBranch(%q, %q)", modulePath, branch)
+	if !version.IsBranch(branch) {
+		return "", fmt.Errorf("%q is not a branch name: %w", branch, derrors.InvalidArgument)
+	}
+	info, err := ds.proxyClient.GetInfo(ctx, modulePath, branch)
+	if err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
 // GetTaggedVersionsForPackageSeries finds the longest module path containing
 // pkgPath, and returns its versions from the proxy /list endpoint, if they are
 // tagged versions. Otherwise, it returns an empty slice.
@@ -216,6 +1055,12 @@ func (ds *DataSource) GetTaggedVersionsForPackageSeries(ctx context.Context, pkg
 	return ds.listPackageVersions(ctx, pkgPath, false)
 }
 
+// SupportedBuildContexts returns internal.BuildContexts, the build contexts
+// used to load packages from the module zip.
+func (*DataSource) SupportedBuildContexts(ctx context.Context) ([]internal.BuildContext, error) {
+	return append([]internal.BuildContext{}, internal.BuildContexts...), nil
+}
+
 // GetModuleInfo returns the LegacyModuleInfo as fetched from the proxy for module
 // version specified by modulePath and version.
 func (ds *DataSource) GetModuleInfo(ctx context.Context, modulePath, version string) (_ *internal.LegacyModuleInfo, err error) {
@@ -227,8 +1072,366 @@ func (ds *DataSource) GetModuleInfo(ctx context.Context, modulePath, version str
 	return &m.LegacyModuleInfo, nil
 }
 
+// GetModuleByCommit returns the LegacyModuleInfo for the highest
+// pseudo-version of the module whose revision starts with commitHash, among
+// the versions that have already been fetched from the proxy.
+func (ds *DataSource) GetModuleByCommit(ctx context.Context, modulePath, commitHash string) (_ *internal.LegacyModuleInfo, err error) {
+	defer derrors.Wrap(&err, "GetModuleByCommit(%q, %q)", modulePath, commitHash)
+	prefix, ok := version.CommitHashPrefix(commitHash)
+	if !ok {
+		return nil, fmt.Errorf("invalid commit hash %q: %w", commitHash, derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	versions := ds.modulePathToVersions[modulePath]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(version.PseudoRevision(versions[i]), prefix) {
+			continue
+		}
+		if e := ds.versionCache[versionKey{modulePath, versions[i]}]; e != nil && e.module != nil {
+			return &e.module.LegacyModuleInfo, nil
+		}
+	}
+	return nil, fmt.Errorf("commit %s of module %s: %w", commitHash, modulePath, derrors.NotFound)
+}
+
+// GetModulesByOwner returns the highest fetched version of each module whose
+// source repository is at host and belongs to owner. The proxy knows nothing
+// of popularity, so modules are ordered by path.
+func (ds *DataSource) GetModulesByOwner(ctx context.Context, host, owner string, limit, offset int) (_ []*internal.ModuleInfo, err error) {
+	defer derrors.Wrap(&err, "GetModulesByOwner(%q, %q, %d, %d)", host, owner, limit, offset)
+	if host == "" || owner == "" {
+		return nil, fmt.Errorf("host and owner cannot be empty: %w", derrors.InvalidArgument)
+	}
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit and offset must be non-negative: %w", derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	latest := map[string]*internal.Module{}
+	for _, e := range ds.versionCache {
+		if e.module == nil {
+			continue
+		}
+		if m := latest[e.module.ModulePath]; m == nil || semver.Compare(e.module.Version, m.Version) > 0 {
+			latest[e.module.ModulePath] = e.module
+		}
+	}
+	ds.mu.RUnlock()
+
+	var modules []*internal.ModuleInfo
+	for _, m := range latest {
+		h, o := repoOwner(m.SourceInfo.RepoURL())
+		if strings.EqualFold(h, host) && strings.EqualFold(o, owner) {
+			modules = append(modules, &m.ModuleInfo)
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].ModulePath < modules[j].ModulePath })
+	if offset >= len(modules) {
+		return nil, nil
+	}
+	modules = modules[offset:]
+	if len(modules) > limit {
+		modules = modules[:limit]
+	}
+	return modules, nil
+}
+
+// repoOwner returns the host of repoURL and the first element of its path,
+// or empty strings if repoURL is not a URL with a non-empty path.
+func repoOwner(repoURL string) (host, owner string) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", ""
+	}
+	owner = strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+	if owner == "" {
+		return "", ""
+	}
+	return u.Host, owner
+}
+
+// GetLatestVersions returns a map from each module path in modulePaths to the
+// version that the proxy resolves "latest" to. Module paths unknown to the
+// proxy are absent from the map.
+func (ds *DataSource) GetLatestVersions(ctx context.Context, modulePaths []string) (_ map[string]string, err error) {
+	defer derrors.Wrap(&err, "GetLatestVersions(%v)", modulePaths)
+	latest := map[string]string{}
+	for _, modulePath := range modulePaths {
+		mi, err := ds.GetModuleInfo(ctx, modulePath, internal.LatestVersion)
+		if errors.Is(err, derrors.NotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		latest[modulePath] = mi.Version
+	}
+	return latest, nil
+}
+
+// A latestPackage is the highest fetched version of a package.
+type latestPackage struct {
+	result    *internal.SearchResult
+	fetchedAt time.Time // when its module version was fetched
+}
+
+// latestPackagesLocked returns the highest fetched version of each package in
+// the cache, by package path. ds.mu must be held.
+func (ds *DataSource) latestPackagesLocked() map[string]*latestPackage {
+	latest := map[string]*latestPackage{}
+	for _, e := range ds.versionCache {
+		if e.module == nil {
+			continue
+		}
+		for _, p := range e.module.LegacyPackages {
+			if u, ok := latest[p.Path]; ok && semver.Compare(u.result.Version, e.module.Version) >= 0 {
+				continue
+			}
+			r := &internal.SearchResult{
+				Name:        p.Name,
+				PackagePath: p.Path,
+				ModulePath:  e.module.ModulePath,
+				Version:     e.module.Version,
+				Synopsis:    p.Synopsis,
+				CommitTime:  e.module.CommitTime,
+			}
+			for _, l := range p.Licenses {
+				r.Licenses = append(r.Licenses, l.Types...)
+			}
+			latest[p.Path] = &latestPackage{r, e.fetchedAt}
+		}
+	}
+	return latest
+}
+
+// GetPackagesByPrefix returns the highest fetched version of each package
+// whose import path is prefix or is under prefix, in order of package path.
+// At most limit packages are returned, after skipping the first offset. It
+// only considers module versions that have already been fetched. The Score,
+// NumImportedBy and NumResults fields of the results are not set.
+func (ds *DataSource) GetPackagesByPrefix(ctx context.Context, prefix string, limit, offset int) (_ []*internal.SearchResult, err error) {
+	defer derrors.Wrap(&err, "GetPackagesByPrefix(%q, %d, %d)", prefix, limit, offset)
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit and offset must be non-negative: %w", derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	latest := ds.latestPackagesLocked()
+	ds.mu.RUnlock()
+
+	var results []*internal.SearchResult
+	for path, u := range latest {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			results = append(results, u.result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].PackagePath < results[j].PackagePath })
+	if offset >= len(results) {
+		return nil, nil
+	}
+	results = results[offset:]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// GetRecentlyUpdatedPackages returns at most limit packages whose highest
+// fetched version was fetched from the proxy after since, newest first, then
+// by package path. It only considers module versions that have already been
+// fetched. The Score, NumImportedBy and NumResults fields of the results are
+// not set.
+func (ds *DataSource) GetRecentlyUpdatedPackages(ctx context.Context, since time.Time, limit int) (_ []*internal.SearchResult, err error) {
+	defer derrors.Wrap(&err, "GetRecentlyUpdatedPackages(%s, %d)", since, limit)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative: %w", derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	latest := ds.latestPackagesLocked()
+	var updates []*latestPackage
+	for _, u := range latest {
+		if u.fetchedAt.After(since) {
+			updates = append(updates, u)
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		if !updates[i].fetchedAt.Equal(updates[j].fetchedAt) {
+			return updates[i].fetchedAt.After(updates[j].fetchedAt)
+		}
+		return updates[i].result.PackagePath < updates[j].result.PackagePath
+	})
+	if len(updates) > limit {
+		updates = updates[:limit]
+	}
+	var results []*internal.SearchResult
+	for _, u := range updates {
+		results = append(results, u.result)
+	}
+	return results, nil
+}
+
+// GetFailedVersions returns the versions of the module with path modulePath
+// that failed to be fetched from the proxy, highest version first, with the
+// HTTP status of their error. A version requested as a query such as "latest"
+// is reported under the query. Transient failures are not cached by getModule,
+// so they are not reported.
+func (ds *DataSource) GetFailedVersions(ctx context.Context, modulePath string) (_ []internal.FailedVersion, err error) {
+	defer derrors.Wrap(&err, "GetFailedVersions(%q)", modulePath)
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	var failed []internal.FailedVersion
+	for key, e := range ds.versionCache {
+		if key.modulePath != modulePath || e.err == nil {
+			continue
+		}
+		failed = append(failed, internal.FailedVersion{
+			Version:   key.version,
+			Status:    derrors.ToHTTPStatus(e.err),
+			Error:     e.err.Error(),
+			FetchedAt: e.fetchedAt,
+		})
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return semver.Compare(failed[i].Version, failed[j].Version) > 0
+	})
+	return failed, nil
+}
+
+// RecentlyFetched returns at most limit of the module versions fetched from
+// the proxy, most recently fetched first, with ties broken by module path and
+// version. Failed fetches that getModule cached are included, with the HTTP
+// status of their error.
+// A module version requested as a query such as "latest" is reported under
+// the version it resolved to, if it was fetched successfully.
+func (ds *DataSource) RecentlyFetched(ctx context.Context, limit int) (_ []*internal.FetchEvent, err error) {
+	defer derrors.Wrap(&err, "RecentlyFetched(%d)", limit)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must be non-negative: %w", derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	latest := map[versionKey]*internal.FetchEvent{}
+	for key, e := range ds.versionCache {
+		ev := &internal.FetchEvent{
+			ModulePath: key.modulePath,
+			Version:    key.version,
+			Status:     http.StatusOK,
+			FetchedAt:  e.fetchedAt,
+		}
+		if e.err != nil {
+			ev.Status = derrors.ToHTTPStatus(e.err)
+			ev.Error = e.err.Error()
+		} else if e.module != nil {
+			ev.Version = e.module.Version
+		}
+		k := versionKey{ev.ModulePath, ev.Version}
+		if prev, ok := latest[k]; ok && !ev.FetchedAt.After(prev.FetchedAt) {
+			continue
+		}
+		latest[k] = ev
+	}
+	var events []*internal.FetchEvent
+	for _, ev := range latest {
+		events = append(events, ev)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		ei, ej := events[i], events[j]
+		if !ei.FetchedAt.Equal(ej.FetchedAt) {
+			return ei.FetchedAt.After(ej.FetchedAt)
+		}
+		if ei.ModulePath != ej.ModulePath {
+			return ei.ModulePath < ej.ModulePath
+		}
+		return semver.Compare(ei.Version, ej.Version) > 0
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// GetSitemapEntries returns at most limit of the module and package paths of
+// the module versions that have already been fetched, in order of path,
+// skipping the first offset of them. The LastModified time of a path is when
+// its latest version was fetched from the proxy, where releases come before
+// prereleases and pseudo-versions.
+func (ds *DataSource) GetSitemapEntries(ctx context.Context, limit, offset int) (_ []internal.SitemapEntry, err error) {
+	defer derrors.Wrap(&err, "GetSitemapEntries(%d, %d)", limit, offset)
+	if limit <= 0 || limit > internal.MaxSitemapEntries || offset < 0 {
+		return nil, fmt.Errorf("limit %d, offset %d: %w", limit, offset, derrors.InvalidArgument)
+	}
+	ds.mu.RLock()
+	type latest struct {
+		version   string
+		fetchedAt time.Time
+	}
+	byPath := map[string]latest{}
+	add := func(path string, e *versionEntry) {
+		if l, ok := byPath[path]; ok && !laterVersion(e.module.Version, l.version) {
+			return
+		}
+		byPath[path] = latest{e.module.Version, e.fetchedAt}
+	}
+	for _, e := range ds.versionCache {
+		if e.module == nil {
+			continue
+		}
+		add(e.module.ModulePath, e)
+		for _, p := range e.module.LegacyPackages {
+			add(p.Path, e)
+		}
+	}
+	ds.mu.RUnlock()
+
+	var entries []internal.SitemapEntry
+	for path, l := range byPath {
+		entries = append(entries, internal.SitemapEntry{Path: path, LastModified: l.fetchedAt})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	if offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[offset:]
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// laterVersion reports whether v1 is later than v2 in the order used to choose
+// the latest version: releases first, then by semantic version.
+func laterVersion(v1, v2 string) bool {
+	r1, r2 := semver.Prerelease(v1) == "", semver.Prerelease(v2) == ""
+	if r1 != r2 {
+		return r1
+	}
+	return semver.Compare(v1, v2) > 0
+}
+
+// SuggestSimilarPaths returns paths similar to path from among the module and
+// package paths of the module versions that have already been fetched. It
+// does not query the proxy.
+func (ds *DataSource) SuggestSimilarPaths(ctx context.Context, path string, limit int) (_ []string, err error) {
+	defer derrors.Wrap(&err, "SuggestSimilarPaths(%q, %d)", path, limit)
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	var candidates []string
+	for modulePath := range ds.modulePathToVersions {
+		candidates = append(candidates, modulePath)
+	}
+	for pkgPath := range ds.packagePathToModules {
+		candidates = append(candidates, pkgPath)
+	}
+	return internal.SimilarPaths(path, candidates, limit), nil
+}
+
 // getModule retrieves a version from the cache, or failing that queries and
-// processes the version from the proxy.
+// processes the version from the proxy. Failures are cached only if they are
+// terminal, as reported by isTerminal, so that a transient failure such as an
+// unreachable proxy is retried on the next request.
 func (ds *DataSource) getModule(ctx context.Context, modulePath, version string) (_ *internal.Module, err error) {
 	defer derrors.Wrap(&err, "getModule(%q, %q)", modulePath, version)
 
@@ -240,8 +1443,11 @@ func (ds *DataSource) getModule(ctx context.Context, modulePath, version string)
 	}
 
 	res := fetch.FetchModule(ctx, modulePath, version, ds.proxyClient, ds.sourceClient)
+	if res.Error != nil && !isTerminal(res.Error) {
+		return nil, res.Error
+	}
 	m := res.Module
-	ds.versionCache[key] = &versionEntry{module: m, err: err}
+	ds.versionCache[key] = &versionEntry{module: m, err: res.Error, fetchedAt: time.Now()}
 	if res.Error != nil {
 		return nil, res.Error
 	}
@@ -278,6 +1484,15 @@ func (ds *DataSource) getModule(ctx context.Context, modulePath, version string)
 	return m, nil
 }
 
+// isTerminal reports whether err, returned by fetch.FetchModule, describes the
+// module version itself, such as derrors.NotFound or derrors.BadModule, so
+// that fetching it again would fail in the same way. Errors that map to an
+// HTTP status of 500 or above, such as those from a failed request to the
+// proxy or a canceled context, are not terminal.
+func isTerminal(err error) bool {
+	return derrors.ToHTTPStatus(err) < http.StatusInternalServerError
+}
+
 // findModule finds the longest module path containing the given package path,
 // using the given finder func and iteratively testing parent directories of
 // the import path. It performs no testing as to whether the specified module
@@ -326,6 +1541,18 @@ func (ds *DataSource) listPackageVersions(ctx context.Context, pkgPath string, p
 // versions.
 func (ds *DataSource) listModuleVersions(ctx context.Context, modulePath string, pseudo bool) (_ []*internal.LegacyModuleInfo, err error) {
 	defer derrors.Wrap(&err, "listModuleVersions(%q, %t)", modulePath, pseudo)
+	// In practice, the /list endpoint should only return either pseudo
+	// versions or tagged versions, but we filter here for maximum
+	// compatibility.
+	return ds.listMatchingModuleVersions(ctx, modulePath, func(vers string) bool {
+		return version.IsPseudo(vers) == pseudo
+	})
+}
+
+// listMatchingModuleVersions returns the versions of the module with path
+// modulePath from the proxy /list endpoint for which keep returns true, sorted
+// in descending semver order.
+func (ds *DataSource) listMatchingModuleVersions(ctx context.Context, modulePath string, keep func(vers string) bool) ([]*internal.LegacyModuleInfo, error) {
 	versions, err := ds.proxyClient.ListVersions(ctx, modulePath)
 	if err != nil {
 		return nil, err
@@ -334,14 +1561,13 @@ func (ds *DataSource) listModuleVersions(ctx context.Context, modulePath string,
 	ds.mu.RLock()
 	defer ds.mu.RUnlock()
 	for _, vers := range versions {
-		// In practice, the /list endpoint should only return either pseudo
-		// versions or tagged versions, but we filter here for maximum
-		// compatibility.
-		if version.IsPseudo(vers) != pseudo {
+		if !keep(vers) {
 			continue
 		}
 		if v, ok := ds.versionCache[versionKey{modulePath, vers}]; ok {
-			vis = append(vis, &v.module.LegacyModuleInfo)
+			mi := v.module.LegacyModuleInfo
+			mi.IsPrerelease = semver.Prerelease(vers) != ""
+			vis = append(vis, &mi)
 		} else {
 			// In this case we can't produce s LegacyModuleInfo without fully processing
 			// the module zip, so we instead append a stub. We could further query
@@ -352,6 +1578,7 @@ func (ds *DataSource) listModuleVersions(ctx context.Context, modulePath string,
 					ModulePath: modulePath,
 					Version:    vers,
 				},
+				IsPrerelease: semver.Prerelease(vers) != "",
 			})
 		}
 	}
@@ -415,6 +1642,45 @@ func (*DataSource) GetExperiments(ctx context.Context) ([]*internal.Experiment,
 	return nil, nil
 }
 
+// ClassifyPath returns the kind of path in the given module version, which is
+// found as by GetPathInfo if unknown.
+func (ds *DataSource) ClassifyPath(ctx context.Context, path, modulePath, version string) (_ internal.PathKind, err error) {
+	defer derrors.Wrap(&err, "ClassifyPath(%q, %q, %q)", path, modulePath, version)
+
+	if modulePath == internal.UnknownModulePath {
+		var info *proxy.VersionInfo
+		modulePath, info, err = ds.findModule(ctx, path, version)
+		if errors.Is(err, derrors.NotFound) {
+			return internal.PathKindNotFound, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		version = info.Version
+	}
+	m, err := ds.getModule(ctx, modulePath, version)
+	if errors.Is(err, derrors.NotFound) {
+		return internal.PathKindNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, d := range m.Directories {
+		if d.Path != path {
+			continue
+		}
+		switch {
+		case path == m.ModulePath:
+			return internal.PathKindModuleRoot, nil
+		case d.Package != nil:
+			return internal.PathKindPackage, nil
+		default:
+			return internal.PathKindDirectory, nil
+		}
+	}
+	return internal.PathKindNotFound, nil
+}
+
 // GetPathInfo returns information about the given path.
 func (ds *DataSource) GetPathInfo(ctx context.Context, path, inModulePath, inVersion string) (outModulePath, outVersion string, isPackage bool, err error) {
 	defer derrors.Wrap(&err, "GetPathInfo(%q, %q, %q)", path, inModulePath, inVersion)
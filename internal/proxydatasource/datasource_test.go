@@ -6,25 +6,34 @@ package proxydatasource
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch/dochtml"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/testing/datasourcetest"
 	"golang.org/x/pkgsite/internal/testing/sample"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
 	"golang.org/x/pkgsite/internal/version"
 )
 
+const bazGo = "//Package baz provides a helpful constant.\npackage baz\nimport \"net/http\"\nconst OK = http.StatusOK"
+
 func setup(t *testing.T) (context.Context, *DataSource, func()) {
 	t.Helper()
 	contents := map[string]string{
 		"go.mod":     "module foo.com/bar",
 		"LICENSE":    testhelper.MITLicense,
-		"baz/baz.go": "//Package baz provides a helpful constant.\npackage baz\nimport \"net/http\"\nconst OK = http.StatusOK",
+		"baz/baz.go": bazGo,
 	}
 	testModules := []*proxy.TestModule{
 		{
@@ -40,7 +49,7 @@ func setup(t *testing.T) (context.Context, *DataSource, func()) {
 	}
 	client, teardownProxy := proxy.SetupTestProxy(t, testModules)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	return ctx, New(client), func() {
+	return ctx, NewForTesting(client), func() {
 		teardownProxy()
 		cancel()
 	}
@@ -55,10 +64,22 @@ var (
 		Imports:           []string{"net/http"},
 		Synopsis:          "Package baz provides a helpful constant.",
 		V1Path:            "foo.com/bar/baz",
+		Doc:               "Package baz provides a helpful constant.\n",
 		Licenses:          []*licenses.Metadata{wantLicenseMD},
 		IsRedistributable: true,
 		GOOS:              "linux",
 		GOARCH:            "amd64",
+		Symbols: []*internal.Symbol{
+			{Name: "OK", Kind: internal.SymbolKindConstant, Synopsis: "const OK"},
+		},
+		SourceFiles: map[string][]byte{"baz.go": []byte(bazGo)},
+		DocBuildInfo: &internal.DocBuildInfo{
+			GoVersion:       runtime.Version(),
+			RendererVersion: dochtml.RendererVersion,
+		},
+		SymbolReferences: []*internal.SymbolReference{
+			{ImportPath: "net/http", Symbol: "StatusOK", Count: 1},
+		},
 	}
 	wantModuleInfo = internal.ModuleInfo{
 		ModulePath:        "foo.com/bar",
@@ -108,6 +129,194 @@ func TestDataSource_GetImports(t *testing.T) {
 	}
 }
 
+func TestDataSource_GetFetchTime(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	if _, err := ds.GetFetchTime(ctx, "foo.com/bar", "v1.2.0"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("before fetch: got error %v, want %v", err, derrors.NotFound)
+	}
+	before := time.Now()
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+	got, err := ds.GetFetchTime(ctx, "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Before(before) || got.After(after) {
+		t.Errorf("got fetch time %v, want between %v and %v", got, before, after)
+	}
+}
+
+func TestDataSource_GetProcessingInfo(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "github.com/a/processed",
+		Version:    "v1.0.0",
+		Files:      map[string]string{"processed.go": "package processed\n"},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	const modulePath, version = "github.com/a/processed", "v1.0.0"
+	if _, err := ds.GetProcessingInfo(ctx, modulePath, version); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("before fetch: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := ds.GetModuleInfo(ctx, modulePath, version); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ds.GetProcessingInfo(ctx, modulePath, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchedAt, err := ds.GetFetchTime(ctx, modulePath, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&internal.ProcessingInfo{FetchedAt: fetchedAt}); !cmp.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDataSource_RecentlyFetched(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{ModulePath: "github.com/a/one", Version: "v1.0.0", Files: map[string]string{"one.go": "package one\n"}},
+		{ModulePath: "github.com/a/two", Version: "v1.0.0", Files: map[string]string{"two.go": "package two\n"}},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	fetch := func(modulePath, version string) {
+		t.Helper()
+		ds.GetModuleInfo(ctx, modulePath, version)
+		// Make sure fetch times differ.
+		time.Sleep(time.Millisecond)
+	}
+	fetch("github.com/a/one", "v1.0.0")
+	fetch("github.com/a/missing", "v1.0.0")
+	fetch("github.com/a/two", "latest")
+
+	got, err := ds.RecentlyFetched(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.FetchEvent{
+		{ModulePath: "github.com/a/two", Version: "v1.0.0", Status: http.StatusOK},
+		{ModulePath: "github.com/a/missing", Version: "v1.0.0", Status: http.StatusNotFound},
+		{ModulePath: "github.com/a/one", Version: "v1.0.0", Status: http.StatusOK},
+	}
+	opts := cmpopts.IgnoreFields(internal.FetchEvent{}, "Error", "FetchedAt")
+	if diff := cmp.Diff(want, got, opts); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got[1].Error == "" {
+		t.Error("failed fetch has no error message")
+	}
+
+	got, err = ds.RecentlyFetched(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want[:1], got, opts); diff != "" {
+		t.Errorf("limit 1: mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := ds.RecentlyFetched(ctx, -1); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("negative limit: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestDataSource_GetFailedVersions(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{ModulePath: "github.com/a/one", Version: "v1.0.0", Files: map[string]string{"one.go": "package one\n"}},
+		{ModulePath: "github.com/a/one", Version: "v1.2.0", Files: map[string]string{"README.md": "no packages\n"}},
+		{ModulePath: "github.com/a/two", Version: "v1.0.0", Files: map[string]string{"two.go": "package two\n"}},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, mv := range [][2]string{
+		{"github.com/a/one", "v1.0.0"},
+		{"github.com/a/one", "v1.1.0"},
+		{"github.com/a/one", "v1.2.0"},
+		{"github.com/a/two", "v1.1.0"},
+	} {
+		ds.GetModuleInfo(ctx, mv[0], mv[1])
+	}
+
+	got, err := ds.GetFailedVersions(ctx, "github.com/a/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []internal.FailedVersion{
+		{Version: "v1.2.0", Status: derrors.ToHTTPStatus(derrors.BadModule)},
+		{Version: "v1.1.0", Status: http.StatusNotFound},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(internal.FailedVersion{}, "Error", "FetchedAt")); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	for _, f := range got {
+		if f.Error == "" || f.FetchedAt.IsZero() {
+			t.Errorf("%s: got error %q at %v, want both set", f.Version, f.Error, f.FetchedAt)
+		}
+	}
+}
+
+func TestDataSource_TransientFailureNotCached(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	failingClient, teardownFailing := proxy.TestProxyServer(t, mux)
+	defer teardownFailing()
+
+	proxyClient := ds.proxyClient
+	ds.proxyClient = failingClient
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.2.0"); err == nil {
+		t.Fatal("GetModuleInfo with a failing proxy: got nil error")
+	}
+	failed, err := ds.GetFailedVersions(ctx, "foo.com/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("GetFailedVersions: got %v, want no cached failures", failed)
+	}
+
+	// Once the proxy recovers, the module version is fetched again.
+	ds.proxyClient = proxyClient
+	got, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantModuleInfo, got.ModuleInfo); diff != "" {
+		t.Errorf("GetModuleInfo mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetImportsGrouped(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	gotStd, gotExternal, err := ds.GetImportsGrouped(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"net/http"}, gotStd); diff != "" {
+		t.Errorf("std mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{}, gotExternal); diff != "" {
+		t.Errorf("external mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestDataSource_GetPackage_Latest(t *testing.T) {
 	ctx, ds, teardown := setup(t)
 	defer teardown()
@@ -132,6 +341,102 @@ func TestDataSource_GetModuleInfo_Latest(t *testing.T) {
 	}
 }
 
+func TestDataSource_GetLicenseFiles(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	got, err := ds.GetLicenseFiles(ctx, "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*licenses.License{{Metadata: wantLicenseMD, Contents: []byte(testhelper.MITLicense)}}
+	if diff := cmp.Diff(want, got, sample.LicenseCmpOpts...); diff != "" {
+		t.Errorf("GetLicenseFiles diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetUndetectedLicenseFiles(t *testing.T) {
+	const unrecognized = "You may do whatever you like with this code, on Tuesdays.\n"
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/recognized",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"r.go":    "package r\n",
+			},
+		},
+		{
+			ModulePath: "github.com/a/undetected",
+			Files: map[string]string{
+				"LICENSE":     testhelper.MITLicense,
+				"sub/COPYING": unrecognized,
+				"u.go":        "package u\n",
+				"sub/sub.go":  "package sub\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		modulePath string
+		want       []internal.LicenseFile
+	}{
+		{"github.com/a/recognized", nil},
+		{"github.com/a/undetected", []internal.LicenseFile{{FilePath: "sub/COPYING", Contents: []byte(unrecognized)}}},
+	} {
+		got, err := ds.GetUndetectedLicenseFiles(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetUndetectedLicenseFiles(%q) diff (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+}
+
+func TestDataSource_GetReadmeHash(t *testing.T) {
+	var testModules []*proxy.TestModule
+	for _, test := range []struct {
+		version, readme string
+	}{
+		{"v1.0.0", "# README\n"},
+		{"v1.1.0", "# README\n"},
+		{"v1.2.0", "# README\n\nNow with more words.\n"},
+		{"v1.3.0", ""},
+	} {
+		files := map[string]string{"m.go": "package m\n"}
+		if test.readme != "" {
+			files["README.md"] = test.readme
+		}
+		testModules = append(testModules, &proxy.TestModule{ModulePath: "github.com/a/m", Version: test.version, Files: files})
+	}
+	client, teardownProxy := proxy.SetupTestProxy(t, testModules)
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	hash := func(version string) string {
+		t.Helper()
+		h, err := ds.GetReadmeHash(ctx, "github.com/a/m", version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	if h1, h2 := hash("v1.0.0"), hash("v1.1.0"); h1 != h2 {
+		t.Errorf("identical READMEs: got different hashes %q and %q", h1, h2)
+	}
+	if h1, h3 := hash("v1.0.0"), hash("v1.2.0"); h1 == h3 {
+		t.Errorf("different READMEs: got the same hash %q", h1)
+	}
+	if _, err := ds.GetReadmeHash(ctx, "github.com/a/m", "v1.3.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("no README: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
 func TestDataSource_GetModuleLicenses(t *testing.T) {
 	ctx, ds, teardown := setup(t)
 	defer teardown()
@@ -145,6 +450,47 @@ func TestDataSource_GetModuleLicenses(t *testing.T) {
 	}
 }
 
+func TestDataSource_GetLicenseBadge(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/mit",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"mit.go":  "package mit\n",
+			},
+		},
+		{
+			ModulePath: "github.com/a/unknown",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE":    "All rights reserved.\n",
+				"unknown.go": "package unknown\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		modulePath string
+		want       *internal.Badge
+	}{
+		{"github.com/a/mit", &internal.Badge{License: "MIT", Color: internal.BadgeColorRedistributable, IsRedistributable: true}},
+		{"github.com/a/unknown", &internal.Badge{License: internal.UnknownLicense, Color: internal.BadgeColorUnknown}},
+	} {
+		got, err := ds.GetLicenseBadge(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+}
+
 func TestDataSource_GetPackage(t *testing.T) {
 	ctx, ds, teardown := setup(t)
 	defer teardown()
@@ -173,7 +519,7 @@ func TestDataSource_GetPackageLicenses(t *testing.T) {
 func TestDataSource_GetPackagesInVersion(t *testing.T) {
 	ctx, ds, teardown := setup(t)
 	defer teardown()
-	got, err := ds.GetPackagesInModule(ctx, "foo.com/bar", "v1.2.0")
+	got, err := ds.GetPackagesInModule(ctx, "foo.com/bar", "v1.2.0", internal.PathOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -183,6 +529,161 @@ func TestDataSource_GetPackagesInVersion(t *testing.T) {
 	}
 }
 
+func TestDataSource_GetImportPathMismatches(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "github.com/a/vanity",
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"LICENSE":      testhelper.MITLicense,
+			"vanity.go":    "package vanity // import \"github.com/a/vanity\"\n",
+			"old/old.go":   "package old // import \"vanity.example.com/old\"\n",
+			"none/none.go": "package none\n",
+		},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetImportPathMismatches(ctx, "github.com/a/vanity", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.ImportPathMismatch{{PackagePath: "github.com/a/vanity/old", ImportComment: "vanity.example.com/old"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetDirectoryNewSubdirectories(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "github.com/a/tree",
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"LICENSE":     testhelper.MITLicense,
+			"README.md":   "root readme",
+			"tree.go":     "package tree\n",
+			"a/README.md": "a readme",
+			"a/b/b.go":    "package b\n",
+			"a/b/README":  "b readme",
+			"c/c.go":      "package c\n",
+		},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		dirPath string
+		opts    internal.PathOptions
+		want    []*internal.Subdirectory
+	}{
+		{
+			dirPath: "github.com/a/tree",
+			opts:    internal.PathOptions{IncludeSubdirectories: true},
+			want: []*internal.Subdirectory{
+				{Path: "github.com/a/tree/a", HasReadme: true},
+				{Path: "github.com/a/tree/a/b", HasReadme: true},
+				{Path: "github.com/a/tree/c", HasReadme: false},
+			},
+		},
+		{
+			dirPath: "github.com/a/tree/a",
+			opts:    internal.PathOptions{IncludeSubdirectories: true},
+			want:    []*internal.Subdirectory{{Path: "github.com/a/tree/a/b", HasReadme: true}},
+		},
+		{
+			dirPath: "github.com/a/tree/c",
+			opts:    internal.PathOptions{IncludeSubdirectories: true},
+			want:    nil,
+		},
+		{
+			dirPath: "github.com/a/tree",
+			opts:    internal.PathOptions{},
+			want:    nil,
+		},
+	} {
+		got, err := ds.GetDirectoryNew(ctx, test.dirPath, "github.com/a/tree", "v1.0.0", test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got.Subdirectories); diff != "" {
+			t.Errorf("%s, %+v: mismatch (-want +got):\n%s", test.dirPath, test.opts, diff)
+		}
+	}
+}
+
+func TestDataSource_GetSiblingPackages(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "github.com/a/sib",
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"LICENSE":    testhelper.MITLicense,
+			"sib.go":     "package sib\n",
+			"a/a.go":     "package a\n",
+			"b/b.go":     "package b\n",
+			"c/d/d.go":   "package d\n",
+			"c/e/e.go":   "package e\n",
+			"c/e/f/f.go": "package f\n",
+		},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		pkgPath string
+		want    []string
+	}{
+		{"github.com/a/sib", nil},
+		{"github.com/a/sib/a", []string{"github.com/a/sib/b"}},
+		{"github.com/a/sib/c/d", []string{"github.com/a/sib/c/e"}},
+		{"github.com/a/sib/c/e/f", nil},
+	} {
+		pkgs, err := ds.GetSiblingPackages(ctx, test.pkgPath, "github.com/a/sib", "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range pkgs {
+			got = append(got, p.Path)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetSiblingPackages(%q) mismatch (-want +got):\n%s", test.pkgPath, diff)
+		}
+	}
+}
+
+func TestDataSource_GetSourceFile(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	got, err := ds.GetSourceFile(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0", "baz.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != bazGo {
+		t.Errorf("GetSourceFile = %q, want %q", got, bazGo)
+	}
+	if _, err := ds.GetSourceFile(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0", "missing.go"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetSourceFile of missing file: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestDataSource_GetLatestVersions(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	got, err := ds.GetLatestVersions(ctx, []string{"foo.com/bar", "foo.com/unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"foo.com/bar": "v1.2.0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetLatestVersions mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestDataSource_GetTaggedVersionsForModule(t *testing.T) {
 	ctx, ds, teardown := setup(t)
 	defer teardown()
@@ -226,6 +727,49 @@ func TestDataSource_GetTaggedVersionsForPackageSeries(t *testing.T) {
 	}
 }
 
+func TestDataSource_GetVersionsMatching(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	v110 := wantModuleInfo
+	v110.Version = "v1.1.0"
+	ignore := cmpopts.IgnoreFields(internal.LegacyModuleInfo{}, "CommitTime", "VersionType", "IsRedistributable", "HasGoMod")
+	for _, test := range []struct {
+		constraint string
+		want       []*internal.LegacyModuleInfo
+	}{
+		{">=1.0.0", []*internal.LegacyModuleInfo{{ModuleInfo: wantModuleInfo}, {ModuleInfo: v110}}},
+		{">=1.1.0 <1.2.0", []*internal.LegacyModuleInfo{{ModuleInfo: v110}}},
+		{">=2.0.0", nil},
+	} {
+		got, err := ds.GetVersionsMatching(ctx, "foo.com/bar", test.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got, ignore); diff != "" {
+			t.Errorf("%q: mismatch (-want +got):\n%s", test.constraint, diff)
+		}
+	}
+	if _, err := ds.GetVersionsMatching(ctx, "foo.com/bar", "~1.0"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestDataSource_ResolveBranch(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	// The test proxy resolves master to the highest version.
+	got, err := ds.ResolveBranch(ctx, "foo.com/bar", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v1.2.0"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := ds.ResolveBranch(ctx, "foo.com/bar", "v1.1.0"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
 func TestDataSource_GetModuleInfo(t *testing.T) {
 	ctx, ds, teardown := setup(t)
 	defer teardown()
@@ -292,3 +836,1142 @@ func TestDataSource_GetPathInfo(t *testing.T) {
 		}
 	}
 }
+
+func TestDataSource_GetSymbolCounts(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "foo.com/syms",
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"go.mod": "module foo.com/syms",
+			"syms.go": `package syms
+const A, B = 1, 2
+var V int
+func F() {}
+type T struct{}
+func NewT() *T { return nil }
+func (T) M() {}
+func (T) N() {}
+func (T) unexported() {}
+`,
+			"empty/empty.go": "package empty\nconst unexported = 1",
+		},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		pkgPath string
+		want    map[internal.SymbolKind]int
+	}{
+		{
+			pkgPath: "foo.com/syms",
+			want: map[internal.SymbolKind]int{
+				internal.SymbolKindConstant: 2,
+				internal.SymbolKindVariable: 1,
+				internal.SymbolKindFunction: 2,
+				internal.SymbolKindType:     1,
+				internal.SymbolKindMethod:   2,
+			},
+		},
+		{
+			pkgPath: "foo.com/syms/empty",
+			want:    map[internal.SymbolKind]int{},
+		},
+	} {
+		t.Run(test.pkgPath, func(t *testing.T) {
+			got, err := ds.GetSymbolCounts(ctx, test.pkgPath, "foo.com/syms", "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GetSymbolCounts diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDataSource_GetSymbols(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "foo.com/syms",
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"go.mod": "module foo.com/syms",
+			"syms.go": `package syms
+type T struct{}
+func (T) M() {}
+func NewT() *T { return nil }
+const A = 1
+`,
+		},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetSymbols(ctx, "foo.com/syms", "foo.com/syms", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.Symbol{
+		{Name: "A", Kind: internal.SymbolKindConstant, Synopsis: "const A"},
+		{Name: "NewT", Kind: internal.SymbolKindFunction, Synopsis: "func NewT() *T", ParentName: "T"},
+		{Name: "T", Kind: internal.SymbolKindType, Synopsis: "type T struct"},
+		{Name: "T.M", Kind: internal.SymbolKindMethod, Synopsis: "func (T) M()", ParentName: "T"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetSymbols diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetSymbolPresence(t *testing.T) {
+	mod := func(version, src string) *proxy.TestModule {
+		return &proxy.TestModule{
+			ModulePath: "foo.com/syms",
+			Version:    version,
+			Files: map[string]string{
+				"go.mod":  "module foo.com/syms",
+				"syms.go": "package syms\n" + src,
+			},
+		}
+	}
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		mod("v1.0.0", "func A() {}"),
+		mod("v1.1.0", "func A() {}\nfunc New() {}"),
+		mod("v1.2.0", "func A() {}\nfunc New() {}\nconst C = 1"),
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetSymbolPresence(ctx, "foo.com/syms", []string{"A", "New"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]bool{
+		"v1.0.0": {"A": true, "New": false},
+		"v1.1.0": {"A": true, "New": true},
+		"v1.2.0": {"A": true, "New": true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetSymbolPresence diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetRequirements(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: "foo.com/reqs",
+		Version:    "v1.0.0",
+		Files: map[string]string{
+			"go.mod": `module foo.com/reqs
+
+require (
+	golang.org/x/text v0.3.0 // indirect
+	example.com/dep v1.2.0
+)
+`,
+			"reqs.go": "package reqs",
+		},
+	}})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetRequirements(ctx, "foo.com/reqs", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.Requirement{
+		{ModulePath: "example.com/dep", Version: "v1.2.0"},
+		{ModulePath: "golang.org/x/text", Version: "v0.3.0", Indirect: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetRequirements diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetDependencyDepth(t *testing.T) {
+	module := func(path, requires string) *proxy.TestModule {
+		goMod := "module " + path + "\n"
+		if requires != "" {
+			goMod += "\nrequire " + requires + " v1.0.0\n"
+		}
+		return &proxy.TestModule{
+			ModulePath: path,
+			Version:    "v1.0.0",
+			Files:      map[string]string{"go.mod": goMod, "p.go": "package p"},
+		}
+	}
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		module("foo.com/a", "foo.com/b"),
+		module("foo.com/b", "foo.com/c"),
+		module("foo.com/c", "foo.com/d"),
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	// Only foo.com/a has been fetched, so its requirement is a leaf.
+	got, err := ds.GetDependencyDepth(ctx, "foo.com/a", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1 {
+		t.Errorf("GetDependencyDepth before fetching dependencies = %d, want 1", got)
+	}
+
+	// Once foo.com/b and foo.com/c have been fetched, foo.com/d is at depth 3.
+	for _, path := range []string{"foo.com/b", "foo.com/c"} {
+		if _, err := ds.GetModuleInfo(ctx, path, "v1.0.0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = ds.GetDependencyDepth(ctx, "foo.com/a", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Errorf("GetDependencyDepth = %d, want 3", got)
+	}
+}
+
+func TestDataSource_GetIndexStats(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+
+	got, err := ds.GetIndexStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&internal.IndexStats{}, got); diff != "" {
+		t.Errorf("GetIndexStats before fetching mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, v := range []string{"v1.1.0", "v1.2.0"} {
+		if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A failed fetch is not counted.
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.3.0"); err == nil {
+		t.Fatal("GetModuleInfo(v1.3.0): got nil error")
+	}
+	// Nor is the latest version counted again when it is requested as such.
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", internal.LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ds.GetIndexStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.IndexStats{TotalModules: 1, TotalModuleVersions: 2, TotalPackages: 1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetIndexStats mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetAllDocs(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+
+	got, err := ds.GetAllDocs(ctx, "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*internal.Documentation{
+		"foo.com/bar/baz": {
+			GOOS:     wantPackage.GOOS,
+			GOARCH:   wantPackage.GOARCH,
+			Synopsis: wantPackage.Synopsis,
+			Doc:      wantPackage.Doc,
+			Symbols:  wantPackage.Symbols,
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(internal.Documentation{}, "HTML")); diff != "" {
+		t.Errorf("GetAllDocs diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetInternalImports(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/bar",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"bar.go": `package bar
+
+import (
+	_ "fmt"
+	_ "foo.com/bar/baz"
+	_ "foo.com/barn"
+	_ "github.com/a/b"
+)
+`,
+				"baz/baz.go": "package baz\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetInternalImports(ctx, "foo.com/bar", "foo.com/bar", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"foo.com/bar/baz"}, got); diff != "" {
+		t.Errorf("GetInternalImports diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetModuleReadmeForPackage(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/withreadme",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"README.md":      "module readme",
+				"LICENSE":        testhelper.MITLicense,
+				"a.go":           "package withreadme\n",
+				"deep/er/er.go":  "package er\n",
+				"deep/README.md": "deep readme",
+			},
+		},
+		{
+			ModulePath: "github.com/a/noreadme",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"p/p.go":  "package p\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	want := &internal.Readme{Filepath: "README.md", Contents: "module readme"}
+	for _, modulePath := range []string{"github.com/a/withreadme", internal.UnknownModulePath} {
+		got, err := ds.GetModuleReadmeForPackage(ctx, "github.com/a/withreadme/deep/er", modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", modulePath, diff)
+		}
+	}
+
+	for _, test := range []struct {
+		name, pkgPath, modulePath string
+	}{
+		{"no README", "github.com/a/noreadme/p", "github.com/a/noreadme"},
+		{"not a package", "github.com/a/withreadme/deep", "github.com/a/withreadme"},
+		{"no such path", "github.com/a/withreadme/other", "github.com/a/withreadme"},
+	} {
+		_, err := ds.GetModuleReadmeForPackage(ctx, test.pkgPath, test.modulePath, "v1.0.0")
+		if !errors.Is(err, derrors.NotFound) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, derrors.NotFound)
+		}
+	}
+}
+
+func TestDataSource_GetParsedModFile(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/bar",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"go.mod": `module foo.com/bar
+
+require foo.com/dep v1.2.0
+
+exclude foo.com/dep v1.1.0
+
+replace foo.com/dep => ../dep
+`,
+				"LICENSE": testhelper.MITLicense,
+				"bar.go":  "package bar\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetParsedModFile(ctx, "foo.com/bar", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.ModFile{
+		ModulePath: "foo.com/bar",
+		Require:    []*internal.Requirement{{ModulePath: "foo.com/dep", Version: "v1.2.0"}},
+		Exclude:    []*internal.ModVersion{{Path: "foo.com/dep", Version: "v1.1.0"}},
+		Replace: []*internal.ModReplace{{
+			Old: internal.ModVersion{Path: "foo.com/dep"},
+			New: internal.ModVersion{Path: "../dep"},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetParsedModFile diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetModFileDiff(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/m",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"go.mod": "module github.com/a/m\n\nrequire github.com/a/dep v1.2.0\n",
+				"m.go":   "package m\n",
+			},
+		},
+		{
+			ModulePath: "github.com/a/m",
+			Version:    "v1.1.0",
+			Files: map[string]string{
+				"go.mod": "module github.com/a/m\n\nrequire (\n\tgithub.com/a/dep v1.3.0\n\tgithub.com/a/new v0.1.0\n)\n",
+				"m.go":   "package m\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetModFileDiff(ctx, "github.com/a/m", "v1.0.0", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.ModDiff{
+		Added: []*internal.Requirement{{ModulePath: "github.com/a/new", Version: "v0.1.0"}},
+		Changed: []*internal.RequirementChange{{
+			ModulePath: "github.com/a/dep",
+			From:       &internal.Requirement{ModulePath: "github.com/a/dep", Version: "v1.2.0"},
+			To:         &internal.Requirement{ModulePath: "github.com/a/dep", Version: "v1.3.0"},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetModFileDiff diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetReplaceDirectives(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/m",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"go.mod": "module github.com/a/m\n\nreplace (\n\tgithub.com/a/dep v1.2.0 => github.com/b/dep v1.2.1\n\tgithub.com/a/local => ./local\n)\n",
+				"m.go":   "package m\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetReplaceDirectives(ctx, "github.com/a/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []internal.Replace{
+		{Old: "github.com/a/dep", OldVersion: "v1.2.0", New: "github.com/b/dep", NewVersion: "v1.2.1"},
+		{Old: "github.com/a/local", New: "./local"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetReplaceDirectives diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetGoDirective(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/withgo",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"go.mod":  "module github.com/a/withgo\n\ngo 1.14\n",
+				"LICENSE": testhelper.MITLicense,
+				"a.go":    "package withgo\n",
+			},
+		},
+		{
+			ModulePath: "github.com/a/nogo",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"go.mod":  "module github.com/a/nogo\n",
+				"LICENSE": testhelper.MITLicense,
+				"a.go":    "package nogo\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		modulePath, want string
+	}{
+		{"github.com/a/withgo", "1.14"},
+		{"github.com/a/nogo", ""},
+	} {
+		got, err := ds.GetGoDirective(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetGoDirective(%q) = %q, want %q", test.modulePath, got, test.want)
+		}
+	}
+}
+
+func TestDataSource_GetDocBuildInfo(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	got, err := ds.GetDocBuildInfo(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.DocBuildInfo{GoVersion: runtime.Version(), RendererVersion: dochtml.RendererVersion}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetDocGenGoVersion(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	got, err := ds.GetDocGenGoVersion(ctx, "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := runtime.Version(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDataSource_GetDocSection(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	for _, test := range []struct {
+		section string
+		want    string // substring of the HTML, or empty for no HTML
+	}{
+		{"overview", "Package baz provides a helpful constant."},
+		{"constants", "OK"},
+		{"functions", ""},
+	} {
+		doc, err := ds.GetDocSection(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0", test.section)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.want == "" {
+			if doc.HTML != "" {
+				t.Errorf("GetDocSection(%q): got HTML %q, want empty", test.section, doc.HTML)
+			}
+			continue
+		}
+		if !strings.HasPrefix(doc.HTML, `<section class="Documentation-`+test.section+`">`) || !strings.Contains(doc.HTML, test.want) {
+			t.Errorf("GetDocSection(%q): got HTML %q, want the section containing %q", test.section, doc.HTML, test.want)
+		}
+		if strings.Count(doc.HTML, "<section") != 1 {
+			t.Errorf("GetDocSection(%q): got more than one section in %q", test.section, doc.HTML)
+		}
+	}
+	if _, err := ds.GetDocSection(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0", "index"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("unknown section: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestDataSource_GetPlaygroundExamples(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/bar",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"bar.go":  "package bar\n\nfunc F() string { return \"f\" }\n",
+				"example_test.go": `package bar_test
+
+import (
+	"fmt"
+
+	"foo.com/bar"
+)
+
+func ExampleF() {
+	fmt.Println(bar.F())
+	// Output: f
+}
+`,
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetPlaygroundExamples(ctx, "foo.com/bar", "foo.com/bar", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "F" {
+		t.Fatalf("got %v, want one example named F", got)
+	}
+	for _, want := range []string{"package main\n", `"foo.com/bar"`, "func main() {\n\tfmt.Println(bar.F())\n}"} {
+		if !strings.Contains(got[0].Code, want) {
+			t.Errorf("got code\n%s\nwant it to contain %q", got[0].Code, want)
+		}
+	}
+}
+
+func TestDataSource_GetModuleDocCoverage(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/bar",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE":        testhelper.MITLicense,
+				"bar.go":         "// Package bar is documented.\npackage bar\n",
+				"baz/baz.go":     "// Package baz is documented.\npackage baz\n",
+				"qux/qux.go":     "package qux\n\n// F is documented, but not the package.\nfunc F() {}\n",
+				"cmd/tq/main.go": "package main\n\nfunc main() {}\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetModuleDocCoverage(ctx, "foo.com/bar", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.ModuleDocCoverage{Documented: 2, Undocumented: 2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetModuleKeywords(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/bar",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE":        testhelper.MITLicense,
+				"bar.go":         "// Package bar parses TOML files.\npackage bar\n",
+				"toml/toml.go":   "// Package toml encodes TOML files.\npackage toml\n",
+				"cmd/tq/main.go": "// Command tq queries TOML files.\npackage main\n\nfunc main() {}\n",
+			},
+		},
+		{
+			// A command without a doc comment has no keywords.
+			ModulePath: "foo.com/cmd",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"main.go": "package main\n\nfunc main() {}\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		modulePath string
+		want       []string
+	}{
+		{"foo.com/bar", []string{"files", "toml", "bar", "encodes", "parses", "queries"}},
+		{"foo.com/cmd", []string{}},
+	} {
+		got, err := ds.GetModuleKeywords(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.modulePath, diff)
+		}
+	}
+}
+
+func TestDataSource_GetCommitSHA(t *testing.T) {
+	const (
+		pseudo = "v0.0.0-20200101000000-abcdef123456"
+		hash   = "abcdef1234567890abcdef1234567890abcdef12"
+	)
+	files := map[string]string{
+		"LICENSE": testhelper.MITLicense,
+		"bar.go":  "package bar\n",
+	}
+	// The modules are on github.com so that their source info is found
+	// without a network request.
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{ModulePath: "github.com/my/bar", Version: pseudo, Files: files, CommitHash: hash},
+		// The proxy does not report the commit of this version.
+		{ModulePath: "github.com/my/bar", Version: "v1.0.0", Files: files},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	got, err := ds.GetCommitSHA(ctx, "github.com/my/bar", pseudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != hash {
+		t.Errorf("got %q, want %q", got, hash)
+	}
+	if _, err := ds.GetCommitSHA(ctx, "github.com/my/bar", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("no hash: got error %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestDataSource_GetDocDiffAcrossPlatforms(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/bar",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"bar.go":  "package bar\n\nfunc F() {}\n",
+				// The windows-only function is not documented, since the
+				// package is documented for linux/amd64.
+				"bar_windows.go": "package bar\n\nfunc LoadDLL() {}\n",
+				// A package with only windows files is documented for
+				// windows/amd64.
+				"win/win.go": "// +build windows\n\npackage win\n\nfunc LoadDLL() {}\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, test := range []struct {
+		pkgPath string
+		want    map[string][]string
+	}{
+		{"foo.com/bar", map[string][]string{"linux/amd64": {}}},
+		{"foo.com/bar/win", map[string][]string{"windows/amd64": {}}},
+	} {
+		got, err := ds.GetDocDiffAcrossPlatforms(ctx, test.pkgPath, "foo.com/bar", "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.pkgPath, diff)
+		}
+	}
+}
+
+func TestDataSource_GetDocumentationWithLinks(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "foo.com/dep",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"dep.go":  "package dep\n\n// Exported is exported.\nfunc Exported() {}\n",
+			},
+		},
+		{
+			ModulePath: "foo.com/user",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"user.go": `// Package user wraps dep.Exported, but not dep.Missing.
+package user
+
+import "foo.com/dep"
+
+// F calls dep.Exported.
+func F() { dep.Exported() }
+`,
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/dep", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ds.GetDocumentationWithLinks(ctx, "foo.com/user", "foo.com/user", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const link = `<a href="/pkg/foo.com/dep#Exported">dep.Exported</a>`
+	if n := strings.Count(string(got), link); n != 2 {
+		t.Errorf("got %d links to dep.Exported, want 2:\n%s", n, got)
+	}
+	if strings.Contains(string(got), "#Missing") {
+		t.Errorf("got a link to dep.Missing, which does not exist:\n%s", got)
+	}
+
+	_, err = ds.GetDocumentationWithLinks(ctx, "foo.com/user/missing", "foo.com/user", "v1.0.0")
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v for a missing package, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestDataSource_GetModuleByCommit(t *testing.T) {
+	const pseudo = "v0.0.0-20200601000000-0123456789ab"
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{ModulePath: "foo.com/bar", Version: pseudo, Files: map[string]string{"bar.go": "package bar"}},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	// Versions that have not been fetched are not found.
+	if _, err := ds.GetModuleByCommit(ctx, "foo.com/bar", "0123456"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("before fetching: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", pseudo); err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{"0123456", "0123456789AB", "0123456789abcdef0123456789abcdef01234567"} {
+		mi, err := ds.GetModuleByCommit(ctx, "foo.com/bar", hash)
+		if err != nil {
+			t.Fatalf("GetModuleByCommit(%q): %v", hash, err)
+		}
+		if mi.Version != pseudo {
+			t.Errorf("GetModuleByCommit(%q) = %s, want %s", hash, mi.Version, pseudo)
+		}
+	}
+	if _, err := ds.GetModuleByCommit(ctx, "foo.com/bar", "abcdef0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("other commit: got error %v, want %v", err, derrors.NotFound)
+	}
+	if _, err := ds.GetModuleByCommit(ctx, "foo.com/bar", "xyz"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("invalid hash: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestDataSource_GetSitemapEntries(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+
+	// v1.1.0 is fetched last, but v1.2.0 is the latest version.
+	for _, v := range []string{"v1.2.0", "v1.1.0"} {
+		if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetched, err := ds.GetFetchTime(ctx, "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []internal.SitemapEntry{
+		{Path: "foo.com/bar", LastModified: fetched},
+		{Path: "foo.com/bar/baz", LastModified: fetched},
+	}
+	got, err := ds.GetSitemapEntries(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetSitemapEntries diff (-want +got):\n%s", diff)
+	}
+	var paged []internal.SitemapEntry
+	for offset := 0; offset < 3; offset++ {
+		page, err := ds.GetSitemapEntries(ctx, 1, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, page...)
+	}
+	if diff := cmp.Diff(want, paged); diff != "" {
+		t.Errorf("paged GetSitemapEntries diff (-want +got):\n%s", diff)
+	}
+	if _, err := ds.GetSitemapEntries(ctx, 0, 0); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("GetSitemapEntries(ctx, 0, 0): got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestDataSource_SuggestSimilarPaths(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+
+	// Nothing has been fetched, so there is nothing to suggest.
+	got, err := ds.SuggestSimilarPaths(ctx, "fooo.com/bar/baz", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("before fetching: got %v, want no suggestions", got)
+	}
+
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ds.SuggestSimilarPaths(ctx, "fooo.com/bar/baz", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"foo.com/bar/baz"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SuggestSimilarPaths diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_GetRecentlyUpdatedPackages(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+
+	start := time.Now()
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.GetModuleInfo(ctx, "foo.com/bar", "v1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ds.GetRecentlyUpdatedPackages(ctx, start.Add(-time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.SearchResult{{
+		Name:        "baz",
+		PackagePath: "foo.com/bar/baz",
+		ModulePath:  "foo.com/bar",
+		Version:     "v1.2.0",
+		Synopsis:    "Package baz provides a helpful constant.",
+		Licenses:    []string{"MIT"},
+		CommitTime:  wantModuleInfo.CommitTime,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetRecentlyUpdatedPackages diff (-want +got):\n%s", diff)
+	}
+
+	got, err = ds.GetRecentlyUpdatedPackages(ctx, time.Now().Add(time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetRecentlyUpdatedPackages(future): got %d results, want none", len(got))
+	}
+	if _, err := ds.GetRecentlyUpdatedPackages(ctx, time.Time{}, -1); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("negative limit: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
+
+func TestDataSource_GetPackagesByPrefix(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{ModulePath: "github.com/a/x", Version: "v1.0.0", Files: map[string]string{"p/p.go": "package p\n"}},
+		{ModulePath: "github.com/a/x", Version: "v1.1.0", Files: map[string]string{"p/p.go": "package p\n", "q/q.go": "package q\n"}},
+		{ModulePath: "github.com/a/x/sub", Version: "v1.0.0", Files: map[string]string{"r/r.go": "package r\n"}},
+		{ModulePath: "github.com/a/xy", Version: "v1.0.0", Files: map[string]string{"p/p.go": "package p\n"}},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, mv := range [][2]string{
+		{"github.com/a/x", "v1.0.0"},
+		{"github.com/a/x", "v1.1.0"},
+		{"github.com/a/x/sub", "v1.0.0"},
+		{"github.com/a/xy", "v1.0.0"},
+	} {
+		if _, err := ds.GetModuleInfo(ctx, mv[0], mv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		prefix        string
+		limit, offset int
+		want          []string
+	}{
+		{"github.com/a/x", 10, 0, []string{"github.com/a/x/p@v1.1.0", "github.com/a/x/q@v1.1.0", "github.com/a/x/sub/r@v1.0.0"}},
+		{"github.com/a/x", 1, 1, []string{"github.com/a/x/q@v1.1.0"}},
+		{"github.com/a/x/p", 10, 0, []string{"github.com/a/x/p@v1.1.0"}},
+		{"github.com/a", 2, 3, []string{"github.com/a/xy/p@v1.0.0"}},
+		{"github.com/a", 10, 4, nil},
+	} {
+		results, err := ds.GetPackagesByPrefix(ctx, test.prefix, test.limit, test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.PackagePath+"@"+r.Version)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetPackagesByPrefix(%q, %d, %d) mismatch (-want +got):\n%s", test.prefix, test.limit, test.offset, diff)
+		}
+	}
+	for _, lo := range [][2]int{{-1, 0}, {1, -1}} {
+		if _, err := ds.GetPackagesByPrefix(ctx, "github.com/a", lo[0], lo[1]); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("GetPackagesByPrefix(%q, %d, %d): got error %v, want InvalidArgument", "github.com/a", lo[0], lo[1], err)
+		}
+	}
+}
+
+func TestDataSource_GetModulesByOwner(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{ModulePath: "github.com/a/x", Version: "v1.0.0", Files: map[string]string{"p/p.go": "package p\n"}},
+		{ModulePath: "github.com/a/x", Version: "v1.1.0", Files: map[string]string{"p/p.go": "package p\n"}},
+		{ModulePath: "github.com/a/y", Version: "v1.0.0", Files: map[string]string{"p/p.go": "package p\n"}},
+		{ModulePath: "github.com/b/x", Version: "v1.0.0", Files: map[string]string{"p/p.go": "package p\n"}},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, mv := range [][2]string{
+		{"github.com/a/x", "v1.0.0"},
+		{"github.com/a/x", "v1.1.0"},
+		{"github.com/a/y", "v1.0.0"},
+		{"github.com/b/x", "v1.0.0"},
+	} {
+		if _, err := ds.GetModuleInfo(ctx, mv[0], mv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		host, owner   string
+		limit, offset int
+		want          []string
+	}{
+		{"github.com", "a", 10, 0, []string{"github.com/a/x@v1.1.0", "github.com/a/y@v1.0.0"}},
+		{"github.com", "a", 1, 1, []string{"github.com/a/y@v1.0.0"}},
+		{"GitHub.com", "B", 10, 0, []string{"github.com/b/x@v1.0.0"}},
+		{"github.com", "c", 10, 0, nil},
+		{"gitlab.com", "a", 10, 0, nil},
+	} {
+		mods, err := ds.GetModulesByOwner(ctx, test.host, test.owner, test.limit, test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range mods {
+			got = append(got, m.ModulePath+"@"+m.Version)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("GetModulesByOwner(%q, %q, %d, %d) mismatch (-want +got):\n%s", test.host, test.owner, test.limit, test.offset, diff)
+		}
+	}
+	for _, lo := range [][2]int{{-1, 0}, {1, -1}} {
+		if _, err := ds.GetModulesByOwner(ctx, "github.com", "a", lo[0], lo[1]); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("GetModulesByOwner(%q, %q, %d, %d): got error %v, want InvalidArgument", "github.com", "a", lo[0], lo[1], err)
+		}
+	}
+}
+
+func TestDataSource_SupportedBuildContexts(t *testing.T) {
+	ctx := context.Background()
+	ds := New(nil)
+	want := []internal.BuildContext{
+		{GOOS: "linux", GOARCH: "amd64"},
+		{GOOS: "windows", GOARCH: "amd64"},
+		{GOOS: "darwin", GOARCH: "amd64"},
+		{GOOS: "js", GOARCH: "wasm"},
+		{GOOS: "linux", GOARCH: "js"},
+	}
+	got, err := ds.SupportedBuildContexts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SupportedBuildContexts diff (-want +got):\n%s", diff)
+	}
+
+	// Modifying the result must not affect later calls.
+	got[0] = internal.BuildContext{GOOS: "plan9", GOARCH: "386"}
+	got, err = ds.SupportedBuildContexts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("second call: SupportedBuildContexts diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDataSource_Conformance(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, datasourcetest.Modules())
+	defer teardownProxy()
+	datasourcetest.RunConformanceTests(t,
+		func() internal.DataSource { return NewForTesting(client) },
+		func(internal.DataSource) {})
+}
+
+func TestDataSource_GetSymbolUsages(t *testing.T) {
+	client, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: "github.com/a/one",
+			Version:    "v1.0.0",
+			Files:      map[string]string{"one.go": "package one\n\nimport \"net/http\"\n\nvar A = http.StatusOK\n"},
+		},
+		{
+			ModulePath: "github.com/a/one",
+			Version:    "v1.1.0",
+			Files:      map[string]string{"one.go": "package one\n\nimport \"net/http\"\n\nvar A, B = http.StatusOK, http.StatusOK\n"},
+		},
+		{
+			ModulePath: "github.com/a/two",
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"two.go":     "package two\n\nimport h \"net/http\"\n\nvar A = h.StatusOK\n",
+				"sub/sub.go": "package sub\n\nimport \"net/http\"\n\nvar A = http.StatusNotFound\n",
+			},
+		},
+	})
+	defer teardownProxy()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ds := NewForTesting(client)
+
+	for _, mv := range [][2]string{
+		{"github.com/a/one", "v1.0.0"},
+		{"github.com/a/one", "v1.1.0"},
+		{"github.com/a/two", "v1.0.0"},
+	} {
+		if _, err := ds.GetModuleInfo(ctx, mv[0], mv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := ds.GetSymbolUsages(ctx, "net/http", "StatusOK", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the highest version of github.com/a/one is considered.
+	want := []*internal.SymbolUsage{
+		{PackagePath: "github.com/a/one", ModulePath: "github.com/a/one", Count: 2},
+		{PackagePath: "github.com/a/two", ModulePath: "github.com/a/two", Count: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	got, err = ds.GetSymbolUsages(ctx, "net/http", "Get", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v for a symbol with no references, want none", got)
+	}
+	if _, err := ds.GetSymbolUsages(ctx, "net/http", "StatusOK", -1); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("negative limit: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+}
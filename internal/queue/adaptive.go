@@ -0,0 +1,134 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// AdaptiveOptions configures an InMemory queue to adjust the number of
+// concurrent fetches based on their results.
+//
+// Whenever a fetch fails with status 429 (Too Many Requests) or a 5xx status,
+// the concurrency limit is halved, but never goes below MinWorkers. After
+// SuccessStreak consecutive fetches without such a failure, the limit is
+// increased by one, up to MaxWorkers.
+type AdaptiveOptions struct {
+	// MinWorkers is the lowest concurrency limit. Values less than 1 are
+	// treated as 1.
+	MinWorkers int
+	// MaxWorkers is the highest concurrency limit. Values less than
+	// MinWorkers are treated as MinWorkers.
+	MaxWorkers int
+	// SuccessStreak is the number of consecutive fetches without a 429 or
+	// 5xx status needed to increase the limit. If zero, 10 is used.
+	SuccessStreak int
+}
+
+// adaptiveLimiter limits the number of fetches that can run at once, and
+// adjusts that limit based on the status of each completed fetch.
+type adaptiveLimiter struct {
+	min, max, successStreak int
+
+	mu      sync.Mutex
+	limit   int
+	active  int
+	streak  int
+	changed chan struct{} // closed when active or limit changes
+}
+
+func newAdaptiveLimiter(opts *AdaptiveOptions, initial int) *adaptiveLimiter {
+	l := &adaptiveLimiter{
+		min:           opts.MinWorkers,
+		max:           opts.MaxWorkers,
+		successStreak: opts.SuccessStreak,
+		changed:       make(chan struct{}),
+	}
+	if l.min < 1 {
+		l.min = 1
+	}
+	if l.max < l.min {
+		l.max = l.min
+	}
+	if l.successStreak <= 0 {
+		l.successStreak = 10
+	}
+	l.limit = initial
+	if l.limit < l.min {
+		l.limit = l.min
+	}
+	if l.limit > l.max {
+		l.limit = l.max
+	}
+	return l
+}
+
+// acquire blocks until fewer than limit fetches are active, then marks one
+// more as active. It returns false without doing so if ctx is done first.
+func (l *adaptiveLimiter) acquire(ctx context.Context) bool {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// release marks a fetch that completed with the given status code as no
+// longer active, and adjusts the limit.
+func (l *adaptiveLimiter) release(ctx context.Context, code int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	old := l.limit
+	if code == http.StatusTooManyRequests || code >= 500 {
+		l.streak = 0
+		l.limit /= 2
+		if l.limit < l.min {
+			l.limit = l.min
+		}
+	} else {
+		l.streak++
+		if l.streak >= l.successStreak && l.limit < l.max {
+			l.streak = 0
+			l.limit++
+		}
+	}
+	if l.limit != old {
+		log.Infof(ctx, "fetch concurrency limit changed from %d to %d (status %d)", old, l.limit, code)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// abandon undoes a successful call to acquire for a fetch that was never
+// started. Unlike release, it does not adjust the limit.
+func (l *adaptiveLimiter) abandon() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// currentLimit returns the current concurrency limit.
+func (l *adaptiveLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newAdaptiveLimiter(&AdaptiveOptions{MinWorkers: 2, MaxWorkers: 6, SuccessStreak: 3}, 4)
+	for _, step := range []struct {
+		code      int
+		wantLimit int
+	}{
+		{http.StatusTooManyRequests, 2}, // halved
+		{http.StatusBadGateway, 2},      // not below MinWorkers
+		{http.StatusOK, 2},
+		{http.StatusNotFound, 2},
+		{http.StatusOK, 3}, // three successes in a row
+		{http.StatusOK, 3},
+		{http.StatusOK, 3},
+		{http.StatusOK, 4},
+		{http.StatusInternalServerError, 2},
+	} {
+		if !l.acquire(ctx) {
+			t.Fatal("acquire failed")
+		}
+		l.release(ctx, step.code)
+		if got := l.currentLimit(); got != step.wantLimit {
+			t.Fatalf("after status %d: got limit %d, want %d", step.code, got, step.wantLimit)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		l.acquire(ctx)
+		l.release(ctx, http.StatusOK)
+	}
+	if got, want := l.currentLimit(), 6; got != want {
+		t.Errorf("got limit %d, want it capped at %d", got, want)
+	}
+}
+
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	l := newAdaptiveLimiter(&AdaptiveOptions{MinWorkers: 1, MaxWorkers: 1}, 1)
+	ctx := context.Background()
+	if !l.acquire(ctx) {
+		t.Fatal("first acquire failed")
+	}
+
+	// The second acquire blocks until the first fetch is released.
+	done := make(chan bool)
+	go func() { done <- l.acquire(ctx) }()
+	select {
+	case <-done:
+		t.Fatal("second acquire succeeded while limit was reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.release(ctx, http.StatusOK)
+	if !<-done {
+		t.Fatal("second acquire failed")
+	}
+
+	// An acquire that cannot proceed returns false when ctx is done.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if l.acquire(cctx) {
+		t.Error("acquire succeeded with a canceled context")
+	}
+}
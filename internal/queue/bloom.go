@@ -0,0 +1,162 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A BloomDedupQueue is a Queue that skips fetches it has probably already
+// scheduled, and schedules the rest on another Queue. It remembers the
+// fetches in a bloom filter, whose size is fixed when it is created, so it
+// can deduplicate a very large number of fetches in little memory, as when a
+// single process enqueues every version of every module in a backfill.
+//
+// The price of the small size is that the dedup is inexact: with a
+// probability of about the false-positive rate given to NewBloomDedupQueue, a
+// fetch that was never scheduled is taken for one that was, and is skipped.
+// It should therefore only be used where a rare missed fetch is acceptable,
+// such as when a later backfill or the index poller will schedule it again.
+// A fetch that was scheduled is never scheduled again, even by concurrent
+// calls to ScheduleFetch.
+type BloomDedupQueue struct {
+	q Queue
+
+	mu      sync.Mutex
+	bits    []uint64
+	m       uint64 // number of bits
+	k       int    // number of hash functions
+	skipped int64
+	// inflight holds a channel for each fetch being scheduled on q, which
+	// is closed when the underlying ScheduleFetch returns.
+	inflight map[string]chan struct{}
+}
+
+// NewBloomDedupQueue returns a BloomDedupQueue that schedules fetches on q.
+// Its bloom filter is sized so that after n distinct fetches are scheduled,
+// the probability of skipping a new fetch is at most falsePositiveRate, which
+// must be between 0 and 1. The filter uses about -1.44·n·log₂(falsePositiveRate)
+// bits: for ten million fetches and a rate of 0.001, about 17MiB. Scheduling
+// more than n fetches raises the rate.
+func NewBloomDedupQueue(q Queue, n int, falsePositiveRate float64) (_ *BloomDedupQueue, err error) {
+	defer derrors.Wrap(&err, "queue.NewBloomDedupQueue(%d, %g)", n, falsePositiveRate)
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive: %w", derrors.InvalidArgument)
+	}
+	if !(falsePositiveRate > 0 && falsePositiveRate < 1) {
+		return nil, fmt.Errorf("false-positive rate must be between 0 and 1: %w", derrors.InvalidArgument)
+	}
+	m, k := bloomParams(n, falsePositiveRate)
+	return &BloomDedupQueue{
+		q:        q,
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		inflight: map[string]chan struct{}{},
+	}, nil
+}
+
+// bloomParams returns the number of bits m and hash functions k of a bloom
+// filter that holds n items with the given false-positive rate.
+func bloomParams(n int, falsePositiveRate float64) (m uint64, k int) {
+	m = uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k = int(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return m, k
+}
+
+// ScheduleFetch schedules a fetch of modulePath at version with the given
+// suffix on the underlying queue, unless a fetch with the same module path,
+// version and suffix has probably been scheduled already, in which case it
+// does nothing and returns nil. A fetch is only remembered once the
+// underlying queue schedules it successfully, so a failed fetch can be
+// retried.
+//
+// If another call is scheduling the same fetch, ScheduleFetch waits for it to
+// finish, and then skips the fetch if it was scheduled, or tries to schedule
+// it again if it failed.
+func (q *BloomDedupQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	idxs := q.indexes(modulePath, version, suffix)
+	key := modulePath + "\x00" + version + "\x00" + suffix
+	for {
+		q.mu.Lock()
+		if q.containsLocked(idxs) {
+			q.skipped++
+			q.mu.Unlock()
+			return nil
+		}
+		wait, ok := q.inflight[key]
+		if !ok {
+			q.inflight[key] = make(chan struct{})
+			q.mu.Unlock()
+			break
+		}
+		q.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err := q.q.ScheduleFetch(ctx, modulePath, version, suffix, taskIDChangeInterval)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err == nil {
+		for _, i := range idxs {
+			q.bits[i/64] |= 1 << (i % 64)
+		}
+	}
+	close(q.inflight[key])
+	delete(q.inflight, key)
+	return err
+}
+
+// Skipped returns the number of fetches that q has skipped as probable
+// duplicates. It includes the fetches skipped because of false positives,
+// which cannot be told apart from true duplicates.
+func (q *BloomDedupQueue) Skipped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.skipped
+}
+
+func (q *BloomDedupQueue) containsLocked(idxs []uint64) bool {
+	for _, i := range idxs {
+		if q.bits[i/64]&(1<<(i%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// indexes returns the positions of the k bits of the filter for a fetch,
+// derived from two halves of a 128-bit FNV hash by double hashing.
+func (q *BloomDedupQueue) indexes(modulePath, version, suffix string) []uint64 {
+	h := fnv.New128a()
+	// NUL cannot appear in module paths, versions or suffixes.
+	fmt.Fprintf(h, "%s\x00%s\x00%s", modulePath, version, suffix)
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1
+	idxs := make([]uint64, q.k)
+	for i := range idxs {
+		idxs[i] = (h1 + uint64(i)*h2) % q.m
+	}
+	return idxs
+}
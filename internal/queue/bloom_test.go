@@ -0,0 +1,167 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestBloomDedupQueue(t *testing.T) {
+	ctx := context.Background()
+	fq := &fakeQueue{}
+	q, err := NewBloomDedupQueue(fq, 1000, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mv := range [][2]string{
+		{"a.com", "v1.0.0"},
+		{"a.com", "v1.0.0"},
+		{"a.com", "v1.1.0"},
+		{"b.com", "v1.0.0"},
+		{"a.com", "v1.1.0"},
+	} {
+		if err := q.ScheduleFetch(ctx, mv[0], mv[1], "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"a.com@v1.0.0", "a.com@v1.1.0", "b.com@v1.0.0"}
+	if diff := cmp.Diff(want, fq.scheduled); diff != "" {
+		t.Errorf("scheduled mismatch (-want +got):\n%s", diff)
+	}
+	if got := q.Skipped(); got != 2 {
+		t.Errorf("Skipped() = %d, want 2", got)
+	}
+
+	// A fetch with a different suffix is not a duplicate.
+	if err := q.ScheduleFetch(ctx, "a.com", "v1.0.0", "reprocess", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(fq.scheduled), 4; got != want {
+		t.Errorf("got %d scheduled fetches after a new suffix, want %d", got, want)
+	}
+
+	// A fetch that fails is not remembered.
+	fq.err = errors.New("bad")
+	if err := q.ScheduleFetch(ctx, "c.com", "v1.0.0", "", time.Hour); err != fq.err {
+		t.Fatalf("got error %v, want %v", err, fq.err)
+	}
+	fq.err = nil
+	if err := q.ScheduleFetch(ctx, "c.com", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fq.scheduled[len(fq.scheduled)-1], "c.com@v1.0.0"; got != want {
+		t.Errorf("retry of failed fetch: last scheduled %q, want %q", got, want)
+	}
+}
+
+// blockingQueue is a Queue whose ScheduleFetch blocks until release is
+// closed. It is safe for concurrent use.
+type blockingQueue struct {
+	release chan struct{}
+
+	mu        sync.Mutex
+	scheduled int
+	err       error // if non-nil, returned by ScheduleFetch instead of scheduling
+}
+
+func (q *blockingQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	<-q.release
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return q.err
+	}
+	q.scheduled++
+	return nil
+}
+
+func TestBloomDedupQueueConcurrent(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name string
+		err  error
+		// The number of fetches scheduled by the concurrent calls.
+		want int
+	}{
+		{"success", nil, 1},
+		// After the first call fails, each waiting call tries again, and
+		// fails in turn.
+		{"failure", errors.New("bad"), 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			bq := &blockingQueue{release: make(chan struct{}), err: test.err}
+			q, err := NewBloomDedupQueue(bq, 1000, 0.001)
+			if err != nil {
+				t.Fatal(err)
+			}
+			const n = 10
+			errc := make(chan error, n)
+			for i := 0; i < n; i++ {
+				go func() { errc <- q.ScheduleFetch(ctx, "a.com", "v1.0.0", "", time.Hour) }()
+			}
+			close(bq.release)
+			var numErrs int
+			for i := 0; i < n; i++ {
+				if err := <-errc; err != nil {
+					numErrs++
+				}
+			}
+			if bq.scheduled != test.want {
+				t.Errorf("scheduled %d fetches, want %d", bq.scheduled, test.want)
+			}
+			if test.err == nil && (numErrs != 0 || q.Skipped() != n-1) {
+				t.Errorf("got %d errors and %d skipped, want 0 and %d", numErrs, q.Skipped(), n-1)
+			}
+			if test.err != nil && numErrs != n {
+				t.Errorf("got %d errors, want %d", numErrs, n)
+			}
+		})
+	}
+}
+
+func TestBloomDedupQueueFalsePositiveRate(t *testing.T) {
+	const (
+		n    = 10000
+		rate = 0.01
+	)
+	ctx := context.Background()
+	q, err := NewBloomDedupQueue(&fakeQueue{}, n, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := q.ScheduleFetch(ctx, fmt.Sprintf("m%d.com", i), "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Fetches of distinct module versions that are skipped are all false
+	// positives. Allow for some variation around the expected rate.
+	if got, max := float64(q.Skipped())/n, 2*rate; got > max {
+		t.Errorf("false-positive rate %g, want at most %g", got, max)
+	}
+}
+
+func TestNewBloomDedupQueueInvalid(t *testing.T) {
+	for _, test := range []struct {
+		n    int
+		rate float64
+	}{
+		{0, 0.01},
+		{100, 0},
+		{100, 1},
+	} {
+		if _, err := NewBloomDedupQueue(&fakeQueue{}, test.n, test.rate); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("NewBloomDedupQueue(%d, %g): got error %v, want %v", test.n, test.rate, err, derrors.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// CampaignDB is the database used by a Campaign to find stale module versions
+// and to record its progress. It is implemented by *postgres.DB.
+type CampaignDB interface {
+	GetStaleVersions(ctx context.Context, campaign, appVersion string, after *postgres.StaleVersion, limit int) ([]*postgres.StaleVersion, error)
+	GetCampaignState(ctx context.Context, name string) (*postgres.CampaignState, error)
+	StartCampaign(ctx context.Context, s *postgres.CampaignState) error
+	SaveCampaignState(ctx context.Context, s *postgres.CampaignState) error
+}
+
+// CampaignOptions configures a Campaign.
+type CampaignOptions struct {
+	// AppVersion is the app version that the campaign reprocesses for: every
+	// module version last processed by an earlier app version is enqueued.
+	AppVersion string
+	// BatchSize is the number of module versions enqueued every Interval.
+	// If zero, 100 is used.
+	BatchSize int
+	// Interval is the time between the starts of two batches. If zero, one
+	// minute is used.
+	Interval time.Duration
+	// MaxAttempts is the number of batches in which scheduling a module
+	// version may fail before the campaign skips it. If zero, 3 is used.
+	MaxAttempts int
+	// Suffix and TaskIDChangeInterval are passed to Queue.ScheduleFetch.
+	Suffix               string
+	TaskIDChangeInterval time.Duration
+}
+
+// CampaignProgress describes how far a Campaign has got.
+type CampaignProgress struct {
+	Name       string
+	AppVersion string
+	// Enqueued is the number of module versions scheduled on the queue.
+	Enqueued int
+	// Skipped is the number of module versions that could not be
+	// scheduled in MaxAttempts batches.
+	Skipped int
+	// Last is the last module version enqueued or skipped, in the form
+	// path@version, or empty if there is none yet.
+	Last string
+	// Done reports whether every stale module version has been enqueued or
+	// skipped.
+	Done      bool
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// campaignSaveTimeout bounds the time spent saving the state of a Campaign
+// after its context is done.
+const campaignSaveTimeout = 10 * time.Second
+
+// A Campaign reprocesses the module versions that were last processed by an
+// app version before CampaignOptions.AppVersion, by scheduling fetches of them
+// on a Queue in batches, most popular module first, at the rate set by the
+// options.
+//
+// A Campaign records its progress in the database under its name after every
+// batch, so a Campaign with the same name and app version resumes where an
+// earlier one stopped, for example after the process crashed. A Campaign
+// with the same name and a different app version starts over. Module versions
+// enqueued in a batch whose progress was not recorded are enqueued again,
+// which is harmless for a queue that deduplicates tasks. Module versions that
+// have been reprocessed are no longer stale, so they are never enqueued again.
+type Campaign struct {
+	name string
+	db   CampaignDB
+	q    Queue
+	opts CampaignOptions
+
+	mu       sync.Mutex
+	state    postgres.CampaignState
+	attempts int // failed attempts to schedule the module version after state.Cursor
+}
+
+// NewCampaign returns a Campaign with the given name that finds stale module
+// versions in db and schedules fetches of them on q. The Campaign does nothing
+// until Run is called.
+func NewCampaign(name string, db CampaignDB, q Queue, opts CampaignOptions) *Campaign {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	return &Campaign{
+		name:  name,
+		db:    db,
+		q:     q,
+		opts:  opts,
+		state: postgres.CampaignState{Name: name, AppVersion: opts.AppVersion},
+	}
+}
+
+// Run loads the recorded progress of the campaign, then enqueues a batch of
+// module versions every Interval until none are left. Errors while enqueueing
+// a batch are logged, and the batch is retried after Interval.
+//
+// Run returns nil when the campaign is done, and ctx.Err() if ctx is done
+// first. In both cases the progress is recorded before it returns.
+func (c *Campaign) Run(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "Campaign.Run(%q)", c.name)
+
+	if err := c.load(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if c.Progress().Done {
+			return nil
+		}
+		if err := c.runBatch(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf(ctx, "Campaign %q: %v", c.name, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Progress returns the progress of the campaign. Before Run has loaded the
+// recorded progress, it reports none.
+func (c *Campaign) Progress() CampaignProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := CampaignProgress{
+		Name:       c.name,
+		AppVersion: c.state.AppVersion,
+		Enqueued:   c.state.NumEnqueued,
+		Skipped:    c.state.NumSkipped,
+		Done:       c.state.Done,
+		StartedAt:  c.state.StartedAt,
+		UpdatedAt:  c.state.UpdatedAt,
+	}
+	if cur := c.state.Cursor; cur != nil {
+		p.Last = cur.ModulePath + "@" + cur.Version
+	}
+	return p
+}
+
+// load reads the recorded progress of the campaign, or starts a new one if
+// there is none for its app version. Starting a campaign fixes the popularity
+// of each module, and so the order in which the campaign enqueues them.
+func (c *Campaign) load(ctx context.Context) error {
+	s, err := c.db.GetCampaignState(ctx, c.name)
+	switch {
+	case errors.Is(err, derrors.NotFound):
+		s = nil
+	case err != nil:
+		return err
+	case s.AppVersion != c.opts.AppVersion:
+		log.Infof(ctx, "Campaign %q: starting over for app version %q (was %q)", c.name, c.opts.AppVersion, s.AppVersion)
+		s = nil
+	default:
+		log.Infof(ctx, "Campaign %q: resuming with %d enqueued and %d skipped", c.name, s.NumEnqueued, s.NumSkipped)
+	}
+	if s == nil {
+		now := time.Now()
+		s = &postgres.CampaignState{
+			Name:       c.name,
+			AppVersion: c.opts.AppVersion,
+			StartedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := c.db.StartCampaign(ctx, s); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = *s
+	c.attempts = 0
+	return nil
+}
+
+// runBatch enqueues the next batch of stale module versions, and records the
+// progress. It stops at the first module version that cannot be scheduled,
+// unless that has failed MaxAttempts times, in which case the module version
+// is skipped.
+func (c *Campaign) runBatch(ctx context.Context) (err error) {
+	c.mu.Lock()
+	cursor := c.state.Cursor
+	c.mu.Unlock()
+	vs, err := c.db.GetStaleVersions(ctx, c.name, c.opts.AppVersion, cursor, c.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(vs) == 0 {
+		c.mu.Lock()
+		c.state.Done = true
+		c.mu.Unlock()
+		log.Infof(ctx, "Campaign %q: done", c.name)
+		return c.save(ctx)
+	}
+
+	defer func() {
+		if serr := c.save(ctx); err == nil {
+			err = serr
+		}
+	}()
+	for _, v := range vs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		serr := c.q.ScheduleFetch(ctx, v.ModulePath, v.Version, c.opts.Suffix, c.opts.TaskIDChangeInterval)
+		c.mu.Lock()
+		if serr != nil {
+			c.attempts++
+			if c.attempts < c.opts.MaxAttempts {
+				attempts := c.attempts
+				c.mu.Unlock()
+				return fmt.Errorf("scheduling %s@%s (attempt %d of %d): %w", v.ModulePath, v.Version, attempts, c.opts.MaxAttempts, serr)
+			}
+			log.Errorf(ctx, "Campaign %q: skipping %s@%s after %d attempts: %v", c.name, v.ModulePath, v.Version, c.attempts, serr)
+			c.state.NumSkipped++
+		} else {
+			c.state.NumEnqueued++
+		}
+		c.state.Cursor = v
+		c.attempts = 0
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// save records the progress of the campaign. If ctx is done, it uses a new
+// context, so that the work done before ctx was canceled is not repeated.
+func (c *Campaign) save(ctx context.Context) error {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), campaignSaveTimeout)
+		defer cancel()
+	}
+	c.mu.Lock()
+	c.state.UpdatedAt = time.Now()
+	s := c.state
+	c.mu.Unlock()
+	return c.db.SaveCampaignState(ctx, &s)
+}
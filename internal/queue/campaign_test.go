@@ -0,0 +1,233 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// fakeCampaignDB is a CampaignDB that holds its stale versions and campaign
+// states in memory. The popularity of its modules never changes, so it has no
+// snapshots.
+type fakeCampaignDB struct {
+	mu      sync.Mutex
+	stale   []*postgres.StaleVersion // sorted as by GetStaleVersions
+	states  map[string]postgres.CampaignState
+	started int // calls to StartCampaign
+}
+
+func newFakeCampaignDB(stale ...*postgres.StaleVersion) *fakeCampaignDB {
+	sort.Slice(stale, func(i, j int) bool { return staleLess(stale[i], stale[j]) })
+	return &fakeCampaignDB{stale: stale, states: map[string]postgres.CampaignState{}}
+}
+
+// staleLess reports whether a comes before b in the order of GetStaleVersions.
+func staleLess(a, b *postgres.StaleVersion) bool {
+	if a.ImportedByCount != b.ImportedByCount {
+		return a.ImportedByCount > b.ImportedByCount
+	}
+	if a.ModulePath != b.ModulePath {
+		return a.ModulePath < b.ModulePath
+	}
+	return a.Version < b.Version
+}
+
+func (db *fakeCampaignDB) GetStaleVersions(ctx context.Context, campaign, appVersion string, after *postgres.StaleVersion, limit int) ([]*postgres.StaleVersion, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var vs []*postgres.StaleVersion
+	for _, v := range db.stale {
+		if after != nil && !staleLess(after, v) {
+			continue
+		}
+		if len(vs) == limit {
+			break
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+func (db *fakeCampaignDB) GetCampaignState(ctx context.Context, name string) (*postgres.CampaignState, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	s, ok := db.states[name]
+	if !ok {
+		return nil, derrors.NotFound
+	}
+	return &s, nil
+}
+
+func (db *fakeCampaignDB) StartCampaign(ctx context.Context, s *postgres.CampaignState) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.started++
+	db.states[s.Name] = *s
+	return nil
+}
+
+func (db *fakeCampaignDB) SaveCampaignState(ctx context.Context, s *postgres.CampaignState) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.states[s.Name] = *s
+	return nil
+}
+
+// funcQueue is a Queue that calls a function to schedule each fetch.
+type funcQueue func(ctx context.Context, modulePath, version string) error
+
+func (q funcQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	return q(ctx, modulePath, version)
+}
+
+func campaignTestVersions() []*postgres.StaleVersion {
+	return []*postgres.StaleVersion{
+		{ModulePath: "c.com", Version: "v1.0.0", ImportedByCount: 1},
+		{ModulePath: "a.com", Version: "v1.0.0", ImportedByCount: 10},
+		{ModulePath: "b.com", Version: "v1.0.0", ImportedByCount: 5},
+		{ModulePath: "a.com", Version: "v1.1.0", ImportedByCount: 10},
+		{ModulePath: "d.com", Version: "v1.0.0"},
+	}
+}
+
+// campaignTestOrder is the order in which a campaign enqueues
+// campaignTestVersions.
+var campaignTestOrder = []string{"a.com@v1.0.0", "a.com@v1.1.0", "b.com@v1.0.0", "c.com@v1.0.0", "d.com@v1.0.0"}
+
+var campaignTestOptions = CampaignOptions{
+	AppVersion: "20200601t000000",
+	BatchSize:  2,
+	Interval:   time.Millisecond,
+}
+
+func TestCampaign(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeCampaignDB(campaignTestVersions()...)
+	q := &fakeQueue{}
+	c := NewCampaign("test", db, q, campaignTestOptions)
+	if err := c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(campaignTestOrder, q.scheduled); diff != "" {
+		t.Errorf("scheduled mismatch (-want +got):\n%s", diff)
+	}
+	got := c.Progress()
+	want := CampaignProgress{
+		Name:       "test",
+		AppVersion: campaignTestOptions.AppVersion,
+		Enqueued:   5,
+		Last:       "d.com@v1.0.0",
+		Done:       true,
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(CampaignProgress{}, "StartedAt", "UpdatedAt")); diff != "" {
+		t.Errorf("Progress() mismatch (-want +got):\n%s", diff)
+	}
+
+	// A finished campaign does nothing when run again.
+	q.scheduled = nil
+	if err := NewCampaign("test", db, q, campaignTestOptions).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.scheduled) != 0 {
+		t.Errorf("finished campaign scheduled %v", q.scheduled)
+	}
+
+	// A campaign for a new app version starts over.
+	opts := campaignTestOptions
+	opts.AppVersion = "20200701t000000"
+	if err := NewCampaign("test", db, q, opts).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(campaignTestOrder, q.scheduled); diff != "" {
+		t.Errorf("new app version: scheduled mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCampaignResume(t *testing.T) {
+	db := newFakeCampaignDB(campaignTestVersions()...)
+
+	// Cancel the first campaign after it schedules three fetches, in the
+	// middle of its second batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var scheduled []string
+	q := funcQueue(func(ctx context.Context, modulePath, version string) error {
+		scheduled = append(scheduled, modulePath+"@"+version)
+		if len(scheduled) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	c := NewCampaign("test", db, q, campaignTestOptions)
+	if err := c.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if p := c.Progress(); p.Enqueued != 3 || p.Done {
+		t.Errorf("canceled campaign: got %+v, want 3 enqueued and not done", p)
+	}
+
+	// A new campaign with the same name continues without scheduling any
+	// fetch twice.
+	c = NewCampaign("test", db, q, campaignTestOptions)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(campaignTestOrder, scheduled); diff != "" {
+		t.Errorf("scheduled mismatch (-want +got):\n%s", diff)
+	}
+	if p := c.Progress(); p.Enqueued != 5 || !p.Done {
+		t.Errorf("resumed campaign: got %+v, want 5 enqueued and done", p)
+	}
+	if db.started != 1 {
+		t.Errorf("started %d campaigns, want 1", db.started)
+	}
+}
+
+func TestCampaignFailures(t *testing.T) {
+	db := newFakeCampaignDB(campaignTestVersions()...)
+	var (
+		scheduled []string
+		attempts  = map[string]int{}
+	)
+	q := funcQueue(func(ctx context.Context, modulePath, version string) error {
+		mv := modulePath + "@" + version
+		attempts[mv]++
+		switch {
+		case mv == "b.com@v1.0.0":
+			return errors.New("always fails")
+		case mv == "c.com@v1.0.0" && attempts[mv] == 1:
+			return errors.New("fails once")
+		}
+		scheduled = append(scheduled, mv)
+		return nil
+	})
+	opts := campaignTestOptions
+	opts.MaxAttempts = 2
+	c := NewCampaign("test", db, q, opts)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.com@v1.0.0", "a.com@v1.1.0", "c.com@v1.0.0", "d.com@v1.0.0"}
+	if diff := cmp.Diff(want, scheduled); diff != "" {
+		t.Errorf("scheduled mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := fmt.Sprint(attempts["b.com@v1.0.0"], attempts["c.com@v1.0.0"]), "2 2"; got != want {
+		t.Errorf("got attempts for b.com and c.com %s, want %s", got, want)
+	}
+	if p := c.Progress(); p.Enqueued != 4 || p.Skipped != 1 || !p.Done {
+		t.Errorf("got %+v, want 4 enqueued, 1 skipped and done", p)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"google.golang.org/api/iterator"
+	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
+)
+
+// ETAUnknown is the eta returned by EstimateDrain when tasks are waiting but
+// there is no throughput to estimate from.
+const ETAUnknown = time.Duration(-1)
+
+// The window over which an InMemory queue averages its completion rate, and
+// the shortest period it averages over. Until the queue has run for
+// minThroughputSpan, the rate is averaged over minThroughputSpan anyway, so
+// that a few quick fetches right after it starts don't inflate the rate.
+const (
+	throughputWindow  = 10 * time.Minute
+	minThroughputSpan = time.Minute
+)
+
+// maxDrainTasks is the most tasks that GCP.EstimateDrain counts, so that
+// counting them takes at most ten ListTasks pages of the largest size. It is
+// replaced in tests.
+var maxDrainTasks = 10 * listTasksPageSize
+
+// listTasksPageSize is the largest page size that ListTasks accepts.
+const listTasksPageSize = 1000
+
+// A throughputMeter measures the rate at which events happen, averaged over
+// a rolling window.
+type throughputMeter struct {
+	window time.Duration
+	now    func() time.Time // replaced in tests
+
+	mu    sync.Mutex
+	start time.Time   // when measuring began
+	times []time.Time // of the events in the window, oldest first
+}
+
+func newThroughputMeter(window time.Duration, now func() time.Time) *throughputMeter {
+	return &throughputMeter{window: window, now: now, start: now()}
+}
+
+// record records that an event happened now.
+func (m *throughputMeter) record() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.pruneLocked(now)
+	m.times = append(m.times, now)
+}
+
+// perMinute returns the number of events per minute, averaged over the
+// window, or over the time since measuring began if that is shorter, but
+// never over less than minThroughputSpan.
+func (m *throughputMeter) perMinute() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.pruneLocked(now)
+	span := m.window
+	if elapsed := now.Sub(m.start); elapsed < span {
+		span = elapsed
+	}
+	if span < minThroughputSpan {
+		span = minThroughputSpan
+	}
+	return float64(len(m.times)) / span.Minutes()
+}
+
+// pruneLocked discards the events that happened before the window ending at
+// now.
+func (m *throughputMeter) pruneLocked(now time.Time) {
+	cutoff := now.Add(-m.window)
+	i := 0
+	for i < len(m.times) && !m.times[i].After(cutoff) {
+		i++
+	}
+	m.times = m.times[i:]
+}
+
+// drainETA returns how long it takes to process tasks at throughputPerMin.
+func drainETA(tasks int, throughputPerMin float64) time.Duration {
+	if tasks == 0 {
+		return 0
+	}
+	if throughputPerMin <= 0 {
+		return ETAUnknown
+	}
+	return time.Duration(float64(tasks) / throughputPerMin * float64(time.Minute))
+}
+
+// EstimateDrain estimates how long the queue will take to finish its tasks.
+// tasks is the number of tasks waiting to be dispatched or being fetched.
+// throughputPerMin is the number of fetches that finished per minute over the
+// last ten minutes, or since the queue was created if that is more recent.
+// eta is the time to finish tasks at that rate: zero if there are no tasks,
+// and ETAUnknown if no fetch has finished recently.
+//
+// The estimate assumes that the recent rate continues, so it is only a rough
+// guide while the rate changes, as when the queue has just started or an
+// adaptive queue is changing its concurrency.
+func (q *InMemory) EstimateDrain() (tasks int, throughputPerMin float64, eta time.Duration) {
+	q.mu.Lock()
+	tasks = len(q.queue) + len(q.sem)
+	if q.pending != nil {
+		tasks++
+	}
+	q.mu.Unlock()
+	throughputPerMin = q.completions.perMinute()
+	return tasks, throughputPerMin, drainETA(tasks, throughputPerMin)
+}
+
+// EstimateDrain estimates how long the Cloud Tasks queue will take to
+// dispatch its tasks. tasks is the number of tasks in the queue, including
+// those being dispatched, counted by listing them. throughputPerMin is the
+// queue's maximum dispatch rate. eta is the time to dispatch tasks at that
+// rate, or zero if there are none.
+//
+// Listing a large queue is slow, so at most 10,000 tasks are counted. If
+// tasks is 10,000, the queue may hold more, and tasks and eta are lower
+// bounds. Since the queue can dispatch no faster than its maximum rate, and
+// may dispatch more slowly when its maximum number of concurrent dispatches
+// is reached or when tasks are retried, eta is a lower bound anyway.
+func (q *GCP) EstimateDrain(ctx context.Context) (tasks int, throughputPerMin float64, eta time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	defer derrors.Wrap(&err, "queue.EstimateDrain(%q)", q.queueID)
+	queueName, err := q.queueName()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	tq, err := q.client.GetQueue(ctx, &taskspb.GetQueueRequest{Name: queueName}, q.callOpts...)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("q.client.GetQueue(ctx, %q): %v", queueName, err)
+	}
+	throughputPerMin = tq.GetRateLimits().GetMaxDispatchesPerSecond() * 60
+
+	it := q.client.ListTasks(ctx, &taskspb.ListTasksRequest{
+		Parent:       queueName,
+		ResponseView: taskspb.Task_BASIC,
+		PageSize:     listTasksPageSize,
+	}, q.callOpts...)
+	for tasks < maxDrainTasks {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("listing tasks of %q: %v", queueName, err)
+		}
+		tasks++
+	}
+	return tasks, throughputPerMin, drainETA(tasks, throughputPerMin), nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestThroughputMeter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newThroughputMeter(10*time.Minute, func() time.Time { return now })
+	check := func(when string, want float64) {
+		t.Helper()
+		if got := m.perMinute(); got != want {
+			t.Errorf("%s: got %g per minute, want %g", when, got, want)
+		}
+	}
+
+	check("no events", 0)
+	// Soon after the start, the rate is averaged over a minute.
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Second)
+		m.record()
+	}
+	check("after 3s", 3)
+	// Before the window fills, the rate is averaged since the start.
+	now = now.Add(2*time.Minute - 3*time.Second)
+	check("after 2m", 1.5)
+	for i := 0; i < 17; i++ {
+		now = now.Add(30 * time.Second)
+		m.record()
+	}
+	// The first three events are now out of the window.
+	now = now.Add(30 * time.Second)
+	check("after 11m", 1.7)
+	// Much later, nothing is in the window.
+	now = now.Add(time.Hour)
+	check("after 71m", 0)
+}
+
+func TestDrainETA(t *testing.T) {
+	for _, test := range []struct {
+		tasks      int
+		throughput float64
+		want       time.Duration
+	}{
+		{0, 0, 0},
+		{0, 10, 0},
+		{10, 0, ETAUnknown},
+		{10, 2, 5 * time.Minute},
+		{240, 1, 4 * time.Hour},
+	} {
+		if got := drainETA(test.tasks, test.throughput); got != test.want {
+			t.Errorf("drainETA(%d, %g) = %s, want %s", test.tasks, test.throughput, got, test.want)
+		}
+	}
+}
+
+func TestInMemoryEstimateDrain(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		unblock = make(chan struct{})
+	)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		if modulePath == "blocking.com" {
+			close(started)
+			<-unblock
+		}
+		return 200, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewInMemory(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{Sequential: true})
+
+	for _, m := range []string{"blocking.com", "a.com", "b.com"} {
+		if err := q.ScheduleFetch(ctx, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	// One task is running, and two are waiting. None has finished.
+	if tasks, tp, eta := q.EstimateDrain(); tasks != 3 || tp != 0 || eta != ETAUnknown {
+		t.Errorf("while blocked: got (%d, %g, %s), want (3, 0, %s)", tasks, tp, eta, ETAUnknown)
+	}
+
+	close(unblock)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tasks, tp, eta := q.EstimateDrain()
+		if tasks == 0 {
+			// All three finished in less than a minute, which the rate is
+			// averaged over.
+			if tp != 3 || eta != 0 {
+				t.Errorf("after draining: got (%d, %g, %s), want (0, 3, 0s)", tasks, tp, eta)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the queue to drain: %d tasks left", tasks)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// ListTasks returns the tasks in order of name, in pages of req.PageSize. The
+// page token is the index of the first task of the page.
+func (f *fakeCloudTasks) ListTasks(ctx context.Context, req *taskspb.ListTasksRequest) (*taskspb.ListTasksResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listRequests = append(f.listRequests, req)
+	var names []string
+	for name := range f.tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	start := 0
+	if req.PageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(req.PageToken); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad page token %q", req.PageToken)
+		}
+	}
+	end := len(names)
+	if req.PageSize > 0 && start+int(req.PageSize) < end {
+		end = start + int(req.PageSize)
+	}
+	resp := &taskspb.ListTasksResponse{}
+	for _, name := range names[start:end] {
+		resp.Tasks = append(resp.Tasks, &taskspb.Task{Name: name})
+	}
+	if end < len(names) {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+func TestGCPEstimateDrain(t *testing.T) {
+	ctx := context.Background()
+	const queueName = "projects/Project/locations/us-central1/queues/queueID"
+	fake := &fakeCloudTasks{queues: map[string]*taskspb.Queue{
+		queueName: {Name: queueName, RateLimits: &taskspb.RateLimits{MaxDispatchesPerSecond: 2}},
+	}}
+	q, teardown := newTestGCP(t, fake, "queueID", nil)
+	defer teardown()
+
+	for i := 0; i < 3; i++ {
+		if err := q.ScheduleFetch(ctx, fmt.Sprintf("mod%d.com", i), "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks, tp, eta, err := q.EstimateDrain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks != 3 || tp != 120 || eta != 1500*time.Millisecond {
+		t.Errorf("got (%d, %g, %s), want (3, 120, 1.5s)", tasks, tp, eta)
+	}
+	for _, req := range fake.listRequests {
+		if req.ResponseView != taskspb.Task_BASIC {
+			t.Errorf("ListTasks got view %s, want %s", req.ResponseView, taskspb.Task_BASIC)
+		}
+	}
+}
+
+func TestGCPEstimateDrainLimit(t *testing.T) {
+	ctx := context.Background()
+	const queueName = "projects/Project/locations/us-central1/queues/queueID"
+	fake := &fakeCloudTasks{queues: map[string]*taskspb.Queue{
+		queueName: {Name: queueName, RateLimits: &taskspb.RateLimits{MaxDispatchesPerSecond: 1}},
+	}}
+	q, teardown := newTestGCP(t, fake, "queueID", nil)
+	defer teardown()
+
+	defer func(m int) { maxDrainTasks = m }(maxDrainTasks)
+	maxDrainTasks = 2
+	for i := 0; i < 5; i++ {
+		if err := q.ScheduleFetch(ctx, fmt.Sprintf("mod%d.com", i), "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks, _, eta, err := q.EstimateDrain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks != 2 || eta != 2*time.Second {
+		t.Errorf("got (%d, %s), want (2, 2s)", tasks, eta)
+	}
+}
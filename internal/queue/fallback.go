@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/pkgsite/internal/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A FallbackQueue is a Queue that schedules fetches on a primary queue,
+// usually a GCP queue, and schedules them on a fallback queue, usually an
+// InMemory queue, when the primary cannot be reached. This lets the same
+// binary use Cloud Tasks in production and process fetches in-process where
+// Cloud Tasks is unavailable, as on a development machine without GCP
+// credentials.
+//
+// Only errors that mean the primary queue could not be used at all cause a
+// fallback: those with the gRPC codes Unavailable, Unauthenticated and
+// PermissionDenied. Other errors, such as an invalid task, are returned as is.
+// A task that already exists is not an error, so it is never scheduled on the
+// fallback queue. Each fetch tries the primary queue first, so fetches return
+// to it as soon as it is reachable again.
+type FallbackQueue struct {
+	primary, fallback Queue
+}
+
+// NewFallbackQueue returns a FallbackQueue that schedules fetches on primary,
+// and on fallback when primary is unreachable.
+func NewFallbackQueue(primary, fallback Queue) *FallbackQueue {
+	return &FallbackQueue{primary: primary, fallback: fallback}
+}
+
+// ScheduleFetch schedules a fetch of modulePath at version on the primary
+// queue, or on the fallback queue if the primary queue fails with one of the
+// errors described for FallbackQueue.
+func (q *FallbackQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	err := q.primary.ScheduleFetch(ctx, modulePath, version, suffix, taskIDChangeInterval)
+	if err == nil || !shouldFallBack(err) {
+		return err
+	}
+	log.Errorf(ctx, "FallbackQueue: primary queue unreachable; scheduling %s@%s on the fallback queue: %v", modulePath, version, err)
+	return q.fallback.ScheduleFetch(ctx, modulePath, version, suffix, taskIDChangeInterval)
+}
+
+// shouldFallBack reports whether err, from the primary queue of a
+// FallbackQueue, wraps a gRPC status error meaning that the queue could not
+// be reached or used.
+func shouldFallBack(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.GRPCStatus().Code() {
+	case codes.Unavailable, codes.Unauthenticated, codes.PermissionDenied:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFallbackQueue(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name         string
+		createErr    error
+		wantErr      bool
+		wantFallback []string
+	}{
+		{"success", nil, false, nil},
+		{"unavailable", status.Error(codes.Unavailable, "down"), false, []string{"mod.com@v1.0.0"}},
+		{"permission denied", status.Error(codes.PermissionDenied, "denied"), false, []string{"mod.com@v1.0.0"}},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "no credentials"), false, []string{"mod.com@v1.0.0"}},
+		{"other error", status.Error(codes.InvalidArgument, "bad task"), true, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeCloudTasks{createErr: test.createErr}
+			gcp, teardown := newTestGCP(t, fake, "queueID", nil)
+			defer teardown()
+			fallback := &fakeQueue{}
+			q := NewFallbackQueue(gcp, fallback)
+
+			err := q.ScheduleFetch(ctx, "mod.com", "v1.0.0", "", time.Hour)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.wantFallback, fallback.scheduled); diff != "" {
+				t.Errorf("fallback fetches mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		fake := &fakeCloudTasks{}
+		gcp, teardown := newTestGCP(t, fake, "queueID", nil)
+		defer teardown()
+		fallback := &fakeQueue{}
+		q := NewFallbackQueue(gcp, fallback)
+		for i := 0; i < 2; i++ {
+			if err := q.ScheduleFetch(ctx, "mod.com", "v1.0.0", "", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(fallback.scheduled) != 0 {
+			t.Errorf("got fallback fetches %v for a duplicate task, want none", fallback.scheduled)
+		}
+	})
+}
+
+func TestShouldFallBack(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{status.Error(codes.Unavailable, "down"), true},
+		{fmt.Errorf("wrapped: %w", status.Error(codes.PermissionDenied, "denied")), true},
+		{status.Error(codes.AlreadyExists, "exists"), false},
+		{status.Error(codes.Internal, "oops"), false},
+		{errors.New("not a status"), false},
+	} {
+		if got := shouldFallBack(test.err); got != test.want {
+			t.Errorf("shouldFallBack(%v) = %t, want %t", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// An EnqueueResult is the outcome of scheduling a fetch.
+type EnqueueResult int
+
+const (
+	// EnqueueNew means that a new task was added to the queue.
+	EnqueueNew EnqueueResult = iota
+	// EnqueueDuplicate means that the queue already had a task for the
+	// fetch, so none was added.
+	EnqueueDuplicate
+	// EnqueueError means that scheduling the fetch failed.
+	EnqueueError
+)
+
+func (r EnqueueResult) String() string {
+	switch r {
+	case EnqueueNew:
+		return "new"
+	case EnqueueDuplicate:
+		return "duplicate"
+	case EnqueueError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// An Observer is told the result of every fetch that a queue is asked to
+// schedule. Queues may call ObserveEnqueue from several goroutines at once,
+// so it must be safe for concurrent use. It is called before ScheduleFetch
+// returns, so it should not block.
+type Observer interface {
+	ObserveEnqueue(ctx context.Context, modulePath, version string, result EnqueueResult)
+}
+
+// observe calls obs.ObserveEnqueue, if obs is non-nil.
+func observe(ctx context.Context, obs Observer, modulePath, version string, result EnqueueResult) {
+	if obs != nil {
+		obs.ObserveEnqueue(ctx, modulePath, version, result)
+	}
+}
+
+// An EnqueueCounter is an Observer that counts the results of scheduling
+// fetches. Its zero value is ready to use.
+type EnqueueCounter struct {
+	mu     sync.Mutex
+	counts map[EnqueueResult]int
+}
+
+// ObserveEnqueue implements Observer.
+func (c *EnqueueCounter) ObserveEnqueue(_ context.Context, _, _ string, result EnqueueResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[EnqueueResult]int{}
+	}
+	c.counts[result]++
+}
+
+// Count returns the number of fetches that were scheduled with result.
+func (c *EnqueueCounter) Count(result EnqueueResult) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[result]
+}
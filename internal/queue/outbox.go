@@ -0,0 +1,93 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// An OutboxQueue is a Queue whose ScheduleFetch records the fetch in the
+// fetch_outbox table as part of a transaction of the caller, instead of
+// scheduling it. An OutboxRelayer schedules the recorded fetches once the
+// transaction commits, so a fetch is scheduled if and only if the rest of
+// the transaction takes effect.
+//
+// The transaction must be on a database that has the fetch_outbox table.
+type OutboxQueue struct {
+	tx *sql.Tx
+}
+
+// NewOutboxQueue returns an OutboxQueue that records fetches as part of tx.
+// It should not be used after tx commits or rolls back.
+func NewOutboxQueue(tx *sql.Tx) *OutboxQueue {
+	return &OutboxQueue{tx: tx}
+}
+
+// ScheduleFetch records a fetch of modulePath at version in the outbox.
+func (q *OutboxQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	return postgres.InsertFetchIntent(ctx, q.tx, &postgres.FetchIntent{
+		ModulePath:           modulePath,
+		Version:              version,
+		Suffix:               suffix,
+		TaskIDChangeInterval: taskIDChangeInterval,
+	})
+}
+
+// outboxBatchSize is the largest number of fetches that OutboxRelayer.Relay
+// schedules in one transaction.
+const outboxBatchSize = 100
+
+// An OutboxRelayer schedules the fetches recorded by OutboxQueues on another
+// Queue.
+type OutboxRelayer struct {
+	db *postgres.DB
+	q  Queue
+}
+
+// NewOutboxRelayer returns an OutboxRelayer that schedules the fetches in the
+// outbox of db on q.
+func NewOutboxRelayer(db *postgres.DB, q Queue) *OutboxRelayer {
+	return &OutboxRelayer{db: db, q: q}
+}
+
+// Relay schedules the fetches in the outbox whose transactions have
+// committed and marks them done, until none are left or scheduling one
+// fails. It returns the number of fetches scheduled.
+//
+// A fetch may be scheduled more than once if marking it done fails.
+func (r *OutboxRelayer) Relay(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := r.db.RelayFetchIntents(ctx, outboxBatchSize, func(ctx context.Context, fi *postgres.FetchIntent) error {
+			return r.q.ScheduleFetch(ctx, fi.ModulePath, fi.Version, fi.Suffix, fi.TaskIDChangeInterval)
+		})
+		total += n
+		if err != nil || n < outboxBatchSize {
+			return total, err
+		}
+	}
+}
+
+// Run calls Relay every interval until ctx is done. Errors are logged, and the
+// fetches that failed are retried on the next call.
+func (r *OutboxRelayer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := r.Relay(ctx); err != nil {
+			log.Errorf(ctx, "OutboxRelayer.Relay: scheduled %d fetches: %v", n, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -9,11 +9,21 @@ package queue
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
+	"sort"
+	"sync"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	"github.com/golang/protobuf/ptypes"
+	gax "github.com/googleapis/gax-go/v2"
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
@@ -31,41 +41,519 @@ type Queue interface {
 	ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error
 }
 
+// ScheduleFetchIfNewer schedules a fetch of modulePath at version on q, but
+// only if version is newer than the latest version of the module known to ds,
+// or ds has no version of the module. It reports whether the fetch was
+// scheduled.
+func ScheduleFetchIfNewer(ctx context.Context, q Queue, ds internal.DataSource, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (scheduled bool, err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetchIfNewer(%q, %q, %q)", modulePath, version, suffix)
+	if !semver.IsValid(version) {
+		return false, fmt.Errorf("invalid version %q: %w", version, derrors.InvalidArgument)
+	}
+	mi, err := ds.GetModuleInfo(ctx, modulePath, internal.LatestVersion)
+	if err != nil && !errors.Is(err, derrors.NotFound) {
+		return false, err
+	}
+	if err == nil && semver.Compare(version, mi.Version) <= 0 {
+		return false, nil
+	}
+	if err := q.ScheduleFetch(ctx, modulePath, version, suffix, taskIDChangeInterval); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// An ImportedByCounter reports the number of packages that import a module:
+// the largest number of importers of any of its packages.
+//
+// internal.DataSource has no such method yet; a DataSource that adds it can be
+// passed directly to ScheduleFetchesByImportedBy.
+type ImportedByCounter interface {
+	GetImportedByCount(ctx context.Context, modulePath string) (int, error)
+}
+
+// ScheduleFetchesByImportedBy schedules fetches of mvs on q, ordered by the
+// imported-by count of their modules as reported by c, most imported first.
+// Module versions with equal counts keep their order in mvs. It is meant for
+// reprocessing, so that the changes reach the most visible modules first.
+//
+// Counts are looked up before anything is scheduled, so a failed lookup
+// schedules nothing. If scheduling a fetch fails, the fetches after it are
+// not scheduled.
+func ScheduleFetchesByImportedBy(ctx context.Context, q Queue, c ImportedByCounter, mvs []*internal.ModuleVersionState, suffix string, taskIDChangeInterval time.Duration) (err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetchesByImportedBy(%d module versions, %q)", len(mvs), suffix)
+
+	counts := map[string]int{}
+	for _, mv := range mvs {
+		if _, ok := counts[mv.ModulePath]; ok {
+			continue
+		}
+		n, err := c.GetImportedByCount(ctx, mv.ModulePath)
+		if err != nil {
+			return err
+		}
+		counts[mv.ModulePath] = n
+	}
+	sorted := append([]*internal.ModuleVersionState(nil), mvs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return counts[sorted[i].ModulePath] > counts[sorted[j].ModulePath]
+	})
+	for _, mv := range sorted {
+		if err := q.ScheduleFetch(ctx, mv.ModulePath, mv.Version, suffix, taskIDChangeInterval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScheduleFetches schedules fetches of mvs on q, calling ScheduleFetch from up
+// to concurrency goroutines at once, and returns the number of fetches it
+// scheduled. If scheduling a fetch fails, no more fetches are started, and
+// the error is returned once the ones in progress finish.
+//
+// ScheduleFetches checks ctx before each fetch it starts, so when ctx is
+// done it returns promptly, without starting the rest of mvs, with an error
+// wrapping ctx.Err(). Fetches in progress are passed ctx, so a Queue that
+// honors it stops them too. The count tells the caller how many fetches were
+// scheduled before the batch stopped, though when concurrency is greater
+// than one, they are not necessarily the first of mvs.
+func ScheduleFetches(ctx context.Context, q Queue, mvs []internal.ModVersion, suffix string, taskIDChangeInterval time.Duration, concurrency int) (n int, err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetches(%d module versions, %q, %d)", len(mvs), suffix, concurrency)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// stop is closed when a fetch fails, to stop feeding the others to the
+	// workers.
+	stop := make(chan struct{})
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	work := make(chan internal.ModVersion)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for mv := range work {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed || ctx.Err() != nil {
+					continue
+				}
+				err := q.ScheduleFetch(ctx, mv.Path, mv.Version, suffix, taskIDChangeInterval)
+				mu.Lock()
+				if err == nil {
+					n++
+				} else if firstErr == nil {
+					firstErr = err
+					close(stop)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, mv := range mvs {
+		select {
+		case work <- mv:
+		case <-ctx.Done():
+			break feed
+		case <-stop:
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return n, fmt.Errorf("scheduled %d of %d: %w", n, len(mvs), ctx.Err())
+	}
+	if firstErr != nil {
+		return n, fmt.Errorf("scheduled %d of %d: %w", n, len(mvs), firstErr)
+	}
+	return n, nil
+}
+
+// ScheduleAllVersions schedules fetches on q of every version of modulePath
+// that proxyClient lists, and returns the number of fetches it scheduled.
+//
+// A failure to schedule one version is logged and does not stop the others
+// from being scheduled; if any failed, the returned error wraps the first
+// such error.
+func ScheduleAllVersions(ctx context.Context, q Queue, proxyClient *proxy.Client, modulePath, suffix string, taskIDChangeInterval time.Duration) (n int, err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleAllVersions(%q, %q)", modulePath, suffix)
+
+	versions, err := proxyClient.ListVersions(ctx, modulePath)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		firstErr error
+		nfailed  int
+	)
+	for _, v := range versions {
+		if err := q.ScheduleFetch(ctx, modulePath, v, suffix, taskIDChangeInterval); err != nil {
+			log.Errorf(ctx, "ScheduleAllVersions: scheduling %s@%s: %v", modulePath, v, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			nfailed++
+			continue
+		}
+		n++
+	}
+	if firstErr != nil {
+		return n, fmt.Errorf("%d of %d versions failed; first error: %w", nfailed, len(versions), firstErr)
+	}
+	return n, nil
+}
+
+// A TeePolicy decides whether a fetch scheduled by a tee queue succeeded.
+type TeePolicy int
+
+const (
+	// TeePrimaryOnly succeeds if the fetch is scheduled on the primary queue.
+	TeePrimaryOnly TeePolicy = iota
+	// TeeAll succeeds only if the fetch is scheduled on every queue.
+	TeeAll
+)
+
+// TeeQueue returns a Queue that schedules each fetch on primary and then on
+// each of others, for mirroring fetches to another queue, as when migrating
+// between queue implementations. Its ScheduleFetch returns the error from
+// primary. Errors from the other queues are logged, and do not stop the fetch
+// from being scheduled on the rest.
+//
+// TeeQueue(primary, others...) is NewTeeQueue(TeePrimaryOnly, primary,
+// others...).
+func TeeQueue(primary Queue, others ...Queue) Queue {
+	return NewTeeQueue(TeePrimaryOnly, primary, others...)
+}
+
+// NewTeeQueue returns a Queue that schedules each fetch on primary and then
+// on each of others. Every failure is logged, and the fetch is still
+// scheduled on the rest of the queues. Whether ScheduleFetch returns an error
+// depends on policy: with TeePrimaryOnly it returns the error from primary,
+// and with TeeAll it returns an error if any queue failed, wrapping the first
+// such error.
+func NewTeeQueue(policy TeePolicy, primary Queue, others ...Queue) Queue {
+	return &teeQueue{policy: policy, queues: append([]Queue{primary}, others...)}
+}
+
+type teeQueue struct {
+	policy TeePolicy
+	queues []Queue // the primary queue is first
+}
+
+func (q *teeQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	var errs []error // indexed by queue
+	nfailed := 0
+	for i, tq := range q.queues {
+		err := tq.ScheduleFetch(ctx, modulePath, version, suffix, taskIDChangeInterval)
+		if err != nil {
+			log.Errorf(ctx, "TeeQueue: scheduling %s@%s on queue %d: %v", modulePath, version, i, err)
+			nfailed++
+		}
+		errs = append(errs, err)
+	}
+	switch q.policy {
+	case TeePrimaryOnly:
+		return errs[0]
+	case TeeAll:
+		for _, err := range errs {
+			if err != nil {
+				return fmt.Errorf("%d of %d queues failed; first error: %w", nfailed, len(q.queues), err)
+			}
+		}
+	}
+	return nil
+}
+
 // GCP provides a Queue implementation backed by the Google Cloud Tasks
 // API.
 type GCP struct {
-	cfg     *config.Config
-	client  *cloudtasks.Client
-	queueID string
+	cfg      *config.Config
+	client   *cloudtasks.Client
+	queueID  string
+	callOpts []gax.CallOption
+	nameFunc func(modulePath, version string, now time.Time) string
+	deadline func(modulePath, version string) time.Duration
+	name     string // from GCPOptions.QueueName
+	window   time.Duration
+	ttl      time.Duration
+	view     taskspb.Task_View
+	observer Observer
 }
 
+// GCPOptions holds optional configuration for a GCP queue.
+type GCPOptions struct {
+	// CallOptions are passed to every Cloud Tasks RPC made by the queue.
+	// They can be used to tune the retry policy and per-call timeout.
+	// ScheduleFetch's 30-second deadline remains an outer bound.
+	CallOptions []gax.CallOption
+
+	// NameFunc, if non-nil, returns the task ID for a fetch of modulePath at
+	// version, replacing the default opaque hash. now is truncated to the
+	// start of the task ID change interval, or of the TaskIDWindow if one is
+	// set, so NameFunc should return the same ID for the same arguments to
+	// preserve de-duplication. IDs may contain only
+	// letters, numbers, hyphens and underscores, and be at most 500
+	// characters long; ScheduleFetch fails for any other ID.
+	NameFunc func(modulePath, version string, now time.Time) string
+
+	// DispatchDeadline, if non-nil, returns how long Cloud Tasks should wait
+	// for the worker to respond to the task that fetches modulePath at
+	// version before cancelling the request and retrying the task. This lets
+	// fetches of large modules run longer than the queue's default deadline
+	// while quick ones keep a short one. A zero duration leaves the task
+	// with the default deadline. Cloud Tasks accepts deadlines between
+	// MinDispatchDeadline and MaxDispatchDeadline; ScheduleFetch fails for
+	// any other duration.
+	//
+	// Cloud Tasks only supports retry configuration, such as the maximum
+	// number of attempts, on the queue, so it cannot be set per task.
+	DispatchDeadline func(modulePath, version string) time.Duration
+
+	// QueueName, if non-empty, is the full resource name of the queue, of the
+	// form projects/PROJECT/locations/LOCATION/queues/QUEUE. It replaces the
+	// name built from the project and location in the config and the queue
+	// ID, so that a queue in another project or location can be used. The
+	// queue ID is then only used in error messages. The methods of GCP fail
+	// if the name is not of that form.
+	QueueName string
+
+	// TaskIDWindow, if positive, is the period during which fetches of the
+	// same module version get the same task ID, replacing the
+	// taskIDChangeInterval passed to ScheduleFetch and CancelFetch. Unlike
+	// the task ID change interval, whose periods start at the same time for
+	// every module version, the windows of each module version start at an
+	// offset derived from a hash of its path and version. Fetches scheduled
+	// together, such as by a cron job that runs near the start of an
+	// interval, then do not all get new task IDs at once, and a module
+	// version is fetched at most once per window unless its single boundary
+	// falls between two requests for it. Callers sensitive to duplicate
+	// processing can set it to a period longer than the interval between
+	// their requests.
+	//
+	// If it is zero, task IDs change every taskIDChangeInterval.
+	TaskIDWindow time.Duration
+
+	// TaskTTL, if positive, is how long a task may wait to be dispatched
+	// before the worker discards it instead of fetching. Cloud Tasks has no
+	// per-task expiration: a task stays in the queue until it succeeds, it
+	// is deleted, or it reaches the 31-day maximum retention, and the
+	// queue's max retry duration only bounds retries after the first
+	// attempt. So each task's schedule time is set to when it was created,
+	// and its expiration is sent to the worker in the TaskExpiresHeader
+	// header, which the worker checks with TaskExpired. Since expiration is
+	// checked on dispatch, an expired task is still dispatched once.
+	TaskTTL time.Duration
+
+	// ResponseView is the view of tasks returned by Cloud Tasks to
+	// GetTaskDispatchCount. The default, taskspb.Task_VIEW_UNSPECIFIED, is
+	// the same as taskspb.Task_BASIC, which omits the HTTP request of each
+	// task. taskspb.Task_FULL includes it, but requires the
+	// cloudtasks.tasks.fullView permission on the queue, and RPCs fail with
+	// PermissionDenied without it. EstimateDrain, which only counts tasks,
+	// always uses taskspb.Task_BASIC.
+	ResponseView taskspb.Task_View
+
+	// Observer, if non-nil, is told the result of each call to ScheduleFetch.
+	// A task that Cloud Tasks rejects because one with the same ID exists is
+	// reported as EnqueueDuplicate.
+	Observer Observer
+}
+
+// The range of dispatch deadlines that Cloud Tasks accepts for App Engine
+// tasks.
+const (
+	MinDispatchDeadline = 15 * time.Second
+	MaxDispatchDeadline = 24 * time.Hour
+)
+
 // NewGCP returns a new Queue that can be used to enqueue tasks using the
 // cloud tasks API.  The given queueID should be the name of the queue in the
-// cloud tasks console.
-func NewGCP(cfg *config.Config, client *cloudtasks.Client, queueID string) *GCP {
+// cloud tasks console. opts may be nil.
+func NewGCP(cfg *config.Config, client *cloudtasks.Client, queueID string, opts *GCPOptions) *GCP {
+	if opts == nil {
+		opts = &GCPOptions{}
+	}
 	return &GCP{
-		cfg:     cfg,
-		client:  client,
-		queueID: queueID,
+		cfg:      cfg,
+		client:   client,
+		queueID:  queueID,
+		callOpts: opts.CallOptions,
+		nameFunc: opts.NameFunc,
+		deadline: opts.DispatchDeadline,
+		name:     opts.QueueName,
+		window:   opts.TaskIDWindow,
+		ttl:      opts.TaskTTL,
+		view:     opts.ResponseView,
+		observer: opts.Observer,
 	}
 }
 
 // ScheduleFetch enqueues a task on GCP to fetch the given modulePath and
 // version. It returns an error if there was an error hashing the task name, or
 // an error pushing the task to GCP.
-func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (err error) {
+func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	return q.ScheduleFetchWithKey(ctx, modulePath, version, suffix, "", taskIDChangeInterval)
+}
+
+// ScheduleFetchWithKey is like ScheduleFetch, but if idempotencyKey is
+// non-empty it is used as the task ID instead of the one derived from
+// modulePath, version and the current time. Cloud Tasks then de-duplicates
+// fetches by the caller's key, such as one from an upstream event system, so a
+// retried request with the same key is ignored no matter how much time has
+// passed. As with derived IDs, a non-empty suffix is appended to the key.
+//
+// The key may contain only letters, numbers, hyphens and underscores, and be
+// at most 500 characters long; otherwise the returned error wraps
+// derrors.InvalidArgument. CancelFetch and GetTaskDispatchCount cannot find
+// tasks scheduled with a key.
+func (q *GCP) ScheduleFetchWithKey(ctx context.Context, modulePath, version, suffix, idempotencyKey string, taskIDChangeInterval time.Duration) (err error) {
 	// the new taskqueue API requires a deadline of <= 30s
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	defer derrors.Wrap(&err, "queue.ScheduleFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
-	queueName := fmt.Sprintf("projects/%s/locations/%s/queues/%s", q.cfg.ProjectID, q.cfg.LocationID, q.queueID)
+	defer derrors.Wrap(&err, "queue.ScheduleFetchWithKey(%q, %q, %q, %q, %d)", modulePath, version, suffix, idempotencyKey, taskIDChangeInterval)
+	req, err := q.newTaskRequest(modulePath, version, suffix, idempotencyKey, time.Now(), taskIDChangeInterval)
+	if err != nil {
+		observe(ctx, q.observer, modulePath, version, EnqueueError)
+		return err
+	}
+	if _, err := q.client.CreateTask(ctx, req, q.callOpts...); err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			log.Infof(ctx, "ignoring duplicate task %s: %q", req.Task.Name, req.Task.GetAppEngineHttpRequest().RelativeUri)
+			observe(ctx, q.observer, modulePath, version, EnqueueDuplicate)
+			return nil
+		}
+		observe(ctx, q.observer, modulePath, version, EnqueueError)
+		return fmt.Errorf("q.client.CreateTask(ctx, req): %w", err)
+	}
+	observe(ctx, q.observer, modulePath, version, EnqueueNew)
+	return nil
+}
+
+// ScheduleFetchAndWait is not supported, since fetches scheduled on a GCP
+// queue run in another process. It returns an error wrapping ErrUnsupported.
+func (q *GCP) ScheduleFetchAndWait(ctx context.Context, modulePath, version string) (numPackages int, err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetchAndWait(%q, %q)", modulePath, version)
+	return 0, ErrUnsupported
+}
+
+// VerifyQueue checks that the Cloud Tasks queue exists and is running. It
+// returns a descriptive error if the queue is missing, paused or disabled, so
+// that a misconfigured queue can be detected at startup instead of tasks
+// silently piling up.
+func (q *GCP) VerifyQueue(ctx context.Context) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	defer derrors.Wrap(&err, "queue.VerifyQueue(%q)", q.queueID)
+	queueName, err := q.queueName()
+	if err != nil {
+		return err
+	}
+	tq, err := q.client.GetQueue(ctx, &taskspb.GetQueueRequest{Name: queueName}, q.callOpts...)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("queue %s does not exist: %w", queueName, derrors.NotFound)
+		}
+		return fmt.Errorf("q.client.GetQueue(ctx, %q): %v", queueName, err)
+	}
+	if tq.State != taskspb.Queue_RUNNING {
+		return fmt.Errorf("queue %s is not running (state %s)", queueName, tq.State)
+	}
+	return nil
+}
+
+// CancelFetch deletes the task that ScheduleFetch would create for the given
+// modulePath, version and suffix at the current time. modulePath, version,
+// suffix and taskIDChangeInterval must match the values passed to
+// ScheduleFetch, and the current time must fall in the same
+// taskIDChangeInterval-sized chunk of time as when the task was scheduled,
+// since both are part of the task name.
+//
+// It is not an error if the task does not exist. Cloud Tasks does not allow a
+// task to be deleted once it has been dispatched, so a fetch that is already
+// running cannot be cancelled.
+func (q *GCP) CancelFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	defer derrors.Wrap(&err, "queue.CancelFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	name, err := q.taskName(modulePath, version, suffix, "", time.Now(), taskIDChangeInterval)
+	if err != nil {
+		return err
+	}
+	if err := q.client.DeleteTask(ctx, &taskspb.DeleteTaskRequest{Name: name}, q.callOpts...); err != nil {
+		if status.Code(err) == codes.NotFound {
+			log.Infof(ctx, "task %s not found; it may have already been dispatched", name)
+			return nil
+		}
+		return fmt.Errorf("q.client.DeleteTask(ctx, %q): %v", name, err)
+	}
+	return nil
+}
+
+// GetTaskDispatchCount returns the number of times that the task that
+// ScheduleFetch would create for the given modulePath, version and suffix at
+// the current time has been dispatched to the worker, including attempts that
+// are still running. As with CancelFetch, the arguments and the current time
+// must identify the same task that ScheduleFetch created.
+//
+// If the task does not exist, because it was never scheduled or has already
+// succeeded, GetTaskDispatchCount returns 0.
+func (q *GCP) GetTaskDispatchCount(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (_ int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	defer derrors.Wrap(&err, "queue.GetTaskDispatchCount(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	name, err := q.taskName(modulePath, version, suffix, "", time.Now(), taskIDChangeInterval)
+	if err != nil {
+		return 0, err
+	}
+	task, err := q.client.GetTask(ctx, &taskspb.GetTaskRequest{Name: name, ResponseView: q.view}, q.callOpts...)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("q.client.GetTask(ctx, %q): %v", name, err)
+	}
+	return int(task.DispatchCount), nil
+}
+
+// queueName returns the full resource name of the Cloud Tasks queue.
+func (q *GCP) queueName() (string, error) {
+	if q.name == "" {
+		return fmt.Sprintf("projects/%s/locations/%s/queues/%s", q.cfg.ProjectID, q.cfg.LocationID, q.queueID), nil
+	}
+	if !validQueueName.MatchString(q.name) {
+		return "", fmt.Errorf("invalid queue name %q: %w", q.name, derrors.InvalidArgument)
+	}
+	return q.name, nil
+}
+
+// validQueueName matches the full resource names of Cloud Tasks queues.
+var validQueueName = regexp.MustCompile(`^projects/[a-z][a-z0-9:.-]*[a-z0-9]/locations/[a-z0-9-]+/queues/[A-Za-z0-9-]{1,100}$`)
+
+// newTaskRequest returns the request to create a task that fetches modulePath
+// at version. key, if non-empty, is the idempotency key passed to
+// ScheduleFetchWithKey.
+func (q *GCP) newTaskRequest(modulePath, version, suffix, key string, now time.Time, taskIDChangeInterval time.Duration) (*taskspb.CreateTaskRequest, error) {
+	name, err := q.taskName(modulePath, version, suffix, key, now, taskIDChangeInterval)
+	if err != nil {
+		return nil, err
+	}
+	queueName, err := q.queueName()
+	if err != nil {
+		return nil, err
+	}
 	mod := fmt.Sprintf("%s/@v/%s", modulePath, version)
 	u := fmt.Sprintf("/fetch/" + mod)
-	taskID := newTaskID(modulePath, version, time.Now(), taskIDChangeInterval)
 	req := &taskspb.CreateTaskRequest{
 		Parent: queueName,
 		Task: &taskspb.Task{
-			Name: fmt.Sprintf("%s/tasks/%s", queueName, taskID),
+			Name: name,
 			MessageType: &taskspb.Task_AppEngineHttpRequest{
 				AppEngineHttpRequest: &taskspb.AppEngineHttpRequest{
 					HttpMethod:  taskspb.HttpMethod_POST,
@@ -77,22 +565,86 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 			},
 		},
 	}
-	// If suffix is non-empty, append it to the task name. This lets us force reprocessing
-	// of tasks that would normally be de-duplicated.
-	if suffix != "" {
-		req.Task.Name += "-" + suffix
+	if q.ttl > 0 {
+		ts, err := ptypes.TimestampProto(now)
+		if err != nil {
+			return nil, err
+		}
+		req.Task.ScheduleTime = ts
+		req.Task.GetAppEngineHttpRequest().Headers = map[string]string{
+			TaskExpiresHeader: now.Add(q.ttl).UTC().Format(time.RFC3339),
+		}
+	}
+	if q.deadline != nil {
+		d := q.deadline(modulePath, version)
+		if d != 0 {
+			if d < MinDispatchDeadline || d > MaxDispatchDeadline {
+				return nil, fmt.Errorf("dispatch deadline %s not between %s and %s: %w",
+					d, MinDispatchDeadline, MaxDispatchDeadline, derrors.InvalidArgument)
+			}
+			req.Task.DispatchDeadline = ptypes.DurationProto(d)
+		}
 	}
+	return req, nil
+}
 
-	if _, err := q.client.CreateTask(ctx, req); err != nil {
-		if status.Code(err) == codes.AlreadyExists {
-			log.Infof(ctx, "ignoring duplicate task ID %s: %q", taskID, mod)
-		} else {
-			return fmt.Errorf("q.client.CreateTask(ctx, req): %v", err)
+// TaskExpiresHeader is the HTTP header that holds the time, in RFC 3339
+// format, after which a fetch task scheduled by a GCP queue with a TaskTTL
+// should be discarded.
+const TaskExpiresHeader = "X-Pkgsite-Task-Expires"
+
+// TaskExpired reports whether the fetch task request r has a
+// TaskExpiresHeader header with a time before now. A request without the
+// header, or with a malformed one, never expires.
+func TaskExpired(r *http.Request, now time.Time) bool {
+	h := r.Header.Get(TaskExpiresHeader)
+	if h == "" {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, h)
+	if err != nil {
+		return false
+	}
+	return now.After(expires)
+}
+
+// taskName returns the full resource name of the task that fetches modulePath
+// at version. Its ID is key if that is non-empty.
+func (q *GCP) taskName(modulePath, version, suffix, key string, now time.Time, taskIDChangeInterval time.Duration) (string, error) {
+	start := now.Truncate(taskIDChangeInterval)
+	if q.window > 0 {
+		start = taskIDWindowStart(modulePath, version, now, q.window)
+	}
+	var taskID string
+	if key != "" {
+		if !validTaskID.MatchString(key) {
+			return "", fmt.Errorf("invalid idempotency key %q: %w", key, derrors.InvalidArgument)
 		}
+		taskID = key
+	} else if q.nameFunc != nil {
+		taskID = q.nameFunc(modulePath, version, start)
+		if !validTaskID.MatchString(taskID) {
+			return "", fmt.Errorf("invalid task ID %q: %w", taskID, derrors.InvalidArgument)
+		}
+	} else {
+		taskID = taskIDForPeriod(modulePath, version, start)
 	}
-	return nil
+	queueName, err := q.queueName()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s/tasks/%s", queueName, taskID)
+	// If suffix is non-empty, append it to the task name. This lets us force reprocessing
+	// of tasks that would normally be de-duplicated.
+	if suffix != "" {
+		name += "-" + suffix
+	}
+	return name, nil
 }
 
+// validTaskID matches the task IDs accepted by Cloud Tasks.
+var validTaskID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,500}$`)
+
 // Create a task ID for the given module path and version.
 // Task IDs can contain only letters ([A-Za-z]), numbers ([0-9]), hyphens (-), or underscores (_).
 // Also include a truncated time in the hash, so it changes periodically.
@@ -101,84 +653,485 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 // for two identical tasks to appear within that time period (for example, one at 2:59
 // and the other at 3:01) -- each is part of a different taskIDChangeInterval-sized chunk
 // of time. But there will never be a third identical task in that interval.
+//
+// GCPOptions.TaskIDWindow staggers the periods of different module versions to
+// avoid that problem for fetches scheduled together.
 func newTaskID(modulePath, version string, now time.Time, taskIDChangeInterval time.Duration) string {
-	t := now.Truncate(taskIDChangeInterval)
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(modulePath+"@"+version+"-"+t.String())))
+	return taskIDForPeriod(modulePath, version, now.Truncate(taskIDChangeInterval))
+}
+
+// taskIDForPeriod returns the task ID for modulePath at version during the
+// period that begins at start.
+func taskIDForPeriod(modulePath, version string, start time.Time) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(modulePath+"@"+version+"-"+start.String())))
+}
+
+// taskIDWindowStart returns the start of the window of length window that
+// contains now, for modulePath at version. The windows of a module version
+// are offset from multiples of window by an amount derived from a hash of its
+// path and version.
+func taskIDWindowStart(modulePath, version string, now time.Time, window time.Duration) time.Time {
+	sum := sha256.Sum256([]byte(modulePath + "@" + version))
+	offset := time.Duration(binary.BigEndian.Uint64(sum[:8]) % uint64(window))
+	return now.Add(-offset).Truncate(window).Add(offset)
 }
 
 type moduleVersion struct {
 	modulePath, version string
+	metadata            interface{}     // from ScheduleFetchWithMetadata
+	done                chan TaskResult // from ScheduleFetchAndWait; buffered
 }
 
 // InMemory is a Queue implementation that schedules in-process fetch
 // operations. Unlike the GCP task queue, it will not automatically retry tasks
 // on failure.
 //
+// Tasks are dispatched to workers by a goroutine that is started by
+// NewInMemory and runs until the context passed to NewInMemory is done. After
+// that, ScheduleFetch returns an error wrapping ErrStopped, and tasks that were
+// queued but not yet started stay in the queue. Restart starts a new
+// dispatcher with a fresh context, which resumes processing those tasks.
+//
 // This should only be used for local development.
 type InMemory struct {
 	proxyClient  *proxy.Client
 	sourceClient *source.Client
 	db           *postgres.DB
+	processFunc  ProcessFunc
+
+	queue        chan moduleVersion
+	sem          chan struct{}
+	limiter      *adaptiveLimiter // nil unless adaptive concurrency is enabled
+	sequential   bool
+	softTimeout  time.Duration
+	stallTimeout time.Duration
+	experiments  *experiment.Set
+	observer     Observer
+	results      chan<- TaskResult
 
-	queue       chan moduleVersion
-	sem         chan struct{}
-	experiments *experiment.Set
+	// logSlowFetch is called once for each fetch that runs longer than
+	// softTimeout. It is replaced in tests.
+	logSlowFetch func(ctx context.Context, v moduleVersion, elapsed time.Duration)
+
+	// completions measures the rate at which fetches finish, for
+	// EstimateDrain.
+	completions *throughputMeter
+
+	mu           sync.Mutex
+	stopped      chan struct{}  // closed when the current dispatcher returns
+	pending      *moduleVersion // task dequeued but not started by a stopped dispatcher
+	lastProgress time.Time      // when a task was last dequeued or finished
+}
+
+// ErrStopped is returned by InMemory.ScheduleFetch when the queue's
+// dispatcher has stopped because its context is done.
+var ErrStopped = errors.New("queue stopped")
+
+// ErrUnsupported is returned by queue operations that a kind of queue does
+// not support.
+var ErrUnsupported = errors.New("unsupported by queue")
+
+// ErrStalled is returned by InMemory.Healthy when tasks are waiting but none
+// has made progress for the queue's stall timeout.
+var ErrStalled = errors.New("queue stalled")
+
+// InMemoryOptions holds optional configuration for an InMemory queue.
+type InMemoryOptions struct {
+	// Adaptive, if non-nil, makes the queue adjust the number of concurrent
+	// fetches based on the status codes they return, starting at
+	// workerCount. If nil, workerCount fetches always run at once.
+	Adaptive *AdaptiveOptions
+
+	// Sequential makes the queue process one task at a time, in the order in
+	// which ScheduleFetch accepted them, each fetch finishing before the next
+	// one starts. workerCount and Adaptive are ignored. This makes the order
+	// of fetches deterministic, which is useful in tests, at the cost of all
+	// concurrency: a single slow fetch holds up every task behind it.
+	Sequential bool
+
+	// SoftTimeout, if positive, is how long a fetch can run before the queue
+	// logs its module path, version and running time. The fetch continues
+	// until it finishes or reaches the hard limit of FetchTimeout; the log
+	// entry, made at most once per fetch, only helps to spot slow modules.
+	SoftTimeout time.Duration
+
+	// StallTimeout, if positive, is how long tasks can wait in the queue
+	// without any task being dequeued or finishing before Healthy reports
+	// the queue as stalled. If zero, DefaultStallTimeout is used.
+	StallTimeout time.Duration
+
+	// Observer, if non-nil, is told the result of each call to ScheduleFetch
+	// and ScheduleFetchWithMetadata. The queue does not de-duplicate fetches,
+	// so it reports only EnqueueNew and EnqueueError.
+	Observer Observer
+
+	// Results, if non-nil, receives a TaskResult after each fetch finishes,
+	// so that tests can observe fetches without polling. The send blocks the
+	// worker that made the fetch until it is received, so the channel must
+	// be drained, or be buffered enough for every task, or the queue stalls.
+	// With Sequential, results arrive in the order in which the tasks were
+	// scheduled. A result is dropped if the queue's context is done before
+	// it is received.
+	Results chan<- TaskResult
+}
+
+// A TaskResult describes a finished fetch of an InMemory queue.
+type TaskResult struct {
+	ModulePath string
+	Version    string
+	// Code and Err are the status code and error returned by the queue's
+	// ProcessFunc.
+	Code int
+	Err  error
+	// NumPackages is the number of packages that the ProcessFunc reported
+	// with ReportNumPackages, or zero if it did not.
+	NumPackages int
+	// Duration is how long the ProcessFunc ran.
+	Duration time.Duration
+}
+
+// FetchTimeout is the longest an InMemory queue lets a fetch run before
+// cancelling it.
+const FetchTimeout = 5 * time.Minute
+
+// DefaultStallTimeout is the default InMemoryOptions.StallTimeout. Since every
+// fetch is cancelled after FetchTimeout, a worker should become free for a
+// waiting task within that time.
+const DefaultStallTimeout = FetchTimeout + time.Minute
+
+// A ProcessFunc fetches modulePath at version for an InMemory queue and
+// returns an HTTP status code describing the result. metadata is the value
+// passed to InMemory.ScheduleFetchWithMetadata for the fetch, or nil if it was
+// scheduled by ScheduleFetch.
+type ProcessFunc func(ctx context.Context, modulePath, version string, metadata interface{},
+	proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB) (int, error)
+
+type numPackagesKey struct{}
+
+// ReportNumPackages records n as the number of packages found by the fetch
+// of an InMemory queue whose ProcessFunc was passed ctx, for TaskResult and
+// ScheduleFetchAndWait. It does nothing if ctx is not the context of such a
+// fetch.
+func ReportNumPackages(ctx context.Context, n int) {
+	if p, ok := ctx.Value(numPackagesKey{}).(*int); ok {
+		*p = n
+	}
 }
 
 // NewInMemory creates a new InMemory that asynchronously fetches
 // from proxyClient and stores in db. It uses workerCount parallelism to
-// execute these fetches.
+// execute these fetches. The queue stops dispatching fetches when ctx is
+// done; see Restart. opts may be nil.
+//
+// processFunc does not receive the metadata of fetches; use
+// NewInMemoryWithMetadata for that.
 func NewInMemory(ctx context.Context, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, workerCount int,
-	processFunc func(context.Context, string, string, *proxy.Client, *source.Client, *postgres.DB) (int, error), experiments *experiment.Set) *InMemory {
+	processFunc func(context.Context, string, string, *proxy.Client, *source.Client, *postgres.DB) (int, error), experiments *experiment.Set,
+	opts *InMemoryOptions) *InMemory {
+	pf := func(ctx context.Context, modulePath, version string, _ interface{}, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB) (int, error) {
+		return processFunc(ctx, modulePath, version, proxyClient, sourceClient, db)
+	}
+	return NewInMemoryWithMetadata(ctx, proxyClient, sourceClient, db, workerCount, pf, experiments, opts)
+}
+
+// NewInMemoryWithMetadata is like NewInMemory, but processFunc also receives
+// the metadata of each fetch, so that it can behave differently for
+// different fetches.
+func NewInMemoryWithMetadata(ctx context.Context, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, workerCount int,
+	processFunc ProcessFunc, experiments *experiment.Set, opts *InMemoryOptions) *InMemory {
+	if opts == nil {
+		opts = &InMemoryOptions{}
+	}
 	q := &InMemory{
 		proxyClient:  proxyClient,
 		sourceClient: sourceClient,
 		db:           db,
+		processFunc:  processFunc,
 		queue:        make(chan moduleVersion, 1000),
-		sem:          make(chan struct{}, workerCount),
+		sequential:   opts.Sequential,
+		softTimeout:  opts.SoftTimeout,
+		stallTimeout: opts.StallTimeout,
 		experiments:  experiments,
+		observer:     opts.Observer,
+		results:      opts.Results,
+		logSlowFetch: logSlowFetch,
+		completions:  newThroughputMeter(throughputWindow, time.Now),
 	}
-	go q.process(ctx, processFunc)
+	if q.stallTimeout <= 0 {
+		q.stallTimeout = DefaultStallTimeout
+	}
+	if opts.Sequential {
+		workerCount = 1
+	} else if opts.Adaptive != nil {
+		q.limiter = newAdaptiveLimiter(opts.Adaptive, workerCount)
+		workerCount = q.limiter.max
+	}
+	q.sem = make(chan struct{}, workerCount)
+	q.start(ctx)
 	return q
 }
 
-func (q *InMemory) process(ctx context.Context, processFunc func(context.Context, string, string, *proxy.Client, *source.Client, *postgres.DB) (int, error)) {
+// Restart starts a new dispatcher that runs until ctx is done, after the
+// previous one has stopped. Tasks that were queued but not started by the
+// previous dispatcher are processed first. Fetches started by the previous
+// dispatcher are not restarted.
+//
+// It returns an error if the current dispatcher is still running.
+func (q *InMemory) Restart(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-q.stopped:
+	default:
+		return errors.New("queue.Restart: dispatcher is still running")
+	}
+	q.startLocked(ctx)
+	return nil
+}
+
+func (q *InMemory) start(ctx context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.startLocked(ctx)
+}
 
-	for v := range q.queue {
-		select {
-		case <-ctx.Done():
+func (q *InMemory) startLocked(ctx context.Context) {
+	stopped := make(chan struct{})
+	q.stopped = stopped
+	q.lastProgress = time.Now()
+	pending := q.pending
+	q.pending = nil
+	go func() {
+		defer close(stopped)
+		q.process(ctx, pending)
+	}()
+}
+
+// process dispatches tasks from the queue until ctx is done or the queue is
+// closed. If pending is non-nil, it is dispatched first.
+func (q *InMemory) process(ctx context.Context, pending *moduleVersion) {
+	for {
+		var v moduleVersion
+		if pending != nil {
+			v, pending = *pending, nil
+		} else {
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok = <-q.queue:
+				if !ok {
+					return
+				}
+			}
+		}
+		q.recordProgress()
+		if !q.acquireWorker(ctx) {
+			// Keep v so that a restarted dispatcher can process it.
+			q.mu.Lock()
+			q.pending = &v
+			q.mu.Unlock()
 			return
-		case q.sem <- struct{}{}:
 		}
 
+		if q.sequential {
+			q.fetch(ctx, v)
+			continue
+		}
 		// If a worker is available, make a request to the fetch service inside a
 		// goroutine and wait for it to finish.
-		go func(v moduleVersion) {
-			defer func() { <-q.sem }()
+		go q.fetch(ctx, v)
+	}
+}
 
-			log.Infof(ctx, "Fetch requested: %q %q (workerCount = %d)", v.modulePath, v.version, cap(q.sem))
+// fetch processes v on a worker reserved by acquireWorker, and then frees
+// the worker.
+func (q *InMemory) fetch(ctx context.Context, v moduleVersion) {
+	defer func() {
+		q.completions.record()
+		q.recordProgress()
+		<-q.sem
+	}()
 
-			fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-			fetchCtx = experiment.NewContext(fetchCtx, q.experiments)
-			defer cancel()
+	workerCount := cap(q.sem)
+	if q.limiter != nil {
+		workerCount = q.limiter.currentLimit()
+	}
+	log.Infof(ctx, "Fetch requested: %q %q (workerCount = %d)", v.modulePath, v.version, workerCount)
 
-			if _, err := processFunc(fetchCtx, v.modulePath, v.version, q.proxyClient, q.sourceClient, q.db); err != nil {
-				log.Error(fetchCtx, err)
-			}
-		}(v)
+	var numPackages int
+	fetchCtx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	fetchCtx = experiment.NewContext(fetchCtx, q.experiments)
+	fetchCtx = context.WithValue(fetchCtx, numPackagesKey{}, &numPackages)
+	defer cancel()
+
+	// fetchCtx must not be reassigned below, since the soft-timeout callback
+	// reads it on another goroutine.
+	if q.softTimeout > 0 {
+		start := time.Now()
+		timer := time.AfterFunc(q.softTimeout, func() {
+			q.logSlowFetch(fetchCtx, v, time.Since(start))
+		})
+		defer timer.Stop()
+	}
+
+	start := time.Now()
+	code, err := q.processFunc(fetchCtx, v.modulePath, v.version, v.metadata, q.proxyClient, q.sourceClient, q.db)
+	duration := time.Since(start)
+	if err != nil {
+		log.Error(fetchCtx, err)
+	}
+	if q.limiter != nil {
+		q.limiter.release(ctx, code)
+	}
+	result := TaskResult{ModulePath: v.modulePath, Version: v.version, Code: code, Err: err, NumPackages: numPackages, Duration: duration}
+	if v.done != nil {
+		v.done <- result
+	}
+	if q.results != nil {
+		select {
+		case q.results <- result:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// recordProgress records that a task was dequeued or finished.
+func (q *InMemory) recordProgress() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastProgress = time.Now()
+}
+
+// Healthy reports whether the queue's dispatcher is running and tasks are
+// making progress. The queue is stalled if tasks are waiting to be dispatched
+// but no task has been dequeued or finished for the stall timeout; see
+// InMemoryOptions.StallTimeout. An idle queue is healthy.
+//
+// If the queue is not healthy, the error wraps ErrStopped if the dispatcher
+// has stopped, and ErrStalled if the queue is stalled.
+func (q *InMemory) Healthy() (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-q.stopped:
+		return false, fmt.Errorf("queue.Healthy: dispatcher is not running: %w", ErrStopped)
+	default:
+	}
+	waiting := len(q.queue)
+	if q.pending != nil {
+		waiting++
+	}
+	if idle := time.Since(q.lastProgress); waiting > 0 && idle > q.stallTimeout {
+		return false, fmt.Errorf("queue.Healthy: no progress for %s with %d tasks waiting: %w",
+			idle.Round(time.Second), waiting, ErrStalled)
+	}
+	return true, nil
+}
+
+// logSlowFetch logs that the fetch of v has been running for elapsed.
+func logSlowFetch(ctx context.Context, v moduleVersion, elapsed time.Duration) {
+	log.Infof(ctx, "Fetch of %q %q has been running for %s (hard limit %s)",
+		v.modulePath, v.version, elapsed.Round(time.Second), FetchTimeout)
+}
+
+// acquireWorker blocks until a worker is available and reserves it. It
+// returns false without doing so if ctx is done first.
+func (q *InMemory) acquireWorker(ctx context.Context) bool {
+	if q.limiter != nil && !q.limiter.acquire(ctx) {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		if q.limiter != nil {
+			q.limiter.abandon()
+		}
+		return false
+	case q.sem <- struct{}{}:
+		return true
 	}
 }
 
 // ScheduleFetch pushes a fetch task into the local queue to be processed
-// asynchronously.
-func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
-	q.queue <- moduleVersion{modulePath, version}
-	return nil
+// asynchronously. It returns an error wrapping ErrStopped if the queue's
+// dispatcher has stopped, including while waiting for room in the queue.
+func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	return q.schedule(ctx, moduleVersion{modulePath: modulePath, version: version})
+}
+
+// ScheduleFetchWithMetadata is like ScheduleFetch, but also attaches metadata
+// to the fetch, which is passed to the ProcessFunc of a queue created by
+// NewInMemoryWithMetadata. The queue does not use metadata itself; it can be
+// anything, such as who requested the fetch or its priority.
+func (q *InMemory) ScheduleFetchWithMetadata(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration, metadata interface{}) (err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetchWithMetadata(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	return q.schedule(ctx, moduleVersion{modulePath: modulePath, version: version, metadata: metadata})
+}
+
+// ScheduleFetchAndWait pushes a fetch task into the local queue, like
+// ScheduleFetch, and waits for that fetch to finish. It returns the number of
+// packages that the queue's ProcessFunc reported with ReportNumPackages, and
+// the error it returned.
+//
+// If ctx is done before the fetch finishes, ScheduleFetchAndWait returns
+// ctx.Err(), but the fetch is not cancelled. If the queue's dispatcher stops
+// first, it returns an error wrapping ErrStopped.
+func (q *InMemory) ScheduleFetchAndWait(ctx context.Context, modulePath, version string) (numPackages int, err error) {
+	defer derrors.Wrap(&err, "queue.ScheduleFetchAndWait(%q, %q)", modulePath, version)
+	done := make(chan TaskResult, 1)
+	if err := q.schedule(ctx, moduleVersion{modulePath: modulePath, version: version, done: done}); err != nil {
+		return 0, err
+	}
+	q.mu.Lock()
+	stopped := q.stopped
+	q.mu.Unlock()
+	select {
+	case r := <-done:
+		return r.NumPackages, r.Err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-stopped:
+		// The fetch may have finished just before the dispatcher stopped.
+		select {
+		case r := <-done:
+			return r.NumPackages, r.Err
+		default:
+			return 0, ErrStopped
+		}
+	}
+}
+
+// schedule pushes v into the local queue.
+func (q *InMemory) schedule(ctx context.Context, v moduleVersion) (err error) {
+	defer func() {
+		result := EnqueueNew
+		if err != nil {
+			result = EnqueueError
+		}
+		observe(ctx, q.observer, v.modulePath, v.version, result)
+	}()
+	q.mu.Lock()
+	stopped := q.stopped
+	q.mu.Unlock()
+	select {
+	case <-stopped:
+		return ErrStopped
+	default:
+	}
+	select {
+	case <-stopped:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	case q.queue <- v:
+		return nil
+	}
 }
 
 // WaitForTesting waits for all queued requests to finish. It should only be
 // used by test code.
-func (q InMemory) WaitForTesting(ctx context.Context) {
+func (q *InMemory) WaitForTesting(ctx context.Context) {
 	for i := 0; i < cap(q.sem); i++ {
 		select {
 		case <-ctx.Done():
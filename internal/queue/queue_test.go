@@ -5,8 +5,34 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/google/go-cmp/cmp"
+	gax "github.com/googleapis/gax-go/v2"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	"google.golang.org/api/option"
+	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestNewTaskID(t *testing.T) {
@@ -28,3 +54,1371 @@ func TestNewTaskID(t *testing.T) {
 		t.Error("wanted different task ID, got same")
 	}
 }
+
+type fakeQueue struct {
+	scheduled []string
+	err       error // if non-nil, returned by ScheduleFetch instead of scheduling
+}
+
+func (q *fakeQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.scheduled = append(q.scheduled, modulePath+"@"+version)
+	return nil
+}
+
+// latestDataSource is an internal.DataSource whose only implemented method is
+// GetModuleInfo, which reports latest as the latest version of every module.
+type latestDataSource struct {
+	internal.DataSource
+	latest string
+}
+
+func (ds latestDataSource) GetModuleInfo(ctx context.Context, modulePath, version string) (*internal.LegacyModuleInfo, error) {
+	if ds.latest == "" {
+		return nil, derrors.NotFound
+	}
+	return &internal.LegacyModuleInfo{ModuleInfo: internal.ModuleInfo{ModulePath: modulePath, Version: ds.latest}}, nil
+}
+
+func TestScheduleFetchIfNewer(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name, stored, version string
+		want                  bool
+	}{
+		{"not stored", "", "v1.0.0", true},
+		{"newer", "v1.0.0", "v1.1.0", true},
+		{"same", "v1.1.0", "v1.1.0", false},
+		{"older", "v1.1.0", "v1.0.0", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := &fakeQueue{}
+			got, err := ScheduleFetchIfNewer(ctx, q, latestDataSource{latest: test.stored}, "m.com", test.version, "", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got scheduled = %t, want %t", got, test.want)
+			}
+			if got != (len(q.scheduled) == 1) {
+				t.Errorf("scheduled %v, but ScheduleFetchIfNewer returned %t", q.scheduled, got)
+			}
+		})
+	}
+}
+
+// importedByCounts is an ImportedByCounter that reports counts from a map.
+type importedByCounts map[string]int
+
+func (c importedByCounts) GetImportedByCount(ctx context.Context, modulePath string) (int, error) {
+	n, ok := c[modulePath]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", modulePath, derrors.NotFound)
+	}
+	return n, nil
+}
+
+func TestScheduleFetchesByImportedBy(t *testing.T) {
+	ctx := context.Background()
+	mvs := []*internal.ModuleVersionState{
+		{ModulePath: "rare.com", Version: "v1.0.0"},
+		{ModulePath: "popular.com", Version: "v1.0.0"},
+		{ModulePath: "tie1.com", Version: "v1.0.0"},
+		{ModulePath: "popular.com", Version: "v1.1.0"},
+		{ModulePath: "tie2.com", Version: "v1.0.0"},
+	}
+	counts := importedByCounts{"rare.com": 0, "popular.com": 100, "tie1.com": 5, "tie2.com": 5}
+
+	q := &fakeQueue{}
+	if err := ScheduleFetchesByImportedBy(ctx, q, counts, mvs, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"popular.com@v1.0.0", "popular.com@v1.1.0", "tie1.com@v1.0.0", "tie2.com@v1.0.0", "rare.com@v1.0.0"}
+	if diff := cmp.Diff(want, q.scheduled); diff != "" {
+		t.Errorf("scheduled mismatch (-want +got):\n%s", diff)
+	}
+	if mvs[0].ModulePath != "rare.com" {
+		t.Error("ScheduleFetchesByImportedBy reordered its argument")
+	}
+
+	// A failed count lookup schedules nothing.
+	q = &fakeQueue{}
+	delete(counts, "tie2.com")
+	if err := ScheduleFetchesByImportedBy(ctx, q, counts, mvs, "", time.Hour); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("got error %v, want %v", err, derrors.NotFound)
+	}
+	if len(q.scheduled) != 0 {
+		t.Errorf("scheduled %v after failed lookup, want none", q.scheduled)
+	}
+}
+
+// failingQueue is a Queue that fails to schedule the versions in fail.
+type failingQueue struct {
+	fakeQueue
+	fail map[string]bool
+}
+
+func (q *failingQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	if q.fail[version] {
+		return fmt.Errorf("%s@%s: %w", modulePath, version, derrors.Unknown)
+	}
+	return q.fakeQueue.ScheduleFetch(ctx, modulePath, version, suffix, taskIDChangeInterval)
+}
+
+// countingQueue is a Queue that counts the fetches it schedules, and can be
+// safely used concurrently. Each fetch takes delay.
+type countingQueue struct {
+	delay time.Duration
+	mu    sync.Mutex
+	n     int
+}
+
+func (q *countingQueue) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
+	select {
+	case <-time.After(q.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.n++
+	return nil
+}
+
+func (q *countingQueue) count() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+func TestScheduleFetches(t *testing.T) {
+	mvs := make([]internal.ModVersion, 100)
+	for i := range mvs {
+		mvs[i] = internal.ModVersion{Path: fmt.Sprintf("m%d.com", i), Version: "v1.0.0"}
+	}
+
+	t.Run("all", func(t *testing.T) {
+		q := &countingQueue{}
+		n, err := ScheduleFetches(context.Background(), q, mvs, "", time.Hour, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(mvs) || q.count() != len(mvs) {
+			t.Errorf("got n = %d, %d scheduled; want %d", n, q.count(), len(mvs))
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		const concurrency = 4
+		mvs := make([]internal.ModVersion, 100000)
+		q := &countingQueue{delay: time.Millisecond}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			for q.count() < 10 {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+		}()
+		start := time.Now()
+		n, err := ScheduleFetches(ctx, q, mvs, "", time.Hour, concurrency)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+		// Draining 100000 fetches at 1ms each would take at least 25s.
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("took %s to stop after cancellation", d)
+		}
+		if n != q.count() {
+			t.Errorf("got n = %d, but %d were scheduled", n, q.count())
+		}
+		if n < 10 || n >= len(mvs) {
+			t.Errorf("got n = %d, want a partial count of at least 10", n)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		q := &fakeQueue{err: errors.New("bad")}
+		n, err := ScheduleFetches(context.Background(), q, mvs, "", time.Hour, 1)
+		if !errors.Is(err, q.err) || n != 0 {
+			t.Errorf("got %d, %v; want 0, %v", n, err, q.err)
+		}
+	})
+}
+
+func TestScheduleAllVersions(t *testing.T) {
+	ctx := context.Background()
+	const modulePath = "github.com/all/versions"
+	var modules []*proxy.TestModule
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		modules = append(modules, &proxy.TestModule{ModulePath: modulePath, Version: v})
+	}
+	proxyClient, teardown := proxy.SetupTestProxy(t, modules)
+	defer teardown()
+
+	q := &fakeQueue{}
+	n, err := ScheduleAllVersions(ctx, q, proxyClient, modulePath, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{modulePath + "@v1.0.0", modulePath + "@v1.1.0", modulePath + "@v1.2.0"}
+	sort.Strings(q.scheduled)
+	if diff := cmp.Diff(want, q.scheduled); diff != "" {
+		t.Errorf("scheduled mismatch (-want +got):\n%s", diff)
+	}
+	if n != len(want) {
+		t.Errorf("got %d scheduled, want %d", n, len(want))
+	}
+
+	// A failure to schedule one version does not stop the others.
+	fq := &failingQueue{fail: map[string]bool{"v1.1.0": true}}
+	n, err = ScheduleAllVersions(ctx, fq, proxyClient, modulePath, "", time.Hour)
+	if !errors.Is(err, derrors.Unknown) {
+		t.Errorf("got error %v, want %v", err, derrors.Unknown)
+	}
+	want = []string{modulePath + "@v1.0.0", modulePath + "@v1.2.0"}
+	sort.Strings(fq.scheduled)
+	if diff := cmp.Diff(want, fq.scheduled); diff != "" {
+		t.Errorf("scheduled with failure mismatch (-want +got):\n%s", diff)
+	}
+	if n != len(want) {
+		t.Errorf("got %d scheduled, want %d", n, len(want))
+	}
+
+	// A module the proxy doesn't know schedules nothing.
+	q = &fakeQueue{}
+	if _, err := ScheduleAllVersions(ctx, q, proxyClient, "github.com/no/such", "", time.Hour); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want %v", err, derrors.NotFound)
+	}
+	if len(q.scheduled) != 0 {
+		t.Errorf("scheduled %v for unknown module, want none", q.scheduled)
+	}
+}
+
+func TestTeeQueue(t *testing.T) {
+	ctx := context.Background()
+	primary, failing, other := &fakeQueue{}, &fakeQueue{err: errors.New("bad")}, &fakeQueue{}
+	q := TeeQueue(primary, failing, other)
+	if err := q.ScheduleFetch(ctx, "a.com", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatalf("got error %v from a failing secondary queue, want nil", err)
+	}
+	want := []string{"a.com@v1.0.0"}
+	for name, fq := range map[string]*fakeQueue{"primary": primary, "other": other} {
+		if diff := cmp.Diff(want, fq.scheduled); diff != "" {
+			t.Errorf("%s queue: scheduled mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+
+	// An error from the primary queue is returned, but the others are still
+	// called.
+	primary.err = errors.New("primary")
+	if err := q.ScheduleFetch(ctx, "b.com", "v1.0.0", "", time.Hour); err != primary.err {
+		t.Errorf("got error %v, want %v", err, primary.err)
+	}
+	want = append(want, "b.com@v1.0.0")
+	if diff := cmp.Diff(want, other.scheduled); diff != "" {
+		t.Errorf("other queue after primary failure: scheduled mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewTeeQueue(t *testing.T) {
+	ctx := context.Background()
+	errBad := errors.New("bad")
+	for _, test := range []struct {
+		name       string
+		policy     TeePolicy
+		primaryErr error
+		otherErr   error
+		wantErr    bool
+	}{
+		{"primary only, all succeed", TeePrimaryOnly, nil, nil, false},
+		{"primary only, other fails", TeePrimaryOnly, nil, errBad, false},
+		{"primary only, primary fails", TeePrimaryOnly, errBad, nil, true},
+		{"all, all succeed", TeeAll, nil, nil, false},
+		{"all, other fails", TeeAll, nil, errBad, true},
+		{"all, primary fails", TeeAll, errBad, nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			primary := &fakeQueue{err: test.primaryErr}
+			failing := &fakeQueue{err: test.otherErr}
+			last := &fakeQueue{}
+			q := NewTeeQueue(test.policy, primary, failing, last)
+			err := q.ScheduleFetch(ctx, "a.com", "v1.0.0", "", time.Hour)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if err != nil && !errors.Is(err, errBad) {
+				t.Errorf("got error %v, want it to wrap %v", err, errBad)
+			}
+			// Every queue is tried, whatever the outcome.
+			if diff := cmp.Diff([]string{"a.com@v1.0.0"}, last.scheduled); diff != "" {
+				t.Errorf("last queue: scheduled mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewTaskRequestNameFunc(t *testing.T) {
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	const queueName = "projects/Project/locations/us-central1/queues/queueID"
+
+	readable := func(modulePath, version string, now time.Time) string {
+		r := strings.NewReplacer("/", "_", ".", "_", "+", "_")
+		return r.Replace(modulePath+"-"+version) + "-" + now.Format("20060102T1504")
+	}
+	for _, test := range []struct {
+		name     string
+		nameFunc func(string, string, time.Time) string
+		suffix   string
+		want     string
+	}{
+		{
+			name:     "default",
+			nameFunc: nil,
+			want:     queueName + "/tasks/" + newTaskID("mod.com/a", "v1.2.3", now, time.Hour),
+		},
+		{
+			name:     "custom",
+			nameFunc: readable,
+			want:     queueName + "/tasks/mod_com_a-v1_2_3-20200601T1000",
+		},
+		{
+			name:     "custom with suffix",
+			nameFunc: readable,
+			suffix:   "reprocess",
+			want:     queueName + "/tasks/mod_com_a-v1_2_3-20200601T1000-reprocess",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := NewGCP(cfg, nil, "queueID", &GCPOptions{NameFunc: test.nameFunc})
+			req, err := q.newTaskRequest("mod.com/a", "v1.2.3", test.suffix, "", now, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Task.Name; got != test.want {
+				t.Errorf("got task name %q, want %q", got, test.want)
+			}
+			if got, want := req.Task.GetAppEngineHttpRequest().RelativeUri, "/fetch/mod.com/a/@v/v1.2.3"; got != want {
+				t.Errorf("got URI %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("same name within interval", func(t *testing.T) {
+		q := NewGCP(cfg, nil, "queueID", &GCPOptions{NameFunc: readable})
+		req1, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req2, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now.Add(20*time.Minute), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req1.Task.Name != req2.Task.Name {
+			t.Errorf("got different task names %q and %q, want the same", req1.Task.Name, req2.Task.Name)
+		}
+	})
+
+	for _, test := range []struct {
+		name, id string
+	}{
+		{"empty", ""},
+		{"bad characters", "mod.com/a@v1.2.3"},
+		{"too long", strings.Repeat("a", 501)},
+	} {
+		id := test.id
+		t.Run(test.name, func(t *testing.T) {
+			q := NewGCP(cfg, nil, "queueID", &GCPOptions{
+				NameFunc: func(string, string, time.Time) string { return id },
+			})
+			_, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour)
+			if !errors.Is(err, derrors.InvalidArgument) {
+				t.Errorf("got error %v, want %v", err, derrors.InvalidArgument)
+			}
+		})
+	}
+}
+
+func TestNewTaskRequestIdempotencyKey(t *testing.T) {
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	const queueName = "projects/Project/locations/us-central1/queues/queueID"
+
+	// The key replaces both the default ID and one from NameFunc.
+	q := NewGCP(cfg, nil, "queueID", &GCPOptions{
+		NameFunc: func(string, string, time.Time) string { return "custom" },
+	})
+	for _, test := range []struct {
+		suffix string
+		now    time.Time
+		want   string
+	}{
+		{"", now, queueName + "/tasks/event-123"},
+		// Unlike a derived ID, the key does not change over time.
+		{"", now.Add(24 * time.Hour), queueName + "/tasks/event-123"},
+		{"reprocess", now, queueName + "/tasks/event-123-reprocess"},
+	} {
+		req, err := q.newTaskRequest("mod.com/a", "v1.2.3", test.suffix, "event-123", test.now, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Task.Name; got != test.want {
+			t.Errorf("suffix %q at %s: got task name %q, want %q", test.suffix, test.now, got, test.want)
+		}
+	}
+
+	for _, key := range []string{"event/123", strings.Repeat("a", 501)} {
+		if _, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", key, now, time.Hour); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("key %.20q: got error %v, want %v", key, err, derrors.InvalidArgument)
+		}
+	}
+}
+
+func TestNewTaskRequestTaskTTL(t *testing.T) {
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+
+	q := NewGCP(cfg, nil, "queueID", &GCPOptions{TaskTTL: 2 * time.Hour})
+	req, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotSchedule, err := ptypes.Timestamp(req.Task.ScheduleTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotSchedule.Equal(now) {
+		t.Errorf("got schedule time %s, want %s", gotSchedule, now)
+	}
+	headers := req.Task.GetAppEngineHttpRequest().Headers
+	if got, want := headers[TaskExpiresHeader], "2020-06-01T12:30:00Z"; got != want {
+		t.Errorf("got %s header %q, want %q", TaskExpiresHeader, got, want)
+	}
+
+	// Without a TTL, neither field is set.
+	q = NewGCP(cfg, nil, "queueID", nil)
+	req, err = q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Task.ScheduleTime != nil || req.Task.GetAppEngineHttpRequest().Headers != nil {
+		t.Errorf("got schedule time %v and headers %v, want neither", req.Task.ScheduleTime, req.Task.GetAppEngineHttpRequest().Headers)
+	}
+}
+
+func TestTaskExpired(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"2020-06-01T10:00:00Z", true},
+		{"2020-06-01T11:00:00Z", false},
+		{"yesterday", false},
+	} {
+		r, err := http.NewRequest(http.MethodPost, "/fetch/mod.com/@v/v1.0.0", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.header != "" {
+			r.Header.Set(TaskExpiresHeader, test.header)
+		}
+		if got := TaskExpired(r, now); got != test.want {
+			t.Errorf("TaskExpired with header %q = %t, want %t", test.header, got, test.want)
+		}
+	}
+}
+
+func TestNewTaskRequestTaskIDWindow(t *testing.T) {
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	const window = 24 * time.Hour
+	q := NewGCP(cfg, nil, "queueID", &GCPOptions{TaskIDWindow: window})
+	name := func(modulePath string, now time.Time) string {
+		t.Helper()
+		// The task ID change interval is ignored.
+		req, err := q.newTaskRequest(modulePath, "v1.2.3", "", "", now, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req.Task.Name
+	}
+
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	start := taskIDWindowStart("mod.com/a", "v1.2.3", now, window)
+	if start.After(now) || !now.Before(start.Add(window)) {
+		t.Fatalf("window [%s, %s) does not contain %s", start, start.Add(window), now)
+	}
+	first := name("mod.com/a", start)
+	for _, tm := range []time.Time{now, start.Add(window - 1)} {
+		if got := name("mod.com/a", tm); got != first {
+			t.Errorf("%s: got task name %q, want %q", tm, got, first)
+		}
+	}
+	for _, tm := range []time.Time{start.Add(-1), start.Add(window)} {
+		if got := name("mod.com/a", tm); got == first {
+			t.Errorf("%s: got task name %q in another window", tm, got)
+		}
+	}
+
+	// The windows of different module versions start at different times.
+	if other := taskIDWindowStart("mod.com/b", "v1.2.3", now, window); other.Equal(start) {
+		t.Errorf("mod.com/a and mod.com/b have windows starting at %s", start)
+	}
+}
+
+func TestNewTaskRequestQueueName(t *testing.T) {
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	readable := func(modulePath, version string, now time.Time) string { return "task" }
+
+	const other = "projects/other-project/locations/europe-west1/queues/fetch-queue"
+	q := NewGCP(cfg, nil, "queueID", &GCPOptions{NameFunc: readable, QueueName: other})
+	req, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Parent != other {
+		t.Errorf("got parent %q, want %q", req.Parent, other)
+	}
+	if want := other + "/tasks/task"; req.Task.Name != want {
+		t.Errorf("got task name %q, want %q", req.Task.Name, want)
+	}
+
+	for _, name := range []string{
+		"fetch-queue",
+		"projects/other-project/queues/fetch-queue",
+		"projects/other-project/locations/europe-west1/queues/fetch_queue",
+		other + "/tasks/task",
+	} {
+		q := NewGCP(cfg, nil, "queueID", &GCPOptions{QueueName: name})
+		if _, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("QueueName %q: got error %v, want %v", name, err, derrors.InvalidArgument)
+		}
+	}
+}
+
+func TestNewTaskRequestDispatchDeadline(t *testing.T) {
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	now := time.Date(2020, 6, 1, 10, 30, 0, 0, time.UTC)
+	// Give modules under big.com a long deadline.
+	deadline := func(modulePath, version string) time.Duration {
+		if strings.HasPrefix(modulePath, "big.com/") {
+			return time.Hour
+		}
+		return 0
+	}
+	for _, test := range []struct {
+		name       string
+		deadline   func(string, string) time.Duration
+		modulePath string
+		want       time.Duration // 0 means no deadline
+	}{
+		{"no option", nil, "big.com/a", 0},
+		{"default deadline", deadline, "small.com/a", 0},
+		{"long deadline", deadline, "big.com/a", time.Hour},
+		{"minimum", func(string, string) time.Duration { return MinDispatchDeadline }, "mod.com/a", MinDispatchDeadline},
+		{"maximum", func(string, string) time.Duration { return MaxDispatchDeadline }, "mod.com/a", MaxDispatchDeadline},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := NewGCP(cfg, nil, "queueID", &GCPOptions{DispatchDeadline: test.deadline})
+			req, err := q.newTaskRequest(test.modulePath, "v1.2.3", "", "", now, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got time.Duration
+			if d := req.Task.DispatchDeadline; d != nil {
+				got, err = ptypes.Duration(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got != test.want {
+				t.Errorf("got dispatch deadline %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	for _, d := range []time.Duration{time.Second, -time.Minute, 25 * time.Hour} {
+		d := d
+		t.Run(d.String(), func(t *testing.T) {
+			q := NewGCP(cfg, nil, "queueID", &GCPOptions{
+				DispatchDeadline: func(string, string) time.Duration { return d },
+			})
+			_, err := q.newTaskRequest("mod.com/a", "v1.2.3", "", "", now, time.Hour)
+			if !errors.Is(err, derrors.InvalidArgument) {
+				t.Errorf("got error %v, want %v", err, derrors.InvalidArgument)
+			}
+		})
+	}
+}
+
+// fakeCloudTasks is an in-process Cloud Tasks server for testing GCP.
+type fakeCloudTasks struct {
+	taskspb.UnimplementedCloudTasksServer
+
+	mu      sync.Mutex
+	queues  map[string]*taskspb.Queue // by full queue name
+	tasks   map[string]bool           // full names of existing tasks
+	deleted []string                  // names passed to DeleteTask
+
+	createErr error // if non-nil, returned by CreateTask
+
+	dispatchCounts map[string]int32    // by full task name, for GetTask
+	views          []taskspb.Task_View // response views of GetTask requests
+
+	listRequests []*taskspb.ListTasksRequest // requests passed to ListTasks
+
+	// timeLeft records, for each call to GetQueue and CreateTask, how long
+	// was left before the deadline of its context, or -1 if it had none.
+	timeLeft []time.Duration
+}
+
+// recordCall records the deadline of ctx and sends a header naming method,
+// so that tests can check that call options reach the RPC. f.mu must be held.
+func (f *fakeCloudTasks) recordCall(ctx context.Context, method string) {
+	left := time.Duration(-1)
+	if d, ok := ctx.Deadline(); ok {
+		left = time.Until(d)
+	}
+	f.timeLeft = append(f.timeLeft, left)
+	grpc.SetHeader(ctx, metadata.Pairs("method", method))
+}
+
+func (f *fakeCloudTasks) GetQueue(ctx context.Context, req *taskspb.GetQueueRequest) (*taskspb.Queue, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recordCall(ctx, "GetQueue")
+	q, ok := f.queues[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "queue %s not found", req.Name)
+	}
+	return q, nil
+}
+
+func (f *fakeCloudTasks) GetTask(ctx context.Context, req *taskspb.GetTaskRequest) (*taskspb.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.views = append(f.views, req.ResponseView)
+	n, ok := f.dispatchCounts[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "task %s not found", req.Name)
+	}
+	return &taskspb.Task{Name: req.Name, DispatchCount: n}, nil
+}
+
+func (f *fakeCloudTasks) CreateTask(ctx context.Context, req *taskspb.CreateTaskRequest) (*taskspb.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recordCall(ctx, "CreateTask")
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	name := req.Task.Name
+	if f.tasks[name] {
+		return nil, status.Errorf(codes.AlreadyExists, "task %s already exists", name)
+	}
+	if f.tasks == nil {
+		f.tasks = map[string]bool{}
+	}
+	f.tasks[name] = true
+	return req.Task, nil
+}
+
+func (f *fakeCloudTasks) DeleteTask(ctx context.Context, req *taskspb.DeleteTaskRequest) (*empty.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, req.Name)
+	if !f.tasks[req.Name] {
+		return nil, status.Errorf(codes.NotFound, "task %s not found", req.Name)
+	}
+	delete(f.tasks, req.Name)
+	return &empty.Empty{}, nil
+}
+
+// newTestGCP starts fake on a local server and returns a GCP queue named
+// queueID that talks to it, along with a function to shut down the server.
+func newTestGCP(t *testing.T, fake *fakeCloudTasks, queueID string, opts *GCPOptions) (*GCP, func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	taskspb.RegisterCloudTasksServer(srv, fake)
+	go srv.Serve(lis)
+
+	ctx := context.Background()
+	client, err := cloudtasks.NewClient(ctx,
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()))
+	if err != nil {
+		srv.Stop()
+		t.Fatal(err)
+	}
+	cfg := &config.Config{ProjectID: "Project", LocationID: "us-central1"}
+	return NewGCP(cfg, client, queueID, opts), func() {
+		client.Close()
+		srv.Stop()
+	}
+}
+
+func TestScheduleFetchObserver(t *testing.T) {
+	ctx := context.Background()
+	counter := &EnqueueCounter{}
+	fake := &fakeCloudTasks{}
+	q, teardown := newTestGCP(t, fake, "queueID", &GCPOptions{Observer: counter})
+	defer teardown()
+
+	// The second fetch gets the same task ID as the first.
+	for i := 0; i < 2; i++ {
+		if err := q.ScheduleFetch(ctx, "mod.com", "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.mu.Lock()
+	fake.createErr = status.Error(codes.PermissionDenied, "denied")
+	fake.mu.Unlock()
+	if err := q.ScheduleFetch(ctx, "other.com", "v1.0.0", "", time.Hour); err == nil {
+		t.Fatal("got nil error, want error")
+	}
+
+	for result, want := range map[EnqueueResult]int{EnqueueNew: 1, EnqueueDuplicate: 1, EnqueueError: 1} {
+		if got := counter.Count(result); got != want {
+			t.Errorf("Count(%s) = %d, want %d", result, got, want)
+		}
+	}
+}
+
+func TestGCPCallOptions(t *testing.T) {
+	const prefix = "projects/Project/locations/us-central1/queues/"
+	fake := &fakeCloudTasks{queues: map[string]*taskspb.Queue{
+		prefix + "queueID": {Name: prefix + "queueID", State: taskspb.Queue_RUNNING},
+	}}
+	// The header call option captures the header the fake sends, which
+	// shows that the queue passed it to the RPC.
+	var header metadata.MD
+	q, teardown := newTestGCP(t, fake, "queueID", &GCPOptions{
+		CallOptions: []gax.CallOption{gax.WithGRPCOptions(grpc.Header(&header))},
+	})
+	defer teardown()
+
+	// The caller's context has no deadline, so any deadline the fake sees
+	// comes from the queue.
+	ctx := context.Background()
+	for _, test := range []struct {
+		method string
+		call   func() error
+	}{
+		{"GetQueue", func() error { return q.VerifyQueue(ctx) }},
+		{"CreateTask", func() error { return q.ScheduleFetch(ctx, "mod.com", "v1.0.0", "", time.Hour) }},
+	} {
+		header = nil
+		if err := test.call(); err != nil {
+			t.Fatalf("%s: %v", test.method, err)
+		}
+		if got := header.Get("method"); len(got) != 1 || got[0] != test.method {
+			t.Errorf("%s: got header %v, want method %q", test.method, header, test.method)
+		}
+		fake.mu.Lock()
+		left := fake.timeLeft[len(fake.timeLeft)-1]
+		fake.mu.Unlock()
+		if left < 0 || left > 30*time.Second {
+			t.Errorf("%s: got %s left before deadline, want at most 30s", test.method, left)
+		}
+	}
+}
+
+func TestVerifyQueue(t *testing.T) {
+	const prefix = "projects/Project/locations/us-central1/queues/"
+	fake := &fakeCloudTasks{queues: map[string]*taskspb.Queue{
+		prefix + "running":  {Name: prefix + "running", State: taskspb.Queue_RUNNING},
+		prefix + "paused":   {Name: prefix + "paused", State: taskspb.Queue_PAUSED},
+		prefix + "disabled": {Name: prefix + "disabled", State: taskspb.Queue_DISABLED},
+	}}
+	ctx := context.Background()
+	for _, test := range []struct {
+		queueID      string
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{queueID: "running"},
+		{queueID: "paused", wantErr: true},
+		{queueID: "disabled", wantErr: true},
+		{queueID: "missing", wantErr: true, wantNotFound: true},
+	} {
+		t.Run(test.queueID, func(t *testing.T) {
+			q, teardown := newTestGCP(t, fake, test.queueID, nil)
+			defer teardown()
+			err := q.VerifyQueue(ctx)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got := errors.Is(err, derrors.NotFound); got != test.wantNotFound {
+				t.Errorf("got error %v; errors.Is(err, derrors.NotFound) = %t, want %t", err, got, test.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestCancelFetch(t *testing.T) {
+	ctx := context.Background()
+	nameFunc := func(modulePath, version string, now time.Time) string {
+		return strings.Replace(modulePath, ".", "_", -1) + "-" + version
+	}
+	const taskPrefix = "projects/Project/locations/us-central1/queues/queueID/tasks/"
+	for _, test := range []struct {
+		name, suffix string
+		tasks        []string
+		wantDeleted  string
+	}{
+		{
+			name:        "pending",
+			tasks:       []string{taskPrefix + "mod_com-v1_2_3"},
+			wantDeleted: taskPrefix + "mod_com-v1_2_3",
+		},
+		{
+			name:        "with suffix",
+			suffix:      "reprocess",
+			tasks:       []string{taskPrefix + "mod_com-v1_2_3-reprocess"},
+			wantDeleted: taskPrefix + "mod_com-v1_2_3-reprocess",
+		},
+		{
+			name:        "not found",
+			wantDeleted: taskPrefix + "mod_com-v1_2_3",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeCloudTasks{tasks: map[string]bool{}}
+			for _, name := range test.tasks {
+				fake.tasks[name] = true
+			}
+			q, teardown := newTestGCP(t, fake, "queueID", &GCPOptions{NameFunc: nameFunc})
+			defer teardown()
+			if err := q.CancelFetch(ctx, "mod.com", "v1_2_3", test.suffix, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if len(fake.deleted) != 1 || fake.deleted[0] != test.wantDeleted {
+				t.Errorf("DeleteTask called with %q, want [%q]", fake.deleted, test.wantDeleted)
+			}
+			if len(fake.tasks) != 0 {
+				t.Errorf("tasks remaining after CancelFetch: %v", fake.tasks)
+			}
+		})
+	}
+
+	t.Run("default name", func(t *testing.T) {
+		fake := &fakeCloudTasks{}
+		q, teardown := newTestGCP(t, fake, "queueID", nil)
+		defer teardown()
+		before := time.Now()
+		if err := q.CancelFetch(ctx, "mod.com", "v1.2.3", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		after := time.Now()
+		// The hour may have changed between before and after.
+		want1 := taskPrefix + newTaskID("mod.com", "v1.2.3", before, time.Hour)
+		want2 := taskPrefix + newTaskID("mod.com", "v1.2.3", after, time.Hour)
+		if len(fake.deleted) != 1 || (fake.deleted[0] != want1 && fake.deleted[0] != want2) {
+			t.Errorf("DeleteTask called with %q, want [%q]", fake.deleted, want1)
+		}
+	})
+}
+
+func TestInMemoryRestart(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed []string
+		started   = make(chan struct{})
+		unblock   = make(chan struct{})
+		done      = make(chan struct{})
+	)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		if modulePath == "blocking.com" {
+			close(started)
+			<-unblock
+		}
+		mu.Lock()
+		processed = append(processed, modulePath)
+		mu.Unlock()
+		done <- struct{}{}
+		return 200, nil
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	q := NewInMemory(ctx1, nil, nil, nil, 1, processFunc, nil, nil)
+	// The first task occupies the only worker, so the second is dequeued but
+	// not started when the dispatcher stops.
+	for _, m := range []string{"blocking.com", "waiting.com"} {
+		if err := q.ScheduleFetch(ctx1, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	cancel1()
+	<-q.stopped
+
+	ctx := context.Background()
+	if err := q.ScheduleFetch(ctx, "rejected.com", "v1.0.0", "", time.Hour); !errors.Is(err, ErrStopped) {
+		t.Fatalf("ScheduleFetch after stop: got error %v, want %v", err, ErrStopped)
+	}
+	close(unblock)
+	if err := q.Restart(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Restart(ctx); err == nil {
+		t.Error("Restart while running: got nil error, want error")
+	}
+	if err := q.ScheduleFetch(ctx, "after.com", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for fetches")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"blocking.com", "waiting.com", "after.com"}
+	if !cmp.Equal(processed, want) {
+		t.Errorf("processed %v, want %v", processed, want)
+	}
+}
+
+func TestInMemorySequential(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		got  []string
+		done = make(chan struct{})
+	)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		// Since processing is sequential, all earlier tasks have finished
+		// when the "done" task runs.
+		if modulePath == "done" {
+			close(done)
+			return 200, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, modulePath)
+		return 200, nil
+	}
+	// workerCount would allow concurrent fetches, but Sequential overrides it.
+	q := NewInMemory(ctx, nil, nil, nil, 10, processFunc, nil, &InMemoryOptions{Sequential: true})
+	want := []string{"a.com", "b.com", "c.com"}
+	for _, m := range append(want, "done") {
+		if err := q.ScheduleFetch(ctx, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fetches")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !cmp.Equal(got, want) {
+		t.Errorf("processed %v, want %v", got, want)
+	}
+}
+
+func TestInMemoryMetadata(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		got  = map[string]interface{}{}
+		done = make(chan struct{})
+	)
+	processFunc := func(ctx context.Context, modulePath, version string, metadata interface{}, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		if modulePath == "done" {
+			close(done)
+			return 200, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got[modulePath] = metadata
+		return 200, nil
+	}
+	q := NewInMemoryWithMetadata(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{Sequential: true})
+	if err := q.ScheduleFetchWithMetadata(ctx, "a.com", "v1.0.0", "", time.Hour, "frontend"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.ScheduleFetch(ctx, "b.com", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.ScheduleFetch(ctx, "done", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fetches")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]interface{}{"a.com": "frontend", "b.com": nil}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("metadata mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestInMemoryObserver(t *testing.T) {
+	counter := &EnqueueCounter{}
+	done := make(chan struct{})
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		if modulePath == "done" {
+			close(done)
+		}
+		return 200, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := NewInMemory(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{Observer: counter})
+	// The queue does not de-duplicate, so both fetches are new.
+	for _, m := range []string{"mod.com", "mod.com", "done"} {
+		if err := q.ScheduleFetch(ctx, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fetches")
+	}
+	cancel()
+	<-q.stopped
+	if err := q.ScheduleFetch(context.Background(), "stopped.com", "v1.0.0", "", time.Hour); !errors.Is(err, ErrStopped) {
+		t.Fatalf("got error %v, want %v", err, ErrStopped)
+	}
+
+	for result, want := range map[EnqueueResult]int{EnqueueNew: 3, EnqueueDuplicate: 0, EnqueueError: 1} {
+		if got := counter.Count(result); got != want {
+			t.Errorf("Count(%s) = %d, want %d", result, got, want)
+		}
+	}
+}
+
+func TestInMemoryResults(t *testing.T) {
+	errBad := errors.New("bad module")
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		if modulePath == "slow.com" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if modulePath == "bad.com" {
+			return 490, errBad
+		}
+		return 200, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The channel is unbuffered, so each worker waits for its result to be
+	// received.
+	results := make(chan TaskResult)
+	q := NewInMemory(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{Sequential: true, Results: results})
+	mods := []string{"a.com", "slow.com", "bad.com", "b.com"}
+	for _, m := range mods {
+		if err := q.ScheduleFetch(ctx, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, m := range mods {
+		var r TaskResult
+		select {
+		case r = <-results:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for result %d", i)
+		}
+		if r.ModulePath != m || r.Version != "v1.0.0" {
+			t.Errorf("result %d: got %s@%s, want %s@v1.0.0", i, r.ModulePath, r.Version, m)
+		}
+		var wantErr error
+		if m == "bad.com" {
+			wantErr = errBad
+		}
+		if r.Err != wantErr {
+			t.Errorf("result %d: got error %v, want %v", i, r.Err, wantErr)
+		}
+		if m == "slow.com" && r.Duration < 10*time.Millisecond {
+			t.Errorf("result %d: got duration %s, want at least 10ms", i, r.Duration)
+		}
+	}
+}
+
+func TestInMemoryScheduleFetchAndWait(t *testing.T) {
+	errBad := errors.New("bad module")
+	unblock := make(chan struct{})
+	defer close(unblock)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		switch modulePath {
+		case "blocking.com":
+			<-unblock
+		case "bad.com":
+			return 490, errBad
+		}
+		ReportNumPackages(ctx, 3)
+		return 200, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewInMemory(ctx, nil, nil, nil, 2, processFunc, nil, nil)
+
+	// Waiting for a fetch does not wait for others that are still running.
+	if err := q.ScheduleFetch(ctx, "blocking.com", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	n, err := q.ScheduleFetchAndWait(ctx, "a.com", "v1.0.0")
+	if n != 3 || err != nil {
+		t.Errorf("a.com: got (%d, %v), want (3, nil)", n, err)
+	}
+	n, err = q.ScheduleFetchAndWait(ctx, "bad.com", "v1.0.0")
+	if n != 0 || !errors.Is(err, errBad) {
+		t.Errorf("bad.com: got (%d, %v), want (0, %v)", n, err, errBad)
+	}
+
+	// Waiting stops when ctx is done.
+	waitCtx, waitCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer waitCancel()
+	if _, err := q.ScheduleFetchAndWait(waitCtx, "blocking.com", "v1.1.0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("blocking.com: got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestInMemoryScheduleFetchAndWaitStopped(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		<-unblock
+		return 200, nil
+	}
+	qctx, qcancel := context.WithCancel(context.Background())
+	q := NewInMemory(qctx, nil, nil, nil, 1, processFunc, nil, nil)
+	// The only worker is busy, so the next fetch waits in the queue.
+	if err := q.ScheduleFetch(qctx, "blocking.com", "v1.0.0", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := q.ScheduleFetchAndWait(ctx, "a.com", "v1.0.0")
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	qcancel()
+	if err := <-errc; !errors.Is(err, ErrStopped) {
+		t.Errorf("got error %v, want %v", err, ErrStopped)
+	}
+}
+
+func TestGCPScheduleFetchAndWait(t *testing.T) {
+	if _, err := (&GCP{}).ScheduleFetchAndWait(context.Background(), "a.com", "v1.0.0"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got error %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestInMemoryHealthy(t *testing.T) {
+	const stallTimeout = 50 * time.Millisecond
+	var (
+		started = make(chan struct{})
+		unblock = make(chan struct{})
+		done    = make(chan struct{})
+	)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		switch modulePath {
+		case "blocking.com":
+			close(started)
+			<-unblock
+		case "waiting.com":
+			close(done)
+		}
+		return 200, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewInMemory(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{
+		Sequential:   true,
+		StallTimeout: stallTimeout,
+	})
+	check := func(when string, wantErr error) {
+		t.Helper()
+		ok, err := q.Healthy()
+		if wantErr == nil {
+			if !ok || err != nil {
+				t.Errorf("%s: Healthy() = %t, %v; want true, nil", when, ok, err)
+			}
+			return
+		}
+		if ok || !errors.Is(err, wantErr) {
+			t.Errorf("%s: Healthy() = %t, %v; want false, %v", when, ok, err, wantErr)
+		}
+	}
+
+	// An idle queue is healthy, however long it has been idle.
+	time.Sleep(2 * stallTimeout)
+	check("idle", nil)
+
+	// The blocking fetch keeps the only worker busy, so the second task waits.
+	for _, m := range []string{"blocking.com", "waiting.com"} {
+		if err := q.ScheduleFetch(ctx, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	time.Sleep(2 * stallTimeout)
+	check("stalled", ErrStalled)
+
+	close(unblock)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fetches")
+	}
+	check("after progress", nil)
+
+	cancel()
+	<-q.stopped
+	check("stopped", ErrStopped)
+}
+
+func TestInMemorySoftTimeout(t *testing.T) {
+	ctx := context.Background()
+	const softTimeout = 20 * time.Millisecond
+	var (
+		mu     sync.Mutex
+		logged []string
+		slow   = make(chan struct{})
+		done   = make(chan struct{})
+	)
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		switch modulePath {
+		case "slow.com":
+			// Keep running past the soft timeout; the fetch is not cancelled.
+			select {
+			case <-slow:
+			case <-ctx.Done():
+				t.Errorf("slow fetch cancelled: %v", ctx.Err())
+			}
+			time.Sleep(2 * softTimeout)
+		case "done":
+			close(done)
+		}
+		return 200, nil
+	}
+	q := NewInMemory(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{
+		Sequential:  true,
+		SoftTimeout: softTimeout,
+	})
+	q.logSlowFetch = func(_ context.Context, v moduleVersion, elapsed time.Duration) {
+		if elapsed < softTimeout {
+			t.Errorf("%s logged after %s, before the soft timeout", v.modulePath, elapsed)
+		}
+		mu.Lock()
+		logged = append(logged, v.modulePath)
+		mu.Unlock()
+		if v.modulePath == "slow.com" {
+			close(slow)
+		}
+	}
+	for _, m := range []string{"fast.com", "slow.com", "done"} {
+		if err := q.ScheduleFetch(ctx, m, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for fetches")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"slow.com"}; !cmp.Equal(logged, want) {
+		t.Errorf("logged %v, want %v", logged, want)
+	}
+}
+
+// TestInMemorySoftTimeoutNumPackages checks that a fetch that passes its soft
+// timeout still reports its package count. Run it with -race: the
+// soft-timeout callback reads the fetch's context while the fetch runs.
+func TestInMemorySoftTimeoutNumPackages(t *testing.T) {
+	ctx := context.Background()
+	const softTimeout = 10 * time.Millisecond
+	logged := make(chan struct{})
+	processFunc := func(ctx context.Context, modulePath, version string, _ *proxy.Client, _ *source.Client, _ *postgres.DB) (int, error) {
+		<-logged
+		ReportNumPackages(ctx, 2)
+		return 200, nil
+	}
+	q := NewInMemory(ctx, nil, nil, nil, 1, processFunc, nil, &InMemoryOptions{SoftTimeout: softTimeout})
+	q.logSlowFetch = func(ctx context.Context, v moduleVersion, _ time.Duration) {
+		if err := ctx.Err(); err != nil {
+			t.Errorf("%s: fetch context done at the soft timeout: %v", v.modulePath, err)
+		}
+		close(logged)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	n, err := q.ScheduleFetchAndWait(ctx, "slow.com", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d packages, want 2", n)
+	}
+}
+
+func TestGetTaskDispatchCount(t *testing.T) {
+	ctx := context.Background()
+	nameFunc := func(modulePath, version string, now time.Time) string {
+		return strings.Replace(modulePath, ".", "_", -1) + "-" + version
+	}
+	const taskPrefix = "projects/Project/locations/us-central1/queues/queueID/tasks/"
+	fake := &fakeCloudTasks{dispatchCounts: map[string]int32{
+		taskPrefix + "mod_com-v1":           3,
+		taskPrefix + "mod_com-v1-reprocess": 1,
+	}}
+	q, teardown := newTestGCP(t, fake, "queueID", &GCPOptions{NameFunc: nameFunc})
+	defer teardown()
+
+	for _, test := range []struct {
+		version, suffix string
+		want            int
+	}{
+		{"v1", "", 3},
+		{"v1", "reprocess", 1},
+		{"v2", "", 0}, // not found
+	} {
+		got, err := q.GetTaskDispatchCount(ctx, "mod.com", test.version, test.suffix, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GetTaskDispatchCount(%q, %q) = %d, want %d", test.version, test.suffix, got, test.want)
+		}
+	}
+}
+
+func TestGCPResponseView(t *testing.T) {
+	ctx := context.Background()
+	for _, view := range []taskspb.Task_View{taskspb.Task_VIEW_UNSPECIFIED, taskspb.Task_FULL} {
+		fake := &fakeCloudTasks{}
+		q, teardown := newTestGCP(t, fake, "queueID", &GCPOptions{ResponseView: view})
+		if _, err := q.GetTaskDispatchCount(ctx, "mod.com", "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		teardown()
+		if diff := cmp.Diff([]taskspb.Task_View{view}, fake.views); diff != "" {
+			t.Errorf("view %s: GetTask response views mismatch (-want +got):\n%s", view, diff)
+		}
+	}
+}
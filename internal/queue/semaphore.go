@@ -0,0 +1,104 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// ErrNoSlot is returned by RedisSemaphore.Acquire when all slots are taken.
+var ErrNoSlot = errors.New("no semaphore slot available")
+
+// A RedisSemaphore is a counting semaphore shared by every process that uses
+// the same Redis key. The worker uses it to cap the number of fetches in
+// flight across all of its instances, which a per-process limit cannot do.
+//
+// Each slot is held for at most a fixed TTL. If a process crashes without
+// releasing its slots, they become available again once the TTL passes, so
+// the TTL should be longer than the longest fetch.
+//
+// Slot expiry is based on the clocks of the processes sharing the semaphore,
+// so those clocks should agree to well within the TTL.
+type RedisSemaphore struct {
+	client *redis.Client
+	key    string
+	limit  int
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewRedisSemaphore returns a RedisSemaphore with limit slots, each held for at
+// most ttl, stored under key in the Redis instance of client.
+func NewRedisSemaphore(client *redis.Client, key string, limit int, ttl time.Duration) *RedisSemaphore {
+	return &RedisSemaphore{
+		client: client,
+		key:    key,
+		limit:  limit,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// The semaphore is a sorted set whose members are the tokens of the held
+// slots, scored by the time in milliseconds at which each slot expires.
+//
+// acquireScript removes the expired slots, then adds a slot for the token
+// ARGV[4] that expires at ARGV[2] if fewer than ARGV[3] slots are held. ARGV[1]
+// is the current time. The set itself expires after the TTL, ARGV[5]
+// milliseconds, so that an unused semaphore does not linger. It returns 1 if
+// the slot was added and 0 otherwise.
+var acquireScript = redis.NewScript(`
+local key = KEYS[1]
+redis.call('ZREMRANGEBYSCORE', key, '-inf', ARGV[1])
+if redis.call('ZCARD', key) < tonumber(ARGV[3]) then
+	redis.call('ZADD', key, ARGV[2], ARGV[4])
+	redis.call('PEXPIRE', key, ARGV[5])
+	return 1
+end
+return 0
+`)
+
+// Acquire takes a slot and returns a token identifying it, to be passed to
+// Release. If all slots are taken, it returns an error wrapping ErrNoSlot
+// without waiting.
+func (s *RedisSemaphore) Acquire(ctx context.Context) (token string, err error) {
+	defer derrors.Wrap(&err, "RedisSemaphore.Acquire(%q)", s.key)
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	token = hex.EncodeToString(b[:])
+	now := s.now()
+	n, err := acquireScript.Run(s.client.WithContext(ctx), []string{s.key},
+		millis(now), millis(now.Add(s.ttl)), s.limit, token, s.ttl.Milliseconds()).Int()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", fmt.Errorf("%d slots taken: %w", s.limit, ErrNoSlot)
+	}
+	return token, nil
+}
+
+// Release gives back the slot identified by token. Releasing a slot that has
+// expired, or has already been released, does nothing.
+func (s *RedisSemaphore) Release(ctx context.Context, token string) (err error) {
+	defer derrors.Wrap(&err, "RedisSemaphore.Release(%q)", s.key)
+	return s.client.WithContext(ctx).ZRem(s.key, token).Err()
+}
+
+// millis returns t as the number of milliseconds since the Unix epoch.
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+)
+
+func TestRedisSemaphore(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	// Two semaphores with the same key, as in two worker instances.
+	newSem := func() *RedisSemaphore {
+		s := NewRedisSemaphore(client, "fetches", 2, time.Minute)
+		s.now = func() time.Time { return now }
+		return s
+	}
+	s1, s2 := newSem(), newSem()
+
+	tok1, err := s1.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok2, err := s2.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok1 == tok2 {
+		t.Fatalf("got the same token %q twice", tok1)
+	}
+	if _, err := s1.Acquire(ctx); !errors.Is(err, ErrNoSlot) {
+		t.Fatalf("third Acquire: got error %v, want %v", err, ErrNoSlot)
+	}
+
+	// Releasing a slot in one process frees it for the other.
+	if err := s1.Release(ctx, tok1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Release(ctx, tok1); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+	tok3, err := s2.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s2.Acquire(ctx); !errors.Is(err, ErrNoSlot) {
+		t.Fatalf("Acquire after reacquiring: got error %v, want %v", err, ErrNoSlot)
+	}
+
+	// Slots that are never released expire after the TTL.
+	now = now.Add(time.Minute + time.Second)
+	for i := 0; i < 2; i++ {
+		if _, err := s1.Acquire(ctx); err != nil {
+			t.Fatalf("Acquire #%d after TTL: %v", i, err)
+		}
+	}
+	// Releasing an expired slot does nothing.
+	for _, tok := range []string{tok2, tok3} {
+		if err := s2.Release(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s1.Acquire(ctx); !errors.Is(err, ErrNoSlot) {
+		t.Errorf("Acquire after releasing expired slots: got error %v, want %v", err, ErrNoSlot)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"path"
+	"sort"
+)
+
+// MaxSuggestionDistance is the largest edit distance between a requested path
+// and a path suggested in its place.
+const MaxSuggestionDistance = 3
+
+// SimilarPaths returns at most limit of the candidates that are close to p,
+// ordered from closest to furthest. A candidate is close to p if it can be
+// turned into p with at most MaxSuggestionDistance single-byte insertions,
+// deletions or substitutions, or if it is in the same directory as p or in
+// one of its subdirectories. p itself and duplicate candidates are never
+// returned.
+func SimilarPaths(p string, candidates []string, limit int) []string {
+	type suggestion struct {
+		path     string
+		distance int
+	}
+	dir := path.Dir(p)
+	seen := map[string]bool{p: true}
+	var suggestions []suggestion
+	for _, c := range candidates {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		d := editDistance(p, c)
+		if d <= MaxSuggestionDistance || (dir != "." && len(c) > len(dir) && c[:len(dir)+1] == dir+"/") {
+			suggestions = append(suggestions, suggestion{c, d})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].path < suggestions[j].path
+	})
+	if limit >= 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	paths := []string{}
+	for _, s := range suggestions {
+		paths = append(paths, s.path)
+	}
+	return paths
+}
+
+// editDistance returns the Levenshtein distance between a and b, counted in
+// bytes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
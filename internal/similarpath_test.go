@@ -0,0 +1,61 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSimilarPaths(t *testing.T) {
+	candidates := []string{
+		"github.com/foo/bar",
+		"github.com/foo/bar/baz",
+		"github.com/foo/barn",
+		"github.com/foo/quux",
+		"github.com/other/thing",
+		"golang.org/x/tools",
+	}
+	for _, test := range []struct {
+		path  string
+		limit int
+		want  []string
+	}{
+		{"githubb.com/foo/bar", 10, []string{"github.com/foo/bar", "github.com/foo/barn"}},
+		{"github.com/foo/bra", 10, []string{
+			"github.com/foo/bar",
+			"github.com/foo/barn",
+			"github.com/foo/bar/baz",
+			"github.com/foo/quux",
+		}},
+		{"github.com/foo/bra", 1, []string{"github.com/foo/bar"}},
+		{"github.com/foo/bar", 10, []string{"github.com/foo/barn", "github.com/foo/bar/baz", "github.com/foo/quux"}},
+		{"example.com/nothing", 10, []string{}},
+	} {
+		got := SimilarPaths(test.path, candidates, test.limit)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("SimilarPaths(%q, %d) mismatch (-want +got):\n%s", test.path, test.limit, diff)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"github.com", "githubb.com", 1},
+		{"bar", "bra", 2},
+	} {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
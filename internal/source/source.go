@@ -48,6 +48,9 @@ type Info struct {
 	moduleDir string       // directory of module relative to repo root
 	commit    string       // tag or ID of commit corresponding to version
 	templates urlTemplates // for building URLs
+	// commitHash is the full hash of the commit corresponding to version,
+	// if known.
+	commitHash string
 }
 
 func (i *Info) RepoURL() string {
@@ -57,6 +60,26 @@ func (i *Info) RepoURL() string {
 	return i.repoURL
 }
 
+// CommitHash returns the full hash of the commit corresponding to the module
+// version, or the empty string if it is not known.
+func (i *Info) CommitHash() string {
+	if i == nil {
+		return ""
+	}
+	return i.commitHash
+}
+
+// WithCommitHash returns a copy of i that records hash as the full hash of the
+// commit corresponding to the module version. It returns nil if i is nil.
+func (i *Info) WithCommitHash(hash string) *Info {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	c.commitHash = hash
+	return &c
+}
+
 // ModuleURL returns a URL for the home page of the module.
 func (i *Info) ModuleURL() string {
 	return i.DirectoryURL("")
@@ -149,8 +172,9 @@ type jsonInfo struct {
 	Commit    string
 	// Store common templates efficiently by setting this to a short string
 	// we look up in a map. If Kind != "", then Templates == nil.
-	Kind      string        `json:",omitempty"`
-	Templates *urlTemplates `json:",omitempty"`
+	Kind       string        `json:",omitempty"`
+	Templates  *urlTemplates `json:",omitempty"`
+	CommitHash string        `json:",omitempty"`
 }
 
 // ToJSONForDB returns the Info encoded for storage in the database.
@@ -158,9 +182,10 @@ func (i *Info) MarshalJSON() (_ []byte, err error) {
 	defer derrors.Wrap(&err, "MarshalJSON")
 
 	ji := &jsonInfo{
-		RepoURL:   i.repoURL,
-		ModuleDir: i.moduleDir,
-		Commit:    i.commit,
+		RepoURL:    i.repoURL,
+		ModuleDir:  i.moduleDir,
+		Commit:     i.commit,
+		CommitHash: i.commitHash,
 	}
 	// Store common templates efficiently, by name.
 	for kind, templs := range urlTemplatesByKind {
@@ -185,6 +210,7 @@ func (i *Info) UnmarshalJSON(data []byte) (err error) {
 	i.repoURL = ji.RepoURL
 	i.moduleDir = ji.ModuleDir
 	i.commit = ji.Commit
+	i.commitHash = ji.CommitHash
 	if ji.Kind != "" {
 		i.templates = urlTemplatesByKind[ji.Kind]
 	} else if ji.Templates != nil {
@@ -208,6 +234,13 @@ func NewClient(timeout time.Duration) *Client {
 	}
 }
 
+// NewClientForTesting returns a Client suitable for testing. It returns the
+// same results as an ordinary client for statically recognizable paths, but
+// fails without making a request for paths that need an HTTP lookup.
+func NewClientForTesting() *Client {
+	return &Client{}
+}
+
 // doURL makes an HTTP request using the given url and method. It returns an
 // error if the request returns an error. If only200 is true, it also returns an
 // error if any status code other than 200 is returned.
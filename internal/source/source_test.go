@@ -349,6 +349,22 @@ func TestMatchStatic(t *testing.T) {
 	}
 }
 
+func TestClientForTesting(t *testing.T) {
+	ctx := context.Background()
+	client := NewClientForTesting()
+
+	info, err := ModuleInfo(ctx, client, "github.com/a/b", "v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.RepoURL(), "https://github.com/a/b"; got != want {
+		t.Errorf("RepoURL() = %q, want %q", got, want)
+	}
+	if _, err := ModuleInfo(ctx, client, "example.com/a", "v1.2.3"); err == nil {
+		t.Error("got nil error for a dynamic path, want non-nil")
+	}
+}
+
 // This test adapted from gddo/gosrc/gosrc_test.go:TestGetDynamic.
 func TestModuleImportDynamic(t *testing.T) {
 	// For this test, fake the HTTP requests so we can cover cases that may not appear in the wild.
@@ -734,6 +750,10 @@ func TestJSON(t *testing.T) {
 			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: urlTemplates{File: "f"}},
 			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Templates":{"Directory":"","File":"f","Line":"","Raw":""}}`,
 		},
+		{
+			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: githubURLTemplates, commitHash: "h"},
+			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Kind":"github","CommitHash":"h"}`,
+		},
 	} {
 		bytes, err := json.Marshal(&test.in)
 		if err != nil {
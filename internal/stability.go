@@ -0,0 +1,80 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// MaxStabilityVersions is the largest number of versions of a package that
+// DataSource.GetStabilitySignal considers.
+const MaxStabilityVersions = 10
+
+// Stability is a heuristic signal of how stable the exported API of a
+// package has been over its most recent tagged versions.
+//
+// Each version is compared with the version before it. The API of the version
+// changed if a symbol was added, removed, or given a different synopsis. The
+// change was breaking if a symbol was removed or given a different synopsis;
+// adding symbols is not breaking. Changes to doc comments are ignored.
+//
+// The signal is coarse: a changed synopsis is not always a breaking change,
+// and a breaking change in behavior that keeps every signature is not
+// detected.
+type Stability struct {
+	// Versions is the number of versions considered, at most
+	// MaxStabilityVersions.
+	Versions int
+	// Changes is the number of versions whose API differs from that of the
+	// previous version.
+	Changes int
+	// ChurnRate is Changes divided by the number of pairs of consecutive
+	// versions. It is 0 if fewer than two versions were considered.
+	ChurnRate float64
+	// LastBreakingVersion is the highest version whose API change was
+	// breaking, or the empty string if no such version was considered.
+	LastBreakingVersion string
+}
+
+// ComputeStability returns the Stability of a package whose exported API at
+// each version is given by apis, which maps each version to a map from the
+// name of each exported symbol to its synopsis. Only the MaxStabilityVersions
+// highest versions in apis are considered.
+func ComputeStability(apis map[string]map[string]string) *Stability {
+	var versions []string
+	for v := range apis {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return semver.Compare(versions[i], versions[j]) < 0 })
+	if len(versions) > MaxStabilityVersions {
+		versions = versions[len(versions)-MaxStabilityVersions:]
+	}
+
+	s := &Stability{Versions: len(versions)}
+	for i := 1; i < len(versions); i++ {
+		prev, cur := apis[versions[i-1]], apis[versions[i]]
+		changed := len(prev) != len(cur)
+		breaking := false
+		for name, synopsis := range prev {
+			if syn, ok := cur[name]; !ok || syn != synopsis {
+				changed = true
+				breaking = true
+				break
+			}
+		}
+		if changed {
+			s.Changes++
+		}
+		if breaking {
+			s.LastBreakingVersion = versions[i]
+		}
+	}
+	if len(versions) > 1 {
+		s.ChurnRate = float64(s.Changes) / float64(len(versions)-1)
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComputeStability(t *testing.T) {
+	stable := map[string]string{"A": "const A", "F": "func F()"}
+	for _, test := range []struct {
+		name string
+		apis map[string]map[string]string
+		want *Stability
+	}{
+		{"no versions", nil, &Stability{}},
+		{"one version", map[string]map[string]string{"v1.0.0": stable}, &Stability{Versions: 1}},
+		{
+			"stable",
+			map[string]map[string]string{"v1.0.0": stable, "v1.1.0": stable, "v1.2.0": stable},
+			&Stability{Versions: 3},
+		},
+		{
+			"additions only",
+			map[string]map[string]string{
+				"v1.0.0": {"A": "const A"},
+				"v1.1.0": stable,
+				"v1.2.0": stable,
+			},
+			&Stability{Versions: 3, Changes: 1, ChurnRate: 0.5},
+		},
+		{
+			"recent breaking change",
+			map[string]map[string]string{
+				"v0.1.0":  stable,
+				"v0.2.0":  {"A": "const A"},
+				"v0.10.0": {"A": "const A", "F": "func F(int)"},
+				"v0.11.0": {"F": "func F(int)"},
+			},
+			&Stability{Versions: 4, Changes: 3, ChurnRate: 1, LastBreakingVersion: "v0.11.0"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := ComputeStability(test.apis)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("only the highest versions", func(t *testing.T) {
+		// A symbol is removed in v1.0.0, which is too old to be considered.
+		apis := map[string]map[string]string{"v1.0.0": {}}
+		for i := 0; i < MaxStabilityVersions; i++ {
+			apis[fmt.Sprintf("v1.%d.0", i+1)] = stable
+		}
+		apis["v0.9.0"] = map[string]string{"Old": "func Old()"}
+		got := ComputeStability(apis)
+		want := &Stability{Versions: MaxStabilityVersions}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
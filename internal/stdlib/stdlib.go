@@ -18,6 +18,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -117,6 +118,49 @@ func TagForVersion(version string) (_ string, err error) {
 	return goVersion, nil
 }
 
+// LinkTagForGoVersion returns the tag of the Go release whose standard
+// library links should point to for goVersion, a Go version such as "1.13"
+// as written in a go directive, given the semantic versions of the standard
+// library that are known. Only releases are considered, not betas or release
+// candidates.
+//
+// The result is the tag of the latest known release that is not in a newer
+// release series than goVersion, or of the oldest known release if they are
+// all newer. It is the empty string, meaning the latest release, if goVersion
+// is empty or invalid, if the chosen release is the latest one known, or if
+// no release is known, so a goVersion newer than any known release never
+// yields a tag that does not exist.
+func LinkTagForGoVersion(goVersion string, known []string) string {
+	want := VersionForTag("go" + goVersion)
+	if goVersion == "" || want == "" || semver.Prerelease(want) != "" {
+		return ""
+	}
+	var releases []string
+	for _, v := range known {
+		if semver.IsValid(v) && semver.Prerelease(v) == "" {
+			releases = append(releases, v)
+		}
+	}
+	if len(releases) == 0 {
+		return ""
+	}
+	sort.Slice(releases, func(i, j int) bool { return semver.Compare(releases[i], releases[j]) < 0 })
+	chosen := releases[0]
+	for _, v := range releases {
+		if semver.Compare(semver.MajorMinor(v), semver.MajorMinor(want)) <= 0 {
+			chosen = v
+		}
+	}
+	if chosen == releases[len(releases)-1] {
+		return ""
+	}
+	tag, err := TagForVersion(chosen)
+	if err != nil {
+		return ""
+	}
+	return tag
+}
+
 // MajorVersionForVersion returns the Go major version for version.
 // E.g. "v1.13.3" => "go1".
 func MajorVersionForVersion(version string) (_ string, err error) {
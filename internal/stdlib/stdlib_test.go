@@ -216,6 +216,32 @@ func TestVersionForTag(t *testing.T) {
 	}
 }
 
+func TestLinkTagForGoVersion(t *testing.T) {
+	known := []string{"v1.13.0", "v1.11.0", "v1.12.0", "v1.12.9", "v1.14.0-beta.1", "v1.13.3"}
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"bad", ""},
+		{"1.12", "go1.12.9"},
+		{"1.12.1", "go1.12.9"},
+		{"1.11", "go1.11"},
+		// Older than every known release.
+		{"1.9", "go1.11"},
+		// The latest known release, and newer ones, need no tag.
+		{"1.13", ""},
+		{"1.14", ""},
+		{"1.99", ""},
+	} {
+		if got := LinkTagForGoVersion(test.in, known); got != test.want {
+			t.Errorf("LinkTagForGoVersion(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+	if got := LinkTagForGoVersion("1.12", nil); got != "" {
+		t.Errorf("no known versions: got %q, want empty", got)
+	}
+}
+
 func TestContains(t *testing.T) {
 	for _, test := range []struct {
 		in   string
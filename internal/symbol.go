@@ -0,0 +1,90 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// SymbolKind is the kind of an exported identifier in a package.
+type SymbolKind string
+
+const (
+	SymbolKindConstant SymbolKind = "Constant"
+	SymbolKindVariable SymbolKind = "Variable"
+	SymbolKindFunction SymbolKind = "Function"
+	SymbolKindType     SymbolKind = "Type"
+	SymbolKindMethod   SymbolKind = "Method"
+)
+
+// Symbol is an exported identifier in the documentation of a package.
+type Symbol struct {
+	// Name is the name of the symbol. For methods, it has the form
+	// "Type.Method".
+	Name string
+	Kind SymbolKind
+	// Synopsis is a one-line declaration of the symbol, such as
+	// "func New(name string) *Client".
+	Synopsis string
+	// ParentName is the name of the type that the symbol is documented
+	// under. It is set for methods, and for constants, variables and
+	// functions associated with a type. It is empty for top-level symbols.
+	ParentName string
+	// Doc is the text of the symbol's doc comment, if any. For constants and
+	// variables declared in a group, it is the doc comment of the group.
+	Doc string
+}
+
+// MaxSymbolPresenceVersions is the largest number of versions of a package
+// that DataSource.GetSymbolPresence considers.
+const MaxSymbolPresenceVersions = 50
+
+// DocText returns the plain text of the documentation of a package, for
+// indexing. It consists of pkgDoc, the text of the package doc comment,
+// followed by the synopsis and doc comment of each symbol in syms that has a
+// doc comment, in order, separated by blank lines.
+func DocText(pkgDoc string, syms []*Symbol) string {
+	var parts []string
+	if d := strings.TrimSpace(pkgDoc); d != "" {
+		parts = append(parts, d)
+	}
+	for _, s := range syms {
+		if d := strings.TrimSpace(s.Doc); d != "" {
+			parts = append(parts, s.Synopsis+"\n"+d)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// PlatformOnlySymbols returns, for each build context in symbolsByContext, the
+// names of the symbols that it has and no other build context has, sorted.
+// symbolsByContext maps the string form of each build context for which a
+// package is documented to the distinct names of its exported symbols. Every
+// build context is in the result, with an empty list if it has no symbols of
+// its own. A package documented for a single build context has nothing to
+// compare, so its list is empty.
+func PlatformOnlySymbols(symbolsByContext map[string][]string) map[string][]string {
+	contexts := map[string]int{} // number of build contexts with each name
+	for _, names := range symbolsByContext {
+		for _, n := range names {
+			contexts[n]++
+		}
+	}
+	diff := map[string][]string{}
+	for bc, names := range symbolsByContext {
+		only := []string{}
+		if len(symbolsByContext) > 1 {
+			for _, n := range names {
+				if contexts[n] == 1 {
+					only = append(only, n)
+				}
+			}
+		}
+		sort.Strings(only)
+		diff[bc] = only
+	}
+	return diff
+}
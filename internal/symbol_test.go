@@ -0,0 +1,83 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDocText(t *testing.T) {
+	syms := []*Symbol{
+		{Name: "A", Synopsis: "const A"},
+		{Name: "F", Synopsis: "func F()", Doc: "F does a thing.\nIt is useful.\n"},
+		{Name: "T", Synopsis: "type T int", Doc: "T is a type.\n"},
+	}
+	for _, test := range []struct {
+		pkgDoc string
+		syms   []*Symbol
+		want   string
+	}{
+		{"", nil, ""},
+		{"Package p is a package.\n", nil, "Package p is a package."},
+		{"Package p is a package.\n", syms,
+			"Package p is a package.\n\nfunc F()\nF does a thing.\nIt is useful.\n\ntype T int\nT is a type."},
+		{"", syms, "func F()\nF does a thing.\nIt is useful.\n\ntype T int\nT is a type."},
+	} {
+		if got := DocText(test.pkgDoc, test.syms); got != test.want {
+			t.Errorf("DocText(%q, ...) = %q, want %q", test.pkgDoc, got, test.want)
+		}
+	}
+}
+
+func TestPlatformOnlySymbols(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		in   map[string][]string
+		want map[string][]string
+	}{
+		{"none", map[string][]string{}, map[string][]string{}},
+		{
+			"one build context",
+			map[string][]string{"linux/amd64": {"A", "B"}},
+			map[string][]string{"linux/amd64": {}},
+		},
+		{
+			"windows-only function",
+			map[string][]string{
+				"linux/amd64":   {"A", "Fd"},
+				"windows/amd64": {"A", "Handle", "Fd"},
+				"darwin/amd64":  {"A", "Fd", "Kqueue"},
+			},
+			map[string][]string{
+				"linux/amd64":   {},
+				"windows/amd64": {"Handle"},
+				"darwin/amd64":  {"Kqueue"},
+			},
+		},
+		{
+			// A symbol in two of three build contexts is unique to neither.
+			"shared by two",
+			map[string][]string{
+				"linux/amd64":   {"Unix", "Z"},
+				"darwin/amd64":  {"Unix"},
+				"windows/amd64": {},
+			},
+			map[string][]string{
+				"linux/amd64":   {"Z"},
+				"darwin/amd64":  {},
+				"windows/amd64": {},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := PlatformOnlySymbols(test.in)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,1205 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package datasourcetest provides conformance tests for implementations of
+// internal.DataSource.
+package datasourcetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch/dochtml"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+// Module paths and versions in the seed data returned by Modules.
+const (
+	basicModule  = "example.com/basic"
+	nestedModule = "example.com/basic/nested"
+	missing      = "v9.9.9"
+)
+
+const basicGoMod = `module example.com/basic
+
+require (
+	example.com/dep v1.2.0
+	golang.org/x/text v0.3.0 // indirect
+)
+`
+
+const basicGoModV110 = `module example.com/basic
+
+require (
+	example.com/dep v1.3.0
+	golang.org/x/text v0.3.0 // indirect
+)
+
+replace golang.org/x/text => golang.org/x/text v0.3.2
+`
+
+const nestedReadme = "# nested\n\nThis is the README of example.com/basic/nested.\n"
+
+const basicSource = `// Package basic is used for DataSource conformance tests.
+package basic
+
+import (
+	"fmt"
+
+	"example.com/dep"
+)
+
+// A is a constant.
+const A = 1
+
+// T is a type.
+type T struct{}
+
+// M is a method.
+func (T) M() { fmt.Println(dep.X) }
+`
+
+const toolSource = `// Command tool prints sub.V.
+package main
+
+import "example.com/basic/sub"
+
+func main() { println(sub.V) }
+`
+
+// Modules returns the modules that RunConformanceTests expects a DataSource
+// to hold, in the form served by a module proxy:
+//
+//   - example.com/basic at v1.0.0 and v1.1.0, with packages example.com/basic
+//     and example.com/basic/sub. v1.1.0 adds the function basic.New, the
+//     packages example.com/basic/util and example.com/basic/nested/pkg, the
+//     internal package example.com/basic/internal/helper and the command
+//     example.com/basic/cmd/tool, which uses sub.V. It also raises the
+//     required version of example.com/dep and replaces golang.org/x/text.
+//   - example.com/basic/nested at v1.0.0, which has a README and also
+//     contains the package example.com/basic/nested/pkg, with an example.
+//
+// Each call returns new values.
+func Modules() []*proxy.TestModule {
+	subFiles := map[string]string{
+		"sub/sub.go": `// Package sub is a subpackage.
+package sub
+
+import "strings"
+
+// V is a variable.
+var V = strings.ToUpper("v")
+`,
+		"sub/LICENSE": testhelper.MITLicense,
+	}
+	basic := func(version string, extra map[string]string) *proxy.TestModule {
+		files := map[string]string{
+			"go.mod":   basicGoMod,
+			"LICENSE":  testhelper.MITLicense,
+			"basic.go": basicSource,
+		}
+		for name, contents := range subFiles {
+			files[name] = contents
+		}
+		for name, contents := range extra {
+			files[name] = contents
+		}
+		return &proxy.TestModule{ModulePath: basicModule, Version: version, Files: files}
+	}
+	return []*proxy.TestModule{
+		basic("v1.0.0", nil),
+		basic("v1.1.0", map[string]string{
+			"go.mod":                    basicGoModV110,
+			"new.go":                    "package basic\n\n// New returns a new T.\nfunc New() *T { return nil }\n",
+			"util/util.go":              "// Package util has utilities.\npackage util\n",
+			"nested/pkg/pkg.go":         "// Package pkg is in example.com/basic.\npackage pkg\n",
+			"internal/helper/helper.go": "// Package helper is internal.\npackage helper\n",
+			"cmd/tool/main.go":          toolSource,
+		}),
+		{
+			ModulePath: nestedModule,
+			Version:    "v1.0.0",
+			Files: map[string]string{
+				"go.mod":              "module " + nestedModule + "\n",
+				"LICENSE":             testhelper.MITLicense,
+				"README.md":           nestedReadme,
+				"pkg/pkg.go":          "// Package pkg is in example.com/basic/nested.\npackage pkg\n",
+				"pkg/example_test.go": "package pkg_test\n\nimport \"fmt\"\n\nfunc Example() {\n\tfmt.Println(\"hello\")\n\t// Output: hello\n}\n",
+			},
+		},
+	}
+}
+
+// RunConformanceTests checks that a DataSource behaves as documented by
+// internal.DataSource, including its not-found semantics and its preference
+// for the longest module path.
+//
+// newDS should return a new DataSource, and seed should add the modules
+// returned by Modules to it, recording each as successfully fetched, as the
+// worker does. A DataSource that reads from a module proxy can serve Modules
+// from a test proxy in newDS, and pass a seed function that does nothing.
+func RunConformanceTests(t *testing.T, newDS func() internal.DataSource, seed func(internal.DataSource)) {
+	t.Helper()
+	ds := newDS()
+	seed(ds)
+	for _, test := range []struct {
+		name string
+		run  func(*testing.T, context.Context, internal.DataSource)
+	}{
+		{"GetModuleInfo", testGetModuleInfo},
+		{"GetFetchTime", testGetFetchTime},
+		{"GetContentHash", testGetContentHash},
+		{"GetFileStats", testGetFileStats},
+		{"GetPackage", testGetPackage},
+		{"GetPackagesInModule", testGetPackagesInModule},
+		{"GetDirectory", testGetDirectory},
+		{"GetDirectoryNew", testGetDirectoryNew},
+		{"GetPathInfo", testGetPathInfo},
+		{"ClassifyPath", testClassifyPath},
+		{"GetImports", testGetImports},
+		{"GetSourceFile", testGetSourceFile},
+		{"Licenses", testLicenses},
+		{"Versions", testVersions},
+		{"Symbols", testSymbols},
+		{"GetRequirements", testGetRequirements},
+		{"GetDependencyDepth", testGetDependencyDepth},
+		{"SupportedBuildContexts", testSupportedBuildContexts},
+		{"SourceInfo", testSourceInfo},
+		{"DocBuildInfo", testDocBuildInfo},
+		{"Documentation", testDocumentation},
+		{"Readme", testReadme},
+		{"ModFileDiff", testModFileDiff},
+		{"ModuleSummary", testModuleSummary},
+		{"FetchHistory", testFetchHistory},
+		{"Index", testIndex},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			test.run(t, ctx, ds)
+		})
+	}
+}
+
+// fetchAll gets the module info of every module version in Modules from ds,
+// so that a DataSource that fetches module versions on demand includes all of
+// them in methods that only consider the module versions it already has.
+func fetchAll(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	t.Helper()
+	for _, m := range Modules() {
+		if _, err := ds.GetModuleInfo(ctx, m.ModulePath, m.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkInvalidArgument reports a test error if err does not wrap
+// derrors.InvalidArgument.
+func checkInvalidArgument(t *testing.T, call string, err error) {
+	t.Helper()
+	if !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("%s: got error %v, want %v", call, err, derrors.InvalidArgument)
+	}
+}
+
+// checkNotFound reports a test error if err does not wrap derrors.NotFound.
+func checkNotFound(t *testing.T, call string, err error) {
+	t.Helper()
+	if !errors.Is(err, derrors.NotFound) {
+		t.Errorf("%s: got error %v, want %v", call, err, derrors.NotFound)
+	}
+}
+
+func testGetModuleInfo(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	mi, err := ds.GetModuleInfo(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.ModulePath != basicModule || mi.Version != "v1.0.0" {
+		t.Errorf("GetModuleInfo: got %s@%s, want %s@v1.0.0", mi.ModulePath, mi.Version, basicModule)
+	}
+	if !mi.IsRedistributable {
+		t.Error("GetModuleInfo: got IsRedistributable = false, want true")
+	}
+	// Ask twice, to check that a cached failure is still reported.
+	for i := 0; i < 2; i++ {
+		_, err = ds.GetModuleInfo(ctx, basicModule, missing)
+		checkNotFound(t, "GetModuleInfo(missing version)", err)
+	}
+}
+
+func testGetFetchTime(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	_, err := ds.GetFetchTime(ctx, basicModule, missing)
+	checkNotFound(t, "GetFetchTime(missing version)", err)
+	_, err = ds.GetProcessingInfo(ctx, basicModule, missing)
+	checkNotFound(t, "GetProcessingInfo(missing version)", err)
+}
+
+func testGetContentHash(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	h1, err := ds.GetContentHash(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := ds.GetContentHash(ctx, basicModule, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 == "" || h1 == h2 {
+		t.Errorf("GetContentHash: got %q and %q for different versions, want distinct non-empty hashes", h1, h2)
+	}
+	_, err = ds.GetContentHash(ctx, basicModule, missing)
+	checkNotFound(t, "GetContentHash(missing version)", err)
+}
+
+func testGetFileStats(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	got, err := ds.GetFileStats(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.FileStats{
+		NumFiles:      5, // go.mod, LICENSE, basic.go, sub/sub.go, sub/LICENSE
+		NumNonGoFiles: 3,
+		GoLines:       24, // 17 in basic.go, 7 in sub/sub.go
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetFileStats mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetFileStats(ctx, basicModule, missing)
+	checkNotFound(t, "GetFileStats(missing version)", err)
+}
+
+func testGetPackage(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	vp, err := ds.GetPackage(ctx, basicModule+"/sub", basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vp.Path != basicModule+"/sub" || vp.Name != "sub" || vp.ModulePath != basicModule || vp.Version != "v1.0.0" {
+		t.Errorf("GetPackage: got %s (%s) in %s@%s, want %s/sub (sub) in %[5]s@v1.0.0",
+			vp.Path, vp.Name, vp.ModulePath, vp.Version, basicModule)
+	}
+
+	// example.com/basic/nested/pkg is in both modules at their latest
+	// versions, so the longest module path should win.
+	vp, err = ds.GetPackage(ctx, nestedModule+"/pkg", internal.UnknownModulePath, internal.LatestVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vp.ModulePath != nestedModule {
+		t.Errorf("GetPackage(unknown module): got module %q, want %q", vp.ModulePath, nestedModule)
+	}
+
+	_, err = ds.GetPackage(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetPackage(missing package)", err)
+	_, err = ds.GetPackage(ctx, basicModule, basicModule, missing)
+	checkNotFound(t, "GetPackage(missing version)", err)
+}
+
+func testGetPackagesInModule(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	pkgs, err := ds.GetPackagesInModule(ctx, basicModule, "v1.0.0", internal.PathOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pkgs {
+		got = append(got, p.Path)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{basicModule, basicModule + "/sub"}, got); diff != "" {
+		t.Errorf("GetPackagesInModule mismatch (-want +got):\n%s", diff)
+	}
+
+	pkgs, err = ds.GetImportablePackages(ctx, basicModule, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, p := range pkgs {
+		got = append(got, p.Path)
+	}
+	sort.Strings(got)
+	want := []string{basicModule, basicModule + "/nested/pkg", basicModule + "/sub", basicModule + "/util"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetImportablePackages mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, test := range []struct {
+		modulePath, want string
+	}{
+		{basicModule, basicModule},
+		{nestedModule, nestedModule + "/pkg"},
+	} {
+		pkg, err := ds.GetPrimaryPackage(ctx, test.modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Path != test.want {
+			t.Errorf("GetPrimaryPackage(%q): got %q, want %q", test.modulePath, pkg.Path, test.want)
+		}
+	}
+
+	pkgs, err = ds.GetSiblingPackages(ctx, basicModule+"/sub", basicModule, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, p := range pkgs {
+		got = append(got, p.Path)
+	}
+	if diff := cmp.Diff([]string{basicModule + "/util"}, got); diff != "" {
+		t.Errorf("GetSiblingPackages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testGetDirectory(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	dir, err := ds.GetDirectory(ctx, basicModule, basicModule, "v1.0.0", internal.AllFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range dir.Packages {
+		got = append(got, p.Path)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{basicModule, basicModule + "/sub"}, got); diff != "" {
+		t.Errorf("GetDirectory mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testGetDirectoryNew(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	dir, err := ds.GetDirectoryNew(ctx, basicModule+"/sub", basicModule, "v1.0.0", internal.PathOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Path != basicModule+"/sub" || dir.ModulePath != basicModule || dir.Version != "v1.0.0" {
+		t.Errorf("GetDirectoryNew: got %s in %s@%s, want %s/sub in %[4]s@v1.0.0",
+			dir.Path, dir.ModulePath, dir.Version, basicModule)
+	}
+	if dir.Subdirectories != nil {
+		t.Errorf("GetDirectoryNew: got subdirectories %v without IncludeSubdirectories", dir.Subdirectories)
+	}
+
+	dir, err = ds.GetDirectoryNew(ctx, basicModule, basicModule, "v1.0.0", internal.PathOptions{IncludeSubdirectories: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.Subdirectory{{Path: basicModule + "/sub", HasReadme: false}}
+	if diff := cmp.Diff(want, dir.Subdirectories); diff != "" {
+		t.Errorf("GetDirectoryNew subdirectories mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testClassifyPath(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	for _, test := range []struct {
+		path, modulePath, version string
+		want                      internal.PathKind
+	}{
+		{basicModule, basicModule, "v1.1.0", internal.PathKindModuleRoot},
+		{basicModule + "/sub", basicModule, "v1.1.0", internal.PathKindPackage},
+		// example.com/basic/internal contains only the package helper.
+		{basicModule + "/internal", basicModule, "v1.1.0", internal.PathKindDirectory},
+		// example.com/basic/nested is a directory of example.com/basic but
+		// the root of the longer module example.com/basic/nested.
+		{nestedModule, basicModule, "v1.1.0", internal.PathKindDirectory},
+		{nestedModule, nestedModule, "v1.0.0", internal.PathKindModuleRoot},
+		{basicModule + "/internal", basicModule, "v1.0.0", internal.PathKindNotFound},
+		{basicModule, basicModule, missing, internal.PathKindNotFound},
+	} {
+		got, err := ds.ClassifyPath(ctx, test.path, test.modulePath, test.version)
+		if err != nil {
+			t.Fatalf("ClassifyPath(%q, %q, %q): %v", test.path, test.modulePath, test.version, err)
+		}
+		if got != test.want {
+			t.Errorf("ClassifyPath(%q, %q, %q) = %q, want %q", test.path, test.modulePath, test.version, got, test.want)
+		}
+	}
+}
+
+func testGetPathInfo(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	modulePath, version, isPackage, err := ds.GetPathInfo(ctx, basicModule+"/sub", basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modulePath != basicModule || version != "v1.0.0" || !isPackage {
+		t.Errorf("GetPathInfo = %q, %q, %t, want %q, %q, true", modulePath, version, isPackage, basicModule, "v1.0.0")
+	}
+	_, _, _, err = ds.GetPathInfo(ctx, basicModule, basicModule, missing)
+	checkNotFound(t, "GetPathInfo(missing version)", err)
+}
+
+func testGetImports(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	imports, err := ds.GetImports(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(imports)
+	if diff := cmp.Diff([]string{"example.com/dep", "fmt"}, imports); diff != "" {
+		t.Errorf("GetImports mismatch (-want +got):\n%s", diff)
+	}
+
+	std, external, err := ds.GetImportsGrouped(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"fmt"}, std); diff != "" {
+		t.Errorf("GetImportsGrouped std mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"example.com/dep"}, external); diff != "" {
+		t.Errorf("GetImportsGrouped external mismatch (-want +got):\n%s", diff)
+	}
+
+	internalImports, err := ds.GetInternalImports(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{}, internalImports); diff != "" {
+		t.Errorf("GetInternalImports mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testGetSourceFile(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	got, err := ds.GetSourceFile(ctx, basicModule, basicModule, "v1.0.0", "basic.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != basicSource {
+		t.Errorf("GetSourceFile: got %q, want %q", got, basicSource)
+	}
+	// Files in subdirectories belong to the subdirectory's package.
+	_, err = ds.GetSourceFile(ctx, basicModule, basicModule, "v1.0.0", "sub.go")
+	checkNotFound(t, "GetSourceFile(file of another package)", err)
+	_, err = ds.GetSourceFile(ctx, basicModule, basicModule, "v1.0.0", "go.mod")
+	checkNotFound(t, "GetSourceFile(non-Go file)", err)
+	_, err = ds.GetSourceFile(ctx, basicModule, basicModule, missing, "basic.go")
+	checkNotFound(t, "GetSourceFile(missing version)", err)
+
+	// No package in basicModule has an import comment.
+	mismatches, err := ds.GetImportPathMismatches(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("GetImportPathMismatches: got %v, want none", mismatches)
+	}
+	_, err = ds.GetImportPathMismatches(ctx, basicModule, missing)
+	checkNotFound(t, "GetImportPathMismatches(missing version)", err)
+}
+
+func testLicenses(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	filePaths := func(lics []*licenses.License) []string {
+		var paths []string
+		for _, l := range lics {
+			paths = append(paths, l.FilePath)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	lics, err := ds.GetModuleLicenses(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"LICENSE"}, filePaths(lics)); diff != "" {
+		t.Errorf("GetModuleLicenses mismatch (-want +got):\n%s", diff)
+	}
+
+	lics, err = ds.GetLicenseFiles(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"LICENSE", "sub/LICENSE"}, filePaths(lics)); diff != "" {
+		t.Errorf("GetLicenseFiles mismatch (-want +got):\n%s", diff)
+	}
+	for _, l := range lics {
+		if len(l.Contents) == 0 {
+			t.Errorf("GetLicenseFiles: %s has no contents", l.FilePath)
+		}
+	}
+
+	lics, err = ds.GetPackageLicenses(ctx, basicModule+"/sub", basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"LICENSE", "sub/LICENSE"}, filePaths(lics)); diff != "" {
+		t.Errorf("GetPackageLicenses mismatch (-want +got):\n%s", diff)
+	}
+
+	badge, err := ds.GetLicenseBadge(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBadge := &internal.Badge{License: "MIT", Color: internal.BadgeColorRedistributable, IsRedistributable: true}
+	if diff := cmp.Diff(wantBadge, badge); diff != "" {
+		t.Errorf("GetLicenseBadge mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetLicenseBadge(ctx, basicModule, "v9.9.9")
+	checkNotFound(t, "GetLicenseBadge(missing version)", err)
+
+	// Every license file in the seed data is an MIT license.
+	undetected, err := ds.GetUndetectedLicenseFiles(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undetected) != 0 {
+		t.Errorf("GetUndetectedLicenseFiles: got %v, want none", undetected)
+	}
+	_, err = ds.GetUndetectedLicenseFiles(ctx, basicModule, missing)
+	checkNotFound(t, "GetUndetectedLicenseFiles(missing version)", err)
+}
+
+func testVersions(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	versions := func(mis []*internal.LegacyModuleInfo) []string {
+		var vs []string
+		for _, mi := range mis {
+			vs = append(vs, mi.Version)
+		}
+		return vs
+	}
+	want := []string{"v1.1.0", "v1.0.0"}
+
+	mis, err := ds.GetTaggedVersionsForModule(ctx, basicModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, versions(mis)); diff != "" {
+		t.Errorf("GetTaggedVersionsForModule mismatch (-want +got):\n%s", diff)
+	}
+	mis, err = ds.GetTaggedVersionsForPackageSeries(ctx, basicModule+"/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, versions(mis)); diff != "" {
+		t.Errorf("GetTaggedVersionsForPackageSeries mismatch (-want +got):\n%s", diff)
+	}
+
+	mis, err = ds.GetPseudoVersionsForModule(ctx, basicModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mis) != 0 {
+		t.Errorf("GetPseudoVersionsForModule: got %v, want none", versions(mis))
+	}
+	mis, err = ds.GetPseudoVersionsForPackageSeries(ctx, basicModule+"/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mis) != 0 {
+		t.Errorf("GetPseudoVersionsForPackageSeries: got %v, want none", versions(mis))
+	}
+	_, err = ds.ResolveBranch(ctx, basicModule, "no-such-branch")
+	checkNotFound(t, "ResolveBranch(missing branch)", err)
+	if _, err := ds.ResolveBranch(ctx, basicModule, "v1.0.0"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("ResolveBranch with a version: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+	mis, err = ds.GetVersionsMatching(ctx, basicModule, ">v1.0.0 <v2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"v1.1.0"}, versions(mis)); diff != "" {
+		t.Errorf("GetVersionsMatching mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := ds.GetVersionsMatching(ctx, basicModule, "newest"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("GetVersionsMatching with invalid constraint: got error %v, want %v", err, derrors.InvalidArgument)
+	}
+
+	latest, err := ds.GetLatestVersions(ctx, []string{basicModule, nestedModule, "example.com/unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLatest := map[string]string{basicModule: "v1.1.0", nestedModule: "v1.0.0"}
+	if diff := cmp.Diff(wantLatest, latest); diff != "" {
+		t.Errorf("GetLatestVersions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testSymbols(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	counts, err := ds.GetSymbolCounts(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCounts := map[internal.SymbolKind]int{
+		internal.SymbolKindConstant: 1,
+		internal.SymbolKindType:     1,
+		internal.SymbolKindMethod:   1,
+	}
+	if diff := cmp.Diff(wantCounts, counts); diff != "" {
+		t.Errorf("GetSymbolCounts mismatch (-want +got):\n%s", diff)
+	}
+
+	syms, err := ds.GetSymbols(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range syms {
+		names = append(names, s.Name)
+	}
+	if diff := cmp.Diff([]string{"A", "T", "T.M"}, names); diff != "" {
+		t.Errorf("GetSymbols mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetSymbols(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetSymbols(missing package)", err)
+
+	text, err := ds.GetDocText(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Package basic is used for DataSource conformance tests.", "func (T) M()\nM is a method."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("GetDocText: got %q, want it to contain %q", text, want)
+		}
+	}
+	_, err = ds.GetDocText(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetDocText(missing package)", err)
+
+	outline, err := ds.GetDocOutline(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOutline := &internal.Outline{Sections: []*internal.OutlineSection{
+		{Title: "Constants", ID: "pkg-constants"},
+		{Title: "Types", Entries: []*internal.OutlineEntry{
+			{Name: "T", ID: "T", Synopsis: "type T struct", Children: []*internal.OutlineEntry{
+				{Name: "T.M", ID: "T.M", Synopsis: "func (T) M()"},
+			}},
+		}},
+	}}
+	if diff := cmp.Diff(wantOutline, outline); diff != "" {
+		t.Errorf("GetDocOutline mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetDocOutline(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetDocOutline(missing package)", err)
+
+	// The package is documented for a single build context, so no symbols
+	// are unique to it.
+	platformDiff, err := ds.GetDocDiffAcrossPlatforms(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string][]string{"linux/amd64": {}}, platformDiff); diff != "" {
+		t.Errorf("GetDocDiffAcrossPlatforms mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetDocDiffAcrossPlatforms(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetDocDiffAcrossPlatforms(missing package)", err)
+
+	sizes, err := ds.GetDocSizes(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{basicModule, basicModule + "/sub"} {
+		vp, err := ds.GetPackage(ctx, path, basicModule, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := sizes[path], len(vp.DocumentationHTML); got != want || got == 0 {
+			t.Errorf("GetDocSizes: got size %d for %s, want %d", got, path, want)
+		}
+	}
+	if len(sizes) != 2 {
+		t.Errorf("GetDocSizes: got sizes for %d packages, want 2", len(sizes))
+	}
+	_, err = ds.GetDocSizes(ctx, basicModule, "v9.9.9")
+	checkNotFound(t, "GetDocSizes(missing version)", err)
+
+	docs, err := ds.GetAllDocs(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSynopses := map[string]string{
+		basicModule:          "Package basic is used for DataSource conformance tests.",
+		basicModule + "/sub": "Package sub is a subpackage.",
+	}
+	gotSynopses := map[string]string{}
+	for path, doc := range docs {
+		if doc == nil {
+			t.Errorf("GetAllDocs: no documentation for %s", path)
+			continue
+		}
+		gotSynopses[path] = doc.Synopsis
+	}
+	if diff := cmp.Diff(wantSynopses, gotSynopses); diff != "" {
+		t.Errorf("GetAllDocs synopses mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetAllDocs(ctx, basicModule, "v9.9.9")
+	checkNotFound(t, "GetAllDocs(missing version)", err)
+
+	presence, err := ds.GetSymbolPresence(ctx, basicModule, []string{"A", "New"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPresence := map[string]map[string]bool{
+		"v1.0.0": {"A": true, "New": false},
+		"v1.1.0": {"A": true, "New": true},
+	}
+	if diff := cmp.Diff(wantPresence, presence); diff != "" {
+		t.Errorf("GetSymbolPresence mismatch (-want +got):\n%s", diff)
+	}
+
+	// v1.1.0 adds basic.New, which is not a breaking change.
+	stability, err := ds.GetStabilitySignal(ctx, basicModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStability := &internal.Stability{Versions: 2, Changes: 1, ChurnRate: 1}
+	if diff := cmp.Diff(wantStability, stability); diff != "" {
+		t.Errorf("GetStabilitySignal mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testGetRequirements(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	reqs, err := ds.GetRequirements(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*internal.Requirement{
+		{ModulePath: "example.com/dep", Version: "v1.2.0"},
+		{ModulePath: "golang.org/x/text", Version: "v0.3.0", Indirect: true},
+	}
+	if diff := cmp.Diff(want, reqs); diff != "" {
+		t.Errorf("GetRequirements mismatch (-want +got):\n%s", diff)
+	}
+
+	mf, err := ds.GetParsedModFile(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantModFile := &internal.ModFile{ModulePath: basicModule, Require: want}
+	if diff := cmp.Diff(wantModFile, mf); diff != "" {
+		t.Errorf("GetParsedModFile mismatch (-want +got):\n%s", diff)
+	}
+	if got, err := ds.GetGoDirective(ctx, basicModule, "v1.0.0"); err != nil || got != "" {
+		t.Errorf("GetGoDirective(no go directive) = %q, %v, want \"\", nil", got, err)
+	}
+
+	reqs, err = ds.GetRequirements(ctx, nestedModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs == nil || len(reqs) != 0 {
+		t.Errorf("GetRequirements(no requirements): got %v, want non-nil empty slice", reqs)
+	}
+}
+
+func testGetDependencyDepth(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	// The modules that example.com/basic requires are not in the seed data.
+	depth, err := ds.GetDependencyDepth(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth != 1 {
+		t.Errorf("GetDependencyDepth(%s@v1.0.0) = %d, want 1", basicModule, depth)
+	}
+	depth, err = ds.GetDependencyDepth(ctx, nestedModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth != 0 {
+		t.Errorf("GetDependencyDepth(%s@v1.0.0) = %d, want 0", nestedModule, depth)
+	}
+	_, err = ds.GetDependencyDepth(ctx, basicModule, missing)
+	checkNotFound(t, "GetDependencyDepth(missing version)", err)
+}
+
+func testSupportedBuildContexts(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	got, err := ds.SupportedBuildContexts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(internal.BuildContexts, got); diff != "" {
+		t.Errorf("SupportedBuildContexts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testSourceInfo(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	// example.com is not a known code host, so the seed data has no source
+	// info to hold a commit hash.
+	_, err := ds.GetCommitSHA(ctx, basicModule, "v1.0.0")
+	checkNotFound(t, "GetCommitSHA(no source info)", err)
+	_, err = ds.GetCommitSHA(ctx, basicModule, missing)
+	checkNotFound(t, "GetCommitSHA(missing version)", err)
+
+	// There are no pseudo-versions for a commit to match.
+	_, err = ds.GetModuleByCommit(ctx, basicModule, "abcdef123456")
+	checkNotFound(t, "GetModuleByCommit(no pseudo-versions)", err)
+	_, err = ds.GetModuleByCommit(ctx, basicModule, "not-a-hash")
+	checkInvalidArgument(t, "GetModuleByCommit(invalid hash)", err)
+
+	fetchAll(t, ctx, ds)
+	mis, err := ds.GetModulesByOwner(ctx, "github.com", "golang", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mis) != 0 {
+		t.Errorf("GetModulesByOwner: got %d modules, want none", len(mis))
+	}
+	_, err = ds.GetModulesByOwner(ctx, "", "golang", 10, 0)
+	checkInvalidArgument(t, "GetModulesByOwner(empty host)", err)
+	_, err = ds.GetModulesByOwner(ctx, "github.com", "golang", 10, -1)
+	checkInvalidArgument(t, "GetModulesByOwner(negative offset)", err)
+}
+
+func testDocBuildInfo(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	// The seed data is processed by the fetcher built into the test binary.
+	want := &internal.DocBuildInfo{GoVersion: runtime.Version(), RendererVersion: dochtml.RendererVersion}
+	got, err := ds.GetDocBuildInfo(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetDocBuildInfo mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetDocBuildInfo(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetDocBuildInfo(missing package)", err)
+
+	goVersion, err := ds.GetDocGenGoVersion(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goVersion != want.GoVersion {
+		t.Errorf("GetDocGenGoVersion = %q, want %q", goVersion, want.GoVersion)
+	}
+	_, err = ds.GetDocGenGoVersion(ctx, basicModule, missing)
+	checkNotFound(t, "GetDocGenGoVersion(missing version)", err)
+}
+
+func testDocumentation(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	vp, err := ds.GetPackage(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, section := range []string{"constants", "variables"} {
+		doc, err := ds.GetDocSection(ctx, basicModule, basicModule, "v1.0.0", section)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// basic has a constant but no variables, so the second section is
+		// empty.
+		want, _ := internal.DocSection(vp.DocumentationHTML, section)
+		if doc.HTML != want || (section == "constants") != (want != "") {
+			t.Errorf("GetDocSection(%q): got HTML %q, want %q", section, doc.HTML, want)
+		}
+		if doc.GOOS != "linux" || doc.GOARCH != "amd64" || doc.Synopsis != vp.Synopsis {
+			t.Errorf("GetDocSection(%q): got %s/%s with synopsis %q, want linux/amd64 with %q",
+				section, doc.GOOS, doc.GOARCH, doc.Synopsis, vp.Synopsis)
+		}
+	}
+	_, err = ds.GetDocSection(ctx, basicModule, basicModule, "v1.0.0", "index")
+	checkInvalidArgument(t, "GetDocSection(unknown section)", err)
+	_, err = ds.GetDocSection(ctx, basicModule+"/missing", basicModule, "v1.0.0", "constants")
+	checkNotFound(t, "GetDocSection(missing package)", err)
+
+	// The documentation of cmd/tool refers to sub.V, which is held at
+	// every version of example.com/basic.
+	fetchAll(t, ctx, ds)
+	html, err := ds.GetDocumentationWithLinks(ctx, basicModule+"/cmd/tool", basicModule, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link := `<a href="/pkg/` + basicModule + `/sub#V">sub.V</a>`; !strings.Contains(string(html), link) {
+		t.Errorf("GetDocumentationWithLinks: got %q, want it to contain %q", html, link)
+	}
+	_, err = ds.GetDocumentationWithLinks(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetDocumentationWithLinks(missing package)", err)
+
+	exs, err := ds.GetPlaygroundExamples(ctx, nestedModule+"/pkg", nestedModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExs := []*internal.PlaygroundExample{{
+		Name: "",
+		Code: "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n",
+	}}
+	if diff := cmp.Diff(wantExs, exs); diff != "" {
+		t.Errorf("GetPlaygroundExamples mismatch (-want +got):\n%s", diff)
+	}
+	exs, err = ds.GetPlaygroundExamples(ctx, basicModule, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exs) != 0 {
+		t.Errorf("GetPlaygroundExamples(no examples): got %d examples, want none", len(exs))
+	}
+	_, err = ds.GetPlaygroundExamples(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetPlaygroundExamples(missing package)", err)
+}
+
+func testReadme(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	want := &internal.Readme{Filepath: "README.md", Contents: nestedReadme}
+	hash, err := ds.GetReadmeHash(ctx, nestedModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != want.Hash() {
+		t.Errorf("GetReadmeHash = %q, want %q", hash, want.Hash())
+	}
+	_, err = ds.GetReadmeHash(ctx, basicModule, "v1.0.0")
+	checkNotFound(t, "GetReadmeHash(no README)", err)
+	_, err = ds.GetReadmeHash(ctx, basicModule, missing)
+	checkNotFound(t, "GetReadmeHash(missing version)", err)
+
+	// example.com/basic/nested/pkg is only in example.com/basic/nested at
+	// v1.0.0.
+	readme, err := ds.GetModuleReadmeForPackage(ctx, nestedModule+"/pkg", internal.UnknownModulePath, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, readme); diff != "" {
+		t.Errorf("GetModuleReadmeForPackage mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetModuleReadmeForPackage(ctx, basicModule+"/sub", basicModule, "v1.0.0")
+	checkNotFound(t, "GetModuleReadmeForPackage(no README)", err)
+	_, err = ds.GetModuleReadmeForPackage(ctx, basicModule+"/missing", basicModule, "v1.0.0")
+	checkNotFound(t, "GetModuleReadmeForPackage(missing package)", err)
+}
+
+func testModFileDiff(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	diff, err := ds.GetModFileDiff(ctx, basicModule, "v1.0.0", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &internal.ModDiff{Changed: []*internal.RequirementChange{{
+		ModulePath: "example.com/dep",
+		From:       &internal.Requirement{ModulePath: "example.com/dep", Version: "v1.2.0"},
+		To:         &internal.Requirement{ModulePath: "example.com/dep", Version: "v1.3.0"},
+	}}}
+	if d := cmp.Diff(want, diff); d != "" {
+		t.Errorf("GetModFileDiff mismatch (-want +got):\n%s", d)
+	}
+	_, err = ds.GetModFileDiff(ctx, basicModule, "v1.0.0", missing)
+	checkNotFound(t, "GetModFileDiff(missing version)", err)
+
+	replaces, err := ds.GetReplaceDirectives(ctx, basicModule, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReplaces := []internal.Replace{{Old: "golang.org/x/text", New: "golang.org/x/text", NewVersion: "v0.3.2"}}
+	if d := cmp.Diff(wantReplaces, replaces); d != "" {
+		t.Errorf("GetReplaceDirectives mismatch (-want +got):\n%s", d)
+	}
+	replaces, err = ds.GetReplaceDirectives(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replaces) != 0 {
+		t.Errorf("GetReplaceDirectives(no replace directives): got %v, want none", replaces)
+	}
+	_, err = ds.GetReplaceDirectives(ctx, basicModule, missing)
+	checkNotFound(t, "GetReplaceDirectives(missing version)", err)
+}
+
+func testModuleSummary(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	coverage, err := ds.GetModuleDocCoverage(ctx, basicModule, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every package in the seed data has a package doc comment.
+	if diff := cmp.Diff(&internal.ModuleDocCoverage{Documented: 6}, coverage); diff != "" {
+		t.Errorf("GetModuleDocCoverage mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetModuleDocCoverage(ctx, basicModule, missing)
+	checkNotFound(t, "GetModuleDocCoverage(missing version)", err)
+
+	pkgs, err := ds.GetPackagesInModule(ctx, basicModule, "v1.0.0", internal.PathOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keywords, err := ds.GetModuleKeywords(ctx, basicModule, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(internal.ModuleKeywords(pkgs), keywords); diff != "" {
+		t.Errorf("GetModuleKeywords mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetModuleKeywords(ctx, basicModule, missing)
+	checkNotFound(t, "GetModuleKeywords(missing version)", err)
+}
+
+func testFetchHistory(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	fetchAll(t, ctx, ds)
+	failed, err := ds.GetFailedVersions(ctx, nestedModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("GetFailedVersions: got %v, want none", failed)
+	}
+
+	events, err := ds.RecentlyFetched(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched := map[string]bool{}
+	for i, e := range events {
+		if i > 0 && e.FetchedAt.After(events[i-1].FetchedAt) {
+			t.Errorf("RecentlyFetched: %s@%s fetched after the event before it", e.ModulePath, e.Version)
+		}
+		if e.Status == http.StatusOK {
+			fetched[e.ModulePath+"@"+e.Version] = true
+		}
+	}
+	for _, m := range Modules() {
+		if mv := m.ModulePath + "@" + m.Version; !fetched[mv] {
+			t.Errorf("RecentlyFetched: no successful fetch of %s", mv)
+		}
+	}
+	events, err = ds.RecentlyFetched(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("RecentlyFetched(1): got %d events, want 1", len(events))
+	}
+	_, err = ds.RecentlyFetched(ctx, -1)
+	checkInvalidArgument(t, "RecentlyFetched(negative limit)", err)
+}
+
+func testIndex(t *testing.T, ctx context.Context, ds internal.DataSource) {
+	start := time.Now()
+	fetchAll(t, ctx, ds)
+
+	stats, err := ds.GetIndexStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// example.com/basic/nested/pkg is in both modules but is counted once.
+	wantStats := &internal.IndexStats{TotalModules: 2, TotalModuleVersions: 3, TotalPackages: 6}
+	if diff := cmp.Diff(wantStats, stats); diff != "" {
+		t.Errorf("GetIndexStats mismatch (-want +got):\n%s", diff)
+	}
+
+	paths := func(rs []*internal.SearchResult) []string {
+		var ps []string
+		for _, r := range rs {
+			ps = append(ps, r.PackagePath+"@"+r.Version)
+		}
+		return ps
+	}
+	results, err := ds.GetPackagesByPrefix(ctx, basicModule, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{basicModule + "@v1.1.0", basicModule + "/cmd/tool@v1.1.0"}
+	if diff := cmp.Diff(want, paths(results)); diff != "" {
+		t.Errorf("GetPackagesByPrefix mismatch (-want +got):\n%s", diff)
+	}
+	results, err = ds.GetPackagesByPrefix(ctx, basicModule+"/su", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("GetPackagesByPrefix(partial path element): got %v, want none", paths(results))
+	}
+
+	results, err = ds.GetRecentlyUpdatedPackages(ctx, start.Add(-time.Hour), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, r := range results {
+		if seen[r.PackagePath] {
+			t.Errorf("GetRecentlyUpdatedPackages: %s appears more than once", r.PackagePath)
+		}
+		seen[r.PackagePath] = true
+		if r.PackagePath == basicModule+"/sub" && r.Version != "v1.1.0" {
+			t.Errorf("GetRecentlyUpdatedPackages: got %s@%s, want version v1.1.0", r.PackagePath, r.Version)
+		}
+	}
+	if !seen[basicModule+"/sub"] {
+		t.Errorf("GetRecentlyUpdatedPackages: got %v, want %s/sub among them", paths(results), basicModule)
+	}
+	results, err = ds.GetRecentlyUpdatedPackages(ctx, time.Now().Add(time.Hour), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("GetRecentlyUpdatedPackages(future): got %v, want none", paths(results))
+	}
+	_, err = ds.GetRecentlyUpdatedPackages(ctx, start, -1)
+	checkInvalidArgument(t, "GetRecentlyUpdatedPackages(negative limit)", err)
+
+	entries, err := ds.GetSitemapEntries(ctx, internal.MaxSitemapEntries, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sitemap []string
+	for _, e := range entries {
+		sitemap = append(sitemap, e.Path)
+		if e.LastModified.IsZero() {
+			t.Errorf("GetSitemapEntries: %s has no LastModified time", e.Path)
+		}
+	}
+	wantSitemap := []string{
+		basicModule,
+		basicModule + "/cmd/tool",
+		basicModule + "/internal/helper",
+		nestedModule,
+		nestedModule + "/pkg",
+		basicModule + "/sub",
+		basicModule + "/util",
+	}
+	if diff := cmp.Diff(wantSitemap, sitemap); diff != "" {
+		t.Errorf("GetSitemapEntries mismatch (-want +got):\n%s", diff)
+	}
+	entries, err = ds.GetSitemapEntries(ctx, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitemap = nil
+	for _, e := range entries {
+		sitemap = append(sitemap, e.Path)
+	}
+	if diff := cmp.Diff(wantSitemap[1:3], sitemap); diff != "" {
+		t.Errorf("GetSitemapEntries(2, 1) mismatch (-want +got):\n%s", diff)
+	}
+	_, err = ds.GetSitemapEntries(ctx, 0, 0)
+	checkInvalidArgument(t, "GetSitemapEntries(zero limit)", err)
+	_, err = ds.GetSitemapEntries(ctx, 10, -1)
+	checkInvalidArgument(t, "GetSitemapEntries(negative offset)", err)
+
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"example.com/basik", []string{basicModule}},
+		{basicModule + "/sbu", []string{basicModule + "/sub"}},
+	} {
+		got, err := ds.SuggestSimilarPaths(ctx, test.path, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("SuggestSimilarPaths(%q) mismatch (-want +got):\n%s", test.path, diff)
+		}
+	}
+
+	for _, test := range []struct {
+		pkgPath, symbol string
+		want            []*internal.SymbolUsage
+	}{
+		{"example.com/dep", "X", []*internal.SymbolUsage{{PackagePath: basicModule, ModulePath: basicModule, Count: 1}}},
+		{basicModule + "/sub", "V", []*internal.SymbolUsage{{PackagePath: basicModule + "/cmd/tool", ModulePath: basicModule, Count: 1}}},
+		{basicModule + "/sub", "W", nil},
+	} {
+		got, err := ds.GetSymbolUsages(ctx, test.pkgPath, test.symbol, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("GetSymbolUsages(%q, %q) mismatch (-want +got):\n%s", test.pkgPath, test.symbol, diff)
+		}
+	}
+	_, err = ds.GetSymbolUsages(ctx, "example.com/dep", "X", -1)
+	checkInvalidArgument(t, "GetSymbolUsages(negative limit)", err)
+}
@@ -77,7 +77,7 @@ func TestEndToEndProcessing(t *testing.T) {
 	// TODO(b/143760329): it would be better if InMemory made http requests
 	// back to worker, rather than calling fetch itself.
 	queue := queue.NewInMemory(ctx, proxyClient, source.NewClient(1*time.Second), testDB, 10,
-		worker.FetchAndUpdateState, nil)
+		worker.FetchAndUpdateState, nil, nil)
 
 	workerServer, err := worker.NewServer(&config.Config{}, worker.ServerConfig{
 		DB:                   testDB,
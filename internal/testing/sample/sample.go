@@ -252,8 +252,10 @@ func DirectoryNewForPackage(pkg *internal.LegacyPackage) *internal.DirectoryNew
 			Documentation: &internal.Documentation{
 				Synopsis: pkg.Synopsis,
 				HTML:     pkg.DocumentationHTML,
+				Doc:      pkg.Doc,
 				GOOS:     pkg.GOOS,
 				GOARCH:   pkg.GOARCH,
+				Symbols:  pkg.Symbols,
 			},
 		},
 	}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// A Constraint is a range of semantic versions, such as ">=v1.2.0 <v2.0.0".
+type Constraint struct {
+	// alternatives holds the comparisons of each part of the constraint
+	// separated by "||". A version matches the constraint if it satisfies all
+	// the comparisons of any alternative.
+	alternatives [][]comparison
+}
+
+type comparison struct {
+	op      string
+	version string
+}
+
+// The operators of a constraint, longest first so that parsing can match
+// prefixes in order.
+var constraintOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+// ParseConstraint parses a constraint. A constraint is one or more
+// alternatives separated by "||". Each alternative is one or more comparisons
+// separated by spaces or commas, all of which a version must satisfy. A
+// comparison is an operator, one of =, !=, >, >=, < and <=, followed by a
+// semantic version, such as ">=v1.2.0". The operator may be omitted, meaning
+// =, and so may the leading "v" of the version.
+//
+// Versions are compared by semver.Compare: a prerelease or pseudo-version is
+// lower than the release it precedes, and matches a constraint if it is in
+// range. Build metadata is ignored.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{}
+	for _, alt := range strings.Split(s, "||") {
+		var cmps []comparison
+		for _, f := range strings.FieldsFunc(alt, func(r rune) bool { return r == ' ' || r == ',' }) {
+			cmp, err := parseComparison(f)
+			if err != nil {
+				return nil, fmt.Errorf("ParseConstraint(%q): %v", s, err)
+			}
+			cmps = append(cmps, cmp)
+		}
+		if len(cmps) == 0 {
+			return nil, fmt.Errorf("ParseConstraint(%q): empty alternative", s)
+		}
+		c.alternatives = append(c.alternatives, cmps)
+	}
+	return c, nil
+}
+
+// parseComparison parses a single comparison, such as ">=v1.2.0".
+func parseComparison(s string) (comparison, error) {
+	op := "="
+	for _, o := range constraintOps {
+		if strings.HasPrefix(s, o) {
+			op = o
+			s = s[len(o):]
+			break
+		}
+	}
+	if !strings.HasPrefix(s, "v") {
+		s = "v" + s
+	}
+	if !semver.IsValid(s) {
+		return comparison{}, fmt.Errorf("invalid version %q", s)
+	}
+	return comparison{op: op, version: s}, nil
+}
+
+// Match reports whether version v satisfies c. It reports false if v is not a
+// valid semantic version.
+func (c *Constraint) Match(v string) bool {
+	if !semver.IsValid(v) {
+		return false
+	}
+	for _, alt := range c.alternatives {
+		if matchAll(alt, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAll(cmps []comparison, v string) bool {
+	for _, c := range cmps {
+		n := semver.Compare(v, c.version)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = n == 0
+		case "!=":
+			ok = n != 0
+		case ">":
+			ok = n > 0
+		case ">=":
+			ok = n >= 0
+		case "<":
+			ok = n < 0
+		case "<=":
+			ok = n <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package version
+
+import "testing"
+
+func TestConstraintMatch(t *testing.T) {
+	for _, test := range []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{">=1.2.0 <2.0.0", "v1.2.0", true},
+		{">=1.2.0 <2.0.0", "v1.9.9", true},
+		{">=1.2.0 <2.0.0", "v2.0.0", false},
+		{">=1.2.0 <2.0.0", "v1.1.9", false},
+		{">=v1.2.0, <v2.0.0", "v1.5.0", true},
+		{">=1.2.0 <2.0.0", "v2.0.0-rc.1", true},
+		{">=1.2.0 <2.0.0", "v1.2.0-rc.1", false},
+		{">1.0.0", "v1.0.1-0.20190311183353-d8887717615a", true},
+		{"1.0.0", "v1.0.0", true},
+		{"=1.0.0", "v1.0.1", false},
+		{"!=1.0.0", "v1.0.1", true},
+		{"<=1.0.0", "v1.0.0", true},
+		{"v1.2", "v1.2.0", true},
+		{"<1.0.0 || >=2.0.0", "v0.1.0", true},
+		{"<1.0.0 || >=2.0.0", "v2.1.0", true},
+		{"<1.0.0 || >=2.0.0", "v1.1.0", false},
+		{">=1.0.0", "not-a-version", false},
+	} {
+		c, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Match(test.version); got != test.want {
+			t.Errorf("ParseConstraint(%q).Match(%q) = %t, want %t", test.constraint, test.version, got, test.want)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", " , ", ">=1.0.0 ||", ">=", ">=latest", "~1.2.0", "=>1.0.0"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q): got nil error, want error", s)
+		}
+	}
+}
@@ -41,6 +41,54 @@ func IsPseudo(v string) bool {
 	return strings.Count(v, "-") >= 2 && pseudoVersionRE.MatchString(v)
 }
 
+// PseudoRevision returns the revision identifier at the end of the
+// pseudo-version v, which is usually the first 12 characters of a commit
+// hash. It returns the empty string if v is not a pseudo-version.
+func PseudoRevision(v string) string {
+	if !IsPseudo(v) {
+		return ""
+	}
+	v = strings.TrimSuffix(v, "+incompatible")
+	return v[strings.LastIndexByte(v, '-')+1:]
+}
+
+// CommitHashPrefix returns the part of the commit hash hash that can be
+// compared with the revision of a pseudo-version: hash in lower case, cut to
+// the 12 characters that a pseudo-version contains. It reports false if hash
+// is not a hexadecimal string of at least 7 characters, the length git uses
+// for short hashes.
+func CommitHashPrefix(hash string) (string, bool) {
+	if len(hash) < 7 {
+		return "", false
+	}
+	hash = strings.ToLower(hash)
+	for _, c := range hash {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return "", false
+		}
+	}
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return hash, true
+}
+
+// IsBranch reports whether s can be the name of a branch in a version query,
+// such as "master" in "example.com/foo@master": it is not empty, not one of
+// the query keywords "latest", "upgrade" and "patch", not a semantic version,
+// not a version comparison such as "<v1.2.0", and has no character that a
+// query cannot contain.
+func IsBranch(s string) bool {
+	switch s {
+	case "", "latest", "upgrade", "patch":
+		return false
+	}
+	if semver.IsValid(s) || strings.ContainsAny(s, "<>=@ ") {
+		return false
+	}
+	return true
+}
+
 // ParseType returns the Type of a given a version.
 func ParseType(version string) (Type, error) {
 	if !semver.IsValid(version) {
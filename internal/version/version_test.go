@@ -151,3 +151,58 @@ func TestParseVersionType(t *testing.T) {
 		})
 	}
 }
+
+func TestPseudoRevision(t *testing.T) {
+	for _, test := range []struct {
+		version, want string
+	}{
+		{"v1.0.0-20190311183353-d8887717615a", "d8887717615a"},
+		{"v1.2.4-0.20190311183353-d8887717615a", "d8887717615a"},
+		{"v2.0.1-0.20190311183353-d8887717615a+incompatible", "d8887717615a"},
+		{"v1.0.0-alpha.1", ""},
+		{"v1.0.0", ""},
+	} {
+		if got := PseudoRevision(test.version); got != test.want {
+			t.Errorf("PseudoRevision(%q) = %q, want %q", test.version, got, test.want)
+		}
+	}
+}
+
+func TestCommitHashPrefix(t *testing.T) {
+	for _, test := range []struct {
+		hash, want string
+		wantOK     bool
+	}{
+		{"d888771", "d888771", true},
+		{"D8887717615A", "d8887717615a", true},
+		{"d8887717615a0123456789abcdef0123456789ab", "d8887717615a", true},
+		{"d88877", "", false},
+		{"not-a-hash", "", false},
+	} {
+		got, ok := CommitHashPrefix(test.hash)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("CommitHashPrefix(%q) = %q, %t; want %q, %t", test.hash, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestIsBranch(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want bool
+	}{
+		{"master", true},
+		{"release-branch.go1.14", true},
+		{"feature/x", true},
+		{"", false},
+		{"latest", false},
+		{"v1.2.3", false},
+		{"v1.2.4-0.20190311183353-d8887717615a", false},
+		{"<v1.2.0", false},
+		{"a b", false},
+	} {
+		if got := IsBranch(test.in); got != test.want {
+			t.Errorf("IsBranch(%q) = %t, want %t", test.in, got, test.want)
+		}
+	}
+}
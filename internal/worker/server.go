@@ -47,6 +47,7 @@ type Server struct {
 	queue                queue.Queue
 	reportingClient      *errorreporting.Client
 	taskIDChangeInterval time.Duration
+	fetchSemaphore       *queue.RedisSemaphore
 
 	indexTemplate *template.Template
 }
@@ -63,6 +64,10 @@ type ServerConfig struct {
 	ReportingClient      *errorreporting.Client
 	TaskIDChangeInterval time.Duration
 	StaticPath           string
+	// FetchSemaphore, if non-nil, limits the number of fetches in flight
+	// across all worker instances. A fetch request that cannot get a slot
+	// fails with 503 (Service Unavailable), so that it is retried later.
+	FetchSemaphore *queue.RedisSemaphore
 }
 
 // NewServer creates a new Server with the given dependencies.
@@ -86,6 +91,7 @@ func NewServer(cfg *config.Config, scfg ServerConfig) (_ *Server, err error) {
 		reportingClient:      scfg.ReportingClient,
 		indexTemplate:        indexTemplate,
 		taskIDChangeInterval: scfg.TaskIDChangeInterval,
+		fetchSemaphore:       scfg.FetchSemaphore,
 	}, nil
 }
 
@@ -214,6 +220,30 @@ func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/favicon.ico" {
 		return
 	}
+	if queue.TaskExpired(r, time.Now()) {
+		log.Infof(r.Context(), "task to fetch %s expired; returning OK to drop it", r.URL.Path)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "task expired\n")
+		return
+	}
+
+	if s.fetchSemaphore != nil {
+		token, err := s.fetchSemaphore.Acquire(r.Context())
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, queue.ErrNoSlot) {
+				code = http.StatusServiceUnavailable
+			}
+			log.Infof(r.Context(), "fetch of %s: %v; returning %d to retry task", r.URL.Path, err, code)
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		defer func() {
+			if err := s.fetchSemaphore.Release(context.Background(), token); err != nil {
+				log.Error(r.Context(), err)
+			}
+		}()
+	}
 
 	msg, code := s.doFetch(r)
 	if code == http.StatusInternalServerError {
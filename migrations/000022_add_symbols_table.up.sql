@@ -0,0 +1,21 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE symbols (
+    path_id INTEGER NOT NULL,
+    goos text NOT NULL,
+    goarch text NOT NULL,
+    name text NOT NULL,
+    kind text NOT NULL,
+    synopsis text NOT NULL,
+    parent_name text NOT NULL,
+    PRIMARY KEY (path_id, goos, goarch, name),
+    FOREIGN KEY (path_id, goos, goarch) REFERENCES documentation(path_id, goos, goarch) ON DELETE CASCADE
+);
+COMMENT ON TABLE symbols IS
+'TABLE symbols contains the exported identifiers in the documentation for a package, for a given GOOS and GOARCH. For methods, name has the form Type.Method.';
+
+END;
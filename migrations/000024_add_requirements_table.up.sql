@@ -0,0 +1,21 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE requirements (
+    module_id INTEGER NOT NULL REFERENCES modules(id) ON DELETE CASCADE,
+    module_path text NOT NULL,
+    version text NOT NULL,
+    indirect boolean NOT NULL,
+    PRIMARY KEY (module_id, module_path)
+);
+COMMENT ON TABLE requirements IS
+'TABLE requirements contains the require directives in the go.mod file of a module version. module_path and version are those of the required module.';
+
+CREATE INDEX idx_requirements_module_path ON requirements(module_path);
+COMMENT ON INDEX idx_requirements_module_path IS
+'INDEX idx_requirements_module_path is used to find the modules that require a given module.';
+
+END;
@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN content_hash TEXT;
+COMMENT ON COLUMN modules.content_hash IS
+'COLUMN content_hash is a hash of the data displayed for the module version, computed when it is fetched. It is NULL for module versions fetched before it was added.';
+
+END;
@@ -0,0 +1,13 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_paths_path_trgm ON paths USING gin (path gin_trgm_ops);
+COMMENT ON INDEX idx_paths_path_trgm IS
+'INDEX idx_paths_path_trgm is used to find paths similar to a path that was not found.';
+
+END;
@@ -0,0 +1,15 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE documentation ADD COLUMN doc TEXT NOT NULL DEFAULT '';
+COMMENT ON COLUMN documentation.doc IS
+'COLUMN doc is the text of the package doc comment.';
+
+ALTER TABLE symbols ADD COLUMN doc TEXT NOT NULL DEFAULT '';
+COMMENT ON COLUMN symbols.doc IS
+'COLUMN doc is the text of the doc comment of the symbol, or of the group it is declared in.';
+
+END;
@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN file_stats JSONB;
+COMMENT ON COLUMN modules.file_stats IS
+'COLUMN file_stats holds statistics about the files in the module zip, such as the number of lines of Go code, computed when it is fetched. It is NULL or a JSON null for module versions fetched before it was added.';
+
+END;
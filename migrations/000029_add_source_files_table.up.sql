@@ -0,0 +1,16 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE source_files (
+    path_id INTEGER NOT NULL REFERENCES paths(id) ON DELETE CASCADE,
+    file_name text NOT NULL,
+    contents bytea NOT NULL,
+    PRIMARY KEY (path_id, file_name)
+);
+COMMENT ON TABLE source_files IS
+'TABLE source_files contains the .go files in the directory of a redistributable package, for viewing the source of the package.';
+
+END;
@@ -0,0 +1,23 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE fetch_outbox (
+    id bigserial PRIMARY KEY,
+    module_path text NOT NULL,
+    version text NOT NULL,
+    suffix text NOT NULL DEFAULT '',
+    task_id_change_interval bigint NOT NULL DEFAULT 0,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    done_at timestamp with time zone
+);
+COMMENT ON TABLE fetch_outbox IS
+'TABLE fetch_outbox contains fetches that callers decided to schedule as part of their own transactions. A relayer schedules each of them on the queue after the transaction commits, and sets done_at.';
+COMMENT ON COLUMN fetch_outbox.task_id_change_interval IS
+'COLUMN task_id_change_interval is the taskIDChangeInterval passed to Queue.ScheduleFetch, in nanoseconds.';
+
+CREATE INDEX idx_fetch_outbox_pending ON fetch_outbox (id) WHERE done_at IS NULL;
+
+END;
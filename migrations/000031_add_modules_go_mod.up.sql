@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN go_mod BYTEA;
+COMMENT ON COLUMN modules.go_mod IS
+'COLUMN go_mod holds the contents of the go.mod file in the module zip. It is NULL for module versions without a go.mod file, and for those fetched before it was added.';
+
+END;
@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE paths ADD COLUMN import_comment TEXT;
+COMMENT ON COLUMN paths.import_comment IS
+'COLUMN import_comment holds the path in the import comment of the package clause of the package at this path, as in package foo // import "example.com/foo". It is NULL for paths without a package or whose package has no import comment.';
+
+END;
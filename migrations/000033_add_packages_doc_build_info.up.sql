@@ -0,0 +1,15 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE packages
+    ADD COLUMN doc_go_version TEXT,
+    ADD COLUMN doc_renderer_version TEXT;
+COMMENT ON COLUMN packages.doc_go_version IS
+'COLUMN doc_go_version holds the version of the Go toolchain, as reported by runtime.Version, of the fetcher that generated the documentation. It is NULL for packages stored before it was recorded.';
+COMMENT ON COLUMN packages.doc_renderer_version IS
+'COLUMN doc_renderer_version holds the dochtml.RendererVersion of the fetcher that generated the documentation. It is NULL for packages stored before it was recorded.';
+
+END;
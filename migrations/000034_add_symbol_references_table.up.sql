@@ -0,0 +1,20 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE symbol_references (
+    to_path text NOT NULL,
+    symbol text NOT NULL,
+    from_path text NOT NULL,
+    from_module_path text NOT NULL,
+    count integer NOT NULL,
+    PRIMARY KEY (to_path, symbol, from_path, from_module_path)
+);
+COMMENT ON TABLE symbol_references IS
+'TABLE symbol_references contains, for the latest version of each module, the number of references in each of its packages (from_path) to each exported symbol of a package it imports (to_path). Like imports_unique, it has no version. The references are found without type checking, so they are best-effort.';
+
+CREATE INDEX idx_symbol_references_from_module_path ON symbol_references (from_module_path);
+
+END;
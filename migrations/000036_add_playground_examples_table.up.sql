@@ -0,0 +1,19 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE playground_examples (
+    path_id INTEGER NOT NULL,
+    goos text NOT NULL,
+    goarch text NOT NULL,
+    name text NOT NULL,
+    code text NOT NULL,
+    PRIMARY KEY (path_id, goos, goarch, name),
+    FOREIGN KEY (path_id, goos, goarch) REFERENCES documentation(path_id, goos, goarch) ON DELETE CASCADE
+);
+COMMENT ON TABLE playground_examples IS
+'TABLE playground_examples contains, for the documentation of a package for a given GOOS and GOARCH, the source of each example that can be run as a program on the Go playground. For an example of a method, name has the form Type_Method; the example for the package has the empty name.';
+
+END;
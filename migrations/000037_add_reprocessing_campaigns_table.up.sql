@@ -0,0 +1,22 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE reprocessing_campaigns (
+    name text PRIMARY KEY,
+    app_version text NOT NULL,
+    cursor_imported_by_count integer NOT NULL DEFAULT 0,
+    cursor_module_path text NOT NULL DEFAULT '',
+    cursor_version text NOT NULL DEFAULT '',
+    num_enqueued integer NOT NULL DEFAULT 0,
+    num_skipped integer NOT NULL DEFAULT 0,
+    done boolean NOT NULL DEFAULT false,
+    started_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+COMMENT ON TABLE reprocessing_campaigns IS
+'TABLE reprocessing_campaigns contains the progress of each reprocessing campaign, which enqueues the module versions last processed by an app version before app_version, most popular first. The cursor columns hold the last module version the campaign enqueued or skipped; cursor_module_path is empty before the first one.';
+
+END;
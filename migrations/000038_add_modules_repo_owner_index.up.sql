@@ -0,0 +1,12 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE INDEX idx_modules_repo_owner ON modules
+    (lower(substring(source_info->>'RepoURL' from '^(?:[a-z]+://)?([^/]+/[^/]+)/')));
+COMMENT ON INDEX idx_modules_repo_owner IS
+'INDEX idx_modules_repo_owner is used to find the modules whose source repository belongs to an owner at a host. It indexes the host and owner of the repo URL, as "github.com/golang" for https://github.com/golang/tools, in lower case.';
+
+END;
@@ -0,0 +1,16 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE reprocessing_campaign_modules (
+    campaign_name text NOT NULL REFERENCES reprocessing_campaigns(name) ON DELETE CASCADE,
+    module_path text NOT NULL,
+    imported_by_count integer NOT NULL,
+    PRIMARY KEY (campaign_name, module_path)
+);
+COMMENT ON TABLE reprocessing_campaign_modules IS
+'TABLE reprocessing_campaign_modules contains the popularity of each module when a reprocessing campaign started, so that the order in which the campaign enqueues module versions does not change while it runs. Modules not in search_documents have no row, and are treated as having an imported_by_count of zero.';
+
+END;